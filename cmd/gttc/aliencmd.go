@@ -0,0 +1,517 @@
+// Copyright 2018 The gttc Authors
+// This file is part of gttc.
+//
+// gttc is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// gttc is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with gttc. If not, see <http://www.gnu.org/licenses/>.
+
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/big"
+
+	"github.com/awesome-chain/Xchain/accounts/abi/bind"
+	"github.com/awesome-chain/Xchain/accounts/keystore"
+	"github.com/awesome-chain/Xchain/cmd/utils"
+	"github.com/awesome-chain/Xchain/common"
+	"github.com/awesome-chain/Xchain/consensus/alien"
+	"github.com/awesome-chain/Xchain/console"
+	"github.com/awesome-chain/Xchain/core/types"
+	"github.com/awesome-chain/Xchain/ethclient"
+	"github.com/awesome-chain/Xchain/ethclient/alienclient"
+	"github.com/awesome-chain/Xchain/node"
+	"gopkg.in/urfave/cli.v1"
+)
+
+var (
+	alienAttachFlag = cli.StringFlag{
+		Name:  "attach",
+		Value: node.DefaultIPCEndpoint(clientIdentifier),
+		Usage: "API endpoint to attach to",
+	}
+	alienFromFlag = cli.StringFlag{
+		Name:  "from",
+		Usage: "Account sending the transaction (address or index in the keystore)",
+	}
+	alienYesFlag = cli.BoolFlag{
+		Name:  "yes",
+		Usage: "Send the transaction without asking for confirmation",
+	}
+	alienValueFlag = cli.Int64Flag{
+		Name:  "value",
+		Value: 5,
+		Usage: "TTC sent to the side chain coinbase",
+	}
+	alienTypeFlag = cli.Uint64Flag{
+		Name:  "type",
+		Value: alienclient.ProposalTypeCandidateAdd,
+		Usage: "Proposal type (1-8)",
+	}
+	alienCandidateFlag = cli.StringFlag{
+		Name:  "candidate",
+		Usage: "Target address of the proposal",
+	}
+	alienSCHashFlag = cli.StringFlag{
+		Name:  "schash",
+		Usage: "Side chain hash of the proposal",
+	}
+	alienVlcntFlag = cli.Uint64Flag{
+		Name:  "vlcnt",
+		Usage: "Validation loop count of the proposal (default of the chain if 0)",
+	}
+	alienMrptFlag = cli.Uint64Flag{
+		Name:  "mrpt",
+		Usage: "Miner reward per thousand",
+	}
+	alienMvbFlag = cli.Uint64Flag{
+		Name:  "mvb",
+		Usage: "Minimum voter balance in TTC",
+	}
+	alienMpdFlag = cli.Uint64Flag{
+		Name:  "mpd",
+		Usage: "Proposal deposit in TTC",
+	}
+	alienSCCountFlag = cli.Uint64Flag{
+		Name:  "sccount",
+		Usage: "Blocks sealed per period on the side chain",
+	}
+	alienSCRewardFlag = cli.Uint64Flag{
+		Name:  "screward",
+		Usage: "Reward per period of the side chain, per thousand",
+	}
+	alienRentFeeFlag = cli.Uint64Flag{
+		Name:  "fee",
+		Value: 100,
+		Usage: "Side chain rent fee in TTC",
+	}
+	alienRentRateFlag = cli.Uint64Flag{
+		Name:  "rate",
+		Usage: "Side chain rent rate",
+	}
+	alienRentLengthFlag = cli.Uint64Flag{
+		Name:  "length",
+		Usage: "Side chain rent length in blocks",
+	}
+
+	// alienTxFlags are the flags of all the commands sending a transaction.
+	alienTxFlags = []cli.Flag{
+		alienAttachFlag,
+		alienFromFlag,
+		alienYesFlag,
+		utils.DataDirFlag,
+		utils.KeyStoreDirFlag,
+		utils.PasswordFileFlag,
+		utils.LightKDFFlag,
+	}
+
+	alienCommand = cli.Command{
+		Name:     "alien",
+		Usage:    "Send alien governance transactions",
+		Category: "ALIEN COMMANDS",
+		Description: `
+
+Vote, propose, declare on proposals and manage side chains on a running node
+using the alien consensus engine.
+
+The arguments are checked against the snapshot of the latest block before the
+transaction is sent. The sending account is unlocked from the keystore of the
+data directory, the password being prompted or read from --password.`,
+		Subcommands: []cli.Command{
+			{
+				Name:      "vote",
+				Usage:     "Vote for a candidate",
+				Action:    utils.MigrateFlags(alienVote),
+				ArgsUsage: "<candidate>",
+				Flags:     alienTxFlags,
+				Description: `
+    gttc alien vote --from <account> <candidate>
+
+Votes for the candidate with the stake of the account.`,
+			},
+			{
+				Name:   "propose",
+				Usage:  "Make a proposal",
+				Action: utils.MigrateFlags(alienPropose),
+				Flags: append([]cli.Flag{
+					alienTypeFlag,
+					alienCandidateFlag,
+					alienSCHashFlag,
+					alienVlcntFlag,
+					alienMrptFlag,
+					alienMvbFlag,
+					alienMpdFlag,
+				}, alienTxFlags...),
+				Description: `
+    gttc alien propose --from <account> --type <type> [proposal flags]
+
+Makes a proposal, the deposit being taken from the balance of the account. The
+proposal types are:
+
+    1 add the --candidate
+    2 remove the --candidate
+    3 modify the miner reward distribution to --mrpt
+    4 add the side chain --schash
+    5 remove the side chain --schash
+    6 modify the minimum voter balance to --mvb
+    7 modify the proposal deposit to --mpd
+    8 rent a side chain, see sidechain-rent`,
+			},
+			{
+				Name:      "declare",
+				Usage:     "Declare a decision on a proposal",
+				Action:    utils.MigrateFlags(alienDeclare),
+				ArgsUsage: "<proposal> <yes|no>",
+				Flags:     alienTxFlags,
+				Description: `
+    gttc alien declare --from <account> <proposal> <yes|no>
+
+Declares the decision of the account, a candidate, on a going proposal.`,
+			},
+			{
+				Name:      "setcoinbase",
+				Usage:     "Set the coinbase of a signer on a side chain",
+				Action:    utils.MigrateFlags(alienSetCoinbase),
+				ArgsUsage: "<schash> <coinbase>",
+				Flags:     append([]cli.Flag{alienValueFlag}, alienTxFlags...),
+				Description: `
+    gttc alien setcoinbase --from <account> <schash> <coinbase>
+
+Sets the coinbase of the account, a candidate, on the side chain. At least 5
+TTC are sent to the coinbase for its confirmation transactions.`,
+			},
+			{
+				Name:      "sidechain-add",
+				Usage:     "Propose to add a side chain",
+				Action:    utils.MigrateFlags(alienSideChainAdd),
+				ArgsUsage: "<schash>",
+				Flags: append([]cli.Flag{
+					alienVlcntFlag,
+					alienSCCountFlag,
+					alienSCRewardFlag,
+				}, alienTxFlags...),
+				Description: `
+    gttc alien sidechain-add --from <account> [--sccount n] [--screward n] <schash>
+
+Proposes to add the side chain with the given genesis hash.`,
+			},
+			{
+				Name:      "sidechain-rent",
+				Usage:     "Propose to rent a side chain",
+				Action:    utils.MigrateFlags(alienSideChainRent),
+				ArgsUsage: "<schash> <target>",
+				Flags: append([]cli.Flag{
+					alienVlcntFlag,
+					alienRentFeeFlag,
+					alienRentRateFlag,
+					alienRentLengthFlag,
+				}, alienTxFlags...),
+				Description: `
+    gttc alien sidechain-rent --from <account> --fee <TTC> <schash> <target>
+
+Proposes to rent the side chain, the gas of the target address on the side
+chain being charged. The rent fee is taken with the deposit.`,
+			},
+		},
+	}
+)
+
+// alienSession is a connection to a node with an unlocked account, and the
+// snapshot the arguments are checked against.
+type alienSession struct {
+	alien *alienclient.Client
+	eth   *ethclient.Client
+	opts  *bind.TransactOpts
+	snap  *alien.Snapshot
+}
+
+// newAlienSession attaches to the node and unlocks the sending account.
+func newAlienSession(ctx *cli.Context) *alienSession {
+	if !ctx.IsSet(alienFromFlag.Name) {
+		utils.Fatalf("No sending account specified (--%s)", alienFromFlag.Name)
+	}
+	client, err := dialRPC(ctx.String(alienAttachFlag.Name))
+	if err != nil {
+		utils.Fatalf("Unable to attach to gttc node: %v", err)
+	}
+	session := &alienSession{
+		alien: alienclient.NewClient(client),
+		eth:   ethclient.NewClient(client),
+	}
+	if session.snap, err = session.alien.Snapshot(context.Background(), nil); err != nil {
+		utils.Fatalf("Failed to retrieve alien snapshot: %v", err)
+	}
+	stack, _ := makeConfigNode(ctx)
+	ks := stack.AccountManager().Backends(keystore.KeyStoreType)[0].(*keystore.KeyStore)
+	account, _ := unlockAccount(ctx, ks, ctx.String(alienFromFlag.Name), 0, utils.MakePasswordList(ctx))
+
+	session.opts = &bind.TransactOpts{
+		From: account.Address,
+		Signer: func(signer types.Signer, address common.Address, tx *types.Transaction) (*types.Transaction, error) {
+			if address != account.Address {
+				return nil, errors.New("not authorized to sign this account")
+			}
+			signature, err := ks.SignHash(account, signer.Hash(tx).Bytes())
+			if err != nil {
+				return nil, err
+			}
+			return tx.WithSignature(signer, signature)
+		},
+	}
+	return session
+}
+
+// confirm asks the user to confirm sending the transaction, unless --yes is set.
+func (s *alienSession) confirm(ctx *cli.Context, action string) {
+	if ctx.Bool(alienYesFlag.Name) {
+		return
+	}
+	ok, err := console.Stdin.PromptConfirm(fmt.Sprintf("Send %s from %s?", action, s.opts.From.Hex()))
+	if err != nil {
+		utils.Fatalf("Failed to read confirmation: %v", err)
+	}
+	if !ok {
+		utils.Fatalf("Aborted")
+	}
+}
+
+// sent reports a transaction sent, or fails with the error sending it.
+func sent(tx *types.Transaction, err error) error {
+	if err != nil {
+		utils.Fatalf("Failed to send transaction: %v", err)
+	}
+	fmt.Printf("Transaction sent: %s\n", tx.Hash().Hex())
+	return nil
+}
+
+// parseAddressArg parses the address argument at the given position.
+func parseAddressArg(ctx *cli.Context, i int, name string) common.Address {
+	arg := ctx.Args().Get(i)
+	if !common.IsHexAddress(arg) {
+		utils.Fatalf("Invalid %s address: %q", name, arg)
+	}
+	return common.HexToAddress(arg)
+}
+
+// parseHashArg parses the hash argument at the given position.
+func parseHashArg(ctx *cli.Context, i int, name string) common.Hash {
+	var hash common.Hash
+	if err := hash.UnmarshalText([]byte(ctx.Args().Get(i))); err != nil {
+		utils.Fatalf("Invalid %s hash: %q", name, ctx.Args().Get(i))
+	}
+	return hash
+}
+
+// ttc formats an amount in wei as TTC.
+func ttc(amount *big.Int) string {
+	value, _ := new(big.Float).Quo(new(big.Float).SetInt(amount), big.NewFloat(1e+18)).Float64()
+	return fmt.Sprintf("%v TTC", value)
+}
+
+// alienVote votes for a candidate.
+func alienVote(ctx *cli.Context) error {
+	if len(ctx.Args()) != 1 {
+		utils.Fatalf("This command requires a candidate argument")
+	}
+	candidate := parseAddressArg(ctx, 0, "candidate")
+	s := newAlienSession(ctx)
+
+	if _, ok := s.snap.Slashed[candidate]; ok {
+		utils.Fatalf("Candidate %s was slashed for double signing", candidate.Hex())
+	}
+	if _, ok := s.snap.Candidates[candidate]; !ok {
+		fmt.Printf("Warning: %s is not a candidate yet\n", candidate.Hex())
+	}
+	balance, err := s.eth.BalanceAt(context.Background(), s.opts.From, nil)
+	if err != nil {
+		utils.Fatalf("Failed to retrieve balance: %v", err)
+	}
+	stake := balance
+	if locked, ok := s.snap.Locked[s.opts.From]; ok {
+		fmt.Printf("Locked stake: %s\n", ttc(locked))
+		stake = locked
+	}
+	if s.snap.MinVB != nil && stake.Cmp(s.snap.MinVB) <= 0 {
+		utils.Fatalf("Stake %s not above the minimum voter balance %s", ttc(stake), ttc(s.snap.MinVB))
+	}
+	s.confirm(ctx, fmt.Sprintf("vote for %s", candidate.Hex()))
+	return sent(s.alien.Vote(s.opts, candidate))
+}
+
+// alienPropose makes a proposal from the flags.
+func alienPropose(ctx *cli.Context) error {
+	payload := &alien.ProposalPayload{
+		ProposalType:           ctx.Uint64(alienTypeFlag.Name),
+		ValidationLoopCnt:      ctx.Uint64(alienVlcntFlag.Name),
+		MinerRewardPerThousand: ctx.Uint64(alienMrptFlag.Name),
+		MinVoterBalance:        ctx.Uint64(alienMvbFlag.Name),
+		ProposalDeposit:        ctx.Uint64(alienMpdFlag.Name),
+	}
+	if arg := ctx.String(alienCandidateFlag.Name); arg != "" {
+		if !common.IsHexAddress(arg) {
+			utils.Fatalf("Invalid candidate address: %q", arg)
+		}
+		payload.TargetAddress = common.HexToAddress(arg)
+	}
+	if arg := ctx.String(alienSCHashFlag.Name); arg != "" {
+		if err := payload.SCHash.UnmarshalText([]byte(arg)); err != nil {
+			utils.Fatalf("Invalid side chain hash: %q", arg)
+		}
+	}
+	s := newAlienSession(ctx)
+
+	switch payload.ProposalType {
+	case alienclient.ProposalTypeCandidateAdd, alienclient.ProposalTypeCandidateRemove:
+		if (payload.TargetAddress == common.Address{}) {
+			utils.Fatalf("No candidate specified (--%s)", alienCandidateFlag.Name)
+		}
+		if _, ok := s.snap.Slashed[payload.TargetAddress]; ok {
+			utils.Fatalf("Candidate %s was slashed for double signing", payload.TargetAddress.Hex())
+		}
+	case alienclient.ProposalTypeMinerRewardDistributionModify:
+		if payload.MinerRewardPerThousand == 0 {
+			utils.Fatalf("No miner reward specified (--%s)", alienMrptFlag.Name)
+		}
+	case alienclient.ProposalTypeSideChainAdd, alienclient.ProposalTypeSideChainRemove:
+		if (payload.SCHash == common.Hash{}) {
+			utils.Fatalf("No side chain specified (--%s)", alienSCHashFlag.Name)
+		}
+		_, ok := s.snap.SCRecordMap[payload.SCHash]
+		if ok && payload.ProposalType == alienclient.ProposalTypeSideChainAdd {
+			utils.Fatalf("Side chain %s already exists", payload.SCHash.Hex())
+		}
+		if !ok && payload.ProposalType == alienclient.ProposalTypeSideChainRemove {
+			utils.Fatalf("Unknown side chain %s", payload.SCHash.Hex())
+		}
+	case alienclient.ProposalTypeMinVoterBalanceModify:
+		if payload.MinVoterBalance == 0 {
+			utils.Fatalf("No minimum voter balance specified (--%s)", alienMvbFlag.Name)
+		}
+	case alienclient.ProposalTypeProposalDepositModify:
+		if payload.ProposalDeposit == 0 {
+			utils.Fatalf("No proposal deposit specified (--%s)", alienMpdFlag.Name)
+		}
+	case alienclient.ProposalTypeRentSideChain:
+		utils.Fatalf("Side chain rents are proposed with sidechain-rent")
+	}
+	return s.propose(ctx, payload)
+}
+
+// alienSideChainAdd proposes to add a side chain.
+func alienSideChainAdd(ctx *cli.Context) error {
+	if len(ctx.Args()) != 1 {
+		utils.Fatalf("This command requires a side chain hash argument")
+	}
+	payload := &alien.ProposalPayload{
+		ProposalType:           alienclient.ProposalTypeSideChainAdd,
+		ValidationLoopCnt:      ctx.Uint64(alienVlcntFlag.Name),
+		SCHash:                 parseHashArg(ctx, 0, "side chain"),
+		SCBlockCountPerPeriod:  ctx.Uint64(alienSCCountFlag.Name),
+		SCBlockRewardPerPeriod: ctx.Uint64(alienSCRewardFlag.Name),
+	}
+	s := newAlienSession(ctx)
+	if _, ok := s.snap.SCRecordMap[payload.SCHash]; ok {
+		utils.Fatalf("Side chain %s already exists", payload.SCHash.Hex())
+	}
+	return s.propose(ctx, payload)
+}
+
+// alienSideChainRent proposes to rent a side chain.
+func alienSideChainRent(ctx *cli.Context) error {
+	if len(ctx.Args()) != 2 {
+		utils.Fatalf("This command requires side chain hash and target address arguments")
+	}
+	payload := &alien.ProposalPayload{
+		ProposalType:      alienclient.ProposalTypeRentSideChain,
+		ValidationLoopCnt: ctx.Uint64(alienVlcntFlag.Name),
+		SCHash:            parseHashArg(ctx, 0, "side chain"),
+		TargetAddress:     parseAddressArg(ctx, 1, "target"),
+		SCRentFee:         ctx.Uint64(alienRentFeeFlag.Name),
+		SCRentRate:        ctx.Uint64(alienRentRateFlag.Name),
+		SCRentLength:      ctx.Uint64(alienRentLengthFlag.Name),
+	}
+	s := newAlienSession(ctx)
+	if _, ok := s.snap.SCRecordMap[payload.SCHash]; !ok {
+		utils.Fatalf("Unknown side chain %s", payload.SCHash.Hex())
+	}
+	return s.propose(ctx, payload)
+}
+
+// propose validates the proposal, checks the balance covers the deposit and
+// sends the proposal.
+func (s *alienSession) propose(ctx *cli.Context, payload *alien.ProposalPayload) error {
+	if err := payload.Validate(); err != nil {
+		utils.Fatalf("Invalid proposal: %v", err)
+	}
+	payment := alien.ProposalPayment(payload.ProposalType, payload.SCRentFee)
+	balance, err := s.eth.BalanceAt(context.Background(), s.opts.From, nil)
+	if err != nil {
+		utils.Fatalf("Failed to retrieve balance: %v", err)
+	}
+	fmt.Printf("Expected deposit: %s (balance %s)\n", ttc(payment), ttc(balance))
+	if balance.Cmp(payment) < 0 {
+		utils.Fatalf("Insufficient balance for the deposit")
+	}
+	s.confirm(ctx, fmt.Sprintf("proposal of type %d", payload.ProposalType))
+	return sent(s.alien.Propose(s.opts, payload))
+}
+
+// alienDeclare declares a decision on a proposal.
+func alienDeclare(ctx *cli.Context) error {
+	if len(ctx.Args()) != 2 {
+		utils.Fatalf("This command requires proposal hash and decision arguments")
+	}
+	proposal := parseHashArg(ctx, 0, "proposal")
+	var decision bool
+	switch ctx.Args().Get(1) {
+	case "yes":
+		decision = true
+	case "no":
+		decision = false
+	default:
+		utils.Fatalf("Invalid decision %q, want yes or no", ctx.Args().Get(1))
+	}
+	s := newAlienSession(ctx)
+	if _, ok := s.snap.Proposals[proposal]; !ok {
+		utils.Fatalf("Unknown proposal %s", proposal.Hex())
+	}
+	if _, ok := s.snap.Candidates[s.opts.From]; !ok {
+		utils.Fatalf("Only candidates can declare, %s is not one", s.opts.From.Hex())
+	}
+	s.confirm(ctx, fmt.Sprintf("declaration %s on %s", ctx.Args().Get(1), proposal.Hex()))
+	return sent(s.alien.Declare(s.opts, proposal, decision))
+}
+
+// alienSetCoinbase sets the coinbase of a signer on a side chain.
+func alienSetCoinbase(ctx *cli.Context) error {
+	if len(ctx.Args()) != 2 {
+		utils.Fatalf("This command requires side chain hash and coinbase arguments")
+	}
+	scHash := parseHashArg(ctx, 0, "side chain")
+	coinbase := parseAddressArg(ctx, 1, "coinbase")
+	value := new(big.Int).Mul(big.NewInt(ctx.Int64(alienValueFlag.Name)), big.NewInt(1e+18))
+	if value.Cmp(alienclient.MinSCSetCoinbaseValue) < 0 {
+		utils.Fatalf("At least %s must be sent to the coinbase", ttc(alienclient.MinSCSetCoinbaseValue))
+	}
+	s := newAlienSession(ctx)
+	if _, ok := s.snap.SCRecordMap[scHash]; !ok {
+		utils.Fatalf("Unknown side chain %s", scHash.Hex())
+	}
+	if _, ok := s.snap.Candidates[s.opts.From]; !ok {
+		utils.Fatalf("Only candidates can set a side chain coinbase, %s is not one", s.opts.From.Hex())
+	}
+	s.opts.Value = value
+	s.confirm(ctx, fmt.Sprintf("%s to coinbase %s", ttc(value), coinbase.Hex()))
+	return sent(s.alien.SetSideChainCoinbase(s.opts, scHash, coinbase))
+}
@@ -42,7 +42,7 @@ var (
 		Name:        "dumpconfig",
 		Usage:       "Show configuration values",
 		ArgsUsage:   "",
-		Flags:       append(append(append(append(nodeFlags, rpcFlags...), whisperFlags...), pbftFlags...), scaFlags...),
+		Flags:       append(append(append(append(append(nodeFlags, rpcFlags...), whisperFlags...), pbftFlags...), algoFlags...), scaFlags...),
 		Category:    "MISCELLANEOUS COMMANDS",
 		Description: `The dumpconfig command shows configuration values.`,
 	}
@@ -112,6 +112,7 @@ var (
 		utils.TestnetFlag,
 		utils.RinkebyFlag,
 		utils.VMEnableDebugFlag,
+		utils.VMParallelExecutionFlag,
 		utils.NetworkIdFlag,
 		utils.RPCCORSDomainFlag,
 		utils.RPCVirtualHostsFlag,
@@ -149,6 +150,11 @@ var (
 		utils.PBFTEnableFlag,
 	}
 
+	algoFlags = []cli.Flag{
+		utils.AlgoKeyFileFlag,
+		utils.AlgoKeyHexFlag,
+	}
+
 	scaFlags = []cli.Flag{
 		utils.SCAEnableFlag,
 		utils.SCAMainRPCAddrFlag,
@@ -177,6 +183,8 @@ func init() {
 		// See accountcmd.go:
 		accountCommand,
 		walletCommand,
+		// See aliencmd.go:
+		alienCommand,
 		// See consolecmd.go:
 		consoleCommand,
 		attachCommand,
@@ -198,6 +206,7 @@ func init() {
 	app.Flags = append(app.Flags, debug.Flags...)
 	app.Flags = append(app.Flags, whisperFlags...)
 	app.Flags = append(app.Flags, pbftFlags...)
+	app.Flags = append(app.Flags, algoFlags...)
 	app.Flags = append(app.Flags, scaFlags...)
 
 	app.Before = func(ctx *cli.Context) error {
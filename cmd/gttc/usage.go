@@ -201,6 +201,7 @@ var AppHelpFlagGroups = []flagGroup{
 		Name: "VIRTUAL MACHINE",
 		Flags: []cli.Flag{
 			utils.VMEnableDebugFlag,
+			utils.VMParallelExecutionFlag,
 		},
 	},
 	{
@@ -219,6 +220,10 @@ var AppHelpFlagGroups = []flagGroup{
 		Name:  "PBFT",
 		Flags: pbftFlags,
 	},
+	{
+		Name:  "ALGO",
+		Flags: algoFlags,
+	},
 	{
 		Name:  "SIDE CHAIN FOR APP",
 		Flags: scaFlags,
@@ -33,6 +33,7 @@ import (
 	"github.com/awesome-chain/Xchain/common"
 	"github.com/awesome-chain/Xchain/common/fdlimit"
 	"github.com/awesome-chain/Xchain/consensus"
+	"github.com/awesome-chain/Xchain/consensus/algo"
 	"github.com/awesome-chain/Xchain/consensus/alien"
 	"github.com/awesome-chain/Xchain/consensus/clique"
 	"github.com/awesome-chain/Xchain/consensus/ethash"
@@ -356,6 +357,10 @@ var (
 		Name:  "vmdebug",
 		Usage: "Record information useful for VM and contract debugging",
 	}
+	VMParallelExecutionFlag = cli.BoolFlag{
+		Name:  "vmparallel",
+		Usage: "Execute the transactions of imported blocks optimistically in parallel",
+	}
 	// Logging and debug settings
 	EthStatsURLFlag = cli.StringFlag{
 		Name:  "ethstats",
@@ -540,6 +545,16 @@ var (
 		Usage: "PBFT miner coinbase send confirm transaction",
 	}
 
+	// Algo settings
+	AlgoKeyFileFlag = cli.StringFlag{
+		Name:  "algo.keyfile",
+		Usage: "Participation key file of the algo consensus engine",
+	}
+	AlgoKeyHexFlag = cli.StringFlag{
+		Name:  "algo.keyhex",
+		Usage: "Participation key as hex (for testing)",
+	}
+
 	// Data side chain settings
 	SCAEnableFlag = cli.BoolFlag{
 		Name:  "sca",
@@ -989,6 +1004,31 @@ func setEthash(ctx *cli.Context, cfg *eth.Config) {
 	}
 }
 
+// setAlgoKey loads the participation key of the algo consensus engine from the
+// command line flags, if any.
+func setAlgoKey(ctx *cli.Context, cfg *eth.Config) {
+	var (
+		hex  = ctx.GlobalString(AlgoKeyHexFlag.Name)
+		file = ctx.GlobalString(AlgoKeyFileFlag.Name)
+		key  *ecdsa.PrivateKey
+		err  error
+	)
+	switch {
+	case file != "" && hex != "":
+		Fatalf("Options %q and %q are mutually exclusive", AlgoKeyFileFlag.Name, AlgoKeyHexFlag.Name)
+	case file != "":
+		if key, err = crypto.LoadECDSA(file); err != nil {
+			Fatalf("Option %q: %v", AlgoKeyFileFlag.Name, err)
+		}
+		cfg.AlgoKey = key
+	case hex != "":
+		if key, err = crypto.HexToECDSA(hex); err != nil {
+			Fatalf("Option %q: %v", AlgoKeyHexFlag.Name, err)
+		}
+		cfg.AlgoKey = key
+	}
+}
+
 // checkExclusive verifies that only a single isntance of the provided flags was
 // set by the user. Each flag might optionally be followed by a string type to
 // specialize it further.
@@ -1053,6 +1093,7 @@ func SetEthConfig(ctx *cli.Context, stack *node.Node, cfg *eth.Config) {
 	setGPO(ctx, &cfg.GPO)
 	setTxPool(ctx, &cfg.TxPool)
 	setEthash(ctx, cfg)
+	setAlgoKey(ctx, cfg)
 
 	switch {
 	case ctx.GlobalIsSet(SyncModeFlag.Name):
@@ -1101,6 +1142,9 @@ func SetEthConfig(ctx *cli.Context, stack *node.Node, cfg *eth.Config) {
 		// TODO(fjl): force-enable this in --dev mode
 		cfg.EnablePreimageRecording = ctx.GlobalBool(VMEnableDebugFlag.Name)
 	}
+	if ctx.GlobalIsSet(VMParallelExecutionFlag.Name) {
+		cfg.ParallelExecution = ctx.GlobalBool(VMParallelExecutionFlag.Name)
+	}
 
 	// Override any default configs for hard coded networks.
 	switch {
@@ -1257,6 +1301,8 @@ func MakeChain(ctx *cli.Context, stack *node.Node) (chain *core.BlockChain, chai
 		engine = clique.New(config.Clique, chainDb)
 	} else if config.Alien != nil {
 		engine = alien.New(config.Alien, chainDb)
+	} else if config.Algo != nil {
+		engine = algo.New(config.Algo, chainDb)
 
 	} else {
 		engine = ethash.NewFaker()
@@ -1282,7 +1328,10 @@ func MakeChain(ctx *cli.Context, stack *node.Node) (chain *core.BlockChain, chai
 	if ctx.GlobalIsSet(CacheFlag.Name) || ctx.GlobalIsSet(CacheGCFlag.Name) {
 		cache.TrieNodeLimit = ctx.GlobalInt(CacheFlag.Name) * ctx.GlobalInt(CacheGCFlag.Name) / 100
 	}
-	vmcfg := vm.Config{EnablePreimageRecording: ctx.GlobalBool(VMEnableDebugFlag.Name)}
+	vmcfg := vm.Config{
+		EnablePreimageRecording: ctx.GlobalBool(VMEnableDebugFlag.Name),
+		ParallelExecution:       ctx.GlobalBool(VMParallelExecutionFlag.Name),
+	}
 	chain, err = core.NewBlockChain(chainDb, cache, config, engine, vmcfg)
 	if err != nil {
 		Fatalf("Can't create BlockChain: %v", err)
@@ -0,0 +1,98 @@
+package algo
+
+import "fmt"
+
+// actionType specifies what the agreement service has to carry out on behalf
+// of the player state machine.
+type actionType int
+
+const (
+	// noop does nothing.
+	noop actionType = iota
+
+	// rezero resets the clock of the service at the start of a new period.
+	rezero
+
+	// assemble asks the pseudonode to assemble and propose blocks for a
+	// (round, period).
+	assemble
+
+	// attest asks the pseudonode to vote for a proposal-value in a step.
+	attest
+
+	// ensure commits an agreed block together with its certificate to the
+	// ledger.
+	ensure
+)
+
+func (a actionType) String() string {
+	switch a {
+	case noop:
+		return "noop"
+	case rezero:
+		return "rezero"
+	case assemble:
+		return "assemble"
+	case attest:
+		return "attest"
+	case ensure:
+		return "ensure"
+	}
+	return fmt.Sprintf("actionType(%d)", int(a))
+}
+
+// An action is the output of the player state machine, executed by the
+// agreement service.
+type action interface {
+	// t returns the actionType associated with the action.
+	t() actionType
+
+	// String returns a string description of an action.
+	String() string
+}
+
+// rezeroAction resets the clock of the service.
+type rezeroAction struct{}
+
+func (a rezeroAction) t() actionType {
+	return rezero
+}
+
+func (a rezeroAction) String() string {
+	return a.t().String()
+}
+
+// pseudonodeAction is delivered to the pseudonode to create the proposals or
+// votes of the local participation key.
+type pseudonodeAction struct {
+	// assemble, attest
+	T actionType
+
+	Round    round
+	Period   period
+	Step     step
+	Proposal ProposalValue
+}
+
+func (a pseudonodeAction) t() actionType {
+	return a.T
+}
+
+func (a pseudonodeAction) String() string {
+	return fmt.Sprintf("%v: %.5x (%v, %v, %v)", a.t(), a.Proposal.BlockDigest, a.Round, a.Period, a.Step)
+}
+
+// ensureAction commits a block once its proposal-value reached a cert
+// threshold.
+type ensureAction struct {
+	Payload     *Proposal
+	Certificate unauthenticatedBundle
+}
+
+func (a ensureAction) t() actionType {
+	return ensure
+}
+
+func (a ensureAction) String() string {
+	return fmt.Sprintf("%v: %.5x (%v, %v)", a.t(), a.Certificate.Proposal.BlockDigest, a.Certificate.Round, a.Certificate.Period)
+}
@@ -1,14 +1,101 @@
+// Package algo implements the Algorand-style pure-proof-of-stake consensus engine.
 package algo
 
 import (
+	"bytes"
+	"crypto/ecdsa"
+	"errors"
+	"math/big"
+	"sync"
+	"time"
+
 	"github.com/awesome-chain/Xchain/accounts"
 	"github.com/awesome-chain/Xchain/common"
 	"github.com/awesome-chain/Xchain/consensus"
+	"github.com/awesome-chain/Xchain/consensus/algo/beacon"
+	"github.com/awesome-chain/Xchain/consensus/algo/committee"
+	"github.com/awesome-chain/Xchain/consensus/algo/protocol"
+	"github.com/awesome-chain/Xchain/core/state"
 	"github.com/awesome-chain/Xchain/core/types"
+	"github.com/awesome-chain/Xchain/crypto"
 	"github.com/awesome-chain/Xchain/crypto/sha3"
+	"github.com/awesome-chain/Xchain/crypto/vrf"
+	"github.com/awesome-chain/Xchain/ethdb"
+	"github.com/awesome-chain/Xchain/log"
+	"github.com/awesome-chain/Xchain/params"
 	"github.com/awesome-chain/Xchain/rlp"
-	"math/big"
-	"sync"
+	"github.com/awesome-chain/Xchain/rpc"
+	"github.com/hashicorp/golang-lru"
+)
+
+const (
+	inMemorySignatures = 4096 // Number of recent block signatures to keep in memory
+	algoVersion        = "1.0"
+)
+
+// Algorand pure-proof-of-stake protocol constants.
+var (
+	defaultBlockPeriod = uint64(1)                // Default minimum difference between two consecutive block's timestamps
+	extraVanity        = 32                       // Fixed number of extra-data prefix bytes reserved for signer vanity
+	sigLength          = 65                       // Fixed number of bytes of the secp256k1 signature in header.Sig
+	uncleHash          = types.CalcUncleHash(nil) // Always Keccak256(RLP([])) as uncles are meaningless outside of PoW.
+	defaultDifficulty  = big.NewInt(1)            // Default difficulty
+)
+
+// Various error messages to mark blocks invalid. These should be private to
+// prevent engine specific errors from being referenced in the remainder of the
+// codebase, inherently breaking if the engine is swapped out. Please put common
+// error types into the consensus package.
+var (
+	// errUnknownBlock is returned when the list of signers is requested for a block
+	// that is not part of the local blockchain.
+	errUnknownBlock = errors.New("unknown block")
+
+	// errMissingVanity is returned if a block's extra-data section is shorter than
+	// 32 bytes, which is required to store the signer vanity.
+	errMissingVanity = errors.New("extra-data 32 byte vanity prefix missing")
+
+	// errMissingSignature is returned if a block's sig field doesn't seem to
+	// contain a 65 byte secp256k1 signature.
+	errMissingSignature = errors.New("65 byte header signature missing")
+
+	// errInvalidMixDigest is returned if a block's mix digest is non-zero.
+	errInvalidMixDigest = errors.New("non-zero mix digest")
+
+	// errInvalidUncleHash is returned if a block contains an non-empty uncle list.
+	errInvalidUncleHash = errors.New("non empty uncle hash")
+
+	// errInvalidDifficulty is returned if the difficulty of a block is not 1.
+	errInvalidDifficulty = errors.New("invalid difficulty")
+
+	// ErrInvalidTimestamp is returned if the timestamp of a block is lower than
+	// the previous block's timestamp + the minimum block period.
+	ErrInvalidTimestamp = errors.New("invalid timestamp")
+
+	// errInvalidCoinbase is returned if a block is signed by neither the coinbase
+	// nor the participation key registered by the coinbase.
+	errInvalidCoinbase = errors.New("coinbase does not match signer")
+
+	// errInvalidProposer is returned if the credential of a block does not prove
+	// that the sortition selected its proposer in the proposal period.
+	errInvalidProposer = errors.New("proposer not selected by sortition")
+
+	// errPeriodMismatch is returned if a proposal was not proposed in the period
+	// recorded in the extra-data of its block.
+	errPeriodMismatch = errors.New("proposal period mismatch")
+
+	// errMissingSeed is returned if the seed of the lookback block is unavailable.
+	errMissingSeed = errors.New("seed lookback header missing")
+
+	// errUnauthorized is returned if a block is sealed without a participation key.
+	errUnauthorized = errors.New("unauthorized")
+
+	// errUnclesNotAllowed is returned if uncles exists
+	errUnclesNotAllowed = errors.New("uncles not allowed")
+
+	// errInvalidUpgradeState is returned if the upgrade state of a block does not
+	// follow from the one of its parent and the vote of its proposer.
+	errInvalidUpgradeState = errors.New("invalid protocol upgrade state")
 )
 
 // SignerFn is a signer callback function to request a hash to be signed by a
@@ -18,87 +105,571 @@ type SignerFn func(accounts.Account, []byte) ([]byte, error)
 // SignTxFn is a signTx
 type SignTxFn func(accounts.Account, *types.Transaction, *big.Int) (*types.Transaction, error)
 
+// HeaderExtra is the struct of info in header.Extra[extraVanity:], it carries
+// everything needed to verify header.Seed on top of the lookback seed.
+type HeaderExtra struct {
+	Period    uint64 // The agreement period in which the block was proposed, and its seed derived
+	SeedProof []byte // The VRF proof of the proposer over the lookback seed, only in period 0
+
+	UpgradeState // The protocol upgrade state after this block
+	UpgradeVote  // The vote of the proposer on protocol upgrades
+
+	Credential []byte // The sortition proof selecting the proposer in the proposal period
+}
+
 // Algorand is the pure-proof-of-stake consensus engine.
 type Algorand struct {
 	sync.RWMutex
-	signer   common.Address
-	signFn   SignerFn // Signer function to authorize hashes with
-	signTxFn SignTxFn // Sign transaction function to sign tx
+	config     *params.AlgoConfig // Consensus engine configuration parameters
+	db         ethdb.Database     // Database to store and retrieve agreement state
+	signatures *lru.ARCCache      // Signatures of recent blocks to speed up mining
+
+	participations *lru.ARCCache // Sortition participants of recent lookback rounds
+
+	account  common.Address  // Stake account the participation key acts for
+	signer   common.Address  // Address of the participation key
+	signFn   SignerFn        // Signer function to authorize hashes with
+	signTxFn SignTxFn        // Sign transaction function to sign tx
+	vrfKey   *vrf.PrivateKey // Participation key to evaluate the seed VRF with
+
+	upgradeTo protocol.ConsensusVersion // Protocol version the local proposer votes for
+}
+
+// New creates an Algorand pure-proof-of-stake consensus engine.
+func New(config *params.AlgoConfig, db ethdb.Database) *Algorand {
+	// Set any missing consensus parameters to their defaults
+	conf := *config
+	if conf.Period == 0 {
+		conf.Period = defaultBlockPeriod
+	}
+	signatures, _ := lru.NewARC(inMemorySignatures)
+	participations, _ := lru.NewARC(inMemoryParticipants)
+
+	return &Algorand{
+		config:         &conf,
+		db:             db,
+		signatures:     signatures,
+		participations: participations,
+	}
+}
+
+// Author implements consensus.Engine, returning the stake account a header was
+// proposed for. The seal verification ensures the account authorized the key
+// which signed the header.
+func (a *Algorand) Author(header *types.Header) (common.Address, error) {
+	if _, err := ecrecover(header, a.signatures); err != nil {
+		return common.Address{}, err
+	}
+	return header.Coinbase, nil
+}
+
+// VerifyHeader checks whether a header conforms to the consensus rules.
+func (a *Algorand) VerifyHeader(chain consensus.ChainReader, header *types.Header, seal bool) error {
+	return a.verifyHeader(chain, header, nil)
+}
+
+// VerifyHeaders is similar to VerifyHeader, but verifies a batch of headers. The
+// method returns a quit channel to abort the operations and a results channel to
+// retrieve the async verifications (the order is that of the input slice).
+func (a *Algorand) VerifyHeaders(chain consensus.ChainReader, headers []*types.Header, seals []bool) (chan<- struct{}, <-chan error) {
+	abort := make(chan struct{})
+	results := make(chan error, len(headers))
+
+	go func() {
+		for i, header := range headers {
+			err := a.verifyHeader(chain, header, headers[:i])
+
+			select {
+			case <-abort:
+				return
+			case results <- err:
+			}
+		}
+	}()
+	return abort, results
+}
+
+// verifyHeader checks whether a header conforms to the consensus rules.The
+// caller may optionally pass in a batch of parents (ascending order) to avoid
+// looking those up from the database. This is useful for concurrently verifying
+// a batch of new headers.
+func (a *Algorand) verifyHeader(chain consensus.ChainReader, header *types.Header, parents []*types.Header) error {
+	if header.Number == nil {
+		return errUnknownBlock
+	}
+	// Don't waste time checking blocks from the future
+	if header.Time.Cmp(big.NewInt(time.Now().Unix())) > 0 {
+		return consensus.ErrFutureBlock
+	}
+	// Check that the extra-data contains the vanity and the seed proof
+	if len(header.Extra) < extraVanity {
+		return errMissingVanity
+	}
+	// Ensure that the mix digest is zero as we don't have fork protection currently
+	if header.MixDigest != (common.Hash{}) {
+		return errInvalidMixDigest
+	}
+	// Ensure that the block doesn't contain any uncles which are meaningless in PoS
+	if header.UncleHash != uncleHash {
+		return errInvalidUncleHash
+	}
+	// Ensure that the block's difficulty is meaningful (may not be correct at this point)
+	if header.Number.Uint64() > 0 && header.Difficulty.Cmp(defaultDifficulty) != 0 {
+		return errInvalidDifficulty
+	}
+	// All basic checks passed, verify cascading fields
+	return a.verifyCascadingFields(chain, header, parents)
+}
+
+// verifyCascadingFields verifies all the header fields that are not standalone,
+// rather depend on a batch of previous headers. The caller may optionally pass
+// in a batch of parents (ascending order) to avoid looking those up from the
+// database. This is useful for concurrently verifying a batch of new headers.
+func (a *Algorand) verifyCascadingFields(chain consensus.ChainReader, header *types.Header, parents []*types.Header) error {
+	// The genesis block is the always valid dead-end
+	number := header.Number.Uint64()
+	if number == 0 {
+		return nil
+	}
+	// Ensure that the block's timestamp isn't too close to it's parent
+	var parent *types.Header
+	if len(parents) > 0 {
+		parent = parents[len(parents)-1]
+	} else {
+		parent = chain.GetHeader(header.ParentHash, number-1)
+	}
+	if parent == nil || parent.Number.Uint64() != number-1 || parent.Hash() != header.ParentHash {
+		return consensus.ErrUnknownAncestor
+	}
+	if parent.Time.Uint64()+a.config.Period > header.Time.Uint64() {
+		return ErrInvalidTimestamp
+	}
+	// Ensure that the protocol upgrade state follows from the parent's one
+	if err := a.verifyUpgradeState(header, parent); err != nil {
+		return err
+	}
+	// All basic checks passed, verify the seal and return
+	return a.verifySeal(chain, header, parents)
+}
+
+// verifyUpgradeState checks that the upgrade state of a header is the one of its
+// parent after applying the upgrade vote of its proposer, and that the node
+// knows the protocol version the header runs.
+func (a *Algorand) verifyUpgradeState(header *types.Header, parent *types.Header) error {
+	prev, err := a.upgradeState(parent)
+	if err != nil {
+		return err
+	}
+	extra, err := decodeHeaderExtra(header)
+	if err != nil {
+		return err
+	}
+	want, err := prev.applyUpgradeVote(header.Number.Uint64(), extra.UpgradeVote)
+	if err != nil {
+		return err
+	}
+	if extra.UpgradeState != want {
+		return errInvalidUpgradeState
+	}
+	if _, ok := Consensus[want.CurrentProtocol]; !ok {
+		return errUnsupportedProtocol
+	}
+	return nil
+}
+
+// VerifyUncles implements consensus.Engine, always returning an error for any
+// uncles as this consensus mechanism doesn't permit uncles.
+func (a *Algorand) VerifyUncles(chain consensus.ChainReader, block *types.Block) error {
+	if len(block.Uncles()) > 0 {
+		return errUnclesNotAllowed
+	}
+	return nil
+}
+
+// VerifySeal implements consensus.Engine, checking whether the signature and the
+// seed contained in the header satisfy the consensus protocol requirements.
+func (a *Algorand) VerifySeal(chain consensus.ChainReader, header *types.Header) error {
+	return a.verifySeal(chain, header, nil)
+}
+
+// verifySeal checks whether the signature and the seed contained in the header
+// satisfy the consensus protocol requirements. The method accepts an optional
+// list of parent headers that aren't yet part of the local blockchain to look
+// up the seed lookback header from.
+func (a *Algorand) verifySeal(chain consensus.ChainReader, header *types.Header, parents []*types.Header) error {
+	// Verifying the genesis block is not supported
+	number := header.Number.Uint64()
+	if number == 0 {
+		return errUnknownBlock
+	}
+	// Resolve the proposer key and check it against the coinbase
+	pubkey, err := sigToPub(header)
+	if err != nil {
+		return err
+	}
+	extra, err := decodeHeaderExtra(header)
+	if err != nil {
+		return err
+	}
+	params, ok := Consensus[extra.CurrentProtocol]
+	if !ok {
+		return errUnsupportedProtocol
+	}
+	lookback := seedHeader(chain, header, parents, params)
+	if lookback == nil {
+		return errMissingSeed
+	}
+	// The coinbase may have registered the key and the sortition reads the stake
+	// in the lookback state. If the state is not available yet (batch import),
+	// Finalize checks them instead.
+	if ledger, ok := chain.(Ledger); ok {
+		if _, err := ledger.StateAt(lookback.Root); err == nil {
+			if err := a.verifyProposer(ledger, header, crypto.PubkeyToAddress(*pubkey)); err != nil {
+				return err
+			}
+			if err := a.verifyProposerCredential(ledger, header, pubkey, extra); err != nil {
+				return err
+			}
+		}
+	}
+	// Verify the seed against the one of the lookback block
+	return beacon.VerifySeed(lookback.Seed, header.Seed, pubkey, extra.Period, extra.SeedProof)
+}
+
+// verifyProposer checks that the key which signed a header is registered as the
+// participation key of the coinbase for the round of the header.
+func (a *Algorand) verifyProposer(ledger Ledger, header *types.Header, signer common.Address) error {
+	if signer == header.Coinbase {
+		return nil
+	}
+	key, online, err := a.participationKey(ledger, header.Coinbase, header.Number.Uint64())
+	if err != nil {
+		return err
+	}
+	if !online || key != signer {
+		return errInvalidCoinbase
+	}
+	return nil
+}
+
+// verifyProposerCredential checks that the credential of a header proves that
+// the sortition selected the participation key which signed the header as a
+// proposer in the proposal period of the header. Blocks are only proposed by
+// the agreement, so this rejects blocks sealed outside of it by anyone else.
+func (a *Algorand) verifyProposerCredential(ledger Ledger, header *types.Header, pubkey *ecdsa.PublicKey, extra HeaderExtra) error {
+	ucred := committee.UnauthenticatedCredential{Proof: extra.Credential}
+	if _, err := a.verifyCredential(ledger, header.Coinbase, crypto.FromECDSAPub(pubkey), ucred, round(header.Number.Uint64()), period(extra.Period), propose); err != nil {
+		log.Debug("Invalid proposer credential", "number", header.Number, "coinbase", header.Coinbase, "err", err)
+		return errInvalidProposer
+	}
+	return nil
+}
+
+// Prepare implements consensus.Engine, preparing all the consensus fields of the
+// header for running the transactions on top.
+func (a *Algorand) Prepare(chain consensus.ChainReader, header *types.Header) error {
+	// Set the correct difficulty
+	header.Difficulty = new(big.Int).Set(defaultDifficulty)
+	header.MixDigest = common.Hash{}
+
+	number := header.Number.Uint64()
+	parent := chain.GetHeader(header.ParentHash, number-1)
+	if parent == nil {
+		return consensus.ErrUnknownAncestor
+	}
+	// Ensure the timestamp has the correct delay
+	if minTime := new(big.Int).Add(parent.Time, new(big.Int).SetUint64(a.config.Period)); header.Time.Cmp(minTime) < 0 {
+		header.Time = minTime
+	}
+	// Ensure the extra data has all it's components
+	if len(header.Extra) < extraVanity {
+		header.Extra = append(header.Extra, bytes.Repeat([]byte{0x00}, extraVanity-len(header.Extra))...)
+	}
+	header.Extra = header.Extra[:extraVanity]
+
+	a.RLock()
+	account, signer, vrfKey := a.account, a.signer, a.vrfKey
+	a.RUnlock()
+
+	// Vote on the protocol upgrades and advance the upgrade state
+	prev, err := a.upgradeState(parent)
+	if err != nil {
+		return err
+	}
+	extra := HeaderExtra{UpgradeVote: a.upgradeVote(prev, number)}
+	if extra.UpgradeState, err = prev.applyUpgradeVote(number, extra.UpgradeVote); err != nil {
+		return err
+	}
+	params, ok := Consensus[extra.CurrentProtocol]
+	if !ok {
+		return errUnsupportedProtocol
+	}
+	// Without a participation key only the pending block is assembled, which is
+	// never sealed, so leave the seed empty
+	if vrfKey != nil {
+		header.Coinbase = account
+		seed, proof, err := DeriveNewSeed(signer, vrfKey, number, 0, params, chain)
+		if err != nil {
+			return err
+		}
+		header.Seed = seed
+		extra.SeedProof = proof
+	}
+	extraEnc, err := rlp.EncodeToBytes(extra)
+	if err != nil {
+		return err
+	}
+	header.Extra = append(header.Extra, extraEnc...)
+	return nil
+}
+
+// Finalize implements consensus.Engine, ensuring no uncles are set, nor block
+// rewards given, applying the participation transactions and returns the final
+// block.
+func (a *Algorand) Finalize(chain consensus.ChainReader, header *types.Header, state *state.StateDB, txs []*types.Transaction, uncles []*types.Header, receipts []*types.Receipt) (*types.Block, error) {
+	// Blocks being imported are signed already, check the proposer key against
+	// the registry and its credential against the sortition, as the seal
+	// verification may have lacked the state to
+	if len(header.Sig) > 0 {
+		pubkey, err := sigToPub(header)
+		if err != nil {
+			return nil, err
+		}
+		ledger, ok := chain.(Ledger)
+		if !ok {
+			return nil, errInvalidProposer
+		}
+		if err := a.verifyProposer(ledger, header, crypto.PubkeyToAddress(*pubkey)); err != nil {
+			return nil, err
+		}
+		extra, err := decodeHeaderExtra(header)
+		if err != nil {
+			return nil, err
+		}
+		if err := a.verifyProposerCredential(ledger, header, pubkey, extra); err != nil {
+			return nil, err
+		}
+	}
+	processParticipationTxs(chain, header, state, txs)
+
+	header.Root = state.IntermediateRoot(chain.Config().IsEIP158(header.Number))
+	// No uncle block
+	header.UncleHash = types.CalcUncleHash(nil)
+
+	// Assemble and return the final block for sealing
+	return types.NewBlock(header, txs, nil, receipts), nil
+}
+
+// Authorize injects a participation key into the consensus engine to evaluate
+// the seed VRF and to mint new blocks with, taking part with the stake of the
+// key's own account.
+func (a *Algorand) Authorize(key *ecdsa.PrivateKey) {
+	a.AuthorizeParticipation(crypto.PubkeyToAddress(key.PublicKey), key)
+}
+
+// AuthorizeParticipation injects a participation key into the consensus engine
+// to take part on behalf of a stake account, which registered the key with a
+// participation transaction. The key of the stake account itself is not needed.
+func (a *Algorand) AuthorizeParticipation(account common.Address, key *ecdsa.PrivateKey) {
+	a.Lock()
+	defer a.Unlock()
+
+	a.account = account
+	a.signer = crypto.PubkeyToAddress(key.PublicKey)
+	a.signFn = func(account accounts.Account, hash []byte) ([]byte, error) {
+		return crypto.Sign(hash, key)
+	}
+	a.vrfKey = &vrf.PrivateKey{PrivateKey: key}
 }
 
 // Seal implements consensus.Engine, attempting to create a sealed block using
-// the local signing credentials.
+// the local signing credentials. Blocks are proposed by the agreement service,
+// the miner doesn't seal them; a block sealed here carries the credential of
+// the first period and is only valid if the sortition selected the local key.
 func (a *Algorand) Seal(chain consensus.ChainReader, block *types.Block, stop <-chan struct{}) (*types.Block, error) {
 	header := block.Header()
+
 	// Sealing the genesis block is not supported
-	//number := header.Number.Uint64()
-	//if number == 0 {
-	//	return nil, errUnknownBlock
-	//}
+	number := header.Number.Uint64()
+	if number == 0 {
+		return nil, errUnknownBlock
+	}
+	// Don't hold the signer fields for the entire sealing procedure
 	a.RLock()
-	signer, signFn := a.signer, a.signFn
+	account, signFn := a.account, a.signFn
 	a.RUnlock()
-	//select {
-	//case <-stop:
-	//	return nil, nil
-	//case <-time.After(delay):
-	//}
-	hash := HashHeader(header)
-	sig, err := signFn(accounts.Account{Address: signer}, hash.Bytes())
+
+	if signFn == nil || header.Coinbase != account {
+		<-stop
+		return nil, errUnauthorized
+	}
+	// correct the time
+	delay := time.Unix(header.Time.Int64(), 0).Sub(time.Now())
+
+	select {
+	case <-stop:
+		return nil, nil
+	case <-time.After(delay):
+	}
+	ledger, ok := chain.(Ledger)
+	if !ok {
+		return nil, errUnauthorized
+	}
+	cred, err := a.credential(ledger, round(number), 0, propose)
 	if err != nil {
 		return nil, err
 	}
-	header.Sig = sig
+	if !cred.Selected() {
+		return nil, errInvalidProposer
+	}
+	if err := a.signProposal(chain, header, 0, cred.UnauthenticatedCredential); err != nil {
+		return nil, err
+	}
 	return block.WithSeal(header), nil
 }
 
+// signProposal records the proposal period and the proposer credential in the
+// extra-data of a header, then signs it with the local participation key. The
+// seed is prepared for period 0, in later periods it only depends on the
+// lookback seed and is derived again.
+func (a *Algorand) signProposal(chain consensus.ChainReader, header *types.Header, p period, cred committee.UnauthenticatedCredential) error {
+	extra, err := decodeHeaderExtra(header)
+	if err != nil {
+		return err
+	}
+	if uint64(p) != extra.Period {
+		params, ok := Consensus[extra.CurrentProtocol]
+		if !ok {
+			return errUnsupportedProtocol
+		}
+		a.RLock()
+		signer, vrfKey := a.signer, a.vrfKey
+		a.RUnlock()
+
+		if header.Seed, extra.SeedProof, err = DeriveNewSeed(signer, vrfKey, header.Number.Uint64(), uint64(p), params, chain); err != nil {
+			return err
+		}
+	}
+	extra.Period, extra.Credential = uint64(p), cred.Proof
+	extraEnc, err := rlp.EncodeToBytes(extra)
+	if err != nil {
+		return err
+	}
+	header.Extra = append(header.Extra[:extraVanity:extraVanity], extraEnc...)
+	return a.sign(header)
+}
+
+// sign signs a header with the local participation key, whose stake account
+// must be the coinbase of the header.
+func (a *Algorand) sign(header *types.Header) error {
+	a.RLock()
+	account, signer, signFn := a.account, a.signer, a.signFn
+	a.RUnlock()
+
+	if signFn == nil || header.Coinbase != account {
+		return errUnauthorized
+	}
+	sig, err := signFn(accounts.Account{Address: signer}, HashHeader(header).Bytes())
+	if err != nil {
+		return err
+	}
+	header.Sig = sig
+	return nil
+}
+
+// participant returns the local participation key together with the stake
+// account it acts for, or a nil key if none was authorized.
+func (a *Algorand) participant() (common.Address, *ecdsa.PrivateKey) {
+	a.RLock()
+	defer a.RUnlock()
+
+	if a.vrfKey == nil {
+		return common.Address{}, nil
+	}
+	return a.account, a.vrfKey.PrivateKey
+}
+
+// CalcDifficulty is the difficulty adjustment algorithm. It returns the difficulty
+// that a new block should have based on the previous blocks in the chain and the
+// current signer.
+func (a *Algorand) CalcDifficulty(chain consensus.ChainReader, time uint64, parent *types.Header) *big.Int {
+	return new(big.Int).Set(defaultDifficulty)
+}
+
+// APIs implements consensus.Engine, returning the user facing RPC API to allow
+// inspecting the proposers and seeds, and the public randomness beacon.
+func (a *Algorand) APIs(chain consensus.ChainReader) []rpc.API {
+	return []rpc.API{{
+		Namespace: "algo",
+		Version:   algoVersion,
+		Service:   &API{chain: chain, algo: a},
+		Public:    false,
+	}, {
+		Namespace: "eth",
+		Version:   algoVersion,
+		Service:   &RandomnessAPI{chain: chain, algo: a},
+		Public:    true,
+	}}
+}
+
+// HashHeader returns the hash which is signed by the proposer into header.Sig.
+// It is the hash of the entire header apart from the signature itself and the
+// unused proof-of-work fields.
 func HashHeader(h *types.Header) common.Hash {
-	return rlpHash([]interface{}{
-		h.ParentHash,
-		h.UncleHash,
-		h.Coinbase,
-		h.Root,
-		h.TxHash,
-		h.ReceiptHash,
-		h.Seed,
-		h.Bloom,
-		h.Difficulty,
-		h.Number,
-		h.GasLimit,
-		h.GasUsed,
-		h.Time,
-		h.Extra,
-	})
+	return beacon.HashHeader(h)
+}
+
+// sigToPub recovers the public key of the proposer from a signed header.
+func sigToPub(header *types.Header) (*ecdsa.PublicKey, error) {
+	if len(header.Sig) != sigLength {
+		return nil, errMissingSignature
+	}
+	return crypto.SigToPub(HashHeader(header).Bytes(), header.Sig)
 }
 
 // ecrecover extracts the Ethereum account address from a signed header.
-//func ecrecover(header *types.Header, sigcache *lru.ARCCache) (common.Address, error) {
-//	// If the signature's already cached, return that
-//	hash := header.Hash()
-//	if address, known := sigcache.Get(hash); known {
-//		return address.(common.Address), nil
-//	}
-//	// Retrieve the signature from the header extra-data
-//	if len(header.Extra) < extraSeal {
-//		return common.Address{}, errMissingSignature
-//	}
-//	signature := header.Extra[len(header.Extra)-extraSeal:]
-//
-//	// Recover the public key and the Ethereum address
-//	headerSigHash, err := sigHash(header)
-//	if err != nil {
-//		return common.Address{}, err
-//	}
-//	pubkey, err := crypto.Ecrecover(headerSigHash.Bytes(), signature)
-//	if err != nil {
-//		return common.Address{}, err
-//	}
-//	var signer common.Address
-//	copy(signer[:], crypto.Keccak256(pubkey[1:])[12:])
-//
-//	sigcache.Add(hash, signer)
-//	return signer, nil
-//}
+func ecrecover(header *types.Header, sigcache *lru.ARCCache) (common.Address, error) {
+	// If the signature's already cached, return that
+	hash := header.Hash()
+	if address, known := sigcache.Get(hash); known {
+		return address.(common.Address), nil
+	}
+	pubkey, err := sigToPub(header)
+	if err != nil {
+		return common.Address{}, err
+	}
+	signer := crypto.PubkeyToAddress(*pubkey)
+
+	sigcache.Add(hash, signer)
+	return signer, nil
+}
+
+// seedHeader retrieves the header whose seed the seed of the given header is
+// derived from, preferring the batch of parents (ascending order) over the
+// database.
+func seedHeader(chain consensus.ChainReader, header *types.Header, parents []*types.Header, params ConsensusParams) *types.Header {
+	target := params.seedRound(header.Number.Uint64())
+	for header != nil && header.Number.Uint64() > target {
+		number, hash := header.Number.Uint64()-1, header.ParentHash
+		if len(parents) > 0 && parents[len(parents)-1].Hash() == hash {
+			header, parents = parents[len(parents)-1], parents[:len(parents)-1]
+		} else {
+			header = chain.GetHeader(hash, number)
+		}
+	}
+	return header
+}
+
+// decodeHeaderExtra decodes the consensus fields stored in the extra-data of a
+// header after the vanity prefix.
+func decodeHeaderExtra(header *types.Header) (HeaderExtra, error) {
+	extra := HeaderExtra{}
+	if len(header.Extra) < extraVanity {
+		return extra, errMissingVanity
+	}
+	err := rlp.DecodeBytes(header.Extra[extraVanity:], &extra)
+	return extra, err
+}
 
 func rlpHash(x interface{}) (h common.Hash) {
 	hw := sha3.NewKeccak256()
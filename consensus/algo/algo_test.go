@@ -0,0 +1,299 @@
+package algo
+
+import (
+	"math/big"
+	"testing"
+
+	"github.com/awesome-chain/Xchain/common"
+	"github.com/awesome-chain/Xchain/consensus/algo/committee"
+	"github.com/awesome-chain/Xchain/consensus/algo/protocol"
+	"github.com/awesome-chain/Xchain/core"
+	"github.com/awesome-chain/Xchain/core/types"
+	"github.com/awesome-chain/Xchain/core/vm"
+	"github.com/awesome-chain/Xchain/crypto"
+	"github.com/awesome-chain/Xchain/ethdb"
+	"github.com/awesome-chain/Xchain/params"
+	"github.com/awesome-chain/Xchain/rpc"
+)
+
+// makeBlock assembles and seals a new block with the given transactions on top
+// of the current head of the chain with the given engine.
+func makeBlock(t *testing.T, chain *core.BlockChain, engine *Algorand, txs ...*types.Transaction) *types.Block {
+	block, err := engine.Seal(chain, finalizeBlock(t, chain, engine, txs...), make(chan struct{}))
+	if err != nil {
+		t.Fatalf("failed to seal block: %v", err)
+	}
+	return block
+}
+
+// finalizeBlock assembles a new unsealed block with the given transactions on
+// top of the current head of the chain with the given engine.
+func finalizeBlock(t *testing.T, chain *core.BlockChain, engine *Algorand, txs ...*types.Transaction) *types.Block {
+	parent := chain.CurrentBlock()
+	header := &types.Header{
+		ParentHash: parent.Hash(),
+		Number:     new(big.Int).Add(parent.Number(), big.NewInt(1)),
+		GasLimit:   core.CalcGasLimit(parent),
+		Time:       new(big.Int).Set(parent.Time()),
+	}
+	if err := engine.Prepare(chain, header); err != nil {
+		t.Fatalf("failed to prepare header: %v", err)
+	}
+	statedb, err := chain.StateAt(parent.Root())
+	if err != nil {
+		t.Fatalf("failed to retrieve parent state: %v", err)
+	}
+	var (
+		gaspool  = new(core.GasPool).AddGas(header.GasLimit)
+		receipts []*types.Receipt
+	)
+	for _, tx := range txs {
+		receipt, _, err := core.ApplyTransaction(chain.Config(), chain, nil, gaspool, statedb, header, tx, &header.GasUsed, vm.Config{})
+		if err != nil {
+			t.Fatalf("failed to apply transaction: %v", err)
+		}
+		receipts = append(receipts, receipt)
+	}
+	block, err := engine.Finalize(chain, header, statedb, txs, nil, receipts)
+	if err != nil {
+		t.Fatalf("failed to finalize block: %v", err)
+	}
+	return block
+}
+
+func TestAlgorandSealAndVerify(t *testing.T) {
+	var (
+		key, _        = crypto.HexToECDSA("b71c71a67e1177ad4e901695e1b4b9ee17ae16c6668d313eac2f96dbcda3f291")
+		address       = crypto.PubkeyToAddress(key.PublicKey)
+		engine, chain = newTestParticipants(t, key)
+	)
+	engine.Authorize(key)
+	defer chain.Stop()
+
+	for i := 0; i < 5; i++ {
+		block := makeBlock(t, chain, engine)
+		if _, err := chain.InsertChain(types.Blocks{block}); err != nil {
+			t.Fatalf("block %d: failed to insert: %v", i+1, err)
+		}
+		if author, err := engine.Author(block.Header()); err != nil || author != address {
+			t.Fatalf("block %d: author mismatch: have %x, want %x, err %v", i+1, author, address, err)
+		}
+	}
+	// A block with a tampered seed must be rejected even if re-signed
+	block := makeBlock(t, chain, engine)
+	header := block.Header()
+	header.Seed[0] ^= 0xff
+	sig, _ := crypto.Sign(HashHeader(header).Bytes(), key)
+	header.Sig = sig
+	if err := engine.VerifyHeader(chain, header, true); err == nil {
+		t.Fatalf("tampered seed accepted")
+	}
+	// A block signed by a foreign key must be rejected
+	header = block.Header()
+	other, _ := crypto.GenerateKey()
+	header.Sig, _ = crypto.Sign(HashHeader(header).Bytes(), other)
+	if err := engine.VerifyHeader(chain, header, true); err != errInvalidCoinbase {
+		t.Fatalf("foreign signature error mismatch: have %v, want %v", err, errInvalidCoinbase)
+	}
+	// A block sealed without the sortition credential of its proposer, or with
+	// the credential of another period, must be rejected
+	cred, err := engine.credential(chain, round(block.NumberU64()), 0, propose)
+	if err != nil {
+		t.Fatalf("failed to evaluate credential: %v", err)
+	}
+	forged := []struct {
+		period period
+		cred   committee.UnauthenticatedCredential
+	}{
+		{0, committee.UnauthenticatedCredential{}},
+		{1, cred.UnauthenticatedCredential},
+	}
+	for i, tt := range forged {
+		header = block.Header()
+		if err := engine.signProposal(chain, header, tt.period, tt.cred); err != nil {
+			t.Fatalf("forged %d: failed to sign: %v", i, err)
+		}
+		if err := engine.VerifyHeader(chain, header, true); err != errInvalidProposer {
+			t.Errorf("forged %d: error mismatch: have %v, want %v", i, err, errInvalidProposer)
+		}
+	}
+	// A block proposed in a later period carries that period and a seed derived
+	// in it
+	if cred, err = engine.credential(chain, round(block.NumberU64()), 1, propose); err != nil || !cred.Selected() {
+		t.Fatalf("failed to select proposer in period 1: %v", err)
+	}
+	header = block.Header()
+	if err := engine.signProposal(chain, header, 1, cred.UnauthenticatedCredential); err != nil {
+		t.Fatalf("failed to sign: %v", err)
+	}
+	if extra, err := decodeHeaderExtra(header); err != nil || extra.Period != 1 || len(extra.SeedProof) != 0 {
+		t.Fatalf("period 1 extra mismatch: %+v, err %v", extra, err)
+	}
+	if header.Seed == block.Header().Seed {
+		t.Errorf("period 1 block kept the period 0 seed")
+	}
+	if err := engine.VerifyHeader(chain, header, true); err != nil {
+		t.Errorf("period 1 block rejected: %v", err)
+	}
+}
+
+func TestAlgorandCredential(t *testing.T) {
+	var (
+		db      = ethdb.NewMemDatabase()
+		key, _  = crypto.HexToECDSA("b71c71a67e1177ad4e901695e1b4b9ee17ae16c6668d313eac2f96dbcda3f291")
+		address = crypto.PubkeyToAddress(key.PublicKey)
+		other   = crypto.PubkeyToAddress(crypto.ToECDSAUnsafe(common.FromHex("0x01")).PublicKey)
+		funds   = new(big.Int).Mul(big.NewInt(10000), stakeUnit)
+		config  = *params.AllAlgoProtocolChanges
+	)
+	config.Algo = &params.AlgoConfig{Period: 1, Participants: []common.UnprefixedAddress{common.UnprefixedAddress(address), common.UnprefixedAddress(other)}}
+	gspec := &core.Genesis{Config: &config, Alloc: core.GenesisAlloc{address: {Balance: funds}, other: {Balance: funds}}}
+	gspec.MustCommit(db)
+
+	engine := New(config.Algo, db)
+	engine.Authorize(key)
+	chain, err := core.NewBlockChain(db, nil, gspec.Config, engine, vm.Config{})
+	if err != nil {
+		t.Fatalf("failed to create chain: %v", err)
+	}
+	defer chain.Stop()
+
+	// Holding half of the stake, the key must be selected about half the committee
+	cred, err := engine.credential(chain, 1, 0, soft)
+	if err != nil {
+		t.Fatalf("failed to evaluate credential: %v", err)
+	}
+	if size := Consensus[protocol.ConsensusV1].committeeSize(soft); cred.Weight < size/2*8/10 || cred.Weight > size/2*12/10 {
+		t.Fatalf("credential weight mismatch: have %d, want about %d", cred.Weight, size/2)
+	}
+	pubkey := crypto.FromECDSAPub(&key.PublicKey)
+	verified, err := engine.verifyCredential(chain, address, pubkey, cred.UnauthenticatedCredential, 1, 0, soft)
+	if err != nil {
+		t.Fatalf("failed to verify credential: %v", err)
+	}
+	if verified.Weight != cred.Weight {
+		t.Fatalf("verified weight mismatch: have %d, want %d", verified.Weight, cred.Weight)
+	}
+	if _, err := engine.verifyCredential(chain, address, pubkey, cred.UnauthenticatedCredential, 1, 0, cert); err == nil {
+		t.Fatalf("credential accepted for wrong step")
+	}
+}
+
+// Tests that networks holding less stake than the committees need can run the
+// sortition with scaled down committees.
+func TestAlgorandSmallStakeCommittees(t *testing.T) {
+	var (
+		key, _  = crypto.HexToECDSA("b71c71a67e1177ad4e901695e1b4b9ee17ae16c6668d313eac2f96dbcda3f291")
+		address = crypto.PubkeyToAddress(key.PublicKey)
+		other   = crypto.PubkeyToAddress(crypto.ToECDSAUnsafe(common.FromHex("0x01")).PublicKey)
+		funds   = new(big.Int).Mul(big.NewInt(10), stakeUnit)
+	)
+	newEngine := func(scale uint64) (*Algorand, *core.BlockChain) {
+		config := *params.AllAlgoProtocolChanges
+		config.Algo = &params.AlgoConfig{Period: 1, Participants: []common.UnprefixedAddress{common.UnprefixedAddress(address), common.UnprefixedAddress(other)}, CommitteeScale: scale}
+
+		db := ethdb.NewMemDatabase()
+		gspec := &core.Genesis{Config: &config, Alloc: core.GenesisAlloc{address: {Balance: funds}, other: {Balance: funds}}}
+		gspec.MustCommit(db)
+
+		engine := New(config.Algo, db)
+		engine.Authorize(key)
+		chain, err := core.NewBlockChain(db, nil, gspec.Config, engine, vm.Config{})
+		if err != nil {
+			t.Fatalf("failed to create chain: %v", err)
+		}
+		return engine, chain
+	}
+	// With full size committees, the 20 units of stake can't fill the committees
+	engine, chain := newEngine(0)
+	if _, err := engine.credential(chain, 1, 0, soft); err == nil {
+		t.Fatalf("full size committee accepted on small stake")
+	}
+	chain.Stop()
+
+	// Scaled down, every step is within the stake and keeps its threshold share
+	engine, chain = newEngine(1000)
+	defer chain.Stop()
+
+	params, err := engine.consensusParams(chain, 1)
+	if err != nil {
+		t.Fatalf("failed to retrieve parameters: %v", err)
+	}
+	full := Consensus[protocol.ConsensusV1]
+	for _, s := range []step{propose, soft, cert, next, late, redo, down} {
+		size := params.committeeSize(s)
+		if size == 0 || size > 20 {
+			t.Errorf("step %v: committee size %d out of stake", s, size)
+		}
+		if s == propose {
+			continue
+		}
+		if threshold := params.threshold(s); threshold > size || threshold*full.committeeSize(s) < full.threshold(s)*size {
+			t.Errorf("step %v: threshold %d of %d below full share %d of %d", s, threshold, size, full.threshold(s), full.committeeSize(s))
+		}
+		cred, err := engine.credential(chain, 1, 0, s)
+		if err != nil {
+			t.Fatalf("step %v: failed to evaluate credential: %v", s, err)
+		}
+		if cred.Selected() {
+			if _, err := engine.verifyCredential(chain, address, crypto.FromECDSAPub(&key.PublicKey), cred.UnauthenticatedCredential, 1, 0, s); err != nil {
+				t.Errorf("step %v: failed to verify credential: %v", s, err)
+			}
+		}
+	}
+}
+
+func TestAlgorandRandomness(t *testing.T) {
+	var (
+		key, _        = crypto.HexToECDSA("b71c71a67e1177ad4e901695e1b4b9ee17ae16c6668d313eac2f96dbcda3f291")
+		address       = crypto.PubkeyToAddress(key.PublicKey)
+		engine, chain = newTestParticipants(t, key)
+	)
+	engine.Authorize(key)
+	defer chain.Stop()
+
+	for i := 0; i < 3; i++ {
+		if _, err := chain.InsertChain(types.Blocks{makeBlock(t, chain, engine)}); err != nil {
+			t.Fatalf("block %d: failed to insert: %v", i+1, err)
+		}
+	}
+	api := &RandomnessAPI{chain: chain, algo: engine}
+	if _, err := api.GetRandomness(0); err != errGenesisRandomness {
+		t.Fatalf("genesis randomness error mismatch: have %v, want %v", err, errGenesisRandomness)
+	}
+	for i := uint64(1); i <= 3; i++ {
+		r, err := api.GetRandomness(rpc.BlockNumber(i))
+		if err != nil {
+			t.Fatalf("block %d: failed to retrieve randomness: %v", i, err)
+		}
+		if r.Proposer != address || r.Signer != address {
+			t.Errorf("block %d: proposer mismatch: have %x/%x, want %x", i, r.Proposer, r.Signer, address)
+		}
+		if err := r.Verify(); err != nil {
+			t.Errorf("block %d: failed to verify randomness: %v", i, err)
+		}
+		header := chain.GetHeaderByNumber(i)
+		if err := r.VerifyHeaders(header, chain.GetHeaderByNumber(uint64(r.LookbackNumber))); err != nil {
+			t.Errorf("block %d: failed to verify randomness headers: %v", i, err)
+		}
+		if byHash, err := api.GetRandomnessByHash(header.Hash()); err != nil || byHash.Seed != r.Seed {
+			t.Errorf("block %d: randomness by hash mismatch: have %v, want %v, err %v", i, byHash, r, err)
+		}
+	}
+	// Tampered seeds and proofs must not verify
+	r, _ := api.GetRandomness(rpc.LatestBlockNumber)
+	r.Seed[0] ^= 0xff
+	if err := r.Verify(); err == nil {
+		t.Errorf("tampered seed verified")
+	}
+	r, _ = api.GetRandomness(rpc.LatestBlockNumber)
+	r.Proof[len(r.Proof)-1] ^= 0xff
+	if err := r.Verify(); err == nil {
+		t.Errorf("tampered proof verified")
+	}
+	r, _ = api.GetRandomness(rpc.LatestBlockNumber)
+	r.LookbackSeed[0] ^= 0xff
+	if err := r.Verify(); err == nil {
+		t.Errorf("tampered lookback seed verified")
+	}
+}
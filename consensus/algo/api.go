@@ -0,0 +1,74 @@
+package algo
+
+import (
+	"github.com/awesome-chain/Xchain/common"
+	"github.com/awesome-chain/Xchain/consensus"
+	"github.com/awesome-chain/Xchain/core/types"
+	"github.com/awesome-chain/Xchain/rpc"
+)
+
+// API is a user facing RPC API to allow inspecting the proposers and seeds of
+// the pure-proof-of-stake scheme.
+type API struct {
+	chain consensus.ChainReader
+	algo  *Algorand
+}
+
+// header retrieves the requested header (or current if none requested).
+func (api *API) header(number *rpc.BlockNumber) (*types.Header, error) {
+	var header *types.Header
+	if number == nil || *number == rpc.LatestBlockNumber {
+		header = api.chain.CurrentHeader()
+	} else {
+		header = api.chain.GetHeaderByNumber(uint64(number.Int64()))
+	}
+	if header == nil {
+		return nil, errUnknownBlock
+	}
+	return header, nil
+}
+
+// GetProposer retrieves the account which proposed and signed a given block.
+func (api *API) GetProposer(number *rpc.BlockNumber) (common.Address, error) {
+	header, err := api.header(number)
+	if err != nil {
+		return common.Address{}, err
+	}
+	return api.algo.Author(header)
+}
+
+// GetSeed retrieves the sortition seed of a given block.
+func (api *API) GetSeed(number *rpc.BlockNumber) (common.Hash, error) {
+	header, err := api.header(number)
+	if err != nil {
+		return common.Hash{}, err
+	}
+	return common.Hash(header.Seed), nil
+}
+
+// Participation is the participation key registration of a stake account.
+type Participation struct {
+	Online     bool           `json:"online"`
+	Key        common.Address `json:"key"`
+	FirstValid uint64         `json:"firstValid"`
+	LastValid  uint64         `json:"lastValid"`
+}
+
+// GetParticipation retrieves the participation key registration of an account
+// as of the state of a given block.
+func (api *API) GetParticipation(account common.Address, number *rpc.BlockNumber) (*Participation, error) {
+	header, err := api.header(number)
+	if err != nil {
+		return nil, err
+	}
+	ledger, ok := api.chain.(Ledger)
+	if !ok {
+		return nil, errUnknownBlock
+	}
+	statedb, err := ledger.StateAt(header.Root)
+	if err != nil {
+		return nil, err
+	}
+	p, _ := api.algo.participation(statedb, account)
+	return &Participation{Online: p.Online, Key: p.Key, FirstValid: p.FirstValid, LastValid: p.LastValid}, nil
+}
@@ -2,10 +2,43 @@ package algo
 
 import (
 	"context"
-	"github.com/awesome-chain/Xchain/util/execpool"
+	"errors"
 	"sync"
+
+	"github.com/awesome-chain/Xchain/util/execpool"
 )
 
+// errVerifierClosed is returned if a verification is requested from a verifier
+// which already quit.
+var errVerifierClosed = errors.New("vote verifier is closed")
+
+// asyncVerifyVoteRequest is a vote or equivocation vote waiting for its turn
+// in the verification pool.
+type asyncVerifyVoteRequest struct {
+	ctx    context.Context
+	engine *Algorand
+	l      Ledger
+	uv     *unauthenticatedVote
+	uev    *unauthenticatedEquivocationVote
+
+	index   int
+	message message
+	out     chan<- asyncVerifyVoteResponse
+}
+
+// asyncVerifyVoteResponse is the result of a vote verification. If the context
+// of the request expired before the vote was verified, cancelled is set.
+type asyncVerifyVoteResponse struct {
+	v         vote
+	ev        equivocationVote
+	index     int
+	message   message
+	err       error
+	cancelled bool
+
+	req *asyncVerifyVoteRequest
+}
+
 // AsyncVoteVerifier uses workers to verify agreement protocol votes and writes the results on an output channel specified by the user.
 type AsyncVoteVerifier struct {
 	done            chan struct{}
@@ -16,3 +49,115 @@ type AsyncVoteVerifier struct {
 	ctx             context.Context
 	ctxCancel       context.CancelFunc
 }
+
+// MakeAsyncVoteVerifier creates an AsyncVoteVerifier running its verifications
+// on the given pool. If the pool is nil, a pool of all the CPUs is created and
+// owned by the verifier.
+func MakeAsyncVoteVerifier(verificationPool execpool.BacklogPool) *AsyncVoteVerifier {
+	verifier := &AsyncVoteVerifier{
+		done:         make(chan struct{}),
+		workerWaitCh: make(chan struct{}),
+		execpoolOut:  make(chan interface{}),
+	}
+	if verificationPool == nil {
+		verifier.backlogExecPool = execpool.MakeBacklog(nil, 0, execpool.HighPriority, verifier)
+	} else {
+		verifier.backlogExecPool = verificationPool
+	}
+	verifier.ctx, verifier.ctxCancel = context.WithCancel(context.Background())
+
+	go verifier.worker()
+	return verifier
+}
+
+// worker forwards the results of the pool to the output channels of their
+// requests. A result is dropped if its output channel is full and the context
+// of its request expired meanwhile.
+func (avv *AsyncVoteVerifier) worker() {
+	defer close(avv.workerWaitCh)
+
+	for res := range avv.execpoolOut {
+		verRes := res.(asyncVerifyVoteResponse)
+		select {
+		case verRes.req.out <- verRes:
+		default:
+			select {
+			case verRes.req.out <- verRes:
+			case <-verRes.req.ctx.Done():
+			}
+		}
+		avv.wg.Done()
+	}
+}
+
+// executeVoteVerification verifies a request on a worker of the pool.
+func (avv *AsyncVoteVerifier) executeVoteVerification(task interface{}) interface{} {
+	req := task.(*asyncVerifyVoteRequest)
+
+	select {
+	case <-req.ctx.Done():
+		// request cancelled, return an error response on the channel
+		return asyncVerifyVoteResponse{err: req.ctx.Err(), cancelled: true, req: req, index: req.index, message: req.message}
+	default:
+	}
+	res := asyncVerifyVoteResponse{req: req, index: req.index, message: req.message}
+	if req.uv != nil {
+		res.v, res.err = req.uv.verify(req.engine, req.l)
+	} else {
+		res.ev, res.err = req.uev.verify(req.engine, req.l)
+	}
+	return res
+}
+
+// enqueue hands a request over to the pool. It blocks until the pool accepts
+// the request, or either the request or the verifier is cancelled.
+func (avv *AsyncVoteVerifier) enqueue(req *asyncVerifyVoteRequest) error {
+	select {
+	case <-avv.ctx.Done():
+		return errVerifierClosed
+	default:
+	}
+	avv.wg.Add(1)
+	if err := avv.backlogExecPool.EnqueueBacklog(req.ctx, avv.executeVoteVerification, req, avv.execpoolOut); err != nil {
+		avv.wg.Done()
+		return err
+	}
+	return nil
+}
+
+// verifyVote queues a vote for verification. The result is written to out,
+// tagged with the given index and message.
+func (avv *AsyncVoteVerifier) verifyVote(verctx context.Context, engine *Algorand, l Ledger, uv unauthenticatedVote, index int, message message, out chan<- asyncVerifyVoteResponse) error {
+	return avv.enqueue(&asyncVerifyVoteRequest{ctx: verctx, engine: engine, l: l, uv: &uv, index: index, message: message, out: out})
+}
+
+// verifyEquivocVote queues an equivocation vote for verification. The result
+// is written to out, tagged with the given index.
+func (avv *AsyncVoteVerifier) verifyEquivocVote(verctx context.Context, engine *Algorand, l Ledger, uev unauthenticatedEquivocationVote, index int, out chan<- asyncVerifyVoteResponse) error {
+	return avv.enqueue(&asyncVerifyVoteRequest{ctx: verctx, engine: engine, l: l, uev: &uev, index: index, out: out})
+}
+
+// Quit stops the verifier, waiting for the pending verifications to be
+// delivered or dropped. The pool is shut down if the verifier owns it.
+func (avv *AsyncVoteVerifier) Quit() {
+	// protect against double-quits.
+	select {
+	case <-avv.done:
+		return
+	default:
+	}
+	close(avv.done)
+
+	avv.ctxCancel()
+	avv.wg.Wait()
+	if avv.backlogExecPool.GetOwner() == avv {
+		avv.backlogExecPool.Shutdown()
+	}
+	close(avv.execpoolOut)
+	<-avv.workerWaitCh
+}
+
+// Parallelism returns the number of verifications run concurrently.
+func (avv *AsyncVoteVerifier) Parallelism() int {
+	return avv.backlogExecPool.GetParallelism()
+}
@@ -0,0 +1,97 @@
+package algo
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"testing"
+
+	"github.com/awesome-chain/Xchain/common"
+	"github.com/awesome-chain/Xchain/crypto"
+)
+
+func TestAsyncVoteVerifier(t *testing.T) {
+	key, _ := crypto.HexToECDSA("b71c71a67e1177ad4e901695e1b4b9ee17ae16c6668d313eac2f96dbcda3f291")
+	other, _ := crypto.GenerateKey()
+	engine, chain := newTestParticipants(t, key, other)
+	defer chain.Stop()
+
+	verifier := MakeAsyncVoteVerifier(nil)
+	defer verifier.Quit()
+
+	value := ProposalValue{BlockDigest: common.HexToHash("0x01")}
+	valid := signVote(t, engine, chain, key, soft, value)
+	invalid := signVote(t, engine, chain, other, soft, value)
+	invalid.R.Proposal.BlockDigest = common.HexToHash("0x02")
+
+	out := make(chan asyncVerifyVoteResponse, 2)
+	if err := verifier.verifyVote(context.Background(), engine, chain, valid, 1, message{UnauthenticatedVote: valid}, out); err != nil {
+		t.Fatalf("failed to queue vote: %v", err)
+	}
+	if err := verifier.verifyVote(context.Background(), engine, chain, invalid, 2, message{}, out); err != nil {
+		t.Fatalf("failed to queue vote: %v", err)
+	}
+	for i := 0; i < 2; i++ {
+		res := <-out
+		switch res.index {
+		case 1:
+			if res.err != nil {
+				t.Fatalf("failed to verify vote: %v", res.err)
+			}
+			if res.v.R.Sender != crypto.PubkeyToAddress(key.PublicKey) || HashObj(res.message.UnauthenticatedVote) != HashObj(valid) {
+				t.Fatalf("verified vote mismatch: have %v", res.v)
+			}
+		case 2:
+			if res.err == nil {
+				t.Fatalf("tampered vote verified")
+			}
+		default:
+			t.Fatalf("unexpected response index %d", res.index)
+		}
+	}
+	// Verifications of expired contexts are cancelled
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if err := verifier.verifyVote(ctx, engine, chain, valid, 0, message{}, out); err == nil {
+		if res := <-out; !res.cancelled {
+			t.Fatalf("expired verification not cancelled: %v", res)
+		}
+	}
+}
+
+func TestBundleVerifyAsync(t *testing.T) {
+	key, _ := crypto.HexToECDSA("b71c71a67e1177ad4e901695e1b4b9ee17ae16c6668d313eac2f96dbcda3f291")
+	other, _ := crypto.GenerateKey()
+	engine, chain := newTestParticipants(t, key, other)
+	defer chain.Stop()
+
+	verifier := MakeAsyncVoteVerifier(nil)
+	defer verifier.Quit()
+
+	value := ProposalValue{BlockDigest: common.HexToHash("0x01")}
+	var votes []vote
+	for _, k := range []*ecdsa.PrivateKey{key, other} {
+		v, err := signVote(t, engine, chain, k, soft, value).verify(engine, chain)
+		if err != nil {
+			t.Fatalf("failed to verify vote: %v", err)
+		}
+		votes = append(votes, v)
+	}
+	b := makeBundle(value, votes, nil)
+	verified, err := b.U.verifyAsync(context.Background(), engine, chain, verifier)()
+	if err != nil {
+		t.Fatalf("failed to verify bundle: %v", err)
+	}
+	for i := range votes {
+		if verified.Votes[i].R.Sender != votes[i].R.Sender {
+			t.Fatalf("vote %d: sender mismatch: have %x, want %x", i, verified.Votes[i].R.Sender, votes[i].R.Sender)
+		}
+	}
+	if _, err := makeBundle(value, votes[:1], nil).U.verifyAsync(context.Background(), engine, chain, verifier)(); err != errBundleBelowThreshold {
+		t.Fatalf("partial bundle error mismatch: have %v, want %v", err, errBundleBelowThreshold)
+	}
+	// A tampered vote invalidates the whole bundle
+	b.U.Votes[1].Sig = b.U.Votes[0].Sig
+	if _, err := b.U.verifyAsync(context.Background(), engine, chain, verifier)(); err == nil {
+		t.Fatalf("bundle with invalid vote verified")
+	}
+}
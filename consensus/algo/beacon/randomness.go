@@ -0,0 +1,86 @@
+package beacon
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+
+	"github.com/awesome-chain/Xchain/common"
+	"github.com/awesome-chain/Xchain/common/hexutil"
+	"github.com/awesome-chain/Xchain/core/types"
+	"github.com/awesome-chain/Xchain/crypto"
+	"github.com/awesome-chain/Xchain/crypto/vrf"
+)
+
+// Randomness is the randomness beacon entry of a block: its seed together with
+// the VRF proof and the inputs needed to verify the seed derivation.
+type Randomness struct {
+	Number    hexutil.Uint64 `json:"number"`
+	Hash      common.Hash    `json:"hash"`
+	Seed      common.Hash    `json:"seed"`
+	Proposer  common.Address `json:"proposer"`  // Stake account the block was proposed for
+	Signer    common.Address `json:"signer"`    // Participation key which signed the block and evaluated the VRF
+	PublicKey hexutil.Bytes  `json:"publicKey"` // Uncompressed public key of the signer
+	Period    hexutil.Uint64 `json:"period"`    // Agreement period the seed was derived in, only period 0 evaluates the VRF
+	Proof     hexutil.Bytes  `json:"proof"`     // VRF proof of the signer over the lookback seed
+	Output    common.Hash    `json:"output"`    // VRF output of the signer over the lookback seed
+
+	LookbackNumber hexutil.Uint64 `json:"lookbackNumber"` // Block whose seed the seed is derived from
+	LookbackHash   common.Hash    `json:"lookbackHash"`
+	LookbackSeed   common.Hash    `json:"lookbackSeed"`
+}
+
+// Verify checks that the seed of the entry is derived from the lookback seed by
+// the VRF of the signer. It does not check that the entry matches the chain,
+// see VerifyHeaders.
+func (r *Randomness) Verify() error {
+	pubkey := crypto.ToECDSAPub(r.PublicKey)
+	if pubkey == nil || pubkey.X == nil {
+		return errors.New("invalid signer public key")
+	}
+	if signer := crypto.PubkeyToAddress(*pubkey); signer != r.Signer {
+		return fmt.Errorf("signer mismatch: have %x, public key of %x", r.Signer, signer)
+	}
+	if r.Period == 0 {
+		output, err := (&vrf.PublicKey{PublicKey: pubkey}).ProofToHash(r.LookbackSeed[:], r.Proof)
+		if err != nil {
+			return fmt.Errorf("invalid seed proof: %v", err)
+		}
+		if common.Hash(output) != r.Output {
+			return fmt.Errorf("VRF output mismatch: have %x, want %x", r.Output, output)
+		}
+	}
+	return VerifySeed(common.Seed(r.LookbackSeed), common.Seed(r.Seed), pubkey, uint64(r.Period), r.Proof)
+}
+
+// VerifyHeaders checks that the entry matches the header of its block and the
+// one of its lookback block, as obtained from a trusted source.
+func (r *Randomness) VerifyHeaders(header, lookback *types.Header) error {
+	if header.Hash() != r.Hash || header.Number.Uint64() != uint64(r.Number) {
+		return fmt.Errorf("header mismatch: have %d [%x], want %d [%x]", header.Number, header.Hash(), r.Number, r.Hash)
+	}
+	if lookback.Hash() != r.LookbackHash || lookback.Number.Uint64() != uint64(r.LookbackNumber) {
+		return fmt.Errorf("lookback header mismatch: have %d [%x], want %d [%x]", lookback.Number, lookback.Hash(), r.LookbackNumber, r.LookbackHash)
+	}
+	if common.Hash(header.Seed) != r.Seed || common.Hash(lookback.Seed) != r.LookbackSeed {
+		return errors.New("seed mismatch")
+	}
+	if header.Coinbase != r.Proposer {
+		return fmt.Errorf("proposer mismatch: have %x, want %x", r.Proposer, header.Coinbase)
+	}
+	pubkey, err := sigToPub(header)
+	if err != nil {
+		return err
+	}
+	if signer := crypto.PubkeyToAddress(*pubkey); signer != r.Signer {
+		return fmt.Errorf("signer mismatch: have %x, want %x", r.Signer, signer)
+	}
+	extra, err := decodeHeaderSeed(header)
+	if err != nil {
+		return err
+	}
+	if extra.Period != uint64(r.Period) || !bytes.Equal(extra.SeedProof, r.Proof) {
+		return errors.New("seed proof mismatch")
+	}
+	return nil
+}
@@ -0,0 +1,168 @@
+// Package beacon implements the randomness beacon of the algo consensus engine:
+// the derivation of the block seeds and the verification of their proofs. It is
+// kept free of the engine itself so that clients can verify the seeds.
+package beacon
+
+import (
+	"crypto/ecdsa"
+	"crypto/sha512"
+	"errors"
+	"fmt"
+
+	"github.com/awesome-chain/Xchain/common"
+	"github.com/awesome-chain/Xchain/consensus/algo/protocol"
+	"github.com/awesome-chain/Xchain/core/types"
+	"github.com/awesome-chain/Xchain/crypto"
+	"github.com/awesome-chain/Xchain/crypto/sha3"
+	"github.com/awesome-chain/Xchain/crypto/vrf"
+	"github.com/awesome-chain/Xchain/rlp"
+)
+
+var (
+	// errMissingSignature is returned if a header doesn't carry a 65 byte
+	// signature.
+	errMissingSignature = errors.New("65 byte header signature missing")
+
+	// errMissingVanity is returned if a header's extra-data section is shorter
+	// than the 32 byte vanity prefix.
+	errMissingVanity = errors.New("extra-data 32 byte vanity prefix missing")
+
+	emptyOutput vrf.Output
+)
+
+const (
+	extraVanity = 32 // Fixed number of extra-data prefix bytes reserved for signer vanity
+	sigLength   = 65 // Fixed number of bytes of the secp256k1 signature in header.Sig
+)
+
+// ProposerSeed is the input to the seed derivation rerandomized by the VRF
+// output of the proposer.
+type ProposerSeed struct {
+	Addr common.Address
+	VRF  vrf.Output
+}
+
+// ToBeHashed returns the domain separated encoding of the proposer seed.
+func (s *ProposerSeed) ToBeHashed() (protocol.HashID, []byte, error) {
+	bs, err := rlp.EncodeToBytes(s)
+	if err != nil {
+		return "", nil, err
+	}
+	return protocol.ProposerSeed, bs, nil
+}
+
+// SeedInput is the input to the seed rerandomization.
+type SeedInput struct {
+	Alpha   common.Hash
+	History common.Hash
+}
+
+// ToBeHashed returns the domain separated encoding of the seed input.
+func (i *SeedInput) ToBeHashed() (protocol.HashID, []byte, error) {
+	bs, err := rlp.EncodeToBytes(i)
+	if err != nil {
+		return "", nil, err
+	}
+	return protocol.ProposerSeed, bs, nil
+}
+
+// hashable is an object with a domain separated encoding.
+type hashable interface {
+	ToBeHashed() (protocol.HashID, []byte, error)
+}
+
+// hashObj computes the SHA512/256 hash of the domain separated encoding of an
+// object.
+func hashObj(h hashable) common.Hash {
+	id, data, _ := h.ToBeHashed()
+	return sha512.Sum512_256(append([]byte(id), data...))
+}
+
+// DeriveSeed derives the seed of a block from the seed of its lookback block.
+// In period 0 the seed is rerandomized by the VRF output of the proposer over
+// the lookback seed, in later periods it only depends on the lookback seed.
+func DeriveSeed(prevSeed common.Seed, proposer common.Address, output vrf.Output, period uint64) common.Seed {
+	alpha := common.Hash(prevSeed)
+	if period == 0 {
+		alpha = hashObj(&ProposerSeed{Addr: proposer, VRF: output})
+	}
+	return common.Seed(hashObj(&SeedInput{Alpha: alpha}))
+}
+
+// VerifySeed checks that seed is correctly derived from prevSeed by the proposer
+// owning pubKey in the given period, using the proposer's VRF proof.
+func VerifySeed(prevSeed common.Seed, seed common.Seed, pubKey *ecdsa.PublicKey, period uint64, proof []byte) error {
+	var output vrf.Output
+	if period == 0 {
+		if pubKey == nil {
+			return fmt.Errorf("verify failed: missing proposer key")
+		}
+		var err error
+		if output, err = (&vrf.PublicKey{PublicKey: pubKey}).ProofToHash(prevSeed[:], proof); err != nil {
+			return fmt.Errorf("verify proof error: [%v]", err)
+		}
+		if output == emptyOutput {
+			return fmt.Errorf("verify failed: [%v]", output)
+		}
+	}
+	var proposer common.Address
+	if pubKey != nil {
+		proposer = crypto.PubkeyToAddress(*pubKey)
+	}
+	if want := DeriveSeed(prevSeed, proposer, output, period); seed != want {
+		return fmt.Errorf("payload seed malformed (%v != %v)", want, seed)
+	}
+	return nil
+}
+
+// HashHeader returns the hash which is signed by the proposer into header.Sig.
+// It is the hash of the entire header apart from the signature itself and the
+// unused proof-of-work fields.
+func HashHeader(h *types.Header) (hash common.Hash) {
+	hw := sha3.NewKeccak256()
+	rlp.Encode(hw, []interface{}{
+		h.ParentHash,
+		h.UncleHash,
+		h.Coinbase,
+		h.Root,
+		h.TxHash,
+		h.ReceiptHash,
+		h.Seed,
+		h.Bloom,
+		h.Difficulty,
+		h.Number,
+		h.GasLimit,
+		h.GasUsed,
+		h.Time,
+		h.Extra,
+	})
+	hw.Sum(hash[:0])
+	return hash
+}
+
+// sigToPub recovers the public key of the proposer from a signed header.
+func sigToPub(header *types.Header) (*ecdsa.PublicKey, error) {
+	if len(header.Sig) != sigLength {
+		return nil, errMissingSignature
+	}
+	return crypto.SigToPub(HashHeader(header).Bytes(), header.Sig)
+}
+
+// headerSeed is the leading part of the consensus fields in the extra-data of
+// an algo header, which carries the seed proof of the block.
+type headerSeed struct {
+	Period    uint64
+	SeedProof []byte
+	Rest      []rlp.RawValue `rlp:"tail"`
+}
+
+// decodeHeaderSeed decodes the seed proof stored in the extra-data of a header
+// after the vanity prefix.
+func decodeHeaderSeed(header *types.Header) (headerSeed, error) {
+	var seed headerSeed
+	if len(header.Extra) < extraVanity {
+		return seed, errMissingVanity
+	}
+	err := rlp.DecodeBytes(header.Extra[extraVanity:], &seed)
+	return seed, err
+}
@@ -0,0 +1,215 @@
+package algo
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/awesome-chain/Xchain/common"
+	"github.com/awesome-chain/Xchain/consensus/algo/committee"
+	"github.com/awesome-chain/Xchain/consensus/algo/protocol"
+	"github.com/awesome-chain/Xchain/rlp"
+)
+
+var (
+	// errBundleDuplicateSender is returned if a sender appears twice in a bundle.
+	errBundleDuplicateSender = errors.New("bundle contains duplicate sender")
+
+	// errBundleBelowThreshold is returned if the weight of a bundle does not
+	// reach the threshold of its step.
+	errBundleBelowThreshold = errors.New("bundle does not reach threshold")
+)
+
+// voteAuthenticator omits the round, period, step and proposal-value of a vote,
+// which are shared by all the votes of a bundle.
+type voteAuthenticator struct {
+	Sender common.Address
+	Cred   committee.UnauthenticatedCredential
+	Sig    []byte
+}
+
+// unauthenticatedBundle is a bundle which has not yet been verified.
+type unauthenticatedBundle struct {
+	Round    uint64
+	Period   uint64
+	Step     uint64
+	Proposal ProposalValue
+
+	Votes             []voteAuthenticator
+	EquivocationVotes []unauthenticatedEquivocationVote
+}
+
+// ToBeHashed implements the Hashable interface.
+func (ub unauthenticatedBundle) ToBeHashed() (protocol.HashID, []byte, error) {
+	bs, err := rlp.EncodeToBytes(&ub)
+	if err != nil {
+		return "", nil, err
+	}
+	return protocol.VoteBundle, bs, nil
+}
+
+// bundle is a set of votes, all from the same round, period, and step, and
+// from distinct senders, that reaches quorum.
+//
+// It also include equivocation pairs -- pairs of votes where someone maliciously
+// voted for two different values -- as these count as votes for *any* value.
+type bundle struct {
+	U unauthenticatedBundle
+
+	Votes             []vote
+	EquivocationVotes []equivocationVote
+}
+
+// makeBundle assembles a bundle out of votes for a proposal-value and the
+// equivocation votes of the same step.
+func makeBundle(proposal ProposalValue, votes []vote, equivocations []equivocationVote) bundle {
+	if len(votes) == 0 {
+		panic("makeBundle: no votes present in bundle")
+	}
+	rv := votes[0].R
+	ub := unauthenticatedBundle{
+		Round:    rv.Round,
+		Period:   rv.Period,
+		Step:     rv.Step,
+		Proposal: proposal,
+	}
+	for _, v := range votes {
+		ub.Votes = append(ub.Votes, voteAuthenticator{Sender: v.R.Sender, Cred: v.Cred.UnauthenticatedCredential, Sig: v.Sig})
+	}
+	for _, ev := range equivocations {
+		ub.EquivocationVotes = append(ub.EquivocationVotes, ev.u())
+	}
+	return bundle{U: ub, Votes: votes, EquivocationVotes: equivocations}
+}
+
+// weight returns the total weight of the votes of the bundle.
+func (b bundle) weight() (weight uint64) {
+	for _, v := range b.Votes {
+		weight += v.Cred.Weight
+	}
+	for _, ev := range b.EquivocationVotes {
+		weight += ev.Cred.Weight
+	}
+	return weight
+}
+
+// votes expands a bundle into the votes it is made of, checking that all of
+// them are from distinct senders and from the step of the bundle.
+func (ub unauthenticatedBundle) votes() ([]unauthenticatedVote, error) {
+	if step(ub.Step) == propose {
+		return nil, fmt.Errorf("unauthenticatedBundle.verify: bundle for propose step")
+	}
+	senders := make(map[common.Address]struct{})
+	uvs := make([]unauthenticatedVote, 0, len(ub.Votes))
+	for _, va := range ub.Votes {
+		if _, ok := senders[va.Sender]; ok {
+			return nil, errBundleDuplicateSender
+		}
+		senders[va.Sender] = struct{}{}
+
+		uvs = append(uvs, unauthenticatedVote{
+			R:    rawVote{Sender: va.Sender, Round: ub.Round, Period: ub.Period, Step: ub.Step, Proposal: ub.Proposal},
+			Cred: va.Cred,
+			Sig:  va.Sig,
+		})
+	}
+	for _, uev := range ub.EquivocationVotes {
+		if _, ok := senders[uev.Sender]; ok {
+			return nil, errBundleDuplicateSender
+		}
+		senders[uev.Sender] = struct{}{}
+
+		if uev.Round != ub.Round || uev.Period != ub.Period || uev.Step != ub.Step {
+			return nil, fmt.Errorf("unauthenticatedBundle.verify: equivocation vote from other step")
+		}
+	}
+	return uvs, nil
+}
+
+// verify checks that a bundle is valid: all its votes are valid votes for its
+// proposal-value from distinct senders, and their weight reaches the threshold
+// of the bundle's step.
+func (ub unauthenticatedBundle) verify(a *Algorand, l Ledger) (bundle, error) {
+	uvs, err := ub.votes()
+	if err != nil {
+		return bundle{}, err
+	}
+	b := bundle{U: ub}
+	for _, uv := range uvs {
+		v, err := uv.verify(a, l)
+		if err != nil {
+			return bundle{}, fmt.Errorf("unauthenticatedBundle.verify: invalid vote: %v", err)
+		}
+		b.Votes = append(b.Votes, v)
+	}
+	for _, uev := range ub.EquivocationVotes {
+		ev, err := uev.verify(a, l)
+		if err != nil {
+			return bundle{}, fmt.Errorf("unauthenticatedBundle.verify: invalid equivocation vote: %v", err)
+		}
+		b.EquivocationVotes = append(b.EquivocationVotes, ev)
+	}
+	params, err := a.consensusParams(l, ub.Round)
+	if err != nil {
+		return bundle{}, err
+	}
+	if b.weight() < params.threshold(step(ub.Step)) {
+		return bundle{}, errBundleBelowThreshold
+	}
+	return b, nil
+}
+
+// verifyAsync is the parallel version of verify: it queues all the votes of a
+// bundle on the verifier and returns a function collecting the results. The
+// collection is aborted if the context expires.
+func (ub unauthenticatedBundle) verifyAsync(ctx context.Context, a *Algorand, l Ledger, avv *AsyncVoteVerifier) func() (bundle, error) {
+	uvs, err := ub.votes()
+	if err != nil {
+		return func() (bundle, error) { return bundle{}, err }
+	}
+	results := make(chan asyncVerifyVoteResponse, len(uvs)+len(ub.EquivocationVotes))
+	for i, uv := range uvs {
+		if err := avv.verifyVote(ctx, a, l, uv, i, message{}, results); err != nil {
+			return func() (bundle, error) { return bundle{}, err }
+		}
+	}
+	for i, uev := range ub.EquivocationVotes {
+		if err := avv.verifyEquivocVote(ctx, a, l, uev, i, results); err != nil {
+			return func() (bundle, error) { return bundle{}, err }
+		}
+	}
+	return func() (bundle, error) {
+		b := bundle{
+			U:                 ub,
+			Votes:             make([]vote, len(uvs)),
+			EquivocationVotes: make([]equivocationVote, len(ub.EquivocationVotes)),
+		}
+		for i := 0; i < cap(results); i++ {
+			var res asyncVerifyVoteResponse
+			select {
+			case res = <-results:
+			case <-ctx.Done():
+				return bundle{}, ctx.Err()
+			}
+			if res.err != nil {
+				if res.req.uev != nil {
+					return bundle{}, fmt.Errorf("unauthenticatedBundle.verify: invalid equivocation vote: %v", res.err)
+				}
+				return bundle{}, fmt.Errorf("unauthenticatedBundle.verify: invalid vote: %v", res.err)
+			}
+			if res.req.uev != nil {
+				b.EquivocationVotes[res.index] = res.ev
+			} else {
+				b.Votes[res.index] = res.v
+			}
+		}
+		params, err := a.consensusParams(l, ub.Round)
+		if err != nil {
+			return bundle{}, err
+		}
+		if b.weight() < params.threshold(step(ub.Step)) {
+			return bundle{}, errBundleBelowThreshold
+		}
+		return b, nil
+	}
+}
@@ -0,0 +1,255 @@
+package algo
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/awesome-chain/Xchain/common"
+	"github.com/awesome-chain/Xchain/core/rawdb"
+	"github.com/awesome-chain/Xchain/core/types"
+	"github.com/awesome-chain/Xchain/ethdb"
+	"github.com/awesome-chain/Xchain/log"
+	"github.com/awesome-chain/Xchain/p2p"
+	"github.com/awesome-chain/Xchain/rlp"
+)
+
+const (
+	maxCertifiedBlocksFetch = 64               // Amount of certified blocks to request at once
+	maxCertifiedBlocksServe = 128              // Amount of certified blocks to serve at once
+	catchupRequestTimeout   = 10 * time.Second // Time allowance for a peer to deliver requested blocks
+)
+
+var (
+	// errCertificateStep is returned if a certificate is not made of cert-votes.
+	errCertificateStep = errors.New("certificate is not a cert bundle")
+
+	// errCertificateMismatch is returned if a certificate is for another block.
+	errCertificateMismatch = errors.New("certificate is for another block")
+)
+
+// certifiedBlock is a block together with the certificate it was committed by.
+type certifiedBlock struct {
+	Block       *types.Block
+	Certificate unauthenticatedBundle
+}
+
+// getCertifiedBlocksData is the network packet requesting certified blocks.
+type getCertifiedBlocksData struct {
+	Origin uint64 // Number of the first block to retrieve
+	Amount uint64 // Maximum number of blocks to retrieve
+}
+
+// certifiedBlocksDelivery is a batch of certified blocks received from a peer.
+type certifiedBlocksDelivery struct {
+	peer   string
+	blocks []certifiedBlock
+}
+
+// readCertificate retrieves the certificate of a block, if it is known.
+func readCertificate(db ethdb.Database, hash common.Hash, number uint64) *unauthenticatedBundle {
+	data := rawdb.ReadCertificateRLP(db, hash, number)
+	if len(data) == 0 {
+		return nil
+	}
+	cert := new(unauthenticatedBundle)
+	if err := rlp.DecodeBytes(data, cert); err != nil {
+		log.Error("Invalid block certificate RLP", "hash", hash, "err", err)
+		return nil
+	}
+	return cert
+}
+
+// writeCertificate stores the certificate of a block.
+func writeCertificate(db ethdb.Database, hash common.Hash, number uint64, cert unauthenticatedBundle) {
+	data, err := rlp.EncodeToBytes(cert)
+	if err != nil {
+		log.Crit("Failed to RLP encode block certificate", "err", err)
+	}
+	rawdb.WriteCertificateRLP(db, hash, number, data)
+}
+
+// checkCertificate checks that a certificate is a cert bundle for the given
+// block, without verifying its votes.
+func checkCertificate(block *types.Block, ub unauthenticatedBundle) error {
+	if step(ub.Step) != cert {
+		return errCertificateStep
+	}
+	if ub.Round != block.NumberU64() || ub.Proposal.BlockDigest != block.Hash() {
+		return errCertificateMismatch
+	}
+	return nil
+}
+
+// catchup synchronises a node which fell behind the network. Instead of running
+// the agreement of the missed rounds, it fetches the committed blocks together
+// with their certificates, and commits them once the certificate is verified
+// against the sortition of its round.
+type catchup struct {
+	service *Service
+
+	wake       chan struct{}                // Notification channel of peers being ahead
+	deliveries chan certifiedBlocksDelivery // Channel of certified blocks received
+
+	quit chan struct{}
+	done chan struct{}
+}
+
+// newCatchup creates the catchup service of an agreement service.
+func newCatchup(service *Service) *catchup {
+	return &catchup{
+		service:    service,
+		wake:       make(chan struct{}, 1),
+		deliveries: make(chan certifiedBlocksDelivery),
+		quit:       make(chan struct{}),
+		done:       make(chan struct{}),
+	}
+}
+
+// start starts the catchup loop.
+func (c *catchup) start() {
+	go c.loop()
+}
+
+// stop terminates the catchup loop and waits for it to finish.
+func (c *catchup) stop() {
+	close(c.quit)
+	<-c.done
+}
+
+// notify signals that some peer might be ahead of the local chain.
+func (c *catchup) notify() {
+	select {
+	case c.wake <- struct{}{}:
+	default:
+	}
+}
+
+// deliver hands a batch of certified blocks received from a peer over to the
+// catchup loop. Unrequested deliveries are dropped by the loop.
+func (c *catchup) deliver(peer string, blocks []certifiedBlock) {
+	select {
+	case c.deliveries <- certifiedBlocksDelivery{peer: peer, blocks: blocks}:
+	case <-c.quit:
+	}
+}
+
+// loop synchronises with the best peer whenever it is ahead of the local chain.
+func (c *catchup) loop() {
+	defer close(c.done)
+
+	for {
+		select {
+		case <-c.wake:
+			for c.sync() {
+			}
+		case <-c.deliveries:
+			// Late delivery of a timed out request, drop it
+		case <-c.quit:
+			return
+		}
+	}
+}
+
+// sync fetches and commits a single batch of certified blocks from the best
+// peer, returning whether it should be called again.
+func (c *catchup) sync() bool {
+	var (
+		ledger = c.service.ledger
+		head   = ledger.CurrentHeader().Number.Uint64()
+		peer   = c.service.gossip.peers.BestPeer()
+	)
+	if peer == nil || peer.Head() <= head {
+		return false
+	}
+	amount := peer.Head() - head
+	if amount > maxCertifiedBlocksFetch {
+		amount = maxCertifiedBlocksFetch
+	}
+	if err := peer.RequestCertifiedBlocks(head+1, amount); err != nil {
+		return false
+	}
+	timeout := time.NewTimer(catchupRequestTimeout)
+	defer timeout.Stop()
+
+	for {
+		select {
+		case delivery := <-c.deliveries:
+			if delivery.peer != peer.id {
+				continue
+			}
+			committed, err := c.commit(head+1, delivery.blocks)
+			if committed > 0 {
+				log.Info("Caught up with certified blocks", "peer", peer.id, "count", committed, "number", head+committed)
+				c.service.deliver(roundInterruptionEvent{Round: round(head + committed + 1)})
+			}
+			if err != nil || committed == 0 {
+				peer.Log().Debug("Certified blocks delivery failed", "err", err)
+				peer.Disconnect(p2p.DiscUselessPeer)
+				return false
+			}
+			return true
+
+		case <-timeout.C:
+			peer.Log().Debug("Certified blocks request timed out")
+			peer.Disconnect(p2p.DiscUselessPeer)
+			return false
+
+		case <-c.quit:
+			return false
+		}
+	}
+}
+
+// commit verifies and commits a batch of certified blocks starting at the given
+// number, returning the number of blocks committed.
+func (c *catchup) commit(number uint64, blocks []certifiedBlock) (uint64, error) {
+	s := c.service
+	for i, cb := range blocks {
+		if cb.Block == nil {
+			return uint64(i), errCertificateMismatch
+		}
+		if cb.Block.NumberU64() != number+uint64(i) {
+			return uint64(i), fmt.Errorf("non contiguous block %d, want %d", cb.Block.NumberU64(), number+uint64(i))
+		}
+		if err := checkCertificate(cb.Block, cb.Certificate); err != nil {
+			return uint64(i), err
+		}
+		if _, err := cb.Certificate.verifyAsync(s.ctx, s.engine, s.ledger, s.verifier)(); err != nil {
+			return uint64(i), err
+		}
+		if err := s.ledger.EnsureBlock(cb.Block); err != nil {
+			return uint64(i), err
+		}
+		if s.db != nil {
+			writeCertificate(s.db, cb.Block.Hash(), cb.Block.NumberU64(), cb.Certificate)
+		}
+	}
+	return uint64(len(blocks)), nil
+}
+
+// certifiedBlocks retrieves a batch of consecutive blocks with their
+// certificates, stopping at the first block without one.
+func (c *catchup) certifiedBlocks(origin uint64, amount uint64) []certifiedBlock {
+	s := c.service
+	if s.db == nil {
+		return nil
+	}
+	if amount > maxCertifiedBlocksServe {
+		amount = maxCertifiedBlocksServe
+	}
+	var blocks []certifiedBlock
+	for number := origin; number < origin+amount; number++ {
+		header := s.ledger.GetHeaderByNumber(number)
+		if header == nil {
+			break
+		}
+		block := s.ledger.GetBlock(header.Hash(), number)
+		cert := readCertificate(s.db, header.Hash(), number)
+		if block == nil || cert == nil {
+			break
+		}
+		blocks = append(blocks, certifiedBlock{Block: block, Certificate: *cert})
+	}
+	return blocks
+}
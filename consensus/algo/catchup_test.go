@@ -0,0 +1,121 @@
+package algo
+
+import (
+	"testing"
+	"time"
+
+	"github.com/awesome-chain/Xchain/crypto"
+	"github.com/awesome-chain/Xchain/p2p"
+	"github.com/awesome-chain/Xchain/p2p/discover"
+)
+
+// connectGossip connects the agreement protocols of two services, returning
+// the function to tear the connection down.
+func connectGossip(a, b *Service) func() {
+	rwa, rwb := p2p.MsgPipe()
+
+	var ida, idb discover.NodeID
+	ida[0], idb[0] = 0x0a, 0x0b
+
+	go a.gossip.SubProtocols[0].Run(p2p.NewPeer(idb, "b", nil), rwa)
+	go b.gossip.SubProtocols[0].Run(p2p.NewPeer(ida, "a", nil), rwb)
+
+	return func() {
+		rwa.Close()
+		rwb.Close()
+	}
+}
+
+func TestCatchup(t *testing.T) {
+	key, _ := crypto.HexToECDSA("b71c71a67e1177ad4e901695e1b4b9ee17ae16c6668d313eac2f96dbcda3f291")
+
+	// Run the agreement on a participating node for a few rounds
+	engine, chain := newTestParticipants(t, key)
+	defer chain.Stop()
+	engine.Authorize(key)
+
+	clock := newTestClock()
+	factory := &testFactory{chain: chain, engine: engine, assembled: make(chan uint64, 16)}
+	service := NewService(engine, Parameters{Ledger: &testLedger{chain}, Factory: factory, Clock: clock})
+	service.Start()
+	defer service.Stop()
+
+	driveAgreement(t, chain, clock, factory, 5)
+	for i := uint64(1); i <= 5; i++ {
+		header := chain.GetHeaderByNumber(i)
+		if cert := readCertificate(engine.db, header.Hash(), i); cert == nil || checkCertificate(chain.GetBlock(header.Hash(), i), *cert) != nil {
+			t.Fatalf("block %d: missing or invalid certificate: %v", i, cert)
+		}
+	}
+	// Connect an observer without the participation key, it must catch up by
+	// means of the certificates alone
+	observerEngine, observerChain := newTestParticipants(t, key)
+	defer observerChain.Stop()
+
+	observer := NewService(observerEngine, Parameters{Ledger: &testLedger{observerChain}, Clock: newTestClock()})
+	observer.Start()
+	defer observer.Stop()
+
+	disconnect := connectGossip(service, observer)
+	defer disconnect()
+
+	deadline := time.After(10 * time.Second)
+	for observerChain.CurrentBlock().NumberU64() < 5 {
+		select {
+		case <-time.After(10 * time.Millisecond):
+		case <-deadline:
+			t.Fatalf("catchup stalled at block %d", observerChain.CurrentBlock().NumberU64())
+		}
+	}
+	for i := uint64(1); i <= 5; i++ {
+		if have, want := observerChain.GetHeaderByNumber(i).Hash(), chain.GetHeaderByNumber(i).Hash(); have != want {
+			t.Fatalf("block %d: hash mismatch: have %x, want %x", i, have, want)
+		}
+		if readCertificate(observerEngine.db, chain.GetHeaderByNumber(i).Hash(), i) == nil {
+			t.Fatalf("block %d: certificate not stored", i)
+		}
+	}
+}
+
+func TestCatchupInvalidCertificate(t *testing.T) {
+	key, _ := crypto.HexToECDSA("b71c71a67e1177ad4e901695e1b4b9ee17ae16c6668d313eac2f96dbcda3f291")
+	engine, chain := newTestParticipants(t, key)
+	defer chain.Stop()
+	engine.Authorize(key)
+
+	clock := newTestClock()
+	factory := &testFactory{chain: chain, engine: engine, assembled: make(chan uint64, 16)}
+	service := NewService(engine, Parameters{Ledger: &testLedger{chain}, Factory: factory, Clock: clock})
+	service.Start()
+	driveAgreement(t, chain, clock, factory, 2)
+	service.Stop()
+
+	blocks := service.catchup.certifiedBlocks(1, 2)
+	if len(blocks) != 2 {
+		t.Fatalf("certified blocks mismatch: have %d, want 2", len(blocks))
+	}
+	observerEngine, observerChain := newTestParticipants(t, key)
+	defer observerChain.Stop()
+
+	observer := NewService(observerEngine, Parameters{Ledger: &testLedger{observerChain}, Clock: newTestClock()})
+	observer.Start()
+	defer observer.Stop()
+
+	// Certificates of other blocks and forged votes must be rejected
+	swapped := []certifiedBlock{{Block: blocks[0].Block, Certificate: blocks[1].Certificate}}
+	if n, err := observer.catchup.commit(1, swapped); n != 0 || err != errCertificateMismatch {
+		t.Fatalf("swapped certificate: have (%d, %v), want (0, %v)", n, err, errCertificateMismatch)
+	}
+	forged := []certifiedBlock{blocks[0], {Block: blocks[1].Block, Certificate: blocks[1].Certificate}}
+	forged[1].Certificate.Votes = append([]voteAuthenticator{}, forged[1].Certificate.Votes...)
+	forged[1].Certificate.Votes[0].Sig = forged[0].Certificate.Votes[0].Sig
+	if n, err := observer.catchup.commit(1, forged); n != 1 || err == nil {
+		t.Fatalf("forged certificate: have (%d, %v), want (1, error)", n, err)
+	}
+	if head := observerChain.CurrentBlock().NumberU64(); head != 1 {
+		t.Fatalf("head mismatch: have %d, want 1", head)
+	}
+	if _, err := observer.catchup.commit(3, blocks[1:]); err == nil {
+		t.Fatalf("non contiguous block committed")
+	}
+}
@@ -0,0 +1,54 @@
+package algo
+
+import (
+	"sync"
+	"time"
+)
+
+// Clock provides timeouts relative to the start of the current period. It is
+// injected into the agreement service so that tests can drive the protocol
+// through any number of rounds without real sleeps.
+type Clock interface {
+	// Zero returns a new Clock whose zero is the current time.
+	Zero() Clock
+
+	// TimeoutAt returns a channel that fires once delta has elapsed since
+	// the zero of the clock. Repeated calls with the same delta return the
+	// same channel.
+	TimeoutAt(delta time.Duration) <-chan time.Time
+}
+
+// monotonicClock is the Clock backed by the system time.
+type monotonicClock struct {
+	zero time.Time
+
+	lock     sync.Mutex
+	timeouts map[time.Duration]<-chan time.Time
+}
+
+// MakeMonotonicClock creates a Clock backed by the system time, with its zero
+// set to the given time.
+func MakeMonotonicClock(zero time.Time) Clock {
+	return &monotonicClock{
+		zero:     zero,
+		timeouts: make(map[time.Duration]<-chan time.Time),
+	}
+}
+
+// Zero implements Clock.
+func (m *monotonicClock) Zero() Clock {
+	return MakeMonotonicClock(time.Now())
+}
+
+// TimeoutAt implements Clock.
+func (m *monotonicClock) TimeoutAt(delta time.Duration) <-chan time.Time {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+
+	if ch, ok := m.timeouts[delta]; ok {
+		return ch
+	}
+	ch := time.After(time.Until(m.zero.Add(delta)))
+	m.timeouts[delta] = ch
+	return ch
+}
@@ -0,0 +1,48 @@
+// Package committee implements the stake-weighted cryptographic sortition
+// which selects the proposers and voting committees of the algo agreement
+// protocol.
+package committee
+
+import (
+	"crypto/ecdsa"
+	"crypto/sha512"
+
+	"github.com/awesome-chain/Xchain/common"
+	"github.com/awesome-chain/Xchain/consensus/algo/protocol"
+)
+
+// A Selector deterministically defines a cryptographic sortition committee. It
+// contains both the input to the sortition VRF and the size of the sortition
+// committee.
+type Selector interface {
+	// ToBeHashed returns the domain separated input to the sortition VRF.
+	ToBeHashed() (protocol.HashID, []byte, error)
+
+	// CommitteeSize returns the expected size of the committee determined by
+	// this Selector.
+	CommitteeSize() uint64
+}
+
+// Membership encodes the parameters used to verify membership in a committee.
+type Membership struct {
+	Address    common.Address   // Account claiming the membership
+	PublicKey  *ecdsa.PublicKey // VRF key of the account
+	Stake      uint64           // Stake of the account at the seed lookback round
+	TotalStake uint64           // Total stake at the seed lookback round
+	Selector   Selector         // Committee the membership is claimed for
+}
+
+// hashRep returns the byte representation of a Selector which is fed into the
+// sortition VRF.
+func hashRep(sel Selector) ([]byte, error) {
+	hashid, data, err := sel.ToBeHashed()
+	if err != nil {
+		return nil, err
+	}
+	return append([]byte(hashid), data...), nil
+}
+
+// hash computes the SHASum512_256 hash of an array of bytes.
+func hash(data []byte) common.Hash {
+	return sha512.Sum512_256(data)
+}
@@ -0,0 +1,119 @@
+package committee
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"math/big"
+
+	"github.com/awesome-chain/Xchain/crypto/sortition"
+	"github.com/awesome-chain/Xchain/crypto/vrf"
+)
+
+var (
+	// ErrNotSelected is returned if a credential is valid but the sortition
+	// did not select any sub-user of the account.
+	ErrNotSelected = errors.New("credential has zero weight")
+
+	// errMissingKey is returned if a membership carries no VRF public key.
+	errMissingKey = errors.New("membership has no VRF key")
+)
+
+// An UnauthenticatedCredential is a Credential which has not yet been
+// authenticated against a Membership.
+type UnauthenticatedCredential struct {
+	Proof []byte
+}
+
+// A Credential represents a proof of committee membership.
+//
+// The multiplicity of this membership is specified in the Credential's weight.
+// The VRF output hash (with the owner's address hashed in) is also cached.
+//
+// Upgrades: whenever the sortition function changes, the code must be updated
+// to compute the appropriate sortition hash from the proof.
+type Credential struct {
+	UnauthenticatedCredential
+
+	Weight uint64
+	VrfOut vrf.Output
+}
+
+// MakeCredential creates a new unauthenticated Credential given some selector.
+func MakeCredential(sk *vrf.PrivateKey, sel Selector) (UnauthenticatedCredential, error) {
+	m, err := hashRep(sel)
+	if err != nil {
+		return UnauthenticatedCredential{}, err
+	}
+	_, proof := sk.Evaluate(m)
+	if proof == nil {
+		return UnauthenticatedCredential{}, fmt.Errorf("failed to evaluate VRF")
+	}
+	return UnauthenticatedCredential{Proof: proof}, nil
+}
+
+// Verify an unauthenticated Credential that was received from the network.
+//
+// Verify checks that the proof is valid for the claimed membership and runs
+// the sortition over the stake of the member. An error is returned if the
+// proof is invalid or if the member was not selected.
+func (cred UnauthenticatedCredential) Verify(m Membership) (Credential, error) {
+	if m.PublicKey == nil {
+		return Credential{}, errMissingKey
+	}
+	msg, err := hashRep(m.Selector)
+	if err != nil {
+		return Credential{}, err
+	}
+	pk := vrf.PublicKey{PublicKey: m.PublicKey}
+	out, err := pk.ProofToHash(msg, cred.Proof)
+	if err != nil {
+		return Credential{}, fmt.Errorf("credential has bad proof: %v", err)
+	}
+	var weight uint64
+	if m.Stake > 0 && m.TotalStake > 0 {
+		expectedSize := float64(m.Selector.CommitteeSize())
+		if expectedSize > float64(m.TotalStake) {
+			return Credential{}, fmt.Errorf("committee size %v exceeds total stake %v", expectedSize, m.TotalStake)
+		}
+		weight = sortition.Select(m.Stake, m.TotalStake, expectedSize, out)
+	}
+	if weight == 0 {
+		return Credential{}, ErrNotSelected
+	}
+	return Credential{UnauthenticatedCredential: cred, Weight: weight, VrfOut: out}, nil
+}
+
+// Selected returns whether this Credential was selected (i.e., if its weight is
+// greater than zero).
+func (cred Credential) Selected() bool {
+	return cred.Weight > 0
+}
+
+// LowestOutput is used for breaking ties when there are multiple proposals.
+// People will vote for the proposal whose credential has the lowest output.
+//
+// We hash the credential and interpret the output as a bigint.
+// For credentials with weight w > 1, we hash the credential w times (with
+// different counter values) and use the lowest output.
+func (cred Credential) LowestOutput() *big.Int {
+	var lowest *big.Int
+	var counter [8]byte
+	for i := uint64(0); i < cred.Weight; i++ {
+		binary.BigEndian.PutUint64(counter[:], i)
+		h := hash(append(append([]byte{}, cred.VrfOut[:]...), counter[:]...))
+		out := new(big.Int).SetBytes(h[:])
+		if lowest == nil || out.Cmp(lowest) < 0 {
+			lowest = out
+		}
+	}
+	return lowest
+}
+
+// Less returns true if this Credential is less than the other credential; false
+// otherwise (i.e., >=).
+//
+// Precondition: both credentials have nonzero weight
+func (cred Credential) Less(otherCred Credential) bool {
+	return cred.LowestOutput().Cmp(otherCred.LowestOutput()) < 0
+}
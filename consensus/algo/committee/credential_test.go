@@ -0,0 +1,83 @@
+package committee
+
+import (
+	"encoding/binary"
+	"testing"
+
+	"github.com/awesome-chain/Xchain/consensus/algo/protocol"
+	"github.com/awesome-chain/Xchain/crypto/vrf"
+)
+
+type testSelector struct {
+	round uint64
+	size  uint64
+}
+
+func (sel testSelector) ToBeHashed() (protocol.HashID, []byte, error) {
+	var bs [8]byte
+	binary.BigEndian.PutUint64(bs[:], sel.round)
+	return protocol.TestHashable, bs[:], nil
+}
+
+func (sel testSelector) CommitteeSize() uint64 {
+	return sel.size
+}
+
+func TestCredentialSelection(t *testing.T) {
+	const (
+		N          = 200
+		size       = 100
+		stake      = 1000
+		totalStake = 4000
+	)
+	sk, pk := vrf.GenerateKey()
+
+	var weight uint64
+	for i := 0; i < N; i++ {
+		m := Membership{PublicKey: pk.PublicKey, Stake: stake, TotalStake: totalStake, Selector: testSelector{round: uint64(i), size: size}}
+		ucred, err := MakeCredential(sk, m.Selector)
+		if err != nil {
+			t.Fatalf("failed to make credential: %v", err)
+		}
+		cred, err := ucred.Verify(m)
+		if err != nil && err != ErrNotSelected {
+			t.Fatalf("round %d: failed to verify credential: %v", i, err)
+		}
+		weight += cred.Weight
+	}
+	// A quarter of the stake should be selected a quarter of the committee size
+	expected := uint64(N * size * stake / totalStake)
+	if weight < expected*9/10 || weight > expected*11/10 {
+		t.Errorf("selected weight mismatch: have %d, want about %d", weight, expected)
+	}
+}
+
+func TestCredentialVerifyFailures(t *testing.T) {
+	sk, pk := vrf.GenerateKey()
+	_, other := vrf.GenerateKey()
+
+	sel := testSelector{round: 1, size: 100}
+	ucred, err := MakeCredential(sk, sel)
+	if err != nil {
+		t.Fatalf("failed to make credential: %v", err)
+	}
+	m := Membership{PublicKey: pk.PublicKey, Stake: 1000, TotalStake: 1000, Selector: sel}
+	if _, err := ucred.Verify(m); err != nil {
+		t.Fatalf("failed to verify credential: %v", err)
+	}
+	// A proof for another selector must be rejected
+	m.Selector = testSelector{round: 2, size: 100}
+	if _, err := ucred.Verify(m); err == nil {
+		t.Errorf("credential for wrong selector accepted")
+	}
+	// A proof for another key must be rejected
+	m.Selector, m.PublicKey = sel, other.PublicKey
+	if _, err := ucred.Verify(m); err == nil {
+		t.Errorf("credential for wrong key accepted")
+	}
+	// Without stake nobody is selected
+	m.PublicKey, m.Stake = pk.PublicKey, 0
+	if _, err := ucred.Verify(m); err != ErrNotSelected {
+		t.Errorf("error mismatch: have %v, want %v", err, ErrNotSelected)
+	}
+}
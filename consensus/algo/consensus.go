@@ -0,0 +1,230 @@
+package algo
+
+import (
+	"errors"
+	"time"
+
+	"github.com/awesome-chain/Xchain/consensus"
+	"github.com/awesome-chain/Xchain/consensus/algo/protocol"
+)
+
+// errUnsupportedProtocol is returned if a round runs a version of the consensus
+// protocol the node does not know the parameters of.
+var errUnsupportedProtocol = errors.New("unsupported consensus protocol")
+
+// ConsensusParams specifies the settings of a version of the agreement
+// protocol. The version of a round is recorded in the header of the previous
+// round, so the parameters can be tuned by an upgrade instead of a fork block.
+type ConsensusParams struct {
+	// ApprovedUpgrades are the versions proposers may vote to upgrade to.
+	ApprovedUpgrades map[protocol.ConsensusVersion]bool
+
+	// UpgradeVoteRounds is the number of rounds proposers may approve a
+	// proposed upgrade in, UpgradeThreshold the number of approvals needed
+	// within them. An approved upgrade activates UpgradeWaitRounds later.
+	UpgradeVoteRounds uint64
+	UpgradeThreshold  uint64
+	UpgradeWaitRounds uint64
+
+	// SeedLookback is the number of rounds between a seed and the seed it is
+	// derived from, which is also the round the sortition reads the stake at.
+	SeedLookback uint64
+
+	// Expected committee sizes and vote thresholds of the steps.
+	NumProposers           uint64
+	SoftCommitteeSize      uint64
+	SoftCommitteeThreshold uint64
+	CertCommitteeSize      uint64
+	CertCommitteeThreshold uint64
+	NextCommitteeSize      uint64
+	NextCommitteeThreshold uint64
+	LateCommitteeSize      uint64
+	LateCommitteeThreshold uint64
+	RedoCommitteeSize      uint64
+	RedoCommitteeThreshold uint64
+	DownCommitteeSize      uint64
+	DownCommitteeThreshold uint64
+
+	SmallLambda        time.Duration // Upper bound on the time to propagate a vote
+	BigLambda          time.Duration // Upper bound on the time to propagate a block
+	FastRecoveryLambda time.Duration // Interval of the fast partition recovery votes
+	AssemblyTime       time.Duration // Max amount of time to spend on assembling a proposal
+}
+
+// Consensus tracks the parameters of every known version of the protocol.
+var Consensus = make(map[protocol.ConsensusVersion]ConsensusParams)
+
+func init() {
+	initConsensusProtocols()
+}
+
+func initConsensusProtocols() {
+	v1 := ConsensusParams{
+		ApprovedUpgrades: map[protocol.ConsensusVersion]bool{},
+
+		UpgradeVoteRounds: 10000,
+		UpgradeThreshold:  9000,
+		UpgradeWaitRounds: 10000,
+
+		SeedLookback: 2,
+
+		NumProposers:           20,
+		SoftCommitteeSize:      2990,
+		SoftCommitteeThreshold: 2267,
+		CertCommitteeSize:      1500,
+		CertCommitteeThreshold: 1112,
+		NextCommitteeSize:      5000,
+		NextCommitteeThreshold: 3838,
+		LateCommitteeSize:      500,
+		LateCommitteeThreshold: 320,
+		RedoCommitteeSize:      2400,
+		RedoCommitteeThreshold: 1768,
+		DownCommitteeSize:      6000,
+		DownCommitteeThreshold: 4560,
+
+		SmallLambda:        2 * time.Second,
+		BigLambda:          15 * time.Second,
+		FastRecoveryLambda: 5 * time.Minute,
+		AssemblyTime:       250 * time.Millisecond,
+	}
+	Consensus[protocol.ConsensusV1] = v1
+
+	// ConsensusFuture is used to test features that are implemented but not
+	// yet released in a production protocol version.
+	vFuture := v1
+	vFuture.ApprovedUpgrades = map[protocol.ConsensusVersion]bool{}
+	Consensus[protocol.ConsensusFuture] = vFuture
+}
+
+// committeeSize returns the expected number of sub-users selected for a step.
+func (params ConsensusParams) committeeSize(s step) uint64 {
+	switch s {
+	case propose:
+		return params.NumProposers
+	case soft:
+		return params.SoftCommitteeSize
+	case cert:
+		return params.CertCommitteeSize
+	case late:
+		return params.LateCommitteeSize
+	case redo:
+		return params.RedoCommitteeSize
+	case down:
+		return params.DownCommitteeSize
+	default:
+		return params.NextCommitteeSize
+	}
+}
+
+// scaled returns the parameters with the committee sizes divided by the scale,
+// rounding up, and the vote thresholds keeping at least their share of the
+// scaled committees.
+func (params ConsensusParams) scaled(scale uint64) ConsensusParams {
+	if scale <= 1 {
+		return params
+	}
+	committee := func(size, threshold *uint64) {
+		scaled := (*size + scale - 1) / scale
+		*threshold = (*threshold*scaled + *size - 1) / *size
+		*size = scaled
+	}
+	params.NumProposers = (params.NumProposers + scale - 1) / scale
+	committee(&params.SoftCommitteeSize, &params.SoftCommitteeThreshold)
+	committee(&params.CertCommitteeSize, &params.CertCommitteeThreshold)
+	committee(&params.NextCommitteeSize, &params.NextCommitteeThreshold)
+	committee(&params.LateCommitteeSize, &params.LateCommitteeThreshold)
+	committee(&params.RedoCommitteeSize, &params.RedoCommitteeThreshold)
+	committee(&params.DownCommitteeSize, &params.DownCommitteeThreshold)
+	return params
+}
+
+// threshold returns the number of votes needed to reach a threshold in a step.
+func (params ConsensusParams) threshold(s step) uint64 {
+	switch s {
+	case propose:
+		panic("propose step has no threshold")
+	case soft:
+		return params.SoftCommitteeThreshold
+	case cert:
+		return params.CertCommitteeThreshold
+	case late:
+		return params.LateCommitteeThreshold
+	case redo:
+		return params.RedoCommitteeThreshold
+	case down:
+		return params.DownCommitteeThreshold
+	default:
+		return params.NextCommitteeThreshold
+	}
+}
+
+// filterTimeout is the offset from the start of a period at which the player
+// stops waiting for proposals and soft-votes the best one seen.
+func (params ConsensusParams) filterTimeout(p period) time.Duration {
+	return 2 * params.SmallLambda
+}
+
+// deadlineTimeout is the offset from the start of a period at which the player
+// gives up on certifying a value and starts next-voting.
+func (params ConsensusParams) deadlineTimeout() time.Duration {
+	return params.BigLambda + params.SmallLambda
+}
+
+// seedRound returns the round whose seed the seed of round rnd is derived from.
+func (params ConsensusParams) seedRound(rnd uint64) uint64 {
+	if rnd > params.SeedLookback {
+		return rnd - params.SeedLookback
+	}
+	return 0
+}
+
+// ConsensusVersionView is the version of the protocol of a round, as far as
+// the ledger can tell. Err is set if the version is not known yet.
+type ConsensusVersionView struct {
+	Err     error
+	Version protocol.ConsensusVersion
+}
+
+// genesisProtocol returns the version of the protocol the chain started with.
+func (a *Algorand) genesisProtocol() protocol.ConsensusVersion {
+	if a.config.Protocol != "" {
+		return protocol.ConsensusVersion(a.config.Protocol)
+	}
+	return protocol.ConsensusV1
+}
+
+// consensusVersion returns the version of the protocol of a round, which is
+// determined by the header of the previous round.
+func (a *Algorand) consensusVersion(chain consensus.ChainReader, r uint64) (protocol.ConsensusVersion, error) {
+	if r == 0 {
+		return a.genesisProtocol(), nil
+	}
+	parent := chain.GetHeaderByNumber(r - 1)
+	if parent == nil {
+		return "", errUnknownBlock
+	}
+	state, err := a.upgradeState(parent)
+	if err != nil {
+		return "", err
+	}
+	return state.versionAt(r), nil
+}
+
+// consensusParams returns the parameters of the protocol of a round.
+func (a *Algorand) consensusParams(chain consensus.ChainReader, r uint64) (ConsensusParams, error) {
+	version, err := a.consensusVersion(chain, r)
+	if err != nil {
+		return ConsensusParams{}, err
+	}
+	params, ok := Consensus[version]
+	if !ok {
+		return ConsensusParams{}, errUnsupportedProtocol
+	}
+	return a.scaleCommittees(params), nil
+}
+
+// scaleCommittees applies the committee scale of the chain to the parameters
+// of a protocol version.
+func (a *Algorand) scaleCommittees(params ConsensusParams) ConsensusParams {
+	return params.scaled(a.config.CommitteeScale)
+}
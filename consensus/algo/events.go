@@ -1,5 +1,8 @@
 package algo
 
+import "fmt"
+
+//go:generate stringer -type=eventType
 type eventType int
 
 // An event represents the communication of an event to a state machine.
@@ -26,7 +29,7 @@ type externalEvent interface {
 
 	// AttachConsensusVersion returns a copy of this externalEvent with a
 	// ConsensusVersion attached.
-	//AttachConsensusVersion(v ConsensusVersionView) externalEvent
+	AttachConsensusVersion(v ConsensusVersionView) externalEvent
 }
 
 const (
@@ -186,3 +189,193 @@ const (
 	// it's invoked by the end of the persistence loop on either success or failuire.
 	checkpointReached
 )
+
+// A message represents an internal message which is passed between components
+// of the agreement service.
+type message struct {
+	Vote                    vote
+	UnauthenticatedVote     unauthenticatedVote
+	Bundle                  bundle
+	UnauthenticatedBundle   unauthenticatedBundle
+	Proposal                *Proposal
+	UnauthenticatedProposal *UnauthenticatedProposal
+}
+
+// messageEvent carries a message received from the network, together with the
+// result of its verification.
+type messageEvent struct {
+	// {vote,bundle,payload}{Present,Verified}
+	T eventType
+
+	// Input represents the message itself.
+	Input message
+
+	// Err is set if cryptographic verification was attempted and failed.
+	Err error
+
+	// Proto is the version of the protocol of the round of the message.
+	Proto ConsensusVersionView
+}
+
+func (e messageEvent) t() eventType {
+	return e.T
+}
+
+func (e messageEvent) String() string {
+	return fmt.Sprintf("%v: %v", e.t(), e.Err)
+}
+
+func (e messageEvent) ComparableStr() string {
+	return e.t().String()
+}
+
+// ConsensusRound implements externalEvent.
+func (e messageEvent) ConsensusRound() uint64 {
+	switch e.T {
+	case votePresent, voteVerified:
+		return e.Input.UnauthenticatedVote.R.Round
+	case bundlePresent, bundleVerified:
+		return e.Input.UnauthenticatedBundle.Round
+	case payloadPresent, payloadVerified:
+		if e.Input.UnauthenticatedProposal != nil {
+			return e.Input.UnauthenticatedProposal.NumberU64()
+		}
+	}
+	return 0
+}
+
+// AttachConsensusVersion implements externalEvent.
+func (e messageEvent) AttachConsensusVersion(v ConsensusVersionView) externalEvent {
+	e.Proto = v
+	return e
+}
+
+// thresholdEvent is emitted by the vote state machines once the votes of a
+// step reach the threshold for a proposal-value.
+type thresholdEvent struct {
+	// {soft,cert,next}Threshold
+	T eventType
+
+	Round    uint64
+	Period   uint64
+	Step     uint64
+	Proposal ProposalValue
+	Bundle   unauthenticatedBundle
+}
+
+func (e thresholdEvent) t() eventType {
+	return e.T
+}
+
+func (e thresholdEvent) String() string {
+	return fmt.Sprintf("%v: %.5x (%v, %v, %v)", e.t(), e.Proposal.BlockDigest, e.Round, e.Period, step(e.Step))
+}
+
+func (e thresholdEvent) ComparableStr() string {
+	return e.String()
+}
+
+// filteredEvent is returned by the vote state machines when a message is
+// irrelevant (voteFiltered, bundleFiltered) or corrupt (voteMalformed,
+// bundleMalformed).
+type filteredEvent struct {
+	T eventType
+
+	// Err is the reason the message was filtered.
+	Err error
+}
+
+func (e filteredEvent) t() eventType {
+	return e.T
+}
+
+func (e filteredEvent) String() string {
+	return fmt.Sprintf("%v: %v", e.t(), e.Err)
+}
+
+func (e filteredEvent) ComparableStr() string {
+	return e.t().String()
+}
+
+// emptyEvent is returned by state machines which have no event to return.
+type emptyEvent struct{}
+
+func (e emptyEvent) t() eventType {
+	return none
+}
+
+func (e emptyEvent) String() string {
+	return e.t().String()
+}
+
+func (e emptyEvent) ComparableStr() string {
+	return e.String()
+}
+
+// timeoutEvent is delivered by the service once the deadline of the player
+// (timeout) or its fast recovery deadline (fastTimeout) has elapsed.
+type timeoutEvent struct {
+	// {timeout,fastTimeout}
+	T eventType
+
+	Round  round
+	Period period
+
+	Proto ConsensusVersionView
+}
+
+func (e timeoutEvent) t() eventType {
+	return e.T
+}
+
+func (e timeoutEvent) String() string {
+	return fmt.Sprintf("%v: (%v, %v)", e.t(), e.Round, e.Period)
+}
+
+func (e timeoutEvent) ComparableStr() string {
+	return e.String()
+}
+
+// ConsensusRound implements externalEvent.
+func (e timeoutEvent) ConsensusRound() uint64 {
+	return uint64(e.Round)
+}
+
+// AttachConsensusVersion implements externalEvent.
+func (e timeoutEvent) AttachConsensusVersion(v ConsensusVersionView) externalEvent {
+	e.Proto = v
+	return e
+}
+
+// roundInterruptionEvent is delivered when the ledger advanced past the current
+// round of the player, e.g. because the block was obtained by synchronisation.
+type roundInterruptionEvent struct {
+	// Round is the next round to agree upon.
+	Round round
+
+	// Proto is the version of the protocol of the next round.
+	Proto ConsensusVersionView
+}
+
+func (e roundInterruptionEvent) t() eventType {
+	return roundInterruption
+}
+
+func (e roundInterruptionEvent) String() string {
+	return fmt.Sprintf("%v: %v", e.t(), e.Round)
+}
+
+func (e roundInterruptionEvent) ComparableStr() string {
+	return e.String()
+}
+
+// ConsensusRound implements externalEvent.
+func (e roundInterruptionEvent) ConsensusRound() uint64 {
+	return uint64(e.Round)
+}
+
+// AttachConsensusVersion implements externalEvent.
+func (e roundInterruptionEvent) AttachConsensusVersion(v ConsensusVersionView) externalEvent {
+	e.Proto = v
+	return e
+}
@@ -0,0 +1,292 @@
+package algo
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/awesome-chain/Xchain/common"
+	"github.com/awesome-chain/Xchain/log"
+	"github.com/awesome-chain/Xchain/p2p"
+	"github.com/awesome-chain/Xchain/p2p/discover"
+	"github.com/hashicorp/golang-lru"
+)
+
+// Constants to match up protocol versions and messages
+const (
+	algo1 = 1
+)
+
+// ProtocolName is the official short name of the agreement protocol used during
+// capability negotiation.
+var ProtocolName = "algo"
+
+// ProtocolVersions are the supported versions of the agreement protocol (first
+// is primary).
+var ProtocolVersions = []uint{algo1}
+
+// ProtocolLengths are the number of implemented message corresponding to
+// different protocol versions.
+var ProtocolLengths = []uint64{6}
+
+const ProtocolMaxMsgSize = 10 * 1024 * 1024 // Maximum cap on the size of a protocol message
+
+// maxSeenMsgs is the number of message hashes remembered to de-duplicate the
+// gossip.
+const maxSeenMsgs = 65536
+
+// agreement protocol message codes
+const (
+	StatusMsg             = 0x00
+	VoteMsg               = 0x01
+	ProposalMsg           = 0x02
+	BundleMsg             = 0x03
+	GetCertifiedBlocksMsg = 0x04
+	CertifiedBlocksMsg    = 0x05
+)
+
+type errCode int
+
+const (
+	ErrMsgTooLarge = iota
+	ErrDecode
+	ErrInvalidMsgCode
+	ErrProtocolVersionMismatch
+	ErrNetworkIdMismatch
+	ErrGenesisBlockMismatch
+	ErrNoStatusMsg
+	ErrExtraStatusMsg
+)
+
+func (e errCode) String() string {
+	return errorToString[int(e)]
+}
+
+// XXX change once legacy code is out
+var errorToString = map[int]string{
+	ErrMsgTooLarge:             "Message too long",
+	ErrDecode:                  "Invalid message",
+	ErrInvalidMsgCode:          "Invalid message code",
+	ErrProtocolVersionMismatch: "Protocol version mismatch",
+	ErrNetworkIdMismatch:       "NetworkId mismatch",
+	ErrGenesisBlockMismatch:    "Genesis block mismatch",
+	ErrNoStatusMsg:             "No status message",
+	ErrExtraStatusMsg:          "Extra status message",
+}
+
+func errResp(code errCode, format string, v ...interface{}) error {
+	return fmt.Errorf("%v - %v", code, fmt.Sprintf(format, v...))
+}
+
+// statusData is the network packet for the status message.
+type statusData struct {
+	ProtocolVersion uint32
+	NetworkId       uint64
+	Head            uint64
+	GenesisBlock    common.Hash
+}
+
+// gossip relays the agreement messages between the agreement service and the
+// network. Every message is delivered to the service at most once, and relayed
+// only after the service verified it and found it relevant.
+type gossip struct {
+	service   *Service
+	networkId uint64
+	genesis   common.Hash
+
+	peers *peerSet
+	seen  *lru.Cache // Hashes of the messages already received or relayed
+
+	SubProtocols []p2p.Protocol
+
+	quit chan struct{}
+	wg   sync.WaitGroup
+}
+
+// newGossip creates the agreement sub-protocols relaying the messages of a
+// service.
+func newGossip(service *Service, networkId uint64, genesis common.Hash) *gossip {
+	seen, _ := lru.New(maxSeenMsgs)
+	g := &gossip{
+		service:   service,
+		networkId: networkId,
+		genesis:   genesis,
+		peers:     newPeerSet(),
+		seen:      seen,
+		quit:      make(chan struct{}),
+	}
+	for i, version := range ProtocolVersions {
+		version := version // Closure for the run
+		g.SubProtocols = append(g.SubProtocols, p2p.Protocol{
+			Name:    ProtocolName,
+			Version: version,
+			Length:  ProtocolLengths[i],
+			Run: func(p *p2p.Peer, rw p2p.MsgReadWriter) error {
+				select {
+				case <-g.quit:
+					return p2p.DiscQuitting
+				default:
+				}
+				g.wg.Add(1)
+				defer g.wg.Done()
+				return g.handle(newPeer(int(version), p, rw))
+			},
+			PeerInfo: func(id discover.NodeID) interface{} {
+				if p := g.peers.Peer(fmt.Sprintf("%x", id[:8])); p != nil {
+					return p.Info()
+				}
+				return nil
+			},
+		})
+	}
+	return g
+}
+
+// stop disconnects all the peers and waits for their handlers to terminate.
+func (g *gossip) stop() {
+	close(g.quit)
+	g.peers.Close()
+	g.wg.Wait()
+}
+
+// handle is the callback invoked to manage the life cycle of an agreement peer.
+// When this function terminates, the peer is disconnected.
+func (g *gossip) handle(p *peer) error {
+	head := g.service.ledger.CurrentHeader().Number.Uint64()
+	if err := p.Handshake(g.networkId, head, g.genesis); err != nil {
+		p.Log().Debug("Agreement handshake failed", "err", err)
+		return err
+	}
+	if err := g.peers.Register(p); err != nil {
+		p.Log().Error("Agreement peer registration failed", "err", err)
+		return err
+	}
+	defer g.peers.Unregister(p.id)
+
+	// Catch up if the peer is ahead
+	if p.Head() > head {
+		g.service.catchup.notify()
+	}
+	for {
+		if err := g.handleMsg(p); err != nil {
+			p.Log().Debug("Agreement message handling failed", "err", err)
+			return err
+		}
+	}
+}
+
+// handleMsg is invoked whenever an inbound message is received from a remote
+// peer. The remote connection is torn down upon returning any error.
+func (g *gossip) handleMsg(p *peer) error {
+	// Read the next message from the remote peer, and ensure it's fully consumed
+	msg, err := p.rw.ReadMsg()
+	if err != nil {
+		return err
+	}
+	if msg.Size > ProtocolMaxMsgSize {
+		return errResp(ErrMsgTooLarge, "%v > %v", msg.Size, ProtocolMaxMsgSize)
+	}
+	defer msg.Discard()
+
+	if msg.Code == StatusMsg {
+		// Status messages should never arrive after the handshake
+		return errResp(ErrExtraStatusMsg, "uncontrolled status message")
+	}
+	// Drop the messages of peers exceeding their allowance
+	if !p.limiter.allow(time.Now()) {
+		p.Log().Trace("Agreement peer rate limited", "code", msg.Code)
+		return nil
+	}
+	var (
+		hash common.Hash
+		e    messageEvent
+	)
+	switch msg.Code {
+	case VoteMsg:
+		var uv unauthenticatedVote
+		if err := msg.Decode(&uv); err != nil {
+			return errResp(ErrDecode, "msg %v: %v", msg, err)
+		}
+		hash, e = HashObj(uv), messageEvent{T: votePresent, Input: message{UnauthenticatedVote: uv}}
+
+	case ProposalMsg:
+		var up UnauthenticatedProposal
+		if err := msg.Decode(&up); err != nil {
+			return errResp(ErrDecode, "msg %v: %v", msg, err)
+		}
+		if up.Block == nil {
+			return errResp(ErrDecode, "msg %v: missing block", msg)
+		}
+		hash, e = HashObj(&up), messageEvent{T: payloadPresent, Input: message{UnauthenticatedProposal: &up}}
+
+	case BundleMsg:
+		var ub unauthenticatedBundle
+		if err := msg.Decode(&ub); err != nil {
+			return errResp(ErrDecode, "msg %v: %v", msg, err)
+		}
+		hash, e = HashObj(ub), messageEvent{T: bundlePresent, Input: message{UnauthenticatedBundle: ub}}
+
+	case GetCertifiedBlocksMsg:
+		var query getCertifiedBlocksData
+		if err := msg.Decode(&query); err != nil {
+			return errResp(ErrDecode, "msg %v: %v", msg, err)
+		}
+		return p.SendCertifiedBlocks(g.service.catchup.certifiedBlocks(query.Origin, query.Amount))
+
+	case CertifiedBlocksMsg:
+		var blocks []certifiedBlock
+		if err := msg.Decode(&blocks); err != nil {
+			return errResp(ErrDecode, "msg %v: %v", msg, err)
+		}
+		g.service.catchup.deliver(p.id, blocks)
+		return nil
+
+	default:
+		return errResp(ErrInvalidMsgCode, "%v", msg.Code)
+	}
+	p.MarkMessage(hash)
+	if g.seen.Contains(hash) {
+		return nil
+	}
+	g.seen.Add(hash, struct{}{})
+	g.service.deliver(e)
+	return nil
+}
+
+// markHeads records that the peers which sent a verified message of round r
+// committed the block of the previous round. It must be called before the
+// message is relayed, as only the senders know it until then.
+func (g *gossip) markHeads(hash common.Hash, r uint64) {
+	if r == 0 {
+		return
+	}
+	for _, p := range g.peers.PeersWithMessage(hash) {
+		p.SetHead(r - 1)
+	}
+}
+
+// relay sends a verified message to all the peers which do not know it yet.
+func (g *gossip) relay(code uint64, hash common.Hash, data interface{}) {
+	g.seen.Add(hash, struct{}{})
+
+	peers := g.peers.PeersWithoutMessage(hash)
+	for _, p := range peers {
+		p.AsyncSendMessage(gossipMsg{code: code, hash: hash, data: data})
+	}
+	log.Trace("Relayed agreement message", "code", code, "hash", hash, "recipients", len(peers))
+}
+
+// relayVote relays a verified vote.
+func (g *gossip) relayVote(uv unauthenticatedVote) {
+	g.relay(VoteMsg, HashObj(uv), uv)
+}
+
+// relayProposal relays a verified proposal.
+func (g *gossip) relayProposal(up *UnauthenticatedProposal) {
+	g.relay(ProposalMsg, HashObj(up), up)
+}
+
+// relayBundle relays a verified bundle.
+func (g *gossip) relayBundle(ub unauthenticatedBundle) {
+	g.relay(BundleMsg, HashObj(ub), ub)
+}
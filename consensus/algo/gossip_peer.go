@@ -0,0 +1,377 @@
+package algo
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/awesome-chain/Xchain/common"
+	"github.com/awesome-chain/Xchain/p2p"
+	"gopkg.in/fatih/set.v0"
+)
+
+var (
+	errClosed            = errors.New("peer set is closed")
+	errAlreadyRegistered = errors.New("peer is already registered")
+	errNotRegistered     = errors.New("peer is not registered")
+)
+
+const (
+	maxKnownMsgs = 32768 // Maximum message hashes to keep in the known list (prevent DOS)
+
+	// maxQueuedMsgs is the maximum number of agreement messages to queue up
+	// before dropping relays. Messages are only relevant for a few seconds, so
+	// there is no point in queueing a lot of them.
+	maxQueuedMsgs = 256
+
+	// maxMsgRate and maxMsgBurst limit the number of messages accepted from a
+	// single peer per second, and in a single burst.
+	maxMsgRate  = 500
+	maxMsgBurst = 2000
+
+	handshakeTimeout = 5 * time.Second
+)
+
+// PeerInfo represents a short summary of the agreement sub-protocol metadata
+// known about a connected peer.
+type PeerInfo struct {
+	Version int    `json:"version"` // Agreement protocol version negotiated
+	Head    uint64 `json:"head"`    // Number of the latest block committed by the peer
+}
+
+// gossipMsg is an agreement message, waiting for its turn in the relay queue.
+type gossipMsg struct {
+	code uint64
+	hash common.Hash
+	data interface{}
+}
+
+// rateLimiter is a token bucket limiting the rate at which messages of a peer
+// are accepted.
+type rateLimiter struct {
+	tokens float64
+	last   time.Time
+}
+
+// allow takes a token from the bucket, returning false if it is empty.
+func (l *rateLimiter) allow(now time.Time) bool {
+	if l.last.IsZero() {
+		l.tokens = maxMsgBurst
+	} else {
+		l.tokens += now.Sub(l.last).Seconds() * maxMsgRate
+		if l.tokens > maxMsgBurst {
+			l.tokens = maxMsgBurst
+		}
+	}
+	l.last = now
+	if l.tokens < 1 {
+		return false
+	}
+	l.tokens--
+	return true
+}
+
+type peer struct {
+	id string
+
+	*p2p.Peer
+	rw p2p.MsgReadWriter
+
+	version int // Protocol version negotiated
+
+	head uint64 // Number of the latest block known to be committed by the peer
+	lock sync.RWMutex
+
+	limiter rateLimiter // Rate limiter of the inbound messages, only used by the read loop
+
+	knownMsgs  *set.Set       // Set of message hashes known to be known by this peer
+	queuedMsgs chan gossipMsg // Queue of messages to relay to the peer
+	term       chan struct{}  // Termination channel to stop the broadcaster
+}
+
+func newPeer(version int, p *p2p.Peer, rw p2p.MsgReadWriter) *peer {
+	return &peer{
+		Peer:       p,
+		rw:         rw,
+		version:    version,
+		id:         fmt.Sprintf("%x", p.ID().Bytes()[:8]),
+		knownMsgs:  set.New(),
+		queuedMsgs: make(chan gossipMsg, maxQueuedMsgs),
+		term:       make(chan struct{}),
+	}
+}
+
+// broadcast is a write loop that relays the queued agreement messages to the
+// remote peer.
+func (p *peer) broadcast() {
+	for {
+		select {
+		case msg := <-p.queuedMsgs:
+			if err := p2p.Send(p.rw, msg.code, msg.data); err != nil {
+				return
+			}
+			p.Log().Trace("Relayed agreement message", "code", msg.code, "hash", msg.hash)
+
+		case <-p.term:
+			return
+		}
+	}
+}
+
+// close signals the broadcast goroutine to terminate.
+func (p *peer) close() {
+	close(p.term)
+}
+
+// Info gathers and returns a collection of metadata known about a peer.
+func (p *peer) Info() *PeerInfo {
+	return &PeerInfo{Version: p.version, Head: p.Head()}
+}
+
+// Head retrieves the number of the latest block known to be committed by the
+// peer.
+func (p *peer) Head() uint64 {
+	p.lock.RLock()
+	defer p.lock.RUnlock()
+
+	return p.head
+}
+
+// SetHead updates the latest block known to be committed by the peer, unless
+// a later one is known already.
+func (p *peer) SetHead(number uint64) {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+
+	if number > p.head {
+		p.head = number
+	}
+}
+
+// MarkMessage marks a message as known for the peer, ensuring that it will
+// never be relayed to this particular peer.
+func (p *peer) MarkMessage(hash common.Hash) {
+	// If we reached the memory allowance, drop a previously known message hash
+	for p.knownMsgs.Size() >= maxKnownMsgs {
+		p.knownMsgs.Pop()
+	}
+	p.knownMsgs.Add(hash)
+}
+
+// AsyncSendMessage queues a message for relay to the remote peer. If the peer's
+// relay queue is full, the message is silently dropped.
+func (p *peer) AsyncSendMessage(msg gossipMsg) {
+	select {
+	case p.queuedMsgs <- msg:
+		p.MarkMessage(msg.hash)
+	default:
+		p.Log().Debug("Dropping agreement message relay", "hash", msg.hash)
+	}
+}
+
+// RequestCertifiedBlocks fetches a batch of consecutive blocks together with
+// their certificates, starting at origin.
+func (p *peer) RequestCertifiedBlocks(origin uint64, amount uint64) error {
+	p.Log().Debug("Fetching certified blocks", "origin", origin, "count", amount)
+	return p2p.Send(p.rw, GetCertifiedBlocksMsg, &getCertifiedBlocksData{Origin: origin, Amount: amount})
+}
+
+// SendCertifiedBlocks sends a batch of blocks with their certificates to the
+// remote peer.
+func (p *peer) SendCertifiedBlocks(blocks []certifiedBlock) error {
+	return p2p.Send(p.rw, CertifiedBlocksMsg, blocks)
+}
+
+// Handshake executes the agreement protocol handshake, negotiating version
+// number, network IDs and genesis blocks, and exchanging the current heads.
+func (p *peer) Handshake(network uint64, head uint64, genesis common.Hash) error {
+	// Send out own handshake in a new thread
+	errc := make(chan error, 2)
+	var status statusData // safe to read after two values have been received from errc
+
+	go func() {
+		errc <- p2p.Send(p.rw, StatusMsg, &statusData{
+			ProtocolVersion: uint32(p.version),
+			NetworkId:       network,
+			Head:            head,
+			GenesisBlock:    genesis,
+		})
+	}()
+	go func() {
+		errc <- p.readStatus(network, &status, genesis)
+	}()
+	timeout := time.NewTimer(handshakeTimeout)
+	defer timeout.Stop()
+	for i := 0; i < 2; i++ {
+		select {
+		case err := <-errc:
+			if err != nil {
+				return err
+			}
+		case <-timeout.C:
+			return p2p.DiscReadTimeout
+		}
+	}
+	p.SetHead(status.Head)
+	return nil
+}
+
+func (p *peer) readStatus(network uint64, status *statusData, genesis common.Hash) (err error) {
+	msg, err := p.rw.ReadMsg()
+	if err != nil {
+		return err
+	}
+	if msg.Code != StatusMsg {
+		return errResp(ErrNoStatusMsg, "first msg has code %x (!= %x)", msg.Code, StatusMsg)
+	}
+	if msg.Size > ProtocolMaxMsgSize {
+		return errResp(ErrMsgTooLarge, "%v > %v", msg.Size, ProtocolMaxMsgSize)
+	}
+	// Decode the handshake and make sure everything matches
+	if err := msg.Decode(&status); err != nil {
+		return errResp(ErrDecode, "msg %v: %v", msg, err)
+	}
+	if status.GenesisBlock != genesis {
+		return errResp(ErrGenesisBlockMismatch, "%x (!= %x)", status.GenesisBlock[:8], genesis[:8])
+	}
+	if status.NetworkId != network {
+		return errResp(ErrNetworkIdMismatch, "%d (!= %d)", status.NetworkId, network)
+	}
+	if int(status.ProtocolVersion) != p.version {
+		return errResp(ErrProtocolVersionMismatch, "%d (!= %d)", status.ProtocolVersion, p.version)
+	}
+	return nil
+}
+
+// String implements fmt.Stringer.
+func (p *peer) String() string {
+	return fmt.Sprintf("Peer %s [%s]", p.id,
+		fmt.Sprintf("%s/%d", ProtocolName, p.version),
+	)
+}
+
+// peerSet represents the collection of active peers currently participating in
+// the agreement sub-protocol.
+type peerSet struct {
+	peers  map[string]*peer
+	lock   sync.RWMutex
+	closed bool
+}
+
+// newPeerSet creates a new peer set to track the active participants.
+func newPeerSet() *peerSet {
+	return &peerSet{
+		peers: make(map[string]*peer),
+	}
+}
+
+// Register injects a new peer into the working set, or returns an error if the
+// peer is already known. If a new peer it registered, its broadcast loop is also
+// started.
+func (ps *peerSet) Register(p *peer) error {
+	ps.lock.Lock()
+	defer ps.lock.Unlock()
+
+	if ps.closed {
+		return errClosed
+	}
+	if _, ok := ps.peers[p.id]; ok {
+		return errAlreadyRegistered
+	}
+	ps.peers[p.id] = p
+	go p.broadcast()
+
+	return nil
+}
+
+// Unregister removes a remote peer from the active set, disabling any further
+// actions to/from that particular entity.
+func (ps *peerSet) Unregister(id string) error {
+	ps.lock.Lock()
+	defer ps.lock.Unlock()
+
+	p, ok := ps.peers[id]
+	if !ok {
+		return errNotRegistered
+	}
+	delete(ps.peers, id)
+	p.close()
+
+	return nil
+}
+
+// Peer retrieves the registered peer with the given id.
+func (ps *peerSet) Peer(id string) *peer {
+	ps.lock.RLock()
+	defer ps.lock.RUnlock()
+
+	return ps.peers[id]
+}
+
+// Len returns if the current number of peers in the set.
+func (ps *peerSet) Len() int {
+	ps.lock.RLock()
+	defer ps.lock.RUnlock()
+
+	return len(ps.peers)
+}
+
+// PeersWithoutMessage retrieves a list of peers that do not have a given
+// message in their set of known hashes.
+func (ps *peerSet) PeersWithoutMessage(hash common.Hash) []*peer {
+	ps.lock.RLock()
+	defer ps.lock.RUnlock()
+
+	list := make([]*peer, 0, len(ps.peers))
+	for _, p := range ps.peers {
+		if !p.knownMsgs.Has(hash) {
+			list = append(list, p)
+		}
+	}
+	return list
+}
+
+// PeersWithMessage retrieves a list of peers that have a given message in their
+// set of known hashes.
+func (ps *peerSet) PeersWithMessage(hash common.Hash) []*peer {
+	ps.lock.RLock()
+	defer ps.lock.RUnlock()
+
+	list := make([]*peer, 0, len(ps.peers))
+	for _, p := range ps.peers {
+		if p.knownMsgs.Has(hash) {
+			list = append(list, p)
+		}
+	}
+	return list
+}
+
+// BestPeer retrieves the peer with the latest committed block.
+func (ps *peerSet) BestPeer() *peer {
+	ps.lock.RLock()
+	defer ps.lock.RUnlock()
+
+	var (
+		bestPeer *peer
+		bestHead uint64
+	)
+	for _, p := range ps.peers {
+		if head := p.Head(); bestPeer == nil || head > bestHead {
+			bestPeer, bestHead = p, head
+		}
+	}
+	return bestPeer
+}
+
+// Close disconnects all peers.
+// No new peers can be registered after Close has returned.
+func (ps *peerSet) Close() {
+	ps.lock.Lock()
+	defer ps.lock.Unlock()
+
+	for _, p := range ps.peers {
+		p.Disconnect(p2p.DiscQuitting)
+	}
+	ps.closed = true
+}
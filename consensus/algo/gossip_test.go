@@ -0,0 +1,176 @@
+package algo
+
+import (
+	"crypto/rand"
+	"testing"
+	"time"
+
+	"github.com/awesome-chain/Xchain/common"
+	"github.com/awesome-chain/Xchain/core/types"
+	"github.com/awesome-chain/Xchain/crypto"
+	"github.com/awesome-chain/Xchain/p2p"
+	"github.com/awesome-chain/Xchain/p2p/discover"
+)
+
+// testGossipPeer is a simulated remote peer of the agreement protocol.
+type testGossipPeer struct {
+	app  *p2p.MsgPipeRW
+	errc chan error
+}
+
+// newTestGossipPeer connects a simulated peer to the gossip of a service and
+// executes the handshake.
+func newTestGossipPeer(t *testing.T, g *gossip) *testGossipPeer {
+	app, net := p2p.MsgPipe()
+
+	var id discover.NodeID
+	rand.Read(id[:])
+
+	errc := make(chan error, 1)
+	go func() {
+		errc <- g.SubProtocols[0].Run(p2p.NewPeer(id, "test", nil), net)
+	}()
+	status := &statusData{ProtocolVersion: algo1, NetworkId: g.networkId, Head: g.service.ledger.CurrentHeader().Number.Uint64(), GenesisBlock: g.genesis}
+	if err := p2p.ExpectMsg(app, StatusMsg, status); err != nil {
+		t.Fatalf("status recv error: %v", err)
+	}
+	// The simulated peer announces the genesis block as its head
+	status.Head = 0
+	if err := p2p.Send(app, StatusMsg, status); err != nil {
+		t.Fatalf("status send error: %v", err)
+	}
+	// Wait for the peer to get registered
+	for g.peers.Len() == 0 {
+		time.Sleep(time.Millisecond)
+	}
+	return &testGossipPeer{app: app, errc: errc}
+}
+
+func TestGossipHandshakeMismatch(t *testing.T) {
+	key, _ := crypto.GenerateKey()
+	engine, chain := newTestParticipants(t, key)
+	defer chain.Stop()
+
+	service := NewService(engine, Parameters{Ledger: &testLedger{chain}, Clock: newTestClock(), NetworkId: 1})
+	app, net := p2p.MsgPipe()
+	defer app.Close()
+
+	var id discover.NodeID
+	errc := make(chan error, 1)
+	go func() {
+		errc <- service.gossip.SubProtocols[0].Run(p2p.NewPeer(id, "test", nil), net)
+	}()
+	go p2p.Send(app, StatusMsg, &statusData{ProtocolVersion: algo1, NetworkId: 2, GenesisBlock: service.gossip.genesis})
+
+	select {
+	case err := <-errc:
+		if err == nil {
+			t.Fatalf("handshake with mismatching network succeeded")
+		}
+	case <-time.After(handshakeTimeout + time.Second):
+		t.Fatalf("handshake did not fail")
+	}
+}
+
+func TestGossipRelay(t *testing.T) {
+	key, _ := crypto.HexToECDSA("b71c71a67e1177ad4e901695e1b4b9ee17ae16c6668d313eac2f96dbcda3f291")
+	other, _ := crypto.GenerateKey()
+	engine, chain := newTestParticipants(t, key, other)
+	defer chain.Stop()
+
+	value := ProposalValue{BlockDigest: common.HexToHash("0x01")}
+	relevant := signVote(t, engine, chain, key, soft, value)
+	stale := relevant
+	stale.R.Round = 2
+
+	// Run the service without a participation key, only relaying
+	service := NewService(New(engine.config, nil), Parameters{Ledger: &testLedger{chain}, Clock: newTestClock(), NetworkId: 1})
+	service.Start()
+	defer service.Stop()
+
+	source := newTestGossipPeer(t, service.gossip)
+	sink := newTestGossipPeer(t, service.gossip)
+
+	// A vote of another round must not be relayed, so the first message reaching
+	// the sink must be the relevant vote
+	if err := p2p.Send(source.app, VoteMsg, stale); err != nil {
+		t.Fatalf("failed to send vote: %v", err)
+	}
+	if err := p2p.Send(source.app, VoteMsg, relevant); err != nil {
+		t.Fatalf("failed to send vote: %v", err)
+	}
+	if err := p2p.ExpectMsg(sink.app, VoteMsg, relevant); err != nil {
+		t.Fatalf("relayed vote mismatch: %v", err)
+	}
+	// Both peers know the vote now, so it will not be relayed to them again
+	if peers := service.gossip.peers.PeersWithoutMessage(HashObj(relevant)); len(peers) != 0 {
+		t.Fatalf("vote not marked as known by %d peers", len(peers))
+	}
+	source.app.Close()
+	sink.app.Close()
+}
+
+func TestGossipVerifiedHead(t *testing.T) {
+	key, _ := crypto.HexToECDSA("b71c71a67e1177ad4e901695e1b4b9ee17ae16c6668d313eac2f96dbcda3f291")
+	engine, chain := newTestParticipants(t, key)
+	engine.Authorize(key)
+	defer chain.Stop()
+
+	for i := 0; i < 2; i++ {
+		if _, err := chain.InsertChain(types.Blocks{makeBlock(t, chain, engine)}); err != nil {
+			t.Fatalf("block %d: failed to insert: %v", i+1, err)
+		}
+	}
+	value := ProposalValue{BlockDigest: common.HexToHash("0x01")}
+	valid := signRoundVote(t, engine, chain, key, 3, soft, value)
+	forged := valid
+	forged.R.Round = 10
+
+	service := NewService(New(engine.config, nil), Parameters{Ledger: &testLedger{chain}, Clock: newTestClock(), NetworkId: 1})
+	service.Start()
+	defer service.Stop()
+
+	source := newTestGossipPeer(t, service.gossip)
+	defer source.app.Close()
+	peer := service.gossip.peers.BestPeer()
+
+	// A vote of a future round can not be verified, so it must not raise the
+	// head of the peer, while a verified vote of the current round does
+	if err := p2p.Send(source.app, VoteMsg, forged); err != nil {
+		t.Fatalf("failed to send vote: %v", err)
+	}
+	if err := p2p.Send(source.app, VoteMsg, valid); err != nil {
+		t.Fatalf("failed to send vote: %v", err)
+	}
+	for deadline := time.Now().Add(time.Second); peer.Head() == 0 && time.Now().Before(deadline); {
+		time.Sleep(time.Millisecond)
+	}
+	if head := peer.Head(); head != 2 {
+		t.Fatalf("peer head mismatch: have %d, want 2", head)
+	}
+}
+
+func TestGossipRateLimit(t *testing.T) {
+	var (
+		limiter rateLimiter
+		now     = time.Now()
+	)
+	for i := 0; i < maxMsgBurst; i++ {
+		if !limiter.allow(now) {
+			t.Fatalf("message %d rejected within burst", i)
+		}
+	}
+	if limiter.allow(now) {
+		t.Fatalf("message accepted beyond burst")
+	}
+	// Tokens refill with time, up to the rate
+	now = now.Add(time.Second)
+	for i := 0; i < maxMsgRate; i++ {
+		if !limiter.allow(now) {
+			t.Fatalf("message %d rejected within rate", i)
+		}
+	}
+	if limiter.allow(now) {
+		t.Fatalf("message accepted beyond rate")
+	}
+}
@@ -0,0 +1,268 @@
+package algo
+
+import (
+	"encoding/binary"
+	"strconv"
+	"strings"
+
+	"github.com/awesome-chain/Xchain/common"
+	"github.com/awesome-chain/Xchain/consensus"
+	"github.com/awesome-chain/Xchain/core/state"
+	"github.com/awesome-chain/Xchain/core/types"
+	"github.com/awesome-chain/Xchain/crypto"
+	"github.com/awesome-chain/Xchain/log"
+)
+
+const (
+	/*
+	 *  algo:version:category:action/data
+	 *
+	 *  algo:1:event:online:<participation key address>:<first valid round>:<last valid round>
+	 *  algo:1:event:offline
+	 */
+	algoTxPrefix        = "algo"
+	algoTxVersion       = "1"
+	algoTxCategoryEvent = "event"
+	algoTxEventOnline   = "online"
+	algoTxEventOffline  = "offline"
+	algoTxMinSplitLen   = 4
+	algoTxOnlineLen     = 7
+	posTxPrefix         = 0
+	posTxVersion        = 1
+	posTxCategory       = 2
+	posTxEvent          = 3
+	posTxKey            = 4
+	posTxFirstValid     = 5
+	posTxLastValid      = 6
+
+	inMemoryParticipants = 128 // Number of recent participant sets to keep in memory
+)
+
+// participationRegistry is the account whose storage holds the participation
+// keys registered by the stake accounts. Keeping them in the state lets the
+// sortition read them from the very same lookback state as the stake.
+var participationRegistry = common.BytesToAddress([]byte("algo-participation"))
+
+const (
+	participationUnknown = iota // Account never sent a participation transaction
+	participationOnline         // Account registered a participation key
+	participationOffline        // Account went offline explicitly
+)
+
+// participation is the registration of a participation key by a stake account.
+// The key may vote and propose on behalf of the account within its validity
+// rounds, while the key holding the stake stays offline.
+type participation struct {
+	Online     bool
+	Key        common.Address // Address of the participation key
+	FirstValid uint64         // First round the key may take part in
+	LastValid  uint64         // Last round the key may take part in
+}
+
+// validAt returns whether the participation key may take part in a round.
+func (p participation) validAt(r uint64) bool {
+	return p.Online && p.FirstValid <= r && r <= p.LastValid
+}
+
+// participant is an account taking part in the sortition, together with its
+// stake at the seed lookback round.
+type participant struct {
+	participation
+	Stake uint64
+}
+
+// participationSlot returns the storage slot of a field of the registration of
+// an account.
+func participationSlot(account common.Address, field byte) common.Hash {
+	return crypto.Keccak256Hash(account.Bytes(), []byte{field})
+}
+
+// registeredSlot returns the storage slot of the i-th registered account.
+func registeredSlot(i uint64) common.Hash {
+	var index [8]byte
+	binary.BigEndian.PutUint64(index[:], i)
+	return crypto.Keccak256Hash([]byte("list"), index[:])
+}
+
+// readParticipation retrieves the registration of an account from the state,
+// returning false if the account never registered.
+func readParticipation(statedb *state.StateDB, account common.Address) (participation, bool) {
+	status := statedb.GetState(participationRegistry, participationSlot(account, 0))
+	if status[0] == participationUnknown {
+		return participation{}, false
+	}
+	rounds := statedb.GetState(participationRegistry, participationSlot(account, 1))
+	return participation{
+		Online:     status[0] == participationOnline,
+		Key:        common.BytesToAddress(status[12:]),
+		FirstValid: binary.BigEndian.Uint64(rounds[16:24]),
+		LastValid:  binary.BigEndian.Uint64(rounds[24:]),
+	}, true
+}
+
+// writeParticipation stores the registration of an account in the state,
+// adding the account to the list of registered accounts on first use.
+func writeParticipation(statedb *state.StateDB, account common.Address, p participation) {
+	// The registry has no balance nor code, make sure it is never deleted as empty
+	if statedb.GetNonce(participationRegistry) == 0 {
+		statedb.SetNonce(participationRegistry, 1)
+	}
+	if _, known := readParticipation(statedb, account); !known {
+		count := statedb.GetState(participationRegistry, common.Hash{})
+		n := binary.BigEndian.Uint64(count[24:])
+		statedb.SetState(participationRegistry, registeredSlot(n), account.Hash())
+
+		binary.BigEndian.PutUint64(count[24:], n+1)
+		statedb.SetState(participationRegistry, common.Hash{}, count)
+	}
+	var status, rounds common.Hash
+	status[0] = participationOffline
+	if p.Online {
+		status[0] = participationOnline
+	}
+	copy(status[12:], p.Key.Bytes())
+	binary.BigEndian.PutUint64(rounds[16:24], p.FirstValid)
+	binary.BigEndian.PutUint64(rounds[24:], p.LastValid)
+
+	statedb.SetState(participationRegistry, participationSlot(account, 0), status)
+	statedb.SetState(participationRegistry, participationSlot(account, 1), rounds)
+}
+
+// registeredAccounts returns the accounts which ever sent a participation
+// transaction, in the order of their first registration.
+func registeredAccounts(statedb *state.StateDB) []common.Address {
+	count := statedb.GetState(participationRegistry, common.Hash{})
+	n := binary.BigEndian.Uint64(count[24:])
+
+	accounts := make([]common.Address, 0, n)
+	for i := uint64(0); i < n; i++ {
+		accounts = append(accounts, common.BytesToAddress(statedb.GetState(participationRegistry, registeredSlot(i)).Bytes()))
+	}
+	return accounts
+}
+
+// parseParticipationTx decodes the registration carried by a participation
+// transaction included in a block, returning false if the transaction is not a
+// valid participation transaction.
+func parseParticipationTx(data []byte, number uint64) (participation, bool) {
+	if len(data) < len(algoTxPrefix) {
+		return participation{}, false
+	}
+	info := strings.Split(string(data), ":")
+	if len(info) < algoTxMinSplitLen || info[posTxPrefix] != algoTxPrefix || info[posTxVersion] != algoTxVersion || info[posTxCategory] != algoTxCategoryEvent {
+		return participation{}, false
+	}
+	switch info[posTxEvent] {
+	case algoTxEventOffline:
+		if len(info) != algoTxMinSplitLen {
+			return participation{}, false
+		}
+		return participation{}, true
+
+	case algoTxEventOnline:
+		if len(info) != algoTxOnlineLen || !common.IsHexAddress(info[posTxKey]) {
+			return participation{}, false
+		}
+		first, err := strconv.ParseUint(info[posTxFirstValid], 10, 64)
+		if err != nil {
+			return participation{}, false
+		}
+		last, err := strconv.ParseUint(info[posTxLastValid], 10, 64)
+		if err != nil {
+			return participation{}, false
+		}
+		key := common.HexToAddress(info[posTxKey])
+		if key == (common.Address{}) || first > last || last < number {
+			return participation{}, false
+		}
+		return participation{Online: true, Key: key, FirstValid: first, LastValid: last}, true
+	}
+	return participation{}, false
+}
+
+// processParticipationTxs applies the participation transactions of a block to
+// the registry. The sender of a transaction is the stake account, so the key
+// holding the stake only ever signs transactions and never has to sit on the
+// participating node. Malformed transactions are left as plain transfers.
+func processParticipationTxs(chain consensus.ChainReader, header *types.Header, statedb *state.StateDB, txs []*types.Transaction) {
+	signer := types.MakeSigner(chain.Config(), header.Number)
+	number := header.Number.Uint64()
+
+	for _, tx := range txs {
+		p, ok := parseParticipationTx(tx.Data(), number)
+		if !ok {
+			continue
+		}
+		sender, err := types.Sender(signer, tx)
+		if err != nil {
+			continue
+		}
+		log.Debug("Participation key registered", "account", sender, "online", p.Online, "key", p.Key, "first", p.FirstValid, "last", p.LastValid)
+		writeParticipation(statedb, sender, p)
+	}
+}
+
+// participants resolves the accounts taking part in the sortition of a round
+// with their stake, as recorded in the state of the seed lookback round. The
+// configured participants take part with their own key unless they registered
+// otherwise. The returned map is shared and must not be modified.
+func (a *Algorand) participants(ledger Ledger, number uint64) (map[common.Address]participant, error) {
+	params, err := a.consensusParams(ledger, number)
+	if err != nil {
+		return nil, err
+	}
+	header := ledger.GetHeaderByNumber(params.seedRound(number))
+	if header == nil {
+		return nil, errMissingSeed
+	}
+	if cached, ok := a.participations.Get(header.Hash()); ok {
+		return cached.(map[common.Address]participant), nil
+	}
+	statedb, err := ledger.StateAt(header.Root)
+	if err != nil {
+		return nil, err
+	}
+	accounts := registeredAccounts(statedb)
+	for _, addr := range a.config.Participants {
+		accounts = append(accounts, common.Address(addr))
+	}
+	parts := make(map[common.Address]participant)
+	for _, account := range accounts {
+		if _, ok := parts[account]; ok {
+			continue
+		}
+		p, _ := a.participation(statedb, account)
+		parts[account] = participant{participation: p, Stake: toStake(statedb.GetBalance(account))}
+	}
+	a.participations.Add(header.Hash(), parts)
+	return parts, nil
+}
+
+// participation retrieves the registration of an account from the state. The
+// configured participants take part with their own key unless they registered
+// otherwise.
+func (a *Algorand) participation(statedb *state.StateDB, account common.Address) (participation, bool) {
+	if p, known := readParticipation(statedb, account); known {
+		return p, true
+	}
+	for _, addr := range a.config.Participants {
+		if common.Address(addr) == account {
+			return participation{Online: true, Key: account, LastValid: ^uint64(0)}, true
+		}
+	}
+	return participation{}, false
+}
+
+// participationKey returns the participation key an account may take part in a
+// round with, or false if it is offline in that round.
+func (a *Algorand) participationKey(ledger Ledger, account common.Address, number uint64) (common.Address, bool, error) {
+	parts, err := a.participants(ledger, number)
+	if err != nil {
+		return common.Address{}, false, err
+	}
+	p, ok := parts[account]
+	if !ok || !p.validAt(number) {
+		return common.Address{}, false, nil
+	}
+	return p.Key, true, nil
+}
@@ -0,0 +1,137 @@
+package algo
+
+import (
+	"fmt"
+	"math/big"
+	"testing"
+
+	"github.com/awesome-chain/Xchain/common"
+	"github.com/awesome-chain/Xchain/consensus/algo/committee"
+	"github.com/awesome-chain/Xchain/core/types"
+	"github.com/awesome-chain/Xchain/crypto"
+	"github.com/awesome-chain/Xchain/crypto/vrf"
+	"github.com/awesome-chain/Xchain/rpc"
+)
+
+func TestParseParticipationTx(t *testing.T) {
+	key := "0x000000000000000000000000000000000000c0de"
+	tests := []struct {
+		data string
+		want participation
+		ok   bool
+	}{
+		{data: "algo:1:event:offline", ok: true},
+		{data: fmt.Sprintf("algo:1:event:online:%s:5:10", key), want: participation{Online: true, Key: common.HexToAddress(key), FirstValid: 5, LastValid: 10}, ok: true},
+		{data: fmt.Sprintf("algo:1:event:online:%s:10:5", key)},                       // first after last
+		{data: fmt.Sprintf("algo:1:event:online:%s:1:2", key)},                        // expired
+		{data: "algo:1:event:online:0x0000000000000000000000000000000000000000:5:10"}, // empty key
+		{data: "algo:1:event:online:nokey:5:10"},
+		{data: fmt.Sprintf("algo:1:event:online:%s:5", key)},
+		{data: fmt.Sprintf("algo:2:event:online:%s:5:10", key)},
+		{data: "algo:1:event:offline:now"},
+		{data: "ufo:1:event:vote"},
+		{data: ""},
+	}
+	for i, tt := range tests {
+		have, ok := parseParticipationTx([]byte(tt.data), 3)
+		if ok != tt.ok || have != tt.want {
+			t.Errorf("test %d: participation mismatch: have (%+v, %v), want (%+v, %v)", i, have, ok, tt.want, tt.ok)
+		}
+	}
+}
+
+func TestParticipationRegistration(t *testing.T) {
+	cold, _ := crypto.HexToECDSA("b71c71a67e1177ad4e901695e1b4b9ee17ae16c6668d313eac2f96dbcda3f291")
+	hot, _ := crypto.GenerateKey()
+	other, _ := crypto.GenerateKey()
+	engine, chain := newTestParticipants(t, cold, other)
+	defer chain.Stop()
+
+	var (
+		account = crypto.PubkeyToAddress(cold.PublicKey)
+		signer  = types.MakeSigner(chain.Config(), big.NewInt(1))
+		nonce   uint64
+	)
+	participate := func(data string) *types.Transaction {
+		tx, err := types.SignTx(types.NewTransaction(nonce, account, new(big.Int), 100000, new(big.Int), []byte(data)), signer, cold)
+		if err != nil {
+			t.Fatalf("failed to sign transaction: %v", err)
+		}
+		nonce++
+		return tx
+	}
+	insert := func(block *types.Block) error {
+		_, err := chain.InsertChain(types.Blocks{block})
+		return err
+	}
+	// Register the hot key of the stake account, sealing with another participant
+	engine.Authorize(other)
+	online := participate(fmt.Sprintf("algo:1:event:online:%s:0:100", crypto.PubkeyToAddress(hot.PublicKey).Hex()))
+	if err := insert(makeBlock(t, chain, engine, online)); err != nil {
+		t.Fatalf("failed to insert registration: %v", err)
+	}
+	api := &API{chain: chain, algo: engine}
+	latest := rpc.LatestBlockNumber
+	if p, err := api.GetParticipation(account, &latest); err != nil || !p.Online || p.Key != crypto.PubkeyToAddress(hot.PublicKey) || p.LastValid != 100 {
+		t.Fatalf("registration mismatch: have %+v, err %v", p, err)
+	}
+	if err := insert(makeBlock(t, chain, engine)); err != nil {
+		t.Fatalf("failed to insert block: %v", err)
+	}
+	// From round 3 on, the stake is held by the hot key, not the cold one
+	hotMember, err := engine.membership(chain, account, &vrf.PublicKey{PublicKey: &hot.PublicKey}, 3, 0, soft)
+	if err != nil {
+		t.Fatalf("failed to resolve membership: %v", err)
+	}
+	coldMember, _ := engine.membership(chain, account, &vrf.PublicKey{PublicKey: &cold.PublicKey}, 3, 0, soft)
+	if hotMember.Stake == 0 || coldMember.Stake != 0 || hotMember.TotalStake != 2*hotMember.Stake {
+		t.Fatalf("stake mismatch: hot %d, cold %d, total %d", hotMember.Stake, coldMember.Stake, hotMember.TotalStake)
+	}
+	engine.AuthorizeParticipation(account, hot)
+	cred, err := engine.credential(chain, 3, 0, soft)
+	if err != nil || !cred.Selected() {
+		t.Fatalf("hot key not selected: %v", err)
+	}
+	rv := rawVote{Sender: account, Round: 3, Period: 0, Step: uint64(soft), Proposal: ProposalValue{BlockDigest: common.HexToHash("0x01")}}
+	uv, _ := makeVote(rv, hot, cred.UnauthenticatedCredential)
+	if _, err := uv.verify(engine, chain); err != nil {
+		t.Fatalf("failed to verify vote of hot key: %v", err)
+	}
+	uv, _ = makeVote(rv, cold, cred.UnauthenticatedCredential)
+	if _, err := uv.verify(engine, chain); err != errVoteSender {
+		t.Fatalf("vote of cold key error mismatch: have %v, want %v", err, errVoteSender)
+	}
+	// The hot key proposes on behalf of the stake account
+	block := makeBlock(t, chain, engine)
+	if err := insert(block); err != nil {
+		t.Fatalf("failed to insert block of hot key: %v", err)
+	}
+	if author, err := engine.Author(block.Header()); err != nil || author != account {
+		t.Fatalf("author mismatch: have %x, want %x, err %v", author, account, err)
+	}
+	// Going offline removes the stake from the sortition
+	engine.Authorize(other)
+	if err := insert(makeBlock(t, chain, engine, participate("algo:1:event:offline"))); err != nil {
+		t.Fatalf("failed to insert deregistration: %v", err)
+	}
+	if err := insert(makeBlock(t, chain, engine)); err != nil {
+		t.Fatalf("failed to insert block: %v", err)
+	}
+	m, err := engine.membership(chain, account, &vrf.PublicKey{PublicKey: &hot.PublicKey}, 6, 0, soft)
+	if err != nil {
+		t.Fatalf("failed to resolve membership: %v", err)
+	}
+	if m.Stake != 0 || m.TotalStake != hotMember.Stake {
+		t.Fatalf("offline stake mismatch: have %d of %d, want 0 of %d", m.Stake, m.TotalStake, hotMember.Stake)
+	}
+	// The sortition doesn't select the offline key, sign its block regardless
+	engine.AuthorizeParticipation(account, hot)
+	block = finalizeBlock(t, chain, engine)
+	header := block.Header()
+	if err := engine.signProposal(chain, header, 0, committee.UnauthenticatedCredential{}); err != nil {
+		t.Fatalf("failed to sign block: %v", err)
+	}
+	if err := insert(block.WithSeal(header)); err != errInvalidCoinbase {
+		t.Fatalf("offline proposer error mismatch: have %v, want %v", err, errInvalidCoinbase)
+	}
+}
@@ -0,0 +1,140 @@
+package algo
+
+import (
+	"time"
+
+	"github.com/awesome-chain/Xchain/common"
+	"github.com/awesome-chain/Xchain/consensus/algo/committee"
+	"github.com/awesome-chain/Xchain/consensus/algo/protocol"
+	"github.com/awesome-chain/Xchain/ethdb"
+	"github.com/awesome-chain/Xchain/rlp"
+)
+
+// agreementStateKey is the database key the agreement state is persisted under.
+var agreementStateKey = []byte("algo-agreement")
+
+// pendingCertificate is the persisted form of the certificate of a committed
+// value whose payload has not been received yet.
+type pendingCertificate struct {
+	Round    uint64
+	Period   uint64
+	Step     uint64
+	Proposal ProposalValue
+	Bundle   unauthenticatedBundle
+}
+
+// diskState is the persisted agreement state. It is written before any of the
+// actions of the player are carried out and before any own vote is relayed, so
+// a restarted node resumes where it stopped without voting twice in a step.
+type diskState struct {
+	Round  uint64
+	Period uint64
+	Step   uint64
+	Proto  protocol.ConsensusVersion
+
+	Deadline             uint64 // Nanoseconds since the start of the period
+	FastRecoveryDeadline uint64 // Nanoseconds since the start of the period
+	Napping              bool
+
+	Pinned     ProposalValue
+	Lowest     ProposalValue
+	LowestCred *committee.Credential `rlp:"nil"`
+	Staging    ProposalValue
+	CertVoted  bool
+
+	// Payloads are stored without their validated form, so after a crash the
+	// raw blocks are applied to the ledger.
+	Payloads []*UnauthenticatedProposal
+	Pending  *pendingCertificate `rlp:"nil"`
+
+	// Votes are the votes sent by the node in the current round.
+	Votes []unauthenticatedVote
+}
+
+// encodeState captures the state of a player and the votes sent in its round.
+func encodeState(p *player, votes []unauthenticatedVote) *diskState {
+	s := &diskState{
+		Round:                uint64(p.Round),
+		Period:               uint64(p.Period),
+		Step:                 uint64(p.Step),
+		Proto:                p.Proto,
+		Deadline:             uint64(p.Deadline),
+		FastRecoveryDeadline: uint64(p.FastRecoveryDeadline),
+		Napping:              p.Napping,
+		Pinned:               p.Pinned,
+		Lowest:               p.Lowest,
+		LowestCred:           p.LowestCred,
+		Staging:              p.Staging,
+		CertVoted:            p.CertVoted,
+		Votes:                votes,
+	}
+	for _, proposal := range p.Payloads {
+		s.Payloads = append(s.Payloads, proposal.U())
+	}
+	if p.Pending != nil {
+		s.Pending = &pendingCertificate{
+			Round:    p.Pending.Round,
+			Period:   p.Pending.Period,
+			Step:     p.Pending.Step,
+			Proposal: p.Pending.Proposal,
+			Bundle:   p.Pending.Bundle,
+		}
+	}
+	return s
+}
+
+// player reconstructs the player from its persisted state.
+func (s *diskState) player() *player {
+	p := &player{
+		Round:                round(s.Round),
+		Period:               period(s.Period),
+		Step:                 step(s.Step),
+		Proto:                s.Proto,
+		Deadline:             time.Duration(s.Deadline),
+		FastRecoveryDeadline: time.Duration(s.FastRecoveryDeadline),
+		Napping:              s.Napping,
+		Pinned:               s.Pinned,
+		Lowest:               s.Lowest,
+		LowestCred:           s.LowestCred,
+		Staging:              s.Staging,
+		CertVoted:            s.CertVoted,
+		Payloads:             make(map[common.Hash]*Proposal),
+	}
+	for _, up := range s.Payloads {
+		proposal := &Proposal{UnauthenticatedProposal: *up}
+		p.Payloads[proposal.Value().key()] = proposal
+	}
+	if s.Pending != nil {
+		p.Pending = &thresholdEvent{
+			T:        certThreshold,
+			Round:    s.Pending.Round,
+			Period:   s.Pending.Period,
+			Step:     s.Pending.Step,
+			Proposal: s.Pending.Proposal,
+			Bundle:   s.Pending.Bundle,
+		}
+	}
+	return p
+}
+
+// readAgreementState retrieves the persisted agreement state, if any.
+func readAgreementState(db ethdb.Database) (*diskState, error) {
+	blob, err := db.Get(agreementStateKey)
+	if err != nil {
+		return nil, err
+	}
+	s := new(diskState)
+	if err := rlp.DecodeBytes(blob, s); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+// writeAgreementState persists the agreement state.
+func writeAgreementState(db ethdb.Database, s *diskState) error {
+	blob, err := rlp.EncodeToBytes(s)
+	if err != nil {
+		return err
+	}
+	return db.Put(agreementStateKey, blob)
+}
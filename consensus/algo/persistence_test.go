@@ -0,0 +1,124 @@
+package algo
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/awesome-chain/Xchain/common"
+	"github.com/awesome-chain/Xchain/consensus/algo/protocol"
+	"github.com/awesome-chain/Xchain/crypto"
+	"github.com/awesome-chain/Xchain/ethdb"
+	"github.com/awesome-chain/Xchain/rlp"
+)
+
+func TestAgreementStatePersistence(t *testing.T) {
+	p, _ := makePlayer(3, protocol.ConsensusV1)
+	proposal, pv := testProposal(3, 0, 1)
+	value := *proposal.Value()
+
+	p.handle(messageEvent{T: voteVerified, Input: message{Vote: pv}})
+	p.handle(messageEvent{T: payloadVerified, Input: message{Proposal: proposal}})
+	p.handle(timeoutEvent{T: timeout, Round: 3})
+	p.Pending = &thresholdEvent{T: certThreshold, Round: 3, Step: uint64(cert), Proposal: value}
+
+	votes := []unauthenticatedVote{pv.u()}
+	db := ethdb.NewMemDatabase()
+	if err := writeAgreementState(db, encodeState(p, votes)); err != nil {
+		t.Fatalf("failed to persist state: %v", err)
+	}
+	state, err := readAgreementState(db)
+	if err != nil {
+		t.Fatalf("failed to read state: %v", err)
+	}
+	restored := state.player()
+
+	// The restored player must encode to the very same state
+	want, _ := rlp.EncodeToBytes(encodeState(p, votes))
+	have, _ := rlp.EncodeToBytes(encodeState(restored, state.Votes))
+	if !bytes.Equal(have, want) {
+		t.Fatalf("restored state mismatch:\nhave %x\nwant %x", have, want)
+	}
+	if restored.Step != cert || restored.LowestCred == nil || restored.Pending == nil || restored.Pending.T != certThreshold {
+		t.Fatalf("restored player mismatch: %+v", restored)
+	}
+	if _, ok := restored.Payloads[value.key()]; !ok {
+		t.Fatalf("restored payload missing")
+	}
+}
+
+func TestServiceRestart(t *testing.T) {
+	key, _ := crypto.HexToECDSA("b71c71a67e1177ad4e901695e1b4b9ee17ae16c6668d313eac2f96dbcda3f291")
+	engine, chain := newTestParticipants(t, key)
+	defer chain.Stop()
+	engine.Authorize(key)
+
+	run := func(height uint64) *Service {
+		clock := newTestClock()
+		factory := &testFactory{chain: chain, engine: engine, assembled: make(chan uint64, 16)}
+		service := NewService(engine, Parameters{Ledger: &testLedger{chain}, Factory: factory, Clock: clock})
+		service.Start()
+		defer service.Stop()
+
+		driveAgreement(t, chain, clock, factory, height)
+		return service
+	}
+	stopped := run(3)
+
+	// A restarted service resumes the persisted round with the votes it sent
+	state, err := readAgreementState(engine.db)
+	if err != nil {
+		t.Fatalf("failed to read state: %v", err)
+	}
+	if state.Round != 4 || state.Round != uint64(stopped.player.Round) {
+		t.Fatalf("persisted round mismatch: have %d, want 4", state.Round)
+	}
+	service := NewService(engine, Parameters{Ledger: &testLedger{chain}, Clock: newTestClock()})
+	if restored := service.restore(4); restored == nil || len(restored.Votes) != len(stopped.sent) {
+		t.Fatalf("restored state mismatch: have %v, want %d votes", restored, len(stopped.sent))
+	}
+	if service.restore(5) != nil {
+		t.Fatalf("state of another round restored")
+	}
+	// And keeps on agreeing afterwards
+	run(6)
+}
+
+func TestServiceDoubleVote(t *testing.T) {
+	key, _ := crypto.HexToECDSA("b71c71a67e1177ad4e901695e1b4b9ee17ae16c6668d313eac2f96dbcda3f291")
+	other, _ := crypto.GenerateKey()
+	engine, chain := newTestParticipants(t, key, other)
+	defer chain.Stop()
+
+	first := signVote(t, engine, chain, key, soft, ProposalValue{BlockDigest: common.HexToHash("0x01")})
+	second := signVote(t, engine, chain, key, soft, ProposalValue{BlockDigest: common.HexToHash("0x02")})
+	foreign := signVote(t, engine, chain, other, soft, ProposalValue{BlockDigest: common.HexToHash("0x02")})
+	engine.Authorize(key)
+
+	service := NewService(engine, Parameters{Ledger: &testLedger{chain}, Clock: newTestClock()})
+	service.player, _ = makePlayer(1, protocol.ConsensusV1)
+
+	for _, uv := range []unauthenticatedVote{first, foreign} {
+		v, err := uv.verify(engine, chain)
+		if err != nil {
+			t.Fatalf("failed to verify vote: %v", err)
+		}
+		if err := service.record(v); err != nil {
+			t.Fatalf("failed to record vote: %v", err)
+		}
+	}
+	v, err := second.verify(engine, chain)
+	if err != nil {
+		t.Fatalf("failed to verify vote: %v", err)
+	}
+	if err := service.record(v); err != errDoubleVote {
+		t.Fatalf("double vote error mismatch: have %v, want %v", err, errDoubleVote)
+	}
+	// Only the first own vote is persisted
+	state, err := readAgreementState(engine.db)
+	if err != nil {
+		t.Fatalf("failed to read state: %v", err)
+	}
+	if len(state.Votes) != 1 || HashObj(state.Votes[0]) != HashObj(first) {
+		t.Fatalf("persisted votes mismatch: have %v", state.Votes)
+	}
+}
@@ -0,0 +1,282 @@
+package algo
+
+import (
+	"time"
+
+	"github.com/awesome-chain/Xchain/common"
+	"github.com/awesome-chain/Xchain/consensus/algo/committee"
+	"github.com/awesome-chain/Xchain/consensus/algo/protocol"
+)
+
+// player is the top-level state machine of the agreement protocol. It tracks
+// the current round, period and step and turns the events delivered to it into
+// actions, which are carried out by the agreement service.
+//
+// The player never reads the time itself. Instead, the service delivers a
+// timeout event once Deadline (or a fastTimeout event once FastRecoveryDeadline)
+// has elapsed on its Clock since the start of the period.
+type player struct {
+	Round  round
+	Period period
+	Step   step
+
+	// Proto is the version of the protocol of the current round, which
+	// determines its timeouts.
+	Proto protocol.ConsensusVersion
+
+	// Deadline is the offset from the start of the period at which the next
+	// timeout fires. It is ignored while Napping.
+	Deadline time.Duration
+
+	// FastRecoveryDeadline is the offset from the start of the period at which
+	// the next fastTimeout fires.
+	FastRecoveryDeadline time.Duration
+
+	// Napping is set once the player ran out of next steps in a period and
+	// only waits for fast partition recovery.
+	Napping bool
+
+	// Pinned is the value of the next bundle which concluded the previous
+	// period. It must be carried over into the current period.
+	Pinned ProposalValue
+
+	// Lowest is the proposal-value with the lowest credential seen in the
+	// propose step of the current period.
+	Lowest     ProposalValue
+	LowestCred *committee.Credential
+
+	// Staging is the value which reached a soft threshold in the current
+	// period, and CertVoted is set once it was cert-voted.
+	Staging   ProposalValue
+	CertVoted bool
+
+	// Payloads holds the verified proposals of the current round.
+	Payloads map[common.Hash]*Proposal
+
+	// Pending is the certificate of a committed value whose payload has not
+	// been received yet.
+	Pending *thresholdEvent
+}
+
+// makePlayer creates a player and the actions to start agreeing on round r,
+// which runs the given version of the protocol.
+func makePlayer(r round, proto protocol.ConsensusVersion) (*player, []action) {
+	p := new(player)
+	return p, p.enterRound(r, proto)
+}
+
+// params returns the parameters of the protocol of the current round.
+func (p *player) params() ConsensusParams {
+	return Consensus[p.Proto]
+}
+
+// handle delivers an event to the player, returning the actions to carry out.
+func (p *player) handle(e event) []action {
+	switch e.t() {
+	case timeout, fastTimeout:
+		ev := e.(timeoutEvent)
+		if ev.Round != p.Round || ev.Period != p.Period {
+			return nil
+		}
+		if ev.T == fastTimeout {
+			return p.handleFastTimeout()
+		}
+		return p.handleTimeout()
+
+	case roundInterruption:
+		ev := e.(roundInterruptionEvent)
+		if ev.Round > p.Round && ev.Proto.Err == nil {
+			return p.enterRound(ev.Round, ev.Proto.Version)
+		}
+
+	case softThreshold, certThreshold, nextThreshold:
+		return p.handleThreshold(e.(thresholdEvent))
+
+	case voteVerified:
+		p.handleProposalVote(e.(messageEvent).Input.Vote)
+
+	case payloadVerified:
+		return p.handlePayload(e.(messageEvent).Input.Proposal)
+	}
+	return nil
+}
+
+// handleTimeout advances the step of the player once its deadline elapsed.
+func (p *player) handleTimeout() []action {
+	if p.Napping {
+		return nil
+	}
+	if p.Step == soft {
+		// Stop waiting for proposals and soft-vote the best one
+		p.Step, p.Deadline = cert, p.params().deadlineTimeout()
+
+		value := p.Lowest
+		if !p.Pinned.isBottom() {
+			value = p.Pinned
+		}
+		if value.isBottom() {
+			return nil
+		}
+		return []action{p.vote(soft, value)}
+	}
+	if p.Step == cert {
+		p.Step = next
+	} else {
+		p.Step++
+	}
+	p.Deadline = p.params().deadlineTimeout() + p.params().SmallLambda*time.Duration(p.Step-next+1)
+	if p.Step >= late-1 {
+		p.Napping = true
+	}
+	return []action{p.vote(p.Step, p.nextValue())}
+}
+
+// handleFastTimeout issues a fast partition recovery vote.
+func (p *player) handleFastTimeout() []action {
+	p.FastRecoveryDeadline += p.params().FastRecoveryLambda
+
+	switch {
+	case p.certifiable():
+		return []action{p.vote(late, p.Staging)}
+	case !p.Pinned.isBottom():
+		return []action{p.vote(redo, p.Pinned)}
+	default:
+		return []action{p.vote(down, bottom)}
+	}
+}
+
+// handleThreshold acts on a threshold reached by the votes of some step.
+func (p *player) handleThreshold(e thresholdEvent) []action {
+	if round(e.Round) != p.Round {
+		return nil
+	}
+	switch e.T {
+	case certThreshold:
+		// A certificate from any period of the round commits its value
+		if payload, ok := p.Payloads[e.Proposal.key()]; ok {
+			return p.commit(payload, e.Bundle)
+		}
+		p.Pending = &e
+		return nil
+
+	case softThreshold:
+		if period(e.Period) != p.Period {
+			return nil
+		}
+		p.Staging = e.Proposal
+		return p.certVote()
+
+	case nextThreshold:
+		if period(e.Period) < p.Period {
+			return nil
+		}
+		return p.enterPeriod(period(e.Period)+1, e.Proposal)
+	}
+	return nil
+}
+
+// handleProposalVote tracks the proposal-vote with the lowest credential until
+// the proposals of the period are frozen by the soft vote.
+func (p *player) handleProposalVote(v vote) {
+	if step(v.R.Step) != propose || round(v.R.Round) != p.Round || period(v.R.Period) != p.Period || p.Step != soft {
+		return
+	}
+	if !v.Cred.Selected() {
+		return
+	}
+	if p.LowestCred == nil || v.Cred.Less(*p.LowestCred) {
+		cred := v.Cred
+		p.Lowest, p.LowestCred = v.R.Proposal, &cred
+	}
+}
+
+// handlePayload stores a verified proposal of the current round and resumes
+// any progress which was waiting for it.
+func (p *player) handlePayload(proposal *Proposal) []action {
+	if proposal == nil || round(proposal.NumberU64()) != p.Round {
+		return nil
+	}
+	value := *proposal.Value()
+	p.Payloads[value.key()] = proposal
+
+	if p.Pending != nil && p.Pending.Proposal.key() == value.key() {
+		return p.commit(proposal, p.Pending.Bundle)
+	}
+	if p.Staging.key() == value.key() {
+		return p.certVote()
+	}
+	return nil
+}
+
+// certVote cert-votes the staging value once its payload is available, as long
+// as the deadline of the period has not passed.
+func (p *player) certVote() []action {
+	if p.CertVoted || p.Step > cert || !p.certifiable() {
+		return nil
+	}
+	p.CertVoted = true
+	return []action{p.vote(cert, p.Staging)}
+}
+
+// certifiable returns whether the staging value can be voted for, i.e. it
+// reached a soft threshold and its payload is available.
+func (p *player) certifiable() bool {
+	if p.Staging.isBottom() {
+		return false
+	}
+	_, ok := p.Payloads[p.Staging.key()]
+	return ok
+}
+
+// nextValue returns the value to next-vote for in the current period.
+func (p *player) nextValue() ProposalValue {
+	switch {
+	case p.certifiable():
+		return p.Staging
+	case !p.Pinned.isBottom():
+		return p.Pinned
+	default:
+		return bottom
+	}
+}
+
+// vote creates the action to vote for a value in a step of the current period.
+func (p *player) vote(s step, value ProposalValue) action {
+	return pseudonodeAction{T: attest, Round: p.Round, Period: p.Period, Step: s, Proposal: value}
+}
+
+// commit creates the actions to commit a block and to move to the next round,
+// whose protocol version is determined by the upgrade state of the block.
+func (p *player) commit(payload *Proposal, cert unauthenticatedBundle) []action {
+	proto := p.Proto
+	if extra, err := decodeHeaderExtra(payload.Header()); err == nil {
+		proto = extra.UpgradeState.versionAt(uint64(p.Round) + 1)
+	}
+	actions := []action{ensureAction{Payload: payload, Certificate: cert}}
+	return append(actions, p.enterRound(p.Round+1, proto)...)
+}
+
+// enterRound resets the player to the first period of round r.
+func (p *player) enterRound(r round, proto protocol.ConsensusVersion) []action {
+	p.Round, p.Proto = r, proto
+	p.Payloads = make(map[common.Hash]*Proposal)
+	p.Pending = nil
+	return p.enterPeriod(0, bottom)
+}
+
+// enterPeriod resets the player to the start of period per. A period which is
+// not pinned to a value starts with fresh proposals.
+func (p *player) enterPeriod(per period, pinned ProposalValue) []action {
+	p.Period, p.Step = per, soft
+	p.Deadline, p.FastRecoveryDeadline = p.params().filterTimeout(per), p.params().FastRecoveryLambda
+	p.Napping = false
+	p.Pinned = pinned
+	p.Lowest, p.LowestCred = bottom, nil
+	p.Staging, p.CertVoted = bottom, false
+
+	actions := []action{rezeroAction{}}
+	if pinned.isBottom() {
+		actions = append(actions, pseudonodeAction{T: assemble, Round: p.Round, Period: p.Period})
+	}
+	return actions
+}
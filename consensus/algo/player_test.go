@@ -0,0 +1,320 @@
+package algo
+
+import (
+	"math/big"
+	"math/rand"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/awesome-chain/Xchain/consensus/algo/committee"
+	"github.com/awesome-chain/Xchain/consensus/algo/protocol"
+	"github.com/awesome-chain/Xchain/core"
+	"github.com/awesome-chain/Xchain/core/types"
+	"github.com/awesome-chain/Xchain/crypto"
+)
+
+// testClock is a Clock whose time only advances when the test says so.
+type testClock struct {
+	lock     sync.Mutex
+	now      time.Duration
+	timeouts map[time.Duration]chan time.Time
+}
+
+func newTestClock() *testClock {
+	return &testClock{timeouts: make(map[time.Duration]chan time.Time)}
+}
+
+// Zero implements Clock, resetting the clock in place so the test keeps
+// control over it.
+func (c *testClock) Zero() Clock {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	c.now = 0
+	c.timeouts = make(map[time.Duration]chan time.Time)
+	return c
+}
+
+// TimeoutAt implements Clock.
+func (c *testClock) TimeoutAt(delta time.Duration) <-chan time.Time {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	ch, ok := c.timeouts[delta]
+	if !ok {
+		ch = make(chan time.Time)
+		if delta <= c.now {
+			close(ch)
+		}
+		c.timeouts[delta] = ch
+	}
+	return ch
+}
+
+// advance moves the clock forward, firing all the timeouts which elapsed.
+func (c *testClock) advance(d time.Duration) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	prev := c.now
+	c.now += d
+	for delta, ch := range c.timeouts {
+		if delta > prev && delta <= c.now {
+			close(ch)
+		}
+	}
+}
+
+// expectActions checks that a list of actions has the given types.
+func expectActions(t *testing.T, have []action, want ...actionType) {
+	t.Helper()
+	if len(have) != len(want) {
+		t.Fatalf("action count mismatch: have %v, want %v", have, want)
+	}
+	for i := range have {
+		if have[i].t() != want[i] {
+			t.Fatalf("action %d mismatch: have %v, want %v", i, have[i], want[i])
+		}
+	}
+}
+
+// expectVote checks that an action is a vote in a step for a value.
+func expectVote(t *testing.T, a action, s step, value ProposalValue) {
+	t.Helper()
+	v, ok := a.(pseudonodeAction)
+	if !ok || v.T != attest || v.Step != s || v.Proposal.key() != value.key() {
+		t.Fatalf("vote mismatch: have %v, want %v vote for %.5x", a, s, value.BlockDigest)
+	}
+}
+
+// testProposal creates a proposal for a round and its proposal-vote.
+func testProposal(r round, p period, nonce uint64) (*Proposal, vote) {
+	key, _ := crypto.HexToECDSA("b71c71a67e1177ad4e901695e1b4b9ee17ae16c6668d313eac2f96dbcda3f291")
+	header := &types.Header{Number: new(big.Int).SetUint64(uint64(r)), Nonce: types.EncodeNonce(nonce)}
+	proposal := MakeProposal(types.NewBlock(header, nil, nil, nil), nil, uint64(p), &key.PublicKey)
+
+	cred := committee.Credential{Weight: 1}
+	cred.VrfOut[0] = byte(nonce)
+	return proposal, vote{
+		R:    rawVote{Round: uint64(r), Period: uint64(p), Step: uint64(propose), Proposal: *proposal.Value()},
+		Cred: cred,
+	}
+}
+
+// threshold creates the threshold event of a step for a value.
+func threshold(r round, p period, s step, value ProposalValue) thresholdEvent {
+	return thresholdEvent{T: s.thresholdType(), Round: uint64(r), Period: uint64(p), Step: uint64(s), Proposal: value}
+}
+
+func TestPlayerTimeouts(t *testing.T) {
+	params := Consensus[protocol.ConsensusV1]
+	p, actions := makePlayer(1, protocol.ConsensusV1)
+	expectActions(t, actions, rezero, assemble)
+
+	// Without any proposal the soft step passes silently
+	if p.Deadline != params.filterTimeout(0) {
+		t.Fatalf("filter deadline mismatch: have %v, want %v", p.Deadline, params.filterTimeout(0))
+	}
+	expectActions(t, p.handle(timeoutEvent{T: timeout, Round: 1}))
+	if p.Step != cert || p.Deadline != params.deadlineTimeout() {
+		t.Fatalf("cert step mismatch: have %v at %v, want %v at %v", p.Step, p.Deadline, cert, params.deadlineTimeout())
+	}
+	// Every further timeout next-votes bottom until the player naps
+	for s := next; s <= late-1; s++ {
+		actions := p.handle(timeoutEvent{T: timeout, Round: 1})
+		expectActions(t, actions, attest)
+		expectVote(t, actions[0], s, bottom)
+		if want := params.deadlineTimeout() + params.SmallLambda*time.Duration(s-next+1); p.Deadline != want {
+			t.Fatalf("step %v: deadline mismatch: have %v, want %v", s, p.Deadline, want)
+		}
+	}
+	if !p.Napping {
+		t.Fatalf("player not napping after last next step")
+	}
+	expectActions(t, p.handle(timeoutEvent{T: timeout, Round: 1}))
+
+	// Fast recovery keeps going while napping
+	actions = p.handle(timeoutEvent{T: fastTimeout, Round: 1})
+	expectActions(t, actions, attest)
+	expectVote(t, actions[0], down, bottom)
+	if p.FastRecoveryDeadline != 2*params.FastRecoveryLambda {
+		t.Fatalf("fast recovery deadline mismatch: have %v, want %v", p.FastRecoveryDeadline, 2*params.FastRecoveryLambda)
+	}
+	// Stale timeouts are ignored
+	expectActions(t, p.handle(timeoutEvent{T: fastTimeout, Round: 1, Period: 1}))
+
+	// A next bundle for bottom starts a fresh period
+	actions = p.handle(threshold(1, 0, down, bottom))
+	expectActions(t, actions, rezero, assemble)
+	if p.Period != 1 || p.Step != soft || p.Napping {
+		t.Fatalf("new period mismatch: have period %v step %v napping %v", p.Period, p.Step, p.Napping)
+	}
+}
+
+func TestPlayerRounds(t *testing.T) {
+	p, actions := makePlayer(1, protocol.ConsensusV1)
+	rng := rand.New(rand.NewSource(1))
+
+	for r := round(1); r <= 5000; r++ {
+		expectActions(t, actions, rezero, assemble)
+		if p.Round != r || p.Period != 0 {
+			t.Fatalf("round mismatch: have (%v, %v), want (%v, 0)", p.Round, p.Period, r)
+		}
+		// Deliver a few competing proposals, the lowest credential must win
+		var lowest ProposalValue
+		for i := 0; i < 1+rng.Intn(3); i++ {
+			proposal, pv := testProposal(r, p.Period, uint64(rng.Intn(256)))
+			expectActions(t, p.handle(messageEvent{T: voteVerified, Input: message{Vote: pv}}))
+			expectActions(t, p.handle(messageEvent{T: payloadVerified, Input: message{Proposal: proposal}}))
+			if p.Lowest.key() == pv.R.Proposal.key() {
+				lowest = p.Lowest
+			}
+		}
+		actions = p.handle(timeoutEvent{T: timeout, Round: r, Period: p.Period})
+		expectActions(t, actions, attest)
+		expectVote(t, actions[0], soft, lowest)
+
+		// Soft threshold reached, cert vote issued
+		actions = p.handle(threshold(r, p.Period, soft, lowest))
+		expectActions(t, actions, attest)
+		expectVote(t, actions[0], cert, lowest)
+
+		// Occasionally the certificate does not form in time and the value is
+		// carried over into the next period
+		if rng.Intn(4) == 0 {
+			actions = p.handle(timeoutEvent{T: timeout, Round: r, Period: p.Period})
+			expectActions(t, actions, attest)
+			expectVote(t, actions[0], next, lowest)
+
+			actions = p.handle(threshold(r, p.Period, next, lowest))
+			expectActions(t, actions, rezero)
+			if p.Period != 1 || p.Pinned.key() != lowest.key() {
+				t.Fatalf("round %v: pinned period mismatch: have (%v, %.5x)", r, p.Period, p.Pinned.BlockDigest)
+			}
+			actions = p.handle(timeoutEvent{T: timeout, Round: r, Period: p.Period})
+			expectActions(t, actions, attest)
+			expectVote(t, actions[0], soft, lowest)
+
+			actions = p.handle(threshold(r, p.Period, soft, lowest))
+			expectActions(t, actions, attest)
+			expectVote(t, actions[0], cert, lowest)
+		}
+		actions = p.handle(threshold(r, p.Period, cert, lowest))
+		if len(actions) == 0 || actions[0].t() != ensure {
+			t.Fatalf("round %v: missing ensure action: %v", r, actions)
+		}
+		if have := actions[0].(ensureAction).Payload.Value().key(); have != lowest.key() {
+			t.Fatalf("round %v: committed wrong value", r)
+		}
+		actions = actions[1:]
+	}
+}
+
+func TestPlayerCertificateBeforePayload(t *testing.T) {
+	p, _ := makePlayer(1, protocol.ConsensusV1)
+	proposal, _ := testProposal(1, 0, 1)
+	value := *proposal.Value()
+
+	// A certificate without its payload must wait for it
+	expectActions(t, p.handle(threshold(1, 0, cert, value)))
+	if p.Round != 1 {
+		t.Fatalf("round advanced without payload")
+	}
+	actions := p.handle(messageEvent{T: payloadVerified, Input: message{Proposal: proposal}})
+	expectActions(t, actions, ensure, rezero, assemble)
+	if p.Round != 2 {
+		t.Fatalf("round mismatch: have %v, want 2", p.Round)
+	}
+	// Thresholds of past rounds are ignored
+	expectActions(t, p.handle(threshold(1, 0, cert, value)))
+}
+
+// testLedger commits agreed blocks into a blockchain.
+type testLedger struct {
+	*core.BlockChain
+}
+
+func (l *testLedger) EnsureBlock(block *types.Block) error {
+	_, err := l.InsertChain(types.Blocks{block})
+	return err
+}
+
+// testFactory assembles empty blocks on top of the chain, announcing the round
+// of every assembled block.
+type testFactory struct {
+	chain     *core.BlockChain
+	engine    *Algorand
+	assembled chan uint64
+}
+
+func (f *testFactory) AssembleBlock(r uint64, deadline time.Time) (*types.Block, error) {
+	parent := f.chain.CurrentBlock()
+	header := &types.Header{
+		ParentHash: parent.Hash(),
+		Number:     new(big.Int).SetUint64(r),
+		GasLimit:   core.CalcGasLimit(parent),
+		Time:       new(big.Int).Set(parent.Time()),
+	}
+	if err := f.engine.Prepare(f.chain, header); err != nil {
+		return nil, err
+	}
+	statedb, err := f.chain.StateAt(parent.Root())
+	if err != nil {
+		return nil, err
+	}
+	block, err := f.engine.Finalize(f.chain, header, statedb, nil, nil, nil)
+	if err == nil {
+		select {
+		case f.assembled <- r:
+		default:
+		}
+	}
+	return block, err
+}
+
+// driveAgreement advances the clock of a running service until the chain
+// reaches the given height. The participants hold all the stake, so every round
+// concludes as soon as the proposals are frozen. Only the real time needed for
+// the proposal to reach the player passes between the timeouts.
+func driveAgreement(t *testing.T, chain *core.BlockChain, clock *testClock, factory *testFactory, height uint64) {
+	t.Helper()
+
+	params := Consensus[protocol.ConsensusV1]
+	deadline := time.After(time.Minute)
+	for chain.CurrentBlock().NumberU64() < height {
+		select {
+		case <-factory.assembled:
+			time.Sleep(50 * time.Millisecond)
+			clock.advance(params.filterTimeout(0))
+		case <-time.After(200 * time.Millisecond):
+			clock.advance(params.SmallLambda)
+		case <-deadline:
+			t.Fatalf("agreement stalled at block %d", chain.CurrentBlock().NumberU64())
+		}
+	}
+}
+
+func TestServiceAgreement(t *testing.T) {
+	key, _ := crypto.HexToECDSA("b71c71a67e1177ad4e901695e1b4b9ee17ae16c6668d313eac2f96dbcda3f291")
+	engine, chain := newTestParticipants(t, key)
+	defer chain.Stop()
+	engine.Authorize(key)
+
+	clock := newTestClock()
+	factory := &testFactory{chain: chain, engine: engine, assembled: make(chan uint64, 16)}
+	service := NewService(engine, Parameters{
+		Ledger:  &testLedger{chain},
+		Factory: factory,
+		Clock:   clock,
+	})
+	service.Start()
+	defer service.Stop()
+
+	driveAgreement(t, chain, clock, factory, 10)
+	for i := uint64(1); i <= 10; i++ {
+		if author, err := engine.Author(chain.GetHeaderByNumber(i)); err != nil || author != crypto.PubkeyToAddress(key.PublicKey) {
+			t.Fatalf("block %d: author mismatch: have %x, err %v", i, author, err)
+		}
+	}
+}
@@ -4,8 +4,11 @@ import (
 	"context"
 	"crypto/ecdsa"
 	"fmt"
+	"io"
+
 	"github.com/awesome-chain/Xchain/common"
 	"github.com/awesome-chain/Xchain/consensus"
+	"github.com/awesome-chain/Xchain/consensus/algo/beacon"
 	"github.com/awesome-chain/Xchain/consensus/algo/protocol"
 	"github.com/awesome-chain/Xchain/core/types"
 	"github.com/awesome-chain/Xchain/crypto"
@@ -13,7 +16,8 @@ import (
 	"github.com/awesome-chain/Xchain/rlp"
 )
 
-//var bottom ProposalValue
+// bottom is the proposal-value voted for when no proposal is to be committed.
+var bottom ProposalValue
 
 // ProposalValue is a triplet of a block hashes (the contents themselves and the encoding of the block),
 // its proposer, and the period in which it was proposed.
@@ -24,6 +28,16 @@ type ProposalValue struct {
 	EncodingDigest   common.Hash
 }
 
+// isBottom returns whether the proposal-value is the empty value.
+func (v ProposalValue) isBottom() bool {
+	return v.BlockDigest == bottom.BlockDigest && v.EncodingDigest == bottom.EncodingDigest && v.OriginalPeriod == 0 && len(v.OriginalProposer) == 0
+}
+
+// key returns a comparable digest of the proposal-value.
+func (v ProposalValue) key() common.Hash {
+	return rlpHash(v)
+}
+
 // UnauthenticatedProposal is an Block along with everything needed to validate it.
 type UnauthenticatedProposal struct {
 	*types.Block
@@ -32,6 +46,35 @@ type UnauthenticatedProposal struct {
 	OriginalProposer []byte
 }
 
+// extProposal is the wire and disk representation of a proposal.
+type extProposal struct {
+	Block            *types.Block
+	SeedProof        []byte
+	OriginalPeriod   uint64
+	OriginalProposer []byte
+}
+
+// EncodeRLP implements rlp.Encoder. Without it the encoder of the embedded block
+// would be promoted, dropping the fields of the proposal.
+func (p UnauthenticatedProposal) EncodeRLP(w io.Writer) error {
+	return rlp.Encode(w, extProposal{
+		Block:            p.Block,
+		SeedProof:        p.SeedProof,
+		OriginalPeriod:   p.OriginalPeriod,
+		OriginalProposer: p.OriginalProposer,
+	})
+}
+
+// DecodeRLP implements rlp.Decoder.
+func (p *UnauthenticatedProposal) DecodeRLP(s *rlp.Stream) error {
+	var ep extProposal
+	if err := s.Decode(&ep); err != nil {
+		return err
+	}
+	p.Block, p.SeedProof, p.OriginalPeriod, p.OriginalProposer = ep.Block, ep.SeedProof, ep.OriginalPeriod, ep.OriginalProposer
+	return nil
+}
+
 // ToBeHashed implements the Hashable interface.
 func (p *UnauthenticatedProposal) ToBeHashed() (protocol.HashID, []byte, error) {
 	bs, err := rlp.EncodeToBytes(p)
@@ -87,111 +130,47 @@ func (p *Proposal) U() *UnauthenticatedProposal {
 	return &p.UnauthenticatedProposal
 }
 
-// A proposerSeed is a Hashable input to proposer seed derivation.
-type ProposerSeed struct {
-	Addr common.Address
-	VRF  vrf.Output
-}
-
-// ToBeHashed implements the Hashable interface.
-func (s *ProposerSeed) ToBeHashed() (protocol.HashID, []byte, error) {
-	bs, err := rlp.EncodeToBytes(s)
-	if err != nil {
-		return "", nil, err
-	}
-	return protocol.ProposerSeed, bs, nil
-}
-
-// A seedInput is a Hashable input to seed rerandomization.
-type SeedInput struct {
-	Alpha   common.Hash
-	History common.Hash
-}
-
-// ToBeHashed implements the Hashable interface.
-func (i *SeedInput) ToBeHashed() (protocol.HashID, []byte, error) {
-	bs, err := rlp.EncodeToBytes(i)
-	if err != nil {
-		return "", nil, err
-	}
-	return protocol.ProposerSeed, bs, nil
-}
-
-func DeriveNewSeed(address common.Address, vrfSK *vrf.PrivateKey, rnd uint64, period uint64, ledger consensus.ChainReader) (newSeed common.Seed, seedProof vrf.Proof, err error) {
-	var seedRound uint64
-	var alpha common.Hash
+func DeriveNewSeed(address common.Address, vrfSK *vrf.PrivateKey, rnd uint64, period uint64, params ConsensusParams, ledger consensus.ChainReader) (newSeed common.Seed, seedProof vrf.Proof, err error) {
 	var output vrf.Output
-	//TODO read config
-	if rnd > 2 {
-		seedRound = rnd - 2
+	prevHeader := ledger.GetHeaderByNumber(params.seedRound(rnd))
+	if prevHeader == nil {
+		return newSeed, nil, errMissingSeed
 	}
-	prevHeader := ledger.GetHeaderByNumber(uint64(seedRound))
 	prevSeed := prevHeader.Seed
 	if period == 0 {
 		output, seedProof = vrfSK.Evaluate(prevSeed[:])
-		alpha = HashObj(&ProposerSeed{
-			Addr: address,
-			VRF:  output,
-		})
-	} else {
-		alpha = common.Hash(prevSeed)
-	}
-	input := SeedInput{
-		Alpha: alpha,
 	}
-	newSeed = common.Seed(HashObj(&input))
+	newSeed = beacon.DeriveSeed(prevSeed, address, output, period)
 	return
 }
 
-func VerifyNewSeed(p *UnauthenticatedProposal, ledger consensus.ChainReader) error {
-	var seedRound uint64
-	var alpha common.Hash
-	value := p.Value()
-	rnd := p.Number().Uint64()
-	//TODO read config
-	if rnd > 2 {
-		seedRound = rnd - 2
+func VerifyNewSeed(p *UnauthenticatedProposal, params ConsensusParams, ledger consensus.ChainReader) error {
+	prevHeader := ledger.GetHeaderByNumber(params.seedRound(p.Number().Uint64()))
+	if prevHeader == nil {
+		return errMissingSeed
 	}
-	prevHeader := ledger.GetHeaderByNumber(uint64(seedRound))
-	prevSeed := prevHeader.Seed
-
-	if value.OriginalPeriod == 0 {
-		pubKey := crypto.ToECDSAPub(p.OriginalProposer)
-		var vrfPubKey vrf.PublicKey
-		vrfPubKey.PublicKey = pubKey
-		output, err := vrfPubKey.ProofToHash(prevSeed[:], p.SeedProof[:])
-		if err != nil {
-			return fmt.Errorf("verify proof error: [%v]", err)
-		}
-		if output == emptyOutput {
-			return fmt.Errorf("verify failed: [%v]", output)
-		}
-		alpha = HashObj(&ProposerSeed{
-			Addr: crypto.PubkeyToAddress(*pubKey),
-			VRF:  output,
-		})
-	} else {
-		alpha = common.Hash(prevSeed)
+	// The header carries the period it was proposed and its seed derived in
+	extra, err := decodeHeaderExtra(p.Header())
+	if err != nil {
+		return err
 	}
-
-	input := SeedInput{Alpha: alpha}
-
-	if p.Seed() != common.Seed(HashObj(&input)) {
-		return fmt.Errorf("payload seed malformed (%v != %v)", common.Seed(HashObj(&input)), p.Seed())
+	if extra.Period != p.OriginalPeriod {
+		return errPeriodMismatch
 	}
-	return nil
+	pubKey := crypto.ToECDSAPub(p.OriginalProposer)
+	return beacon.VerifySeed(prevHeader.Seed, p.Seed(), pubKey, extra.Period, extra.SeedProof)
 }
 
 // Validate returns true if the proposal is valid.
 // It checks the proposal seed and then calls validator.Validate.
-func (p UnauthenticatedProposal) Validate(ctx context.Context, current uint64, ledger consensus.ChainReader, validator BlockValidator) (*Proposal, error) {
+func (p UnauthenticatedProposal) Validate(ctx context.Context, current uint64, params ConsensusParams, ledger consensus.ChainReader, validator BlockValidator) (*Proposal, error) {
 	entry := p.Block
 
 	if entry.Number().Uint64() != current {
 		return nil, fmt.Errorf("proposed entry from wrong round: entry.Round() != current: %v != %v", Round(entry.Number().Uint64()), current)
 	}
 
-	err := VerifyNewSeed(&p, ledger)
+	err := VerifyNewSeed(&p, params, ledger)
 	if err != nil {
 		return nil, fmt.Errorf("proposal has bad seed: %v", err)
 	}
@@ -1,6 +1,7 @@
 package algo
 
 import (
+	"github.com/awesome-chain/Xchain/consensus/algo/protocol"
 	"github.com/awesome-chain/Xchain/consensus/ethash"
 	"github.com/awesome-chain/Xchain/core"
 	"github.com/awesome-chain/Xchain/core/types"
@@ -34,7 +35,7 @@ func TestProposal(t *testing.T) {
 		Coinbase: address,
 		Number:   new(big.Int).SetUint64(1),
 	}
-	newSeed, seedProof, err := DeriveNewSeed(address, &vrfSK, 1, 0, chain)
+	newSeed, seedProof, err := DeriveNewSeed(address, &vrfSK, 1, 0, Consensus[protocol.ConsensusV1], chain)
 	if err != nil {
 		t.Fatal(err)
 	}
@@ -47,6 +48,6 @@ func TestProposal(t *testing.T) {
 	//header.Sig = sig
 	b := types.NewBlock(header, nil, nil, nil)
 	p := MakeProposal(b, seedProof[:], 0, &key.PublicKey)
-	VerifyNewSeed(&p.UnauthenticatedProposal, chain)
+	VerifyNewSeed(&p.UnauthenticatedProposal, Consensus[protocol.ConsensusV1], chain)
 	defer chain.Stop()
 }
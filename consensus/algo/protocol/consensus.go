@@ -0,0 +1,16 @@
+package protocol
+
+// ConsensusVersion is a string that identifies a version of the consensus
+// protocol, i.e. a set of consensus parameters.
+type ConsensusVersion string
+
+// ConsensusV1 is the baseline version of the algo agreement protocol.
+const ConsensusV1 = ConsensusVersion("algo-v1")
+
+// ConsensusFuture is a protocol that should not appear in any production
+// network, but is used to test features before they are released.
+const ConsensusFuture = ConsensusVersion("future")
+
+// ConsensusCurrentVersion is the latest version the node supports, and the one
+// its proposers vote to upgrade to.
+const ConsensusCurrentVersion = ConsensusV1
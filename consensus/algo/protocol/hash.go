@@ -27,5 +27,6 @@ const (
 	Seed              HashID = "SD"
 	TestHashable      HashID = "TE"
 	Transaction       HashID = "TX"
+	VoteBundle        HashID = "VB"
 	Vote              HashID = "VO"
 )
@@ -2,37 +2,34 @@ package algo
 
 import (
 	"context"
-	"crypto/ecdsa"
 	"fmt"
-	"github.com/awesome-chain/Xchain/consensus"
-	"github.com/awesome-chain/Xchain/core/types"
-	"github.com/awesome-chain/Xchain/crypto"
-	"github.com/awesome-chain/Xchain/crypto/vrf"
-	"math/big"
 	"sync"
 	"time"
-)
 
-// AssemblyTime is the max amount of time to spend on generating a proposal block.
-const AssemblyTime time.Duration = 250 * time.Millisecond
+	"github.com/awesome-chain/Xchain/log"
+)
 
 // TODO put these in config
 const (
 	pseudonodeVerificationBacklog = 32
 )
+
 var errPseudonodeBacklogFull = fmt.Errorf("pseudonode input channel is full")
 var errPseudonodeVerifierClosedChannel = fmt.Errorf("crypto verifier closed the output channel prematurely")
 var errPseudonodeNoVotes = fmt.Errorf("no valid participation keys to generate votes for given round")
 var errPseudonodeNoProposals = fmt.Errorf("no valid participation keys to generate proposals for given round")
 
-
+// AsyncPseudoNode creates the proposals and votes of the local participation
+// key on behalf of the player, off the main agreement loop.
 type AsyncPseudoNode struct {
-	factory   BlockFactory
-	validator BlockValidator
-	ledger            consensus.ChainReader
+	engine            *Algorand
+	factory           BlockFactory
+	validator         BlockValidator
+	ledger            Ledger
 	quit              chan struct{}
 	closeWG           *sync.WaitGroup
 	proposalsVerifier *PseudoNodeVerifier
+	votesVerifier     *PseudoNodeVerifier
 }
 
 // PseudoNodeTask encapsulates a single task which should be executed by the pseudonode.
@@ -53,11 +50,39 @@ type PseudoNodeProposalsTask struct {
 	period uint64
 }
 
+type PseudoNodeVotesTask struct {
+	PseudoNodeBaseTask
+	round    uint64
+	period   uint64
+	step     step
+	proposal ProposalValue
+}
+
 type PseudoNodeVerifier struct {
 	verifier      *AsyncVoteVerifier
 	incomingTasks chan PseudoNodeTask
 }
 
+// MakeAsyncPseudoNode creates a pseudonode proposing blocks assembled by the
+// factory and voting with the participation key authorized on the engine. If a
+// verifier is given, the created votes are checked on it before delivery.
+func MakeAsyncPseudoNode(engine *Algorand, factory BlockFactory, validator BlockValidator, ledger Ledger, verifier *AsyncVoteVerifier) *AsyncPseudoNode {
+	n := &AsyncPseudoNode{
+		engine:    engine,
+		factory:   factory,
+		validator: validator,
+		ledger:    ledger,
+		quit:      make(chan struct{}),
+		closeWG:   &sync.WaitGroup{},
+	}
+	n.proposalsVerifier = n.makePseudonodeVerifier(verifier)
+	n.votesVerifier = n.makePseudonodeVerifier(verifier)
+	return n
+}
+
+// MakeProposals asynchronously assembles the proposals of the participation key
+// for a (round, period). The returned channel delivers a proposal-vote and a
+// payload event for each proposal, and is closed once the task is done.
 func (n *AsyncPseudoNode) MakeProposals(ctx context.Context, r uint64, p uint64) (<-chan externalEvent, error) {
 	proposalTask := n.makeProposalsTask(ctx, r, p)
 	select {
@@ -69,27 +94,96 @@ func (n *AsyncPseudoNode) MakeProposals(ctx context.Context, r uint64, p uint64)
 	}
 }
 
-// makeProposals creates a slice of block proposals for the given round and period.
-func (n *AsyncPseudoNode) makeProposals(round uint64, period uint64, sk *ecdsa.PrivateKey) []*Proposal {
-	//deadline := time.Now().Add(AssemblyTime)
-	proposals := make([]*Proposal, 0)
-	address := crypto.PubkeyToAddress(sk.PublicKey)
-	newSeed, seedProof, err := DeriveNewSeed(address, &vrf.PrivateKey{sk}, round, period, n.ledger)
+// MakeVotes asynchronously creates the vote of the participation key for a
+// proposal-value in a (round, period, step). The returned channel delivers the
+// vote if the key was selected for the committee, and is closed once the task
+// is done.
+func (n *AsyncPseudoNode) MakeVotes(ctx context.Context, r uint64, p uint64, s step, prop ProposalValue) (<-chan externalEvent, error) {
+	voteTask := n.makeVotesTask(ctx, r, p, s, prop)
+	select {
+	case n.votesVerifier.incomingTasks <- voteTask:
+		return voteTask.outputChannel(), nil
+	default:
+		voteTask.close()
+		return nil, errPseudonodeBacklogFull
+	}
+}
+
+// Quit stops the pseudonode, waiting for the running tasks to finish.
+func (n *AsyncPseudoNode) Quit() {
+	// protect against double-quits.
+	select {
+	case <-n.quit:
+		// if we already quit, just exit.
+		return
+	default:
+	}
+	close(n.quit)
+	n.proposalsVerifier.close()
+	n.votesVerifier.close()
+	n.closeWG.Wait()
+}
+
+// makeProposals creates a slice of block proposals for the given round and
+// period, together with their proposal-votes.
+func (n *AsyncPseudoNode) makeProposals(r uint64, p uint64) ([]*Proposal, []vote) {
+	account, key := n.engine.participant()
+	if key == nil || n.factory == nil {
+		return nil, nil
+	}
+	cred, err := n.engine.credential(n.ledger, round(r), period(p), propose)
+	if err != nil || !cred.Selected() {
+		return nil, nil
+	}
+	params, err := n.engine.consensusParams(n.ledger, r)
 	if err != nil {
-		return nil
+		return nil, nil
+	}
+	deadline := time.Now().Add(params.AssemblyTime)
+	block, err := n.factory.AssembleBlock(r, deadline)
+	if err != nil {
+		log.Warn("Failed to assemble proposal", "round", r, "period", p, "err", err)
+		return nil, nil
+	}
+	header := block.Header()
+	if err := n.engine.signProposal(n.ledger, header, period(p), cred.UnauthenticatedCredential); err != nil {
+		log.Warn("Failed to sign proposal", "round", r, "period", p, "err", err)
+		return nil, nil
 	}
-	header := &types.Header{
-		Coinbase: address,
-		Number:   new(big.Int).SetUint64(round),
+	extra, err := decodeHeaderExtra(header)
+	if err != nil {
+		log.Warn("Assembled proposal without consensus fields", "round", r, "err", err)
+		return nil, nil
 	}
-	header.Seed = newSeed
-	b := types.NewBlock(header, nil, nil, nil)
-	p := MakeProposal(b, seedProof[:], 0, &sk.PublicKey)
 	// create the block proposal
-	proposals = append(proposals, p)
-	return proposals
+	proposal := MakeProposal(block.WithSeal(header), extra.SeedProof, p, &key.PublicKey)
+
+	rv := rawVote{Sender: account, Round: r, Period: p, Step: uint64(propose), Proposal: *proposal.Value()}
+	uv, err := makeVote(rv, key, cred.UnauthenticatedCredential)
+	if err != nil {
+		return nil, nil
+	}
+	return []*Proposal{proposal}, []vote{{R: rv, Cred: cred, Sig: uv.Sig}}
 }
 
+// makeVotes creates the votes of the participation key for a proposal-value in
+// the given round, period and step.
+func (n *AsyncPseudoNode) makeVotes(r uint64, p uint64, s step, prop ProposalValue) []vote {
+	account, key := n.engine.participant()
+	if key == nil {
+		return nil
+	}
+	cred, err := n.engine.credential(n.ledger, round(r), period(p), s)
+	if err != nil || !cred.Selected() {
+		return nil
+	}
+	rv := rawVote{Sender: account, Round: r, Period: p, Step: uint64(s), Proposal: prop}
+	uv, err := makeVote(rv, key, cred.UnauthenticatedCredential)
+	if err != nil {
+		return nil
+	}
+	return []vote{{R: rv, Cred: cred, Sig: uv.Sig}}
+}
 
 func (n *AsyncPseudoNode) makeProposalsTask(ctx context.Context, r uint64, p uint64) *PseudoNodeProposalsTask {
 	pt := &PseudoNodeProposalsTask{
@@ -104,14 +198,126 @@ func (n *AsyncPseudoNode) makeProposalsTask(ctx context.Context, r uint64, p uin
 	return pt
 }
 
+func (n *AsyncPseudoNode) makeVotesTask(ctx context.Context, r uint64, p uint64, s step, prop ProposalValue) *PseudoNodeVotesTask {
+	vt := &PseudoNodeVotesTask{
+		PseudoNodeBaseTask: PseudoNodeBaseTask{
+			node:    n,
+			context: ctx,
+			out:     make(chan externalEvent),
+		},
+		round:    r,
+		period:   p,
+		step:     s,
+		proposal: prop,
+	}
+	return vt
+}
+
+func (n *AsyncPseudoNode) makePseudonodeVerifier(verifier *AsyncVoteVerifier) *PseudoNodeVerifier {
+	pv := &PseudoNodeVerifier{
+		verifier:      verifier,
+		incomingTasks: make(chan PseudoNodeTask, pseudonodeVerificationBacklog),
+	}
+	n.closeWG.Add(1)
+	go pv.verifierLoop(n)
+	return pv
+}
+
+// verifierLoop executes the tasks of the verifier until it is closed.
+func (pv *PseudoNodeVerifier) verifierLoop(n *AsyncPseudoNode) {
+	defer n.closeWG.Done()
+	for task := range pv.incomingTasks {
+		task.execute(pv.verifier, n.quit)
+	}
+}
+
+func (pv *PseudoNodeVerifier) close() {
+	close(pv.incomingTasks)
+}
+
 func (t *PseudoNodeProposalsTask) execute(verifier *AsyncVoteVerifier, quit chan struct{}) {
 	defer t.close()
 	// check to see if task already expired.
-	//if t.context.Err() != nil {
-	//	return
-	//}
-	//payloads, votes := t.node.makeProposals(t.round, t.period, t.participation)
-	return
+	if t.context.Err() != nil {
+		return
+	}
+	proposals, votes := t.node.makeProposals(t.round, t.period)
+	votes, ok := t.verify(verifier, votes, quit)
+	if !ok {
+		return
+	}
+	for i := range proposals {
+		events := []externalEvent{
+			messageEvent{T: voteVerified, Input: message{Vote: votes[i], UnauthenticatedVote: votes[i].u()}},
+			messageEvent{T: payloadVerified, Input: message{Proposal: proposals[i], UnauthenticatedProposal: proposals[i].U()}},
+		}
+		for _, e := range events {
+			if !t.deliver(e, quit) {
+				return
+			}
+		}
+	}
+}
+
+func (t *PseudoNodeVotesTask) execute(verifier *AsyncVoteVerifier, quit chan struct{}) {
+	defer t.close()
+	// check to see if task already expired.
+	if t.context.Err() != nil {
+		return
+	}
+	votes, ok := t.verify(verifier, t.node.makeVotes(t.round, t.period, t.step, t.proposal), quit)
+	if !ok {
+		return
+	}
+	for _, v := range votes {
+		if !t.deliver(messageEvent{T: voteVerified, Input: message{Vote: v, UnauthenticatedVote: v.u()}}, quit) {
+			return
+		}
+	}
+}
+
+// verify checks the votes created by the pseudonode on the verifier, returning
+// false if any of them is invalid or the task expired meanwhile. Without a
+// verifier the votes are returned as they are.
+func (t *PseudoNodeBaseTask) verify(verifier *AsyncVoteVerifier, votes []vote, quit chan struct{}) ([]vote, bool) {
+	if verifier == nil || len(votes) == 0 {
+		return votes, true
+	}
+	results := make(chan asyncVerifyVoteResponse, len(votes))
+	for i, v := range votes {
+		if err := verifier.verifyVote(t.context, t.node.engine, t.node.ledger, v.u(), i, message{}, results); err != nil {
+			return nil, false
+		}
+	}
+	verified := make([]vote, len(votes))
+	for range votes {
+		select {
+		case res := <-results:
+			if res.err != nil {
+				log.Error("Pseudonode created an invalid vote", "round", res.req.uv.R.Round, "step", step(res.req.uv.R.Step), "err", res.err)
+				return nil, false
+			}
+			verified[res.index] = res.v
+		case <-t.context.Done():
+			return nil, false
+		case <-quit:
+			return nil, false
+		}
+	}
+	return verified, true
+}
+
+// deliver writes an event to the output channel of the task, returning false
+// if the task expired or the pseudonode quit meanwhile.
+func (t *PseudoNodeBaseTask) deliver(e externalEvent, quit chan struct{}) bool {
+	select {
+	case t.out <- e:
+		return true
+	case <-t.context.Done():
+		return false
+	case <-quit:
+		return false
+	}
 }
 
 func (t *PseudoNodeBaseTask) outputChannel() chan externalEvent {
@@ -121,23 +327,3 @@ func (t *PseudoNodeBaseTask) outputChannel() chan externalEvent {
 func (t *PseudoNodeBaseTask) close() {
 	close(t.out)
 }
-
-//func (n *AsyncPseudoNode) Quit() {
-//	// protect against double-quits.
-//	select {
-//	case <-n.quit:
-//		// if we already quit, just exit.
-//		return
-//	default:
-//	}
-//	close(n.quit)
-//	n.proposalsVerifier.close()
-//	n.votesVerifier.close()
-//	n.closeWg.Wait()
-//}
-
-// PseudoNodeTask encapsulates a single task which should be executed by the pseudonode.
-//type PseudoNodeTask interface {
-//	// Execute a task with a given cryptoVerifier and quit channel.
-//	execute(verifier *AsyncVoteVerifier, quit chan struct{})
-//}
@@ -0,0 +1,93 @@
+package algo
+
+import (
+	"errors"
+
+	"github.com/awesome-chain/Xchain/common"
+	"github.com/awesome-chain/Xchain/common/hexutil"
+	"github.com/awesome-chain/Xchain/consensus"
+	"github.com/awesome-chain/Xchain/consensus/algo/beacon"
+	"github.com/awesome-chain/Xchain/core/types"
+	"github.com/awesome-chain/Xchain/crypto"
+	"github.com/awesome-chain/Xchain/crypto/vrf"
+	"github.com/awesome-chain/Xchain/rpc"
+)
+
+// errGenesisRandomness is returned if the randomness of the genesis block is
+// requested, whose seed is configured rather than derived.
+var errGenesisRandomness = errors.New("genesis seed has no proof")
+
+// randomness assembles the randomness beacon entry of a header.
+func (a *Algorand) randomness(chain consensus.ChainReader, header *types.Header) (*beacon.Randomness, error) {
+	number := header.Number.Uint64()
+	if number == 0 {
+		return nil, errGenesisRandomness
+	}
+	extra, err := decodeHeaderExtra(header)
+	if err != nil {
+		return nil, err
+	}
+	params, ok := Consensus[extra.CurrentProtocol]
+	if !ok {
+		return nil, errUnsupportedProtocol
+	}
+	pubkey, err := sigToPub(header)
+	if err != nil {
+		return nil, err
+	}
+	lookback := seedHeader(chain, header, nil, params)
+	if lookback == nil {
+		return nil, errMissingSeed
+	}
+	r := &beacon.Randomness{
+		Number:         hexutil.Uint64(number),
+		Hash:           header.Hash(),
+		Seed:           common.Hash(header.Seed),
+		Proposer:       header.Coinbase,
+		Signer:         crypto.PubkeyToAddress(*pubkey),
+		PublicKey:      crypto.FromECDSAPub(pubkey),
+		Period:         hexutil.Uint64(extra.Period),
+		Proof:          extra.SeedProof,
+		LookbackNumber: hexutil.Uint64(lookback.Number.Uint64()),
+		LookbackHash:   lookback.Hash(),
+		LookbackSeed:   common.Hash(lookback.Seed),
+	}
+	if extra.Period == 0 {
+		output, err := (&vrf.PublicKey{PublicKey: pubkey}).ProofToHash(lookback.Seed[:], extra.SeedProof)
+		if err != nil {
+			return nil, err
+		}
+		r.Output = common.Hash(output)
+	}
+	return r, nil
+}
+
+// RandomnessAPI is the user facing RPC API of the randomness beacon, exposing
+// the seeds of the blocks together with their proofs.
+type RandomnessAPI struct {
+	chain consensus.ChainReader
+	algo  *Algorand
+}
+
+// GetRandomness retrieves the randomness beacon entry of a given block.
+func (api *RandomnessAPI) GetRandomness(number rpc.BlockNumber) (*beacon.Randomness, error) {
+	var header *types.Header
+	if number == rpc.LatestBlockNumber || number == rpc.PendingBlockNumber {
+		header = api.chain.CurrentHeader()
+	} else {
+		header = api.chain.GetHeaderByNumber(uint64(number.Int64()))
+	}
+	if header == nil {
+		return nil, errUnknownBlock
+	}
+	return api.algo.randomness(api.chain, header)
+}
+
+// GetRandomnessByHash retrieves the randomness beacon entry of a given block.
+func (api *RandomnessAPI) GetRandomnessByHash(hash common.Hash) (*beacon.Randomness, error) {
+	header := api.chain.GetHeaderByHash(hash)
+	if header == nil {
+		return nil, errUnknownBlock
+	}
+	return api.algo.randomness(api.chain, header)
+}
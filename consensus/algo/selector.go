@@ -0,0 +1,145 @@
+package algo
+
+import (
+	"fmt"
+	"math/big"
+
+	"github.com/awesome-chain/Xchain/common"
+	"github.com/awesome-chain/Xchain/consensus"
+	"github.com/awesome-chain/Xchain/consensus/algo/committee"
+	"github.com/awesome-chain/Xchain/consensus/algo/protocol"
+	"github.com/awesome-chain/Xchain/core/state"
+	"github.com/awesome-chain/Xchain/crypto"
+	"github.com/awesome-chain/Xchain/crypto/vrf"
+	"github.com/awesome-chain/Xchain/rlp"
+)
+
+// stakeUnit is the amount of wei counted as one unit of stake by the sortition.
+var stakeUnit = new(big.Int).SetUint64(1e18)
+
+// Ledger defines the chain access needed to resolve the sortition membership
+// of an account at a given round.
+type Ledger interface {
+	consensus.ChainReader
+
+	// StateAt returns the state database at a given root hash.
+	StateAt(root common.Hash) (*state.StateDB, error)
+}
+
+// A selector is the input used to define proposers and members of voting
+// committees.
+type selector struct {
+	Seed   common.Seed
+	Round  uint64
+	Period uint64
+	Step   uint64
+
+	size uint64 // Expected committee size, not part of the VRF input
+}
+
+// ToBeHashed implements the Hashable interface.
+func (sel selector) ToBeHashed() (protocol.HashID, []byte, error) {
+	bs, err := rlp.EncodeToBytes(&sel)
+	if err != nil {
+		return "", nil, err
+	}
+	return protocol.AgreementSelector, bs, nil
+}
+
+// CommitteeSize returns the size of the committee, which is determined by
+// the step of the selector and the protocol version of its round.
+func (sel selector) CommitteeSize() uint64 {
+	return sel.size
+}
+
+// makeSelector returns the selector of a (round, period, step), seeded by the
+// seed of the lookback round.
+func (a *Algorand) makeSelector(ledger consensus.ChainReader, r round, p period, s step) (selector, error) {
+	params, err := a.consensusParams(ledger, uint64(r))
+	if err != nil {
+		return selector{}, err
+	}
+	header := ledger.GetHeaderByNumber(params.seedRound(uint64(r)))
+	if header == nil {
+		return selector{}, errMissingSeed
+	}
+	return selector{Seed: header.Seed, Round: uint64(r), Period: uint64(p), Step: uint64(s), size: params.committeeSize(s)}, nil
+}
+
+// toStake converts a balance into units of stake.
+func toStake(balance *big.Int) uint64 {
+	stake := new(big.Int).Div(balance, stakeUnit)
+	if !stake.IsUint64() {
+		return ^uint64(0)
+	}
+	return stake.Uint64()
+}
+
+// membership resolves the online stake of an account and the total online stake
+// at the seed lookback round of a (round, period, step), together with its
+// selector. The account has no stake unless pk is its participation key.
+func (a *Algorand) membership(ledger Ledger, addr common.Address, pk *vrf.PublicKey, r round, p period, s step) (committee.Membership, error) {
+	sel, err := a.makeSelector(ledger, r, p, s)
+	if err != nil {
+		return committee.Membership{}, err
+	}
+	parts, err := a.participants(ledger, uint64(r))
+	if err != nil {
+		return committee.Membership{}, err
+	}
+	m := committee.Membership{Address: addr, Selector: sel}
+	if pk != nil {
+		m.PublicKey = pk.PublicKey
+	}
+	for account, part := range parts {
+		if !part.validAt(uint64(r)) {
+			continue
+		}
+		if account == addr && pk != nil && part.Key == crypto.PubkeyToAddress(*pk.PublicKey) {
+			m.Stake = part.Stake
+		}
+		m.TotalStake += part.Stake
+	}
+	return m, nil
+}
+
+// credential evaluates the sortition of the local participation key for a
+// (round, period, step). The returned credential has zero weight if the key
+// was not selected.
+func (a *Algorand) credential(ledger Ledger, r round, p period, s step) (committee.Credential, error) {
+	a.RLock()
+	account, vrfKey := a.account, a.vrfKey
+	a.RUnlock()
+	if vrfKey == nil {
+		return committee.Credential{}, errUnauthorized
+	}
+	m, err := a.membership(ledger, account, &vrf.PublicKey{PublicKey: &vrfKey.PublicKey}, r, p, s)
+	if err != nil {
+		return committee.Credential{}, err
+	}
+	ucred, err := committee.MakeCredential(vrfKey, m.Selector)
+	if err != nil {
+		return committee.Credential{}, err
+	}
+	cred, err := ucred.Verify(m)
+	if err == committee.ErrNotSelected {
+		// A valid proof which did not select the key is not an error
+		return committee.Credential{UnauthenticatedCredential: ucred}, nil
+	}
+	return cred, err
+}
+
+// verifyCredential checks a credential claimed by the participation key pubkey
+// of an account for a (round, period, step) against the online stake at the
+// seed lookback round.
+func (a *Algorand) verifyCredential(ledger Ledger, addr common.Address, pubkey []byte, ucred committee.UnauthenticatedCredential, r round, p period, s step) (committee.Credential, error) {
+	pk := crypto.ToECDSAPub(pubkey)
+	if pk == nil || pk.X == nil {
+		return committee.Credential{}, fmt.Errorf("invalid participation key")
+	}
+	m, err := a.membership(ledger, addr, &vrf.PublicKey{PublicKey: pk}, r, p, s)
+	if err != nil {
+		return committee.Credential{}, err
+	}
+	return ucred.Verify(m)
+}
@@ -0,0 +1,509 @@
+package algo
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/awesome-chain/Xchain/core/types"
+	"github.com/awesome-chain/Xchain/ethdb"
+	"github.com/awesome-chain/Xchain/log"
+	"github.com/awesome-chain/Xchain/p2p"
+	"github.com/awesome-chain/Xchain/util/execpool"
+)
+
+// agreementInputBacklog is the number of input events buffered by the service.
+// TODO put these in config
+const agreementInputBacklog = 1024
+
+// errDoubleVote is returned if the node is about to send a second vote in a
+// step, for a different value than its first one.
+var errDoubleVote = errors.New("conflicting vote already sent in step")
+
+// AgreementLedger is the ledger the agreement service reads the participation
+// state from and commits the agreed blocks to.
+type AgreementLedger interface {
+	Ledger
+
+	// EnsureBlock adds a block agreed upon by the protocol to the ledger,
+	// unless it was already added by other means.
+	EnsureBlock(block *types.Block) error
+}
+
+// Parameters holds the dependencies of the agreement service.
+type Parameters struct {
+	Ledger    AgreementLedger
+	Factory   BlockFactory
+	Validator BlockValidator
+
+	// Clock provides the timeouts of the protocol. If nil, the system time
+	// is used.
+	Clock Clock
+
+	// NetworkId is the network the agreement messages are gossiped in.
+	NetworkId uint64
+
+	// VerificationPool runs the signature and VRF checks of the votes. If nil,
+	// a pool of all the CPUs is created for the service.
+	VerificationPool execpool.BacklogPool
+}
+
+// pendingVerification is a message waiting to be handed over to the vote
+// verifier, together with the context of the round it was received in.
+type pendingVerification struct {
+	ctx context.Context
+	m   messageEvent
+}
+
+// Service runs the agreement protocol: it feeds the network input and the
+// timeouts of its Clock into the player state machine and carries out the
+// resulting actions.
+type Service struct {
+	engine     *Algorand
+	ledger     AgreementLedger
+	validator  BlockValidator
+	clock      Clock
+	pseudonode *AsyncPseudoNode
+	verifier   *AsyncVoteVerifier
+	gossip     *gossip
+	catchup    *catchup
+
+	player *player
+	votes  *voteAggregator
+	db     ethdb.Database        // Database the agreement state is persisted in, if any
+	sent   []unauthenticatedVote // Votes sent by the node in the current round
+
+	input       chan externalEvent
+	verifyQueue chan pendingVerification
+	verified    chan asyncVerifyVoteResponse
+
+	ctx         context.Context // Context of the service, cancelled on stop
+	cancel      context.CancelFunc
+	roundCtx    context.Context // Context of the current round, cancelled when it concludes
+	roundCancel context.CancelFunc
+
+	quit       chan struct{}
+	done       chan struct{}
+	verifyDone chan struct{}
+}
+
+// NewService creates an agreement service voting with the participation key
+// authorized on the engine.
+func NewService(engine *Algorand, params Parameters) *Service {
+	clock := params.Clock
+	if clock == nil {
+		clock = MakeMonotonicClock(time.Now())
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	verifier := MakeAsyncVoteVerifier(params.VerificationPool)
+	s := &Service{
+		engine:      engine,
+		ledger:      params.Ledger,
+		validator:   params.Validator,
+		clock:       clock,
+		verifier:    verifier,
+		db:          engine.db,
+		pseudonode:  MakeAsyncPseudoNode(engine, params.Factory, params.Validator, params.Ledger, verifier),
+		input:       make(chan externalEvent, agreementInputBacklog),
+		verifyQueue: make(chan pendingVerification, agreementInputBacklog),
+		verified:    make(chan asyncVerifyVoteResponse, agreementInputBacklog),
+		ctx:         ctx,
+		cancel:      cancel,
+		quit:        make(chan struct{}),
+		done:        make(chan struct{}),
+		verifyDone:  make(chan struct{}),
+	}
+	s.gossip = newGossip(s, params.NetworkId, params.Ledger.GetHeaderByNumber(0).Hash())
+	s.catchup = newCatchup(s)
+	return s
+}
+
+// Protocols returns the agreement gossip sub-protocols of the service.
+func (s *Service) Protocols() []p2p.Protocol {
+	return s.gossip.SubProtocols
+}
+
+// Start starts agreeing on the round after the current head of the ledger. If
+// the agreement state of that round was persisted before a restart, the node
+// resumes from it and sends its payloads and votes of the round again.
+func (s *Service) Start() {
+	r := round(s.ledger.CurrentHeader().Number.Uint64() + 1)
+
+	var actions []action
+	if state := s.restore(r); state != nil {
+		s.player, s.sent = state.player(), state.Votes
+		actions = []action{rezeroAction{}}
+		log.Info("Resumed agreement", "round", s.player.Round, "period", s.player.Period, "step", s.player.Step, "votes", len(s.sent))
+	} else {
+		s.player, actions = makePlayer(r, s.consensusVersion(uint64(r)).Version)
+	}
+	s.checkProtocol()
+	s.votes = makeVoteAggregator(s.player.Round, s.player.Period, s.engine.scaleCommittees(s.player.params()))
+	s.roundCtx, s.roundCancel = context.WithCancel(s.ctx)
+
+	for _, proposal := range s.player.Payloads {
+		s.deliver(messageEvent{T: payloadVerified, Input: message{Proposal: proposal, UnauthenticatedProposal: proposal.U()}})
+	}
+	for _, uv := range s.sent {
+		s.deliver(messageEvent{T: votePresent, Input: message{UnauthenticatedVote: uv}})
+	}
+	go s.verifyLoop()
+	go s.loop(actions)
+	s.catchup.start()
+}
+
+// restore retrieves the persisted agreement state if it belongs to round r.
+func (s *Service) restore(r round) *diskState {
+	if s.db == nil {
+		return nil
+	}
+	state, err := readAgreementState(s.db)
+	if err != nil {
+		return nil
+	}
+	if round(state.Round) != r {
+		log.Debug("Discarded stale agreement state", "round", state.Round, "current", r)
+		return nil
+	}
+	return state
+}
+
+// persist writes the agreement state to the database.
+func (s *Service) persist() error {
+	if s.db == nil {
+		return nil
+	}
+	return writeAgreementState(s.db, encodeState(s.player, s.sent))
+}
+
+// record adds a vote sent by the node to the votes of the current round and
+// persists it, returning an error if the node already sent another vote in the
+// same step. Votes of other senders are ignored.
+func (s *Service) record(v vote) error {
+	account, key := s.engine.participant()
+	if key == nil || v.R.Sender != account || round(v.R.Round) != s.player.Round {
+		return nil
+	}
+	for _, sent := range s.sent {
+		if sent.R.Period != v.R.Period || sent.R.Step != v.R.Step {
+			continue
+		}
+		if sent.R.Proposal.key() != v.R.Proposal.key() {
+			return errDoubleVote
+		}
+		return nil
+	}
+	s.sent = append(s.sent, v.u())
+	return s.persist()
+}
+
+// Stop stops the agreement service and waits for it to terminate.
+func (s *Service) Stop() {
+	s.gossip.stop()
+	s.cancel()
+	s.catchup.stop()
+	close(s.quit)
+	<-s.done
+	<-s.verifyDone
+	s.pseudonode.Quit()
+	s.verifier.Quit()
+}
+
+// deliver queues an event for the service, dropping it if the backlog is full.
+func (s *Service) deliver(e externalEvent) {
+	select {
+	case s.input <- e:
+	case <-s.quit:
+	default:
+		log.Debug("Agreement backlog full, dropping event", "event", e)
+	}
+}
+
+// loop is the main loop of the agreement service, serializing all the input of
+// the player.
+func (s *Service) loop(actions []action) {
+	defer close(s.done)
+
+	s.execute(actions)
+	for {
+		// Without the parameters of the round the node can only catch up
+		var timeoutCh, fastTimeoutCh <-chan time.Time
+		if _, ok := Consensus[s.player.Proto]; ok {
+			if !s.player.Napping {
+				timeoutCh = s.clock.TimeoutAt(s.player.Deadline)
+			}
+			fastTimeoutCh = s.clock.TimeoutAt(s.player.FastRecoveryDeadline)
+		}
+
+		select {
+		case e := <-s.input:
+			s.handle(e)
+		case res := <-s.verified:
+			if res.cancelled {
+				continue
+			}
+			m := messageEvent{T: voteVerified, Input: res.message, Err: res.err}
+			m.Input.Vote = res.v
+			s.handle(m)
+		case <-timeoutCh:
+			s.dispatch(timeoutEvent{T: timeout, Round: s.player.Round, Period: s.player.Period})
+		case <-fastTimeoutCh:
+			s.dispatch(timeoutEvent{T: fastTimeout, Round: s.player.Round, Period: s.player.Period})
+		case <-s.quit:
+			return
+		}
+	}
+}
+
+// consensusVersion returns the view of the ledger on the protocol version of a
+// round.
+func (s *Service) consensusVersion(r uint64) ConsensusVersionView {
+	version, err := s.engine.consensusVersion(s.ledger, r)
+	return ConsensusVersionView{Err: err, Version: version}
+}
+
+// checkProtocol warns if the node does not support the protocol version of the
+// current round, in which case it stops voting until the node is upgraded.
+func (s *Service) checkProtocol() {
+	if _, ok := Consensus[s.player.Proto]; !ok {
+		log.Error("Unsupported agreement protocol, please upgrade", "round", s.player.Round, "protocol", s.player.Proto)
+	}
+}
+
+// handle verifies an input event and routes it to the state machines.
+func (s *Service) handle(e externalEvent) {
+	e = e.AttachConsensusVersion(s.consensusVersion(e.ConsensusRound()))
+	if e.t() == roundInterruption {
+		s.dispatch(e)
+		return
+	}
+	m, ok := e.(messageEvent)
+	if !ok {
+		return
+	}
+	// Messages of rounds whose protocol version is unknown can not be verified
+	if m.Proto.Err != nil {
+		log.Trace("Discarded message of unknown protocol version", "round", m.ConsensusRound(), "err", m.Proto.Err)
+		return
+	}
+	switch m.T {
+	case votePresent:
+		if res := s.votes.handle(m); res.t() == voteFiltered {
+			return
+		}
+		s.verify(m)
+
+	case voteVerified:
+		if m.Err != nil {
+			log.Debug("Discarded malformed vote", "err", m.Err)
+			return
+		}
+		s.gossip.markHeads(HashObj(m.Input.Vote.u()), m.Input.Vote.R.Round)
+
+		// Own votes must hit the disk before they leave the node
+		if err := s.record(m.Input.Vote); err != nil {
+			log.Error("Refused to send vote", "round", m.Input.Vote.R.Round, "period", m.Input.Vote.R.Period, "step", step(m.Input.Vote.R.Step), "err", err)
+			return
+		}
+		// Only relay votes which are relevant to the current round and period
+		if step(m.Input.Vote.R.Step) == propose {
+			if err := s.votes.filterRelevance(m.Input.Vote.R.Round, m.Input.Vote.R.Period); err != nil {
+				return
+			}
+			s.gossip.relayVote(m.Input.Vote.u())
+			s.dispatch(m)
+			return
+		}
+		res := s.votes.handle(m)
+		if res.t() == voteFiltered {
+			return
+		}
+		s.gossip.relayVote(m.Input.Vote.u())
+		s.route(res)
+
+	case bundlePresent:
+		if res := s.votes.handle(m); res.t() == bundleFiltered {
+			return
+		}
+		s.verify(m)
+
+	case bundleVerified:
+		if m.Err != nil {
+			log.Debug("Discarded malformed bundle", "err", m.Err)
+			return
+		}
+		s.gossip.markHeads(HashObj(m.Input.Bundle.U), m.Input.Bundle.U.Round)
+		res := s.votes.handle(m)
+		if res.t() == bundleFiltered {
+			return
+		}
+		s.gossip.relayBundle(m.Input.Bundle.U)
+		s.route(res)
+
+	case payloadPresent:
+		up := m.Input.UnauthenticatedProposal
+		if up == nil || s.validator == nil || round(up.NumberU64()) != s.player.Round {
+			return
+		}
+		p, err := up.Validate(s.ctx, uint64(s.player.Round), s.player.params(), s.ledger, s.validator)
+		if err != nil {
+			log.Debug("Discarded malformed proposal", "err", err)
+			return
+		}
+		m.T, m.Input.Proposal = payloadVerified, p
+		s.handle(m)
+
+	case payloadVerified:
+		if round(m.Input.Proposal.NumberU64()) != s.player.Round {
+			return
+		}
+		s.gossip.relayProposal(m.Input.Proposal.U())
+		s.dispatch(m)
+	}
+}
+
+// verify queues a vote or bundle for verification in the context of the current
+// round, dropping it if the queue is full.
+func (s *Service) verify(m messageEvent) {
+	select {
+	case s.verifyQueue <- pendingVerification{ctx: s.roundCtx, m: m}:
+	default:
+		log.Debug("Agreement verification backlog full, dropping message", "event", m)
+	}
+}
+
+// verifyLoop hands the queued messages over to the vote verifier. The verified
+// votes are delivered on the verified channel, bundles are collected in their
+// own goroutine and fed back as input events. Verifications are abandoned once
+// the round they were received in concludes.
+func (s *Service) verifyLoop() {
+	defer close(s.verifyDone)
+
+	for {
+		select {
+		case pv := <-s.verifyQueue:
+			switch pv.m.T {
+			case votePresent:
+				uv := pv.m.Input.UnauthenticatedVote
+				if err := s.verifier.verifyVote(pv.ctx, s.engine, s.ledger, uv, 0, pv.m.Input, s.verified); err != nil {
+					log.Trace("Abandoned vote verification", "err", err)
+				}
+
+			case bundlePresent:
+				collect := pv.m.Input.UnauthenticatedBundle.verifyAsync(pv.ctx, s.engine, s.ledger, s.verifier)
+				go func(pv pendingVerification) {
+					m := pv.m
+					m.T = bundleVerified
+					m.Input.Bundle, m.Err = collect()
+					if pv.ctx.Err() != nil {
+						return
+					}
+					select {
+					case s.input <- m:
+					case <-s.quit:
+					}
+				}(pv)
+			}
+
+		case <-s.quit:
+			return
+		}
+	}
+}
+
+// route dispatches the output of the vote aggregator to the player if it is a
+// threshold event.
+func (s *Service) route(e event) {
+	switch e.t() {
+	case softThreshold, certThreshold, nextThreshold:
+		s.dispatch(e)
+	}
+}
+
+// dispatch delivers an event to the player, keeping the vote aggregator in sync
+// with its round and period, and carries out the resulting actions.
+func (s *Service) dispatch(e event) {
+	r, p, st := s.player.Round, s.player.Period, s.player.Step
+	actions := s.player.handle(e)
+	if s.player.Round != r {
+		s.checkProtocol()
+		s.votes.newRound(s.player.Round, s.engine.scaleCommittees(s.player.params()))
+		s.sent = nil
+
+		s.roundCancel()
+		s.roundCtx, s.roundCancel = context.WithCancel(s.ctx)
+	}
+	if s.player.Period != p {
+		s.votes.newPeriod(s.player.Period)
+	}
+	// Persist the state whenever the player moved on or took a new payload
+	if len(actions) == 0 && e.t() != payloadVerified && s.player.Round == r && s.player.Period == p && s.player.Step == st {
+		return
+	}
+	s.execute(actions)
+}
+
+// execute carries out the actions of the player. Agreed blocks are committed
+// first, then the state of the player is persisted, and only then any vote is
+// made. If the state can not be persisted the node stops voting.
+func (s *Service) execute(actions []action) {
+	for _, a := range actions {
+		if a, ok := a.(ensureAction); ok {
+			s.ensure(a)
+		}
+	}
+	persisted := true
+	if err := s.persist(); err != nil {
+		log.Error("Failed to persist agreement state", "round", s.player.Round, "err", err)
+		persisted = false
+	}
+	for _, a := range actions {
+		switch a := a.(type) {
+		case rezeroAction:
+			s.clock = s.clock.Zero()
+
+		case pseudonodeAction:
+			if !persisted {
+				continue
+			}
+			var (
+				out <-chan externalEvent
+				err error
+			)
+			if a.T == assemble {
+				out, err = s.pseudonode.MakeProposals(s.roundCtx, uint64(a.Round), uint64(a.Period))
+			} else {
+				out, err = s.pseudonode.MakeVotes(s.roundCtx, uint64(a.Round), uint64(a.Period), a.Step, a.Proposal)
+			}
+			if err != nil {
+				log.Warn("Failed to run pseudonode task", "action", a, "err", err)
+				continue
+			}
+			go s.forward(out)
+		}
+	}
+}
+
+// ensure commits an agreed block to the ledger, storing its certificate next
+// to it.
+func (s *Service) ensure(a ensureAction) {
+	if err := s.ledger.EnsureBlock(a.Payload.Block); err != nil {
+		log.Error("Failed to commit agreed block", "number", a.Payload.NumberU64(), "hash", a.Payload.Hash(), "err", err)
+		return
+	}
+	if s.db != nil {
+		writeCertificate(s.db, a.Payload.Hash(), a.Payload.NumberU64(), a.Certificate)
+	}
+	log.Info("Committed agreed block", "number", a.Payload.NumberU64(), "hash", a.Payload.Hash(), "period", a.Certificate.Period)
+}
+
+// forward feeds the output of a pseudonode task back into the service.
+func (s *Service) forward(out <-chan externalEvent) {
+	for e := range out {
+		select {
+		case s.input <- e:
+		case <-s.quit:
+			return
+		}
+	}
+}
@@ -0,0 +1,672 @@
+package algo
+
+import (
+	"container/heap"
+	"context"
+	"crypto/ecdsa"
+	"encoding/binary"
+	"fmt"
+	"math/rand"
+	"testing"
+	"time"
+
+	"github.com/awesome-chain/Xchain/common"
+	"github.com/awesome-chain/Xchain/core"
+	"github.com/awesome-chain/Xchain/core/types"
+	"github.com/awesome-chain/Xchain/crypto"
+)
+
+// simBehavior is the behavior of a simulated node.
+type simBehavior int
+
+const (
+	simHonest     simBehavior = iota // Follows the protocol
+	simEquivocate                    // Splits the network with conflicting votes in every step
+	simWithhold                      // Sends its proposal-votes but withholds the payloads
+)
+
+// simConfig specifies the network a simulation runs on.
+type simConfig struct {
+	Nodes     int                 // Number of nodes, all holding the same stake
+	Seed      int64               // Seed of the keys and of the randomness of the network
+	Latency   time.Duration       // Minimum delay of a message
+	Jitter    time.Duration       // Maximum random delay added to the latency
+	Loss      float64             // Probability of a message being lost
+	Byzantine map[int]simBehavior // Behavior of the misbehaving nodes
+}
+
+// simReport sums up the safety and liveness of a simulation.
+type simReport struct {
+	Rounds     uint64          // Rounds committed by every honest node
+	Elapsed    time.Duration   // Virtual time the simulation ran for
+	RoundTimes []time.Duration // Time it took every honest node to commit each round
+	Periods    []uint64        // Period each round was certified in
+	Violations []string        // Rounds certified with conflicting values
+	Sent       uint64          // Messages sent over the network
+	Dropped    uint64          // Messages lost or blocked by a partition
+	CatchUps   uint64          // Blocks committed by catching up with a peer
+}
+
+// meanRoundTime returns the average time it took to commit a round.
+func (r *simReport) meanRoundTime() time.Duration {
+	if len(r.RoundTimes) == 0 {
+		return 0
+	}
+	var total time.Duration
+	for _, d := range r.RoundTimes {
+		total += d
+	}
+	return total / time.Duration(len(r.RoundTimes))
+}
+
+func (r *simReport) String() string {
+	var maxPeriod uint64
+	for _, p := range r.Periods {
+		if p > maxPeriod {
+			maxPeriod = p
+		}
+	}
+	return fmt.Sprintf("rounds=%d elapsed=%v mean=%v maxperiod=%d violations=%d sent=%d dropped=%d catchups=%d",
+		r.Rounds, r.Elapsed, r.meanRoundTime(), maxPeriod, len(r.Violations), r.Sent, r.Dropped, r.CatchUps)
+}
+
+// simNode is a simulated participant: a player and a vote aggregator fed by
+// the simulator, voting through an AsyncPseudoNode on its own chain.
+type simNode struct {
+	id       int
+	behavior simBehavior
+	account  common.Address
+	key      *ecdsa.PrivateKey
+
+	engine     *Algorand
+	chain      *core.BlockChain
+	ledger     *testLedger
+	validator  BlockValidator
+	pseudonode *AsyncPseudoNode
+
+	player *player
+	votes  *voteAggregator
+	zero   time.Duration // Virtual time the current period started at
+
+	seen  map[common.Hash]struct{}         // Messages already received or relayed
+	certs map[uint64]unauthenticatedBundle // Certificates of the committed blocks
+}
+
+// timer returns the virtual time the next timeout of the node fires at, and
+// whether it is the fast recovery one.
+func (n *simNode) timer() (time.Duration, bool, bool) {
+	if _, ok := Consensus[n.player.Proto]; !ok {
+		return 0, false, false
+	}
+	fast := n.zero + n.player.FastRecoveryDeadline
+	if n.player.Napping {
+		return fast, true, true
+	}
+	if deadline := n.zero + n.player.Deadline; deadline <= fast {
+		return deadline, false, true
+	}
+	return fast, true, true
+}
+
+// simValidator accepts the blocks whose header passes the checks of the engine.
+type simValidator struct {
+	chain  *core.BlockChain
+	engine *Algorand
+}
+
+func (v *simValidator) Validate(ctx context.Context, block *types.Block) (*types.Block, error) {
+	if err := v.engine.VerifyHeader(v.chain, block.Header(), true); err != nil {
+		return nil, err
+	}
+	return block, nil
+}
+
+// simDelivery is a message in flight on the simulated network.
+type simDelivery struct {
+	at   time.Duration
+	seq  uint64 // Sequence number to order deliveries of the same time
+	from *simNode
+	to   *simNode
+	msg  messageEvent
+}
+
+// simQueue is a priority queue of deliveries ordered by time.
+type simQueue []*simDelivery
+
+func (q simQueue) Len() int { return len(q) }
+func (q simQueue) Less(i, j int) bool {
+	return q[i].at < q[j].at || (q[i].at == q[j].at && q[i].seq < q[j].seq)
+}
+func (q simQueue) Swap(i, j int)       { q[i], q[j] = q[j], q[i] }
+func (q *simQueue) Push(x interface{}) { *q = append(*q, x.(*simDelivery)) }
+func (q *simQueue) Pop() interface{} {
+	old := *q
+	d := old[len(old)-1]
+	*q = old[:len(old)-1]
+	return d
+}
+
+// simulator runs the agreement of a set of nodes in a single goroutine over a
+// simulated network. Messages and timeouts are processed in the order of a
+// virtual clock, so a run only depends on the configuration and its seed.
+type simulator struct {
+	t      *testing.T
+	config simConfig
+	rand   *rand.Rand
+
+	nodes  []*simNode
+	groups []int // Partition group of every node, nil if the network is whole
+	queue  simQueue
+	now    time.Duration
+	seq    uint64
+
+	certified map[uint64]ProposalValue // First value certified in every round
+	headAt    time.Duration            // Time every honest node committed the last round at
+	report    simReport
+}
+
+// newSimulator creates the nodes of a simulation, all of them starting at the
+// same genesis block.
+func newSimulator(t *testing.T, config simConfig) *simulator {
+	keys := make([]*ecdsa.PrivateKey, config.Nodes)
+	for i := range keys {
+		var seed [16]byte
+		binary.BigEndian.PutUint64(seed[:8], uint64(config.Seed))
+		binary.BigEndian.PutUint64(seed[8:], uint64(i))
+
+		key, err := crypto.ToECDSA(crypto.Keccak256(seed[:]))
+		if err != nil {
+			t.Fatalf("failed to derive key %d: %v", i, err)
+		}
+		keys[i] = key
+	}
+	s := &simulator{
+		t:         t,
+		config:    config,
+		rand:      rand.New(rand.NewSource(config.Seed)),
+		certified: make(map[uint64]ProposalValue),
+	}
+	for i, key := range keys {
+		engine, chain := newTestParticipants(t, keys...)
+		engine.Authorize(key)
+
+		n := &simNode{
+			id:        i,
+			behavior:  config.Byzantine[i],
+			account:   crypto.PubkeyToAddress(key.PublicKey),
+			key:       key,
+			engine:    engine,
+			chain:     chain,
+			ledger:    &testLedger{chain},
+			validator: &simValidator{chain: chain, engine: engine},
+			seen:      make(map[common.Hash]struct{}),
+			certs:     make(map[uint64]unauthenticatedBundle),
+		}
+		n.pseudonode = MakeAsyncPseudoNode(engine, &testFactory{chain: chain, engine: engine}, n.validator, n.ledger, nil)
+		s.nodes = append(s.nodes, n)
+	}
+	for _, n := range s.nodes {
+		r := round(n.chain.CurrentBlock().NumberU64() + 1)
+		version, _ := n.engine.consensusVersion(n.ledger, uint64(r))
+
+		var actions []action
+		n.player, actions = makePlayer(r, version)
+		n.votes = makeVoteAggregator(n.player.Round, n.player.Period, n.player.params())
+		s.execute(n, actions)
+	}
+	return s
+}
+
+// stop tears down the nodes of the simulation.
+func (s *simulator) stop() {
+	for _, n := range s.nodes {
+		n.pseudonode.Quit()
+		n.chain.Stop()
+	}
+}
+
+// partition splits the network into groups of nodes which can only talk among
+// themselves. Nodes not listed in any group are isolated.
+func (s *simulator) partition(groups ...[]int) {
+	s.groups = make([]int, len(s.nodes))
+	for i := range s.groups {
+		s.groups[i] = -1 - i
+	}
+	for g, group := range groups {
+		for _, i := range group {
+			s.groups[i] = g
+		}
+	}
+}
+
+// heal reconnects all the nodes of a partitioned network.
+func (s *simulator) heal() {
+	s.groups = nil
+}
+
+// honestHead returns the lowest head among the honest nodes.
+func (s *simulator) honestHead() uint64 {
+	head := ^uint64(0)
+	for _, n := range s.nodes {
+		if n.behavior != simHonest {
+			continue
+		}
+		if number := n.chain.CurrentBlock().NumberU64(); number < head {
+			head = number
+		}
+	}
+	return head
+}
+
+// run processes the events of the simulation until every honest node committed
+// the given number of rounds, or the virtual clock passes the time limit.
+func (s *simulator) run(rounds uint64, limit time.Duration) *simReport {
+	for s.honestHead() < rounds && s.now < limit {
+		// Fire the earliest timeout unless a message is delivered before it
+		var (
+			timed  *simNode
+			timeAt time.Duration
+			fast   bool
+		)
+		for _, n := range s.nodes {
+			if at, isFast, ok := n.timer(); ok && (timed == nil || at < timeAt) {
+				timed, timeAt, fast = n, at, isFast
+			}
+		}
+		if len(s.queue) > 0 && (timed == nil || s.queue[0].at < timeAt) {
+			d := heap.Pop(&s.queue).(*simDelivery)
+			s.advance(d.at)
+			s.receive(d.to, d.from, d.msg)
+			continue
+		}
+		if timed == nil {
+			break
+		}
+		s.advance(timeAt)
+		e := timeoutEvent{T: timeout, Round: timed.player.Round, Period: timed.player.Period}
+		if fast {
+			e.T = fastTimeout
+		}
+		s.dispatch(timed, e)
+	}
+	// Account the rounds committed by the last event
+	s.advance(s.now)
+	s.report.Elapsed = s.now
+	return &s.report
+}
+
+// advance moves the virtual clock forward, accounting the rounds committed by
+// every honest node meanwhile.
+func (s *simulator) advance(now time.Duration) {
+	s.now = now
+	for head := s.honestHead(); s.report.Rounds < head; {
+		s.report.Rounds++
+		s.report.RoundTimes = append(s.report.RoundTimes, s.now-s.headAt)
+		s.headAt = s.now
+	}
+}
+
+// send puts a message on the wire from one node to another, unless it is lost
+// or blocked by a partition.
+func (s *simulator) send(from, to *simNode, m messageEvent) {
+	s.report.Sent++
+	if (s.groups != nil && s.groups[from.id] != s.groups[to.id]) || s.rand.Float64() < s.config.Loss {
+		s.report.Dropped++
+		return
+	}
+	delay := s.config.Latency
+	if s.config.Jitter > 0 {
+		delay += time.Duration(s.rand.Int63n(int64(s.config.Jitter)))
+	}
+	s.seq++
+	heap.Push(&s.queue, &simDelivery{at: s.now + delay, seq: s.seq, from: from, to: to, msg: m})
+}
+
+// relay sends a verified message of a node to all the other nodes, applying the
+// misbehavior of the node to its own messages.
+func (s *simulator) relay(n *simNode, m messageEvent) {
+	var hash common.Hash
+	switch m.T {
+	case votePresent:
+		hash = HashObj(m.Input.UnauthenticatedVote)
+	case payloadPresent:
+		hash = HashObj(m.Input.UnauthenticatedProposal)
+	case bundlePresent:
+		hash = HashObj(m.Input.UnauthenticatedBundle)
+	}
+	n.seen[hash] = struct{}{}
+
+	switch n.behavior {
+	case simWithhold:
+		if m.T == payloadPresent {
+			return
+		}
+	case simEquivocate:
+		uv := m.Input.UnauthenticatedVote
+		if m.T == votePresent && uv.R.Sender == n.account && step(uv.R.Step) != propose {
+			twin := uv.R
+			twin.Proposal.BlockDigest = crypto.Keccak256Hash(twin.Proposal.BlockDigest[:])
+			tv, err := makeVote(twin, n.key, uv.Cred)
+			if err != nil {
+				s.t.Fatalf("node %d: failed to sign equivocation: %v", n.id, err)
+			}
+			for _, peer := range s.nodes {
+				if peer == n {
+					continue
+				}
+				if peer.id%2 == 0 {
+					s.send(n, peer, m)
+				} else {
+					s.send(n, peer, messageEvent{T: votePresent, Input: message{UnauthenticatedVote: tv}})
+				}
+			}
+			return
+		}
+	}
+	for _, peer := range s.nodes {
+		if peer != n {
+			s.send(n, peer, m)
+		}
+	}
+}
+
+// receive delivers a message from the network to a node, de-duplicating it the
+// way the gossip does. A message of a future round means the sender committed
+// blocks the node misses, so it catches up with the sender first.
+func (s *simulator) receive(n *simNode, from *simNode, m messageEvent) {
+	var (
+		hash common.Hash
+		r    uint64
+	)
+	switch m.T {
+	case votePresent:
+		hash, r = HashObj(m.Input.UnauthenticatedVote), m.Input.UnauthenticatedVote.R.Round
+	case payloadPresent:
+		hash = HashObj(m.Input.UnauthenticatedProposal)
+	case bundlePresent:
+		hash, r = HashObj(m.Input.UnauthenticatedBundle), m.Input.UnauthenticatedBundle.Round
+	}
+	if _, ok := n.seen[hash]; ok {
+		return
+	}
+	n.seen[hash] = struct{}{}
+
+	if round(r) > n.player.Round {
+		s.catchup(n, from, r-1)
+	}
+	s.handle(n, m)
+}
+
+// catchup commits the certified blocks of a peer up to the given number,
+// verifying their certificates, and moves the node to the following round.
+func (s *simulator) catchup(n *simNode, from *simNode, number uint64) {
+	head := n.chain.CurrentBlock().NumberU64()
+	for next := head + 1; next <= number; next++ {
+		cert, ok := from.certs[next]
+		if !ok {
+			break
+		}
+		header := from.chain.GetHeaderByNumber(next)
+		block := from.chain.GetBlock(header.Hash(), next)
+		if err := checkCertificate(block, cert); err != nil {
+			s.t.Fatalf("node %d: invalid certificate of block %d from node %d: %v", n.id, next, from.id, err)
+		}
+		if _, err := cert.verify(n.engine, n.ledger); err != nil {
+			s.t.Fatalf("node %d: unverifiable certificate of block %d from node %d: %v", n.id, next, from.id, err)
+		}
+		s.commit(n, block, cert)
+		s.report.CatchUps++
+	}
+	if committed := n.chain.CurrentBlock().NumberU64(); committed > head {
+		r := committed + 1
+		version, err := n.engine.consensusVersion(n.ledger, r)
+		s.dispatch(n, roundInterruptionEvent{Round: round(r), Proto: ConsensusVersionView{Err: err, Version: version}})
+	}
+}
+
+// handle verifies a message and routes it to the state machines of a node, the
+// way the agreement service does.
+func (s *simulator) handle(n *simNode, m messageEvent) {
+	if _, err := n.engine.consensusVersion(n.ledger, m.ConsensusRound()); err != nil {
+		return
+	}
+	switch m.T {
+	case votePresent:
+		if res := n.votes.handle(m); res.t() == voteFiltered {
+			return
+		}
+		m.T = voteVerified
+		m.Input.Vote, m.Err = m.Input.UnauthenticatedVote.verify(n.engine, n.ledger)
+		s.handle(n, m)
+
+	case voteVerified:
+		if m.Err != nil {
+			return
+		}
+		uv := m.Input.Vote.u()
+		if step(uv.R.Step) == propose {
+			if err := n.votes.filterRelevance(uv.R.Round, uv.R.Period); err != nil {
+				return
+			}
+			s.relay(n, messageEvent{T: votePresent, Input: message{UnauthenticatedVote: uv}})
+			s.dispatch(n, m)
+			return
+		}
+		res := n.votes.handle(m)
+		if res.t() == voteFiltered {
+			return
+		}
+		s.relay(n, messageEvent{T: votePresent, Input: message{UnauthenticatedVote: uv}})
+		s.route(n, res)
+
+	case bundlePresent:
+		if res := n.votes.handle(m); res.t() == bundleFiltered {
+			return
+		}
+		m.T = bundleVerified
+		m.Input.Bundle, m.Err = m.Input.UnauthenticatedBundle.verify(n.engine, n.ledger)
+		s.handle(n, m)
+
+	case bundleVerified:
+		if m.Err != nil {
+			return
+		}
+		res := n.votes.handle(m)
+		if res.t() == bundleFiltered {
+			return
+		}
+		s.relay(n, messageEvent{T: bundlePresent, Input: message{UnauthenticatedBundle: m.Input.Bundle.U}})
+		s.route(n, res)
+
+	case payloadPresent:
+		up := m.Input.UnauthenticatedProposal
+		if round(up.NumberU64()) != n.player.Round {
+			return
+		}
+		p, err := up.Validate(context.Background(), uint64(n.player.Round), n.player.params(), n.ledger, n.validator)
+		if err != nil {
+			return
+		}
+		m.T, m.Input.Proposal = payloadVerified, p
+		s.handle(n, m)
+
+	case payloadVerified:
+		if round(m.Input.Proposal.NumberU64()) != n.player.Round {
+			return
+		}
+		s.relay(n, messageEvent{T: payloadPresent, Input: message{UnauthenticatedProposal: m.Input.Proposal.U()}})
+		s.dispatch(n, m)
+	}
+}
+
+// route dispatches the threshold events of the vote aggregator of a node,
+// checking that no two values are ever certified in the same round.
+func (s *simulator) route(n *simNode, e event) {
+	switch e.t() {
+	case certThreshold:
+		te := e.(thresholdEvent)
+		if value, ok := s.certified[te.Round]; !ok {
+			s.certified[te.Round] = te.Proposal
+			s.report.Periods = append(s.report.Periods, te.Period)
+		} else if value.key() != te.Proposal.key() {
+			s.report.Violations = append(s.report.Violations, fmt.Sprintf("round %d: node %d certified %x in period %d, %x certified before",
+				te.Round, n.id, te.Proposal.BlockDigest, te.Period, value.BlockDigest))
+		}
+		s.dispatch(n, e)
+
+	case softThreshold, nextThreshold:
+		s.dispatch(n, e)
+	}
+}
+
+// dispatch delivers an event to the player of a node, keeping its vote
+// aggregator in sync, and carries out the resulting actions.
+func (s *simulator) dispatch(n *simNode, e event) {
+	r, p := n.player.Round, n.player.Period
+	actions := n.player.handle(e)
+	if n.player.Round != r {
+		n.votes.newRound(n.player.Round, n.player.params())
+	}
+	if n.player.Period != p {
+		n.votes.newPeriod(n.player.Period)
+	}
+	s.execute(n, actions)
+}
+
+// execute carries out the actions of the player of a node. The pseudonode
+// tasks are waited for, so their output is handled at the current time.
+func (s *simulator) execute(n *simNode, actions []action) {
+	for _, a := range actions {
+		if a, ok := a.(ensureAction); ok {
+			s.commit(n, a.Payload.Block, a.Certificate)
+		}
+	}
+	for _, a := range actions {
+		switch a := a.(type) {
+		case rezeroAction:
+			n.zero = s.now
+
+		case pseudonodeAction:
+			var (
+				out <-chan externalEvent
+				err error
+			)
+			if a.T == assemble {
+				out, err = n.pseudonode.MakeProposals(context.Background(), uint64(a.Round), uint64(a.Period))
+			} else {
+				out, err = n.pseudonode.MakeVotes(context.Background(), uint64(a.Round), uint64(a.Period), a.Step, a.Proposal)
+			}
+			if err != nil {
+				s.t.Fatalf("node %d: failed to run pseudonode task %v: %v", n.id, a, err)
+			}
+			var events []externalEvent
+			for e := range out {
+				events = append(events, e)
+			}
+			for _, e := range events {
+				s.handle(n, e.(messageEvent))
+			}
+		}
+	}
+}
+
+// commit adds a certified block to the chain of a node.
+func (s *simulator) commit(n *simNode, block *types.Block, cert unauthenticatedBundle) {
+	if err := n.ledger.EnsureBlock(block); err != nil {
+		s.t.Fatalf("node %d: failed to commit block %d: %v", n.id, block.NumberU64(), err)
+	}
+	n.certs[block.NumberU64()] = cert
+}
+
+func TestSimulationHonest(t *testing.T) {
+	sim := newSimulator(t, simConfig{Nodes: 5, Seed: 1, Latency: 50 * time.Millisecond, Jitter: 100 * time.Millisecond})
+	defer sim.stop()
+
+	report := sim.run(5, time.Hour)
+	t.Log(report)
+	if len(report.Violations) > 0 {
+		t.Fatalf("safety violated: %v", report.Violations)
+	}
+	if report.Rounds < 5 {
+		t.Fatalf("liveness lost: committed %d rounds, want 5", report.Rounds)
+	}
+	// Without failures every round concludes in the first period
+	for r, p := range report.Periods {
+		if p != 0 {
+			t.Errorf("round %d: certified in period %d, want 0", r+1, p)
+		}
+	}
+}
+
+func TestSimulationLossy(t *testing.T) {
+	sim := newSimulator(t, simConfig{Nodes: 7, Seed: 2, Latency: 100 * time.Millisecond, Jitter: 500 * time.Millisecond, Loss: 0.2})
+	defer sim.stop()
+
+	report := sim.run(5, time.Hour)
+	t.Log(report)
+	if len(report.Violations) > 0 {
+		t.Fatalf("safety violated: %v", report.Violations)
+	}
+	if report.Rounds < 5 {
+		t.Fatalf("liveness lost: committed %d rounds, want 5", report.Rounds)
+	}
+}
+
+func TestSimulationPartition(t *testing.T) {
+	sim := newSimulator(t, simConfig{Nodes: 7, Seed: 3, Latency: 50 * time.Millisecond, Jitter: 100 * time.Millisecond})
+	defer sim.stop()
+
+	if report := sim.run(2, time.Hour); report.Rounds < 2 {
+		t.Fatalf("liveness lost before partition: committed %d rounds, want 2", report.Rounds)
+	}
+	// Neither side of an even split holds enough stake to make progress
+	sim.partition([]int{0, 1, 2, 3}, []int{4, 5, 6})
+	report := sim.run(3, sim.now+time.Minute)
+	if len(report.Violations) > 0 {
+		t.Fatalf("safety violated in partition: %v", report.Violations)
+	}
+	if report.Rounds != 2 {
+		t.Fatalf("partition made progress: committed %d rounds, want 2", report.Rounds)
+	}
+	// Once healed, the network must recover
+	sim.heal()
+	report = sim.run(5, sim.now+time.Hour)
+	t.Log(report)
+	if len(report.Violations) > 0 {
+		t.Fatalf("safety violated after partition: %v", report.Violations)
+	}
+	if report.Rounds < 5 {
+		t.Fatalf("liveness lost after partition: committed %d rounds, want 5", report.Rounds)
+	}
+}
+
+func TestSimulationByzantine(t *testing.T) {
+	tests := []struct {
+		name     string
+		behavior simBehavior
+	}{
+		{"equivocate", simEquivocate},
+		{"withhold", simWithhold},
+	}
+	for i, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			sim := newSimulator(t, simConfig{
+				Nodes:     7,
+				Seed:      int64(10 + i),
+				Latency:   50 * time.Millisecond,
+				Jitter:    100 * time.Millisecond,
+				Byzantine: map[int]simBehavior{0: tt.behavior},
+			})
+			defer sim.stop()
+
+			report := sim.run(5, time.Hour)
+			t.Log(report)
+			if len(report.Violations) > 0 {
+				t.Fatalf("safety violated: %v", report.Violations)
+			}
+			if report.Rounds < 5 {
+				t.Fatalf("liveness lost: committed %d rounds, want 5", report.Rounds)
+			}
+		})
+	}
+}
@@ -4,7 +4,7 @@ import (
 	"context"
 	"github.com/awesome-chain/Xchain/consensus/algo/protocol"
 	"github.com/awesome-chain/Xchain/core/types"
-	"github.com/awesome-chain/Xchain/crypto/vrf"
+	"strconv"
 	"time"
 )
 
@@ -35,9 +35,23 @@ const (
 	down
 )
 
-var (
-	emptyOutput = vrf.Output{0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00} //0x0000000000000000000000000000000000000000000000000000000000000000
-)
+func (s step) String() string {
+	switch s {
+	case propose:
+		return "propose"
+	case soft:
+		return "soft"
+	case cert:
+		return "cert"
+	case late:
+		return "late"
+	case redo:
+		return "redo"
+	case down:
+		return "down"
+	}
+	return "next" + strconv.FormatUint(uint64(s-next), 10)
+}
 
 type BlockFactory interface {
 	// AssembleBlock produces a new ValidatedBlock which is suitable for proposal
@@ -0,0 +1,114 @@
+package algo
+
+import (
+	"fmt"
+
+	"github.com/awesome-chain/Xchain/consensus/algo/protocol"
+	"github.com/awesome-chain/Xchain/core/types"
+)
+
+// UpgradeState tracks the protocol upgrade state machine, it is carried by
+// every header. A proposer proposes an upgrade to a version approved by the
+// current one, the following proposers approve it within the vote rounds and,
+// if enough of them did, the chain switches to it at NextProtocolSwitchOn.
+type UpgradeState struct {
+	CurrentProtocol        protocol.ConsensusVersion // Version of the round of the header
+	NextProtocol           protocol.ConsensusVersion // Proposed version, if any
+	NextProtocolApprovals  uint64                    // Approvals of the proposed version so far
+	NextProtocolVoteBefore uint64                    // Last round to approve the proposed version in
+	NextProtocolSwitchOn   uint64                    // Round the proposed version activates in, if approved
+}
+
+// UpgradeVote is the vote of the proposer of a header on protocol upgrades.
+type UpgradeVote struct {
+	UpgradePropose protocol.ConsensusVersion // Version the proposer proposes to upgrade to
+	UpgradeApprove bool                      // Whether the proposer approves the pending upgrade
+}
+
+// versionAt returns the version of the protocol of the round after the header
+// carrying the upgrade state.
+func (s UpgradeState) versionAt(r uint64) protocol.ConsensusVersion {
+	if s.NextProtocol == "" || r < s.NextProtocolSwitchOn {
+		return s.CurrentProtocol
+	}
+	// The approvals are final by the switch, as it never precedes the deadline
+	if params, ok := Consensus[s.CurrentProtocol]; ok && s.NextProtocolApprovals >= params.UpgradeThreshold {
+		return s.NextProtocol
+	}
+	return s.CurrentProtocol
+}
+
+// applyUpgradeVote computes the upgrade state of round r out of the state of
+// the previous round and the upgrade vote of the proposer of round r.
+func (s UpgradeState) applyUpgradeVote(r uint64, vote UpgradeVote) (UpgradeState, error) {
+	params, ok := Consensus[s.CurrentProtocol]
+	if !ok {
+		return UpgradeState{}, fmt.Errorf("applyUpgradeVote: %v %q", errUnsupportedProtocol, s.CurrentProtocol)
+	}
+	res := s
+	if res.NextProtocol == "" {
+		// No pending upgrade, the proposer may propose one
+		if vote.UpgradeApprove {
+			return UpgradeState{}, fmt.Errorf("applyUpgradeVote: approval without upgrade proposal")
+		}
+		if vote.UpgradePropose != "" {
+			if !params.ApprovedUpgrades[vote.UpgradePropose] {
+				return UpgradeState{}, fmt.Errorf("applyUpgradeVote: proposed upgrade %q not approved by %q", vote.UpgradePropose, s.CurrentProtocol)
+			}
+			res.NextProtocol = vote.UpgradePropose
+			res.NextProtocolApprovals = 0
+			res.NextProtocolVoteBefore = r + params.UpgradeVoteRounds
+			res.NextProtocolSwitchOn = r + params.UpgradeVoteRounds + params.UpgradeWaitRounds
+		}
+		return res, nil
+	}
+	// An upgrade is pending, count the approvals while the vote is open
+	if vote.UpgradePropose != "" {
+		return UpgradeState{}, fmt.Errorf("applyUpgradeVote: new proposal during pending upgrade")
+	}
+	if r < res.NextProtocolVoteBefore {
+		if vote.UpgradeApprove {
+			res.NextProtocolApprovals++
+		}
+	} else if vote.UpgradeApprove {
+		return UpgradeState{}, fmt.Errorf("applyUpgradeVote: approval after vote deadline")
+	}
+	// Drop the upgrade if it was not approved in time, switch to it if it was
+	if r == res.NextProtocolVoteBefore && res.NextProtocolApprovals < params.UpgradeThreshold {
+		return UpgradeState{CurrentProtocol: res.CurrentProtocol}, nil
+	}
+	if r >= res.NextProtocolSwitchOn {
+		return UpgradeState{CurrentProtocol: res.NextProtocol}, nil
+	}
+	return res, nil
+}
+
+// upgradeVote returns the vote of the local proposer on the upgrades of round
+// r, given the upgrade state of the previous round. The proposer proposes and
+// approves the version its node wants to run.
+func (a *Algorand) upgradeVote(s UpgradeState, r uint64) UpgradeVote {
+	want := a.upgradeTo
+	if want == "" {
+		want = protocol.ConsensusCurrentVersion
+	}
+	if s.NextProtocol == "" {
+		if s.CurrentProtocol != want && Consensus[s.CurrentProtocol].ApprovedUpgrades[want] {
+			return UpgradeVote{UpgradePropose: want}
+		}
+		return UpgradeVote{}
+	}
+	return UpgradeVote{UpgradeApprove: s.NextProtocol == want && r < s.NextProtocolVoteBefore}
+}
+
+// upgradeState returns the upgrade state carried by a header. The genesis block
+// starts with the configured protocol.
+func (a *Algorand) upgradeState(header *types.Header) (UpgradeState, error) {
+	if header.Number.Uint64() == 0 {
+		return UpgradeState{CurrentProtocol: a.genesisProtocol()}, nil
+	}
+	extra, err := decodeHeaderExtra(header)
+	if err != nil {
+		return UpgradeState{}, err
+	}
+	return extra.UpgradeState, nil
+}
@@ -0,0 +1,141 @@
+package algo
+
+import (
+	"testing"
+
+	"github.com/awesome-chain/Xchain/consensus/algo/protocol"
+	"github.com/awesome-chain/Xchain/core/types"
+	"github.com/awesome-chain/Xchain/crypto"
+	"github.com/awesome-chain/Xchain/rlp"
+)
+
+const (
+	testUpgradeFrom = protocol.ConsensusVersion("test-upgrade-from")
+	testUpgradeTo   = protocol.ConsensusVersion("test-upgrade-to")
+)
+
+// Register a pair of protocol versions with short upgrade windows, the first
+// one approving the second.
+func init() {
+	from := Consensus[protocol.ConsensusV1]
+	from.ApprovedUpgrades = map[protocol.ConsensusVersion]bool{testUpgradeTo: true}
+	from.UpgradeVoteRounds, from.UpgradeThreshold, from.UpgradeWaitRounds = 3, 2, 2
+	Consensus[testUpgradeFrom] = from
+
+	to := Consensus[protocol.ConsensusV1]
+	to.SeedLookback = 3
+	Consensus[testUpgradeTo] = to
+}
+
+func TestApplyUpgradeVote(t *testing.T) {
+	approve := UpgradeVote{UpgradeApprove: true}
+	tests := []struct {
+		votes []UpgradeVote // Votes of the rounds starting at 1
+		want  UpgradeState  // State after the last vote
+		fail  bool          // Whether the last vote is invalid
+	}{
+		// No proposal, nothing changes
+		{votes: []UpgradeVote{{}, {}}, want: UpgradeState{CurrentProtocol: testUpgradeFrom}},
+		// Proposal of a version not approved by the current one
+		{votes: []UpgradeVote{{UpgradePropose: protocol.ConsensusFuture}}, fail: true},
+		// Approval without pending proposal
+		{votes: []UpgradeVote{approve}, fail: true},
+		// Pending proposal counting approvals
+		{
+			votes: []UpgradeVote{{UpgradePropose: testUpgradeTo}, approve},
+			want:  UpgradeState{CurrentProtocol: testUpgradeFrom, NextProtocol: testUpgradeTo, NextProtocolApprovals: 1, NextProtocolVoteBefore: 4, NextProtocolSwitchOn: 6},
+		},
+		// Second proposal during a pending upgrade
+		{votes: []UpgradeVote{{UpgradePropose: testUpgradeTo}, {UpgradePropose: testUpgradeTo}}, fail: true},
+		// Approval after the vote deadline
+		{votes: []UpgradeVote{{UpgradePropose: testUpgradeTo}, approve, approve, approve}, fail: true},
+		// Not enough approvals, the proposal is dropped at the deadline
+		{votes: []UpgradeVote{{UpgradePropose: testUpgradeTo}, approve, {}, {}}, want: UpgradeState{CurrentProtocol: testUpgradeFrom}},
+		// Enough approvals, the upgrade activates at the switch round
+		{
+			votes: []UpgradeVote{{UpgradePropose: testUpgradeTo}, approve, approve, {}, {}},
+			want:  UpgradeState{CurrentProtocol: testUpgradeFrom, NextProtocol: testUpgradeTo, NextProtocolApprovals: 2, NextProtocolVoteBefore: 4, NextProtocolSwitchOn: 6},
+		},
+		{votes: []UpgradeVote{{UpgradePropose: testUpgradeTo}, approve, approve, {}, {}, {}}, want: UpgradeState{CurrentProtocol: testUpgradeTo}},
+	}
+	for i, tt := range tests {
+		var (
+			state = UpgradeState{CurrentProtocol: testUpgradeFrom}
+			err   error
+		)
+		for r, vote := range tt.votes {
+			if state, err = state.applyUpgradeVote(uint64(r+1), vote); err != nil {
+				break
+			}
+		}
+		if tt.fail {
+			if err == nil {
+				t.Errorf("test %d: invalid vote accepted", i)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("test %d: failed to apply votes: %v", i, err)
+			continue
+		}
+		if state != tt.want {
+			t.Errorf("test %d: state mismatch: have %+v, want %+v", i, state, tt.want)
+		}
+	}
+}
+
+func TestUpgradeVersionAt(t *testing.T) {
+	pending := UpgradeState{CurrentProtocol: testUpgradeFrom, NextProtocol: testUpgradeTo, NextProtocolApprovals: 2, NextProtocolVoteBefore: 4, NextProtocolSwitchOn: 6}
+	if have := pending.versionAt(5); have != testUpgradeFrom {
+		t.Errorf("version before switch mismatch: have %q, want %q", have, testUpgradeFrom)
+	}
+	if have := pending.versionAt(6); have != testUpgradeTo {
+		t.Errorf("version at switch mismatch: have %q, want %q", have, testUpgradeTo)
+	}
+	pending.NextProtocolApprovals = 1
+	if have := pending.versionAt(6); have != testUpgradeFrom {
+		t.Errorf("version of rejected upgrade mismatch: have %q, want %q", have, testUpgradeFrom)
+	}
+}
+
+func TestProtocolUpgrade(t *testing.T) {
+	key, _ := crypto.HexToECDSA("b71c71a67e1177ad4e901695e1b4b9ee17ae16c6668d313eac2f96dbcda3f291")
+	engine, chain := newTestParticipants(t, key)
+	defer chain.Stop()
+
+	engine.config.Protocol = string(testUpgradeFrom)
+	engine.upgradeTo = testUpgradeTo
+	engine.Authorize(key)
+
+	// The sole proposer proposes and approves the upgrade, which must activate
+	// at the switch round
+	for i := uint64(1); i <= 6; i++ {
+		block := makeBlock(t, chain, engine)
+		if _, err := chain.InsertChain(types.Blocks{block}); err != nil {
+			t.Fatalf("block %d: failed to insert: %v", i, err)
+		}
+		want := testUpgradeFrom
+		if i >= 6 {
+			want = testUpgradeTo
+		}
+		if version, err := engine.consensusVersion(chain, i); err != nil || version != want {
+			t.Fatalf("block %d: version mismatch: have %q, want %q, err %v", i, version, want, err)
+		}
+	}
+	if params, err := engine.consensusParams(chain, 7); err != nil || params.SeedLookback != 3 {
+		t.Fatalf("upgraded params mismatch: have lookback %d, want 3, err %v", params.SeedLookback, err)
+	}
+	// A block with a tampered upgrade state must be rejected even if re-signed
+	header := makeBlock(t, chain, engine).Header()
+	extra, err := decodeHeaderExtra(header)
+	if err != nil {
+		t.Fatalf("failed to decode header extra: %v", err)
+	}
+	extra.CurrentProtocol = testUpgradeFrom
+	enc, _ := rlp.EncodeToBytes(extra)
+	header.Extra = append(header.Extra[:extraVanity:extraVanity], enc...)
+	header.Sig, _ = crypto.Sign(HashHeader(header).Bytes(), key)
+	if err := engine.VerifyHeader(chain, header, true); err != errInvalidUpgradeState {
+		t.Fatalf("tampered upgrade state error mismatch: have %v, want %v", err, errInvalidUpgradeState)
+	}
+}
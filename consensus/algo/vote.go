@@ -0,0 +1,222 @@
+package algo
+
+import (
+	"bytes"
+	"crypto/ecdsa"
+	"errors"
+	"fmt"
+
+	"github.com/awesome-chain/Xchain/common"
+	"github.com/awesome-chain/Xchain/consensus/algo/committee"
+	"github.com/awesome-chain/Xchain/consensus/algo/protocol"
+	"github.com/awesome-chain/Xchain/crypto"
+	"github.com/awesome-chain/Xchain/rlp"
+)
+
+var (
+	// errVoteSender is returned if the signature of a vote was not produced by
+	// its sender.
+	errVoteSender = errors.New("vote signature does not match sender")
+
+	// errVoteBottom is returned if a vote for the empty value is cast in a step
+	// which only accepts real proposal-values.
+	errVoteBottom = errors.New("bottom vote in step which requires a value")
+
+	// errEquivocationSame is returned if the two votes of an equivocation vote
+	// are for the same proposal-value.
+	errEquivocationSame = errors.New("equivocation vote has two identical proposal-values")
+)
+
+// rawVote is the inner struct which is authenticated with the participation
+// key of the sender.
+type rawVote struct {
+	Sender   common.Address // Stake account the vote is cast for
+	Round    uint64
+	Period   uint64
+	Step     uint64
+	Proposal ProposalValue
+}
+
+// ToBeHashed implements the Hashable interface.
+func (rv rawVote) ToBeHashed() (protocol.HashID, []byte, error) {
+	bs, err := rlp.EncodeToBytes(&rv)
+	if err != nil {
+		return "", nil, err
+	}
+	return protocol.Vote, bs, nil
+}
+
+// An unauthenticatedVote is a vote which has not been verified.
+type unauthenticatedVote struct {
+	R    rawVote
+	Cred committee.UnauthenticatedCredential
+	Sig  []byte
+}
+
+// ToBeHashed implements the Hashable interface.
+func (uv unauthenticatedVote) ToBeHashed() (protocol.HashID, []byte, error) {
+	bs, err := rlp.EncodeToBytes(&uv)
+	if err != nil {
+		return "", nil, err
+	}
+	return protocol.Vote, bs, nil
+}
+
+// A vote is an endorsement of a particular proposal in Algorand
+type vote struct {
+	R    rawVote
+	Cred committee.Credential
+	Sig  []byte
+}
+
+// u returns the unauthenticated version of the vote.
+func (v vote) u() unauthenticatedVote {
+	return unauthenticatedVote{R: v.R, Cred: v.Cred.UnauthenticatedCredential, Sig: v.Sig}
+}
+
+// makeVote creates a new unauthenticated vote from its constituent components.
+func makeVote(rv rawVote, key *ecdsa.PrivateKey, cred committee.UnauthenticatedCredential) (unauthenticatedVote, error) {
+	sig, err := crypto.Sign(HashObj(rv).Bytes(), key)
+	if err != nil {
+		return unauthenticatedVote{}, err
+	}
+	return unauthenticatedVote{R: rv, Cred: cred, Sig: sig}, nil
+}
+
+// recoverSender returns the participation key which signed a raw vote, checking
+// that the sender of the vote registered it for the round of the vote.
+func recoverSender(a *Algorand, l Ledger, rv rawVote, sig []byte) ([]byte, error) {
+	if len(sig) != sigLength {
+		return nil, errMissingSignature
+	}
+	pubkey, err := crypto.Ecrecover(HashObj(rv).Bytes(), sig)
+	if err != nil {
+		return nil, err
+	}
+	key, online, err := a.participationKey(l, rv.Sender, rv.Round)
+	if err != nil {
+		return nil, err
+	}
+	if !online || common.BytesToAddress(crypto.Keccak256(pubkey[1:])[12:]) != key {
+		return nil, errVoteSender
+	}
+	return pubkey, nil
+}
+
+// verify verifies that a vote that was received from the network is valid:
+// it is signed by its sender, the sender was selected for the committee of
+// the vote's step and the vote is well-formed for that step.
+func (uv unauthenticatedVote) verify(a *Algorand, l Ledger) (vote, error) {
+	rv := uv.R
+	if rv.Proposal.isBottom() && (step(rv.Step) == propose || step(rv.Step) == soft || step(rv.Step) == cert) {
+		return vote{}, errVoteBottom
+	}
+	pubkey, err := recoverSender(a, l, rv, uv.Sig)
+	if err != nil {
+		return vote{}, err
+	}
+	cred, err := a.verifyCredential(l, rv.Sender, pubkey, uv.Cred, round(rv.Round), period(rv.Period), step(rv.Step))
+	if err != nil {
+		return vote{}, fmt.Errorf("unauthenticatedVote.verify: got a vote, but sender was not selected: %v", err)
+	}
+	return vote{R: rv, Cred: cred, Sig: uv.Sig}, nil
+}
+
+// An unauthenticatedEquivocationVote is a pair of votes which has not
+// been verified to be equivocating.
+type unauthenticatedEquivocationVote struct {
+	Sender    common.Address
+	Round     uint64
+	Period    uint64
+	Step      uint64
+	Cred      committee.UnauthenticatedCredential
+	Proposals [2]ProposalValue
+	Sigs      [2][]byte
+}
+
+// An equivocationVote is a pair of votes by the same sender in the same
+// (round, period, step) for two different proposal-values.
+type equivocationVote struct {
+	Sender    common.Address
+	Round     uint64
+	Period    uint64
+	Step      uint64
+	Cred      committee.Credential
+	Proposals [2]ProposalValue
+	Sigs      [2][]byte
+}
+
+// makeEquivocationVote assembles the evidence of two conflicting votes. The
+// caller is responsible for both votes sharing sender, round, period and step.
+func makeEquivocationVote(v0 vote, v1 vote) equivocationVote {
+	return equivocationVote{
+		Sender:    v0.R.Sender,
+		Round:     v0.R.Round,
+		Period:    v0.R.Period,
+		Step:      v0.R.Step,
+		Cred:      v0.Cred,
+		Proposals: [2]ProposalValue{v0.R.Proposal, v1.R.Proposal},
+		Sigs:      [2][]byte{v0.Sig, v1.Sig},
+	}
+}
+
+// u returns the unauthenticated version of the equivocation vote.
+func (ev equivocationVote) u() unauthenticatedEquivocationVote {
+	return unauthenticatedEquivocationVote{
+		Sender:    ev.Sender,
+		Round:     ev.Round,
+		Period:    ev.Period,
+		Step:      ev.Step,
+		Cred:      ev.Cred.UnauthenticatedCredential,
+		Proposals: ev.Proposals,
+		Sigs:      ev.Sigs,
+	}
+}
+
+// votes returns the two votes making up an unauthenticated equivocation vote.
+func (uev unauthenticatedEquivocationVote) votes() [2]unauthenticatedVote {
+	var uvs [2]unauthenticatedVote
+	for i := range uvs {
+		uvs[i] = unauthenticatedVote{
+			R:    rawVote{Sender: uev.Sender, Round: uev.Round, Period: uev.Period, Step: uev.Step, Proposal: uev.Proposals[i]},
+			Cred: uev.Cred,
+			Sig:  uev.Sigs[i],
+		}
+	}
+	return uvs
+}
+
+// verify verifies that both votes of an equivocation vote are valid and that
+// they are in fact for two different proposal-values.
+func (uev unauthenticatedEquivocationVote) verify(a *Algorand, l Ledger) (equivocationVote, error) {
+	if uev.Proposals[0].key() == uev.Proposals[1].key() {
+		return equivocationVote{}, errEquivocationSame
+	}
+	uvs := uev.votes()
+	v0, err := uvs[0].verify(a, l)
+	if err != nil {
+		return equivocationVote{}, err
+	}
+	// The credential only depends on the selector, so the second vote merely
+	// needs a valid signature by the same participation key
+	pubkey0, err := crypto.Ecrecover(HashObj(uvs[0].R).Bytes(), uvs[0].Sig)
+	if err != nil {
+		return equivocationVote{}, err
+	}
+	pubkey1, err := recoverSender(a, l, uvs[1].R, uvs[1].Sig)
+	if err != nil {
+		return equivocationVote{}, err
+	}
+	if !bytes.Equal(pubkey0, pubkey1) {
+		return equivocationVote{}, errVoteSender
+	}
+	return equivocationVote{
+		Sender:    uev.Sender,
+		Round:     uev.Round,
+		Period:    uev.Period,
+		Step:      uev.Step,
+		Cred:      v0.Cred,
+		Proposals: uev.Proposals,
+		Sigs:      uev.Sigs,
+	}, nil
+}
@@ -0,0 +1,184 @@
+package algo
+
+import (
+	"crypto/ecdsa"
+	"math/big"
+	"testing"
+
+	"github.com/awesome-chain/Xchain/common"
+	"github.com/awesome-chain/Xchain/consensus/algo/committee"
+	"github.com/awesome-chain/Xchain/consensus/algo/protocol"
+	"github.com/awesome-chain/Xchain/core"
+	"github.com/awesome-chain/Xchain/core/vm"
+	"github.com/awesome-chain/Xchain/crypto"
+	"github.com/awesome-chain/Xchain/ethdb"
+	"github.com/awesome-chain/Xchain/params"
+)
+
+// newTestParticipants creates a chain where every key is a participant holding
+// an equal share of the stake.
+func newTestParticipants(t *testing.T, keys ...*ecdsa.PrivateKey) (*Algorand, *core.BlockChain) {
+	var (
+		db     = ethdb.NewMemDatabase()
+		funds  = new(big.Int).Mul(big.NewInt(10000), stakeUnit)
+		config = *params.AllAlgoProtocolChanges
+		alloc  = make(core.GenesisAlloc)
+	)
+	config.Algo = &params.AlgoConfig{Period: 1}
+	for _, key := range keys {
+		addr := crypto.PubkeyToAddress(key.PublicKey)
+		config.Algo.Participants = append(config.Algo.Participants, common.UnprefixedAddress(addr))
+		alloc[addr] = core.GenesisAccount{Balance: funds}
+	}
+	gspec := &core.Genesis{Config: &config, Alloc: alloc}
+	gspec.MustCommit(db)
+
+	engine := New(config.Algo, db)
+	chain, err := core.NewBlockChain(db, nil, gspec.Config, engine, vm.Config{})
+	if err != nil {
+		t.Fatalf("failed to create chain: %v", err)
+	}
+	return engine, chain
+}
+
+// signVote evaluates the credential of a key and signs a vote with it.
+func signVote(t *testing.T, engine *Algorand, chain *core.BlockChain, key *ecdsa.PrivateKey, s step, value ProposalValue) unauthenticatedVote {
+	return signRoundVote(t, engine, chain, key, 1, s, value)
+}
+
+// signRoundVote signs a vote of a given round in period 0.
+func signRoundVote(t *testing.T, engine *Algorand, chain *core.BlockChain, key *ecdsa.PrivateKey, r round, s step, value ProposalValue) unauthenticatedVote {
+	engine.Authorize(key)
+	cred, err := engine.credential(chain, r, 0, s)
+	if err != nil {
+		t.Fatalf("failed to evaluate credential: %v", err)
+	}
+	rv := rawVote{Sender: crypto.PubkeyToAddress(key.PublicKey), Round: uint64(r), Period: 0, Step: uint64(s), Proposal: value}
+	uv, err := makeVote(rv, key, cred.UnauthenticatedCredential)
+	if err != nil {
+		t.Fatalf("failed to sign vote: %v", err)
+	}
+	return uv
+}
+
+func TestVoteVerify(t *testing.T) {
+	key, _ := crypto.HexToECDSA("b71c71a67e1177ad4e901695e1b4b9ee17ae16c6668d313eac2f96dbcda3f291")
+	other, _ := crypto.GenerateKey()
+	engine, chain := newTestParticipants(t, key, other)
+	defer chain.Stop()
+
+	value := ProposalValue{BlockDigest: common.HexToHash("0x01")}
+	uv := signVote(t, engine, chain, key, soft, value)
+	v, err := uv.verify(engine, chain)
+	if err != nil {
+		t.Fatalf("failed to verify vote: %v", err)
+	}
+	if v.Cred.Weight == 0 {
+		t.Fatalf("verified vote has no weight")
+	}
+	// A vote claiming another sender must be rejected
+	forged := uv
+	forged.R.Sender = crypto.PubkeyToAddress(other.PublicKey)
+	if _, err := forged.verify(engine, chain); err != errVoteSender {
+		t.Fatalf("forged sender error mismatch: have %v, want %v", err, errVoteSender)
+	}
+	// A soft vote for the empty value must be rejected
+	uv = signVote(t, engine, chain, key, soft, bottom)
+	if _, err := uv.verify(engine, chain); err != errVoteBottom {
+		t.Fatalf("bottom vote error mismatch: have %v, want %v", err, errVoteBottom)
+	}
+}
+
+// testVote creates an unsigned vote with the given weight for tracker tests.
+func testVote(sender byte, s step, value ProposalValue, weight uint64) vote {
+	return vote{
+		R:    rawVote{Sender: common.Address{sender}, Round: 1, Period: 0, Step: uint64(s), Proposal: value},
+		Cred: committee.Credential{Weight: weight},
+	}
+}
+
+func TestVoteTrackerThreshold(t *testing.T) {
+	var (
+		params = Consensus[protocol.ConsensusV1]
+		agg    = makeVoteAggregator(1, 0, params)
+		value  = ProposalValue{BlockDigest: common.HexToHash("0x01")}
+		other  = ProposalValue{BlockDigest: common.HexToHash("0x02")}
+		weight = params.threshold(cert) / 4
+	)
+	deliver := func(v vote) event {
+		return agg.handle(messageEvent{T: voteVerified, Input: message{Vote: v}})
+	}
+	// Three honest votes stay below the threshold
+	for i := byte(1); i <= 3; i++ {
+		if e := deliver(testVote(i, cert, value, weight)); e.t() != none {
+			t.Fatalf("vote %d: unexpected event %v", i, e)
+		}
+	}
+	// Duplicates are filtered
+	if e := deliver(testVote(1, cert, value, weight)); e.t() != voteFiltered {
+		t.Fatalf("duplicate vote: have event %v, want %v", e.t(), voteFiltered)
+	}
+	// An equivocating sender counts for every value and reaches the threshold
+	e := deliver(testVote(4, cert, other, weight+1))
+	if e.t() != none {
+		t.Fatalf("first vote of equivocator: unexpected event %v", e)
+	}
+	e = deliver(testVote(4, cert, value, weight+1))
+	if e.t() != certThreshold {
+		t.Fatalf("equivocation: have event %v, want %v", e.t(), certThreshold)
+	}
+	th := e.(thresholdEvent)
+	if th.Proposal.key() != value.key() {
+		t.Fatalf("threshold value mismatch: have %v, want %v", th.Proposal, value)
+	}
+	if len(th.Bundle.Votes) != 3 || len(th.Bundle.EquivocationVotes) != 1 {
+		t.Fatalf("bundle size mismatch: have %d votes and %d equivocations, want 3 and 1", len(th.Bundle.Votes), len(th.Bundle.EquivocationVotes))
+	}
+	// The threshold fires only once, and further votes of the equivocator are filtered
+	if e := deliver(testVote(5, cert, value, weight)); e.t() != none {
+		t.Fatalf("vote after threshold: unexpected event %v", e)
+	}
+	if e := deliver(testVote(4, cert, value, weight)); e.t() != voteFiltered {
+		t.Fatalf("vote of equivocator: have event %v, want %v", e.t(), voteFiltered)
+	}
+	// Votes of other rounds are filtered
+	v := testVote(6, cert, value, weight)
+	v.R.Round = 2
+	if e := deliver(v); e.t() != voteFiltered {
+		t.Fatalf("vote of other round: have event %v, want %v", e.t(), voteFiltered)
+	}
+}
+
+func TestBundleVerify(t *testing.T) {
+	key, _ := crypto.HexToECDSA("b71c71a67e1177ad4e901695e1b4b9ee17ae16c6668d313eac2f96dbcda3f291")
+	other, _ := crypto.GenerateKey()
+	engine, chain := newTestParticipants(t, key, other)
+	defer chain.Stop()
+
+	value := ProposalValue{BlockDigest: common.HexToHash("0x01")}
+	var votes []vote
+	for _, k := range []*ecdsa.PrivateKey{key, other} {
+		v, err := signVote(t, engine, chain, k, soft, value).verify(engine, chain)
+		if err != nil {
+			t.Fatalf("failed to verify vote: %v", err)
+		}
+		votes = append(votes, v)
+	}
+	// Both participants together hold enough stake to reach the threshold
+	b := makeBundle(value, votes, nil)
+	verified, err := b.U.verify(engine, chain)
+	if err != nil {
+		t.Fatalf("failed to verify bundle: %v", err)
+	}
+	if verified.weight() != b.weight() {
+		t.Fatalf("bundle weight mismatch: have %d, want %d", verified.weight(), b.weight())
+	}
+	// A single participant holds only half of the stake
+	if _, err := makeBundle(value, votes[:1], nil).U.verify(engine, chain); err != errBundleBelowThreshold {
+		t.Fatalf("partial bundle error mismatch: have %v, want %v", err, errBundleBelowThreshold)
+	}
+	// Counting a sender twice must be rejected
+	if _, err := makeBundle(value, []vote{votes[0], votes[0], votes[1]}, nil).U.verify(engine, chain); err != errBundleDuplicateSender {
+		t.Fatalf("duplicate sender error mismatch: have %v, want %v", err, errBundleDuplicateSender)
+	}
+}
@@ -0,0 +1,308 @@
+package algo
+
+import (
+	"bytes"
+	"fmt"
+	"sort"
+
+	"github.com/awesome-chain/Xchain/common"
+	"github.com/awesome-chain/Xchain/log"
+)
+
+// proposalVoteCounter accumulates the votes for a single proposal-value.
+type proposalVoteCounter struct {
+	Value  ProposalValue
+	Weight uint64
+	Votes  map[common.Address]vote
+}
+
+// sortedVotes returns the votes of the counter ordered by sender.
+func (c *proposalVoteCounter) sortedVotes() []vote {
+	votes := make([]vote, 0, len(c.Votes))
+	for _, v := range c.Votes {
+		votes = append(votes, v)
+	}
+	sort.Slice(votes, func(i, j int) bool {
+		return bytes.Compare(votes[i].R.Sender[:], votes[j].R.Sender[:]) < 0
+	})
+	return votes
+}
+
+// voteTracker counts the votes of a single (round, period, step) and emits a
+// threshold event once the weight for some proposal-value reaches the threshold
+// of the step.
+type voteTracker struct {
+	// Voters holds the vote of every sender which did not equivocate.
+	Voters map[common.Address]vote
+
+	// Counts holds the votes for each proposal-value.
+	Counts map[common.Hash]*proposalVoteCounter
+
+	// Equivocators holds the evidence of every sender which equivocated. The
+	// weight of an equivocator counts towards every proposal-value.
+	Equivocators       map[common.Address]equivocationVote
+	EquivocatorsWeight uint64
+
+	// Fired is set once the threshold event of the step was emitted.
+	Fired bool
+
+	threshold uint64 // Weight needed for the threshold event of the step
+}
+
+func makeVoteTracker(threshold uint64) *voteTracker {
+	return &voteTracker{
+		threshold:    threshold,
+		Voters:       make(map[common.Address]vote),
+		Counts:       make(map[common.Hash]*proposalVoteCounter),
+		Equivocators: make(map[common.Address]equivocationVote),
+	}
+}
+
+// thresholdType returns the type of the threshold event emitted by a step.
+func (s step) thresholdType() eventType {
+	switch s {
+	case propose:
+		return none
+	case soft:
+		return softThreshold
+	case cert:
+		return certThreshold
+	default:
+		return nextThreshold
+	}
+}
+
+// filter returns an error if a vote would not change the state of the tracker.
+func (tracker *voteTracker) filter(rv rawVote) error {
+	if _, ok := tracker.Equivocators[rv.Sender]; ok {
+		return fmt.Errorf("voteTracker: sender %x already equivocated", rv.Sender)
+	}
+	if prev, ok := tracker.Voters[rv.Sender]; ok && prev.R.Proposal.key() == rv.Proposal.key() {
+		return fmt.Errorf("voteTracker: duplicate vote from sender %x", rv.Sender)
+	}
+	return nil
+}
+
+// handle counts a verified vote. It returns a filteredEvent if the vote is a
+// duplicate, a thresholdEvent if the vote made the step reach its threshold,
+// and an emptyEvent otherwise.
+func (tracker *voteTracker) handle(v vote) event {
+	if err := tracker.filter(v.R); err != nil {
+		return filteredEvent{T: voteFiltered, Err: err}
+	}
+	sender := v.R.Sender
+	if prev, ok := tracker.Voters[sender]; ok {
+		// A second vote for another value is an equivocation
+		ev := makeEquivocationVote(prev, v)
+		log.Warn("Detected equivocating vote", "sender", sender, "round", v.R.Round, "period", v.R.Period, "step", step(v.R.Step))
+		tracker.removeVoter(prev)
+		tracker.Equivocators[sender] = ev
+		tracker.EquivocatorsWeight += ev.Cred.Weight
+	} else {
+		tracker.Voters[sender] = v
+		key := v.R.Proposal.key()
+		counter, ok := tracker.Counts[key]
+		if !ok {
+			counter = &proposalVoteCounter{Value: v.R.Proposal, Votes: make(map[common.Address]vote)}
+			tracker.Counts[key] = counter
+		}
+		counter.Votes[sender] = v
+		counter.Weight += v.Cred.Weight
+	}
+	return tracker.check(step(v.R.Step))
+}
+
+// handleEquivocation counts a verified equivocation vote, e.g. one carried by
+// a bundle.
+func (tracker *voteTracker) handleEquivocation(ev equivocationVote) event {
+	if _, ok := tracker.Equivocators[ev.Sender]; ok {
+		return filteredEvent{T: voteFiltered, Err: fmt.Errorf("voteTracker: sender %x already equivocated", ev.Sender)}
+	}
+	if prev, ok := tracker.Voters[ev.Sender]; ok {
+		tracker.removeVoter(prev)
+	}
+	tracker.Equivocators[ev.Sender] = ev
+	tracker.EquivocatorsWeight += ev.Cred.Weight
+	return tracker.check(step(ev.Step))
+}
+
+// removeVoter removes the vote of a sender from the counts.
+func (tracker *voteTracker) removeVoter(v vote) {
+	delete(tracker.Voters, v.R.Sender)
+	if counter, ok := tracker.Counts[v.R.Proposal.key()]; ok {
+		delete(counter.Votes, v.R.Sender)
+		counter.Weight -= v.Cred.Weight
+	}
+}
+
+// check emits the threshold event of the step if some proposal-value reached
+// the threshold and no event was emitted before.
+func (tracker *voteTracker) check(s step) event {
+	if tracker.Fired || s == propose {
+		return emptyEvent{}
+	}
+	// Pick the heaviest value, breaking ties by key to stay deterministic
+	var (
+		best    *proposalVoteCounter
+		bestKey common.Hash
+	)
+	for key, counter := range tracker.Counts {
+		if len(counter.Votes) == 0 {
+			continue
+		}
+		if best == nil || counter.Weight > best.Weight || (counter.Weight == best.Weight && bytes.Compare(key[:], bestKey[:]) < 0) {
+			best, bestKey = counter, key
+		}
+	}
+	if best == nil || best.Weight+tracker.EquivocatorsWeight < tracker.threshold {
+		return emptyEvent{}
+	}
+	tracker.Fired = true
+
+	equivocations := make([]equivocationVote, 0, len(tracker.Equivocators))
+	for _, ev := range tracker.Equivocators {
+		equivocations = append(equivocations, ev)
+	}
+	sort.Slice(equivocations, func(i, j int) bool {
+		return bytes.Compare(equivocations[i].Sender[:], equivocations[j].Sender[:]) < 0
+	})
+	b := makeBundle(best.Value, best.sortedVotes(), equivocations)
+	return thresholdEvent{
+		T:        s.thresholdType(),
+		Round:    b.U.Round,
+		Period:   b.U.Period,
+		Step:     b.U.Step,
+		Proposal: best.Value,
+		Bundle:   b.U,
+	}
+}
+
+// voteTrackerKey identifies the tracker of a step within a round.
+type voteTrackerKey struct {
+	Period period
+	Step   step
+}
+
+// voteAggregator routes the votes and bundles of the current round to the
+// trackers of their (period, step), filtering the irrelevant ones.
+type voteAggregator struct {
+	Round    round
+	Period   period
+	Trackers map[voteTrackerKey]*voteTracker
+
+	params ConsensusParams // Parameters of the protocol version of the round
+}
+
+func makeVoteAggregator(r round, p period, params ConsensusParams) *voteAggregator {
+	return &voteAggregator{Round: r, Period: p, Trackers: make(map[voteTrackerKey]*voteTracker), params: params}
+}
+
+// newRound drops the state of the previous round.
+func (agg *voteAggregator) newRound(r round, params ConsensusParams) {
+	agg.Round, agg.Period = r, 0
+	agg.Trackers = make(map[voteTrackerKey]*voteTracker)
+	agg.params = params
+}
+
+// newPeriod drops the state of all periods except the current and previous one.
+func (agg *voteAggregator) newPeriod(p period) {
+	agg.Period = p
+	for key := range agg.Trackers {
+		if key.Period+1 < p {
+			delete(agg.Trackers, key)
+		}
+	}
+}
+
+// tracker returns the tracker of a (period, step), creating it if needed.
+func (agg *voteAggregator) tracker(p period, s step) *voteTracker {
+	key := voteTrackerKey{Period: p, Step: s}
+	tracker, ok := agg.Trackers[key]
+	if !ok {
+		var threshold uint64
+		if s != propose {
+			threshold = agg.params.threshold(s)
+		}
+		tracker = makeVoteTracker(threshold)
+		agg.Trackers[key] = tracker
+	}
+	return tracker
+}
+
+// filterRelevance returns an error if a message of the given (round, period)
+// is irrelevant to the current state of the aggregator.
+func (agg *voteAggregator) filterRelevance(r uint64, p uint64) error {
+	if round(r) != agg.Round {
+		return fmt.Errorf("voteAggregator: message from round %v, current round %v", r, agg.Round)
+	}
+	if period(p)+1 < agg.Period {
+		return fmt.Errorf("voteAggregator: message from period %v, current period %v", p, agg.Period)
+	}
+	return nil
+}
+
+// filterVote returns an error if a vote is irrelevant or a duplicate.
+func (agg *voteAggregator) filterVote(rv rawVote) error {
+	if err := agg.filterRelevance(rv.Round, rv.Period); err != nil {
+		return err
+	}
+	if tracker, ok := agg.Trackers[voteTrackerKey{Period: period(rv.Period), Step: step(rv.Step)}]; ok {
+		return tracker.filter(rv)
+	}
+	return nil
+}
+
+// handle delivers a vote or bundle event to the aggregator. Present messages
+// are only filtered, verified ones are also counted.
+func (agg *voteAggregator) handle(e messageEvent) event {
+	switch e.T {
+	case votePresent:
+		if err := agg.filterVote(e.Input.UnauthenticatedVote.R); err != nil {
+			return filteredEvent{T: voteFiltered, Err: err}
+		}
+		return emptyEvent{}
+
+	case voteVerified:
+		if e.Err != nil {
+			return filteredEvent{T: voteMalformed, Err: e.Err}
+		}
+		rv := e.Input.Vote.R
+		if err := agg.filterVote(rv); err != nil {
+			return filteredEvent{T: voteFiltered, Err: err}
+		}
+		return agg.tracker(period(rv.Period), step(rv.Step)).handle(e.Input.Vote)
+
+	case bundlePresent:
+		ub := e.Input.UnauthenticatedBundle
+		if err := agg.filterRelevance(ub.Round, ub.Period); err != nil {
+			return filteredEvent{T: bundleFiltered, Err: err}
+		}
+		if tracker, ok := agg.Trackers[voteTrackerKey{Period: period(ub.Period), Step: step(ub.Step)}]; ok && tracker.Fired {
+			return filteredEvent{T: bundleFiltered, Err: fmt.Errorf("voteAggregator: threshold already reached")}
+		}
+		return emptyEvent{}
+
+	case bundleVerified:
+		if e.Err != nil {
+			return filteredEvent{T: bundleMalformed, Err: e.Err}
+		}
+		b := e.Input.Bundle
+		if err := agg.filterRelevance(b.U.Round, b.U.Period); err != nil {
+			return filteredEvent{T: bundleFiltered, Err: err}
+		}
+		tracker := agg.tracker(period(b.U.Period), step(b.U.Step))
+		var res event = emptyEvent{}
+		for _, v := range b.Votes {
+			if ev := tracker.handle(v); ev.t() != voteFiltered && ev.t() != none {
+				res = ev
+			}
+		}
+		for _, ev := range b.EquivocationVotes {
+			if ev := tracker.handleEquivocation(ev); ev.t() != voteFiltered && ev.t() != none {
+				res = ev
+			}
+		}
+		return res
+	}
+	return emptyEvent{}
+}
@@ -29,11 +29,13 @@ import (
 	"github.com/awesome-chain/Xchain/accounts"
 	"github.com/awesome-chain/Xchain/common"
 	"github.com/awesome-chain/Xchain/consensus"
+	"github.com/awesome-chain/Xchain/core/rawdb"
 	"github.com/awesome-chain/Xchain/core/state"
 	"github.com/awesome-chain/Xchain/core/types"
 	"github.com/awesome-chain/Xchain/crypto"
 	"github.com/awesome-chain/Xchain/crypto/sha3"
 	"github.com/awesome-chain/Xchain/ethdb"
+	"github.com/awesome-chain/Xchain/event"
 	"github.com/awesome-chain/Xchain/log"
 	"github.com/awesome-chain/Xchain/params"
 	"github.com/awesome-chain/Xchain/rlp"
@@ -44,6 +46,7 @@ import (
 const (
 	inMemorySnapshots  = 128             // Number of recent vote snapshots to keep in memory
 	inMemorySignatures = 4096            // Number of recent block signatures to keep in memory
+	inMemoryRewards    = 128             // Number of recent reward ledgers to keep in memory until their block is written
 	secondsPerYear     = 365 * 24 * 3600 // Number of seconds for one year
 	checkpointInterval = 360             // About N hours if config.period is N
 	scUnconfirmLoop    = 3               // First count of Loop not send confirm tx to main chain
@@ -147,11 +150,15 @@ type Alien struct {
 	db         ethdb.Database      // Database to store and retrieve snapshot checkpoints
 	recents    *lru.ARCCache       // Snapshots for recent block to speed up reorgs
 	signatures *lru.ARCCache       // Signatures of recent blocks to speed up mining
+	rewards    *lru.ARCCache       // Reward ledgers of recently finalized blocks, keyed by ledger key
 	signer     common.Address      // Ethereum address of the signing key
 	signFn     SignerFn            // Signer function to authorize hashes with
 	signTxFn   SignTxFn            // Sign transaction function to sign tx
 	lock       sync.RWMutex        // Protects the signer fields
 	lcsc       uint64              // Last confirmed side chain
+
+	confirmations *confirmationPool // Confirmations of the recent blocks received off-chain
+	confirmFeed   event.Feed        // Feed of the confirmations signed by the local signer
 }
 
 // SignerFn is a signer callback function to request a hash to be signed by a
@@ -244,12 +251,15 @@ func New(config *params.AlienConfig, db ethdb.Database) *Alien {
 	// Allocate the snapshot caches and create the engine
 	recents, _ := lru.NewARC(inMemorySnapshots)
 	signatures, _ := lru.NewARC(inMemorySignatures)
+	rewards, _ := lru.NewARC(inMemoryRewards)
 
 	return &Alien{
-		config:     &conf,
-		db:         db,
-		recents:    recents,
-		signatures: signatures,
+		config:        &conf,
+		db:            db,
+		recents:       recents,
+		signatures:    signatures,
+		rewards:       rewards,
+		confirmations: newConfirmationPool(),
 	}
 }
 
@@ -348,6 +358,12 @@ func (a *Alien) verifyCascadingFields(chain consensus.ChainReader, header *types
 	if err != nil {
 		return err
 	}
+	// Blocks are confirmed by the certificates in the headers after the fork
+	if a.config.IsConfirm(header.Number) {
+		if err := a.verifyCertificate(chain, header, parents, parent); err != nil {
+			return err
+		}
+	}
 
 	// All basic checks passed, verify the seal and return
 	return a.verifySeal(chain, header, parents)
@@ -822,6 +838,7 @@ func (a *Alien) Finalize(chain consensus.ChainReader, header *types.Header, stat
 	if err != nil {
 		return nil, err
 	}
+	ledger := make(rewardLedger)
 	if !chain.Config().Alien.SideChain {
 		// calculate votes write into header.extra
 		mcCurrentHeaderExtra, refundGas, err := a.processCustomTx(currentHeaderExtra, chain, header, state, txs, receipts)
@@ -829,7 +846,14 @@ func (a *Alien) Finalize(chain consensus.ChainReader, header *types.Header, stat
 			return nil, err
 		}
 		currentHeaderExtra = mcCurrentHeaderExtra
-		currentHeaderExtra.ConfirmedBlockNumber = snap.getLastConfirmedBlockNumber(currentHeaderExtra.CurrentBlockConfirmations).Uint64()
+		if a.config.IsConfirm(header.Number) {
+			currentHeaderExtra.ConfirmationCertificate = a.certificate(chain, header, currentHeaderExtra.ConfirmedBlockNumber)
+			if currentHeaderExtra.ConfirmationCertificate.Number > 0 {
+				currentHeaderExtra.ConfirmedBlockNumber = currentHeaderExtra.ConfirmationCertificate.Number
+			}
+		} else {
+			currentHeaderExtra.ConfirmedBlockNumber = snap.getLastConfirmedBlockNumber(currentHeaderExtra.CurrentBlockConfirmations).Uint64()
+		}
 		// write signerQueue in first header, from self vote signers in genesis block
 		if number == 1 {
 			currentHeaderExtra.LoopStartTime = a.config.GenesisTimestamp
@@ -851,7 +875,7 @@ func (a *Alien) Finalize(chain consensus.ChainReader, header *types.Header, stat
 		}
 
 		// Accumulate any block rewards and commit the final state root
-		if err := accumulateRewards(chain.Config(), state, header, snap, refundGas); err != nil {
+		if err := accumulateRewards(chain.Config(), state, header, snap, refundGas, ledger); err != nil {
 			return nil, errUnauthorized
 		}
 	} else {
@@ -860,7 +884,7 @@ func (a *Alien) Finalize(chain consensus.ChainReader, header *types.Header, stat
 		if len(currentHeaderExtra.SignerQueue) > int(a.config.MaxSignerCount) {
 			currentHeaderExtra.SignerQueue = currentHeaderExtra.SignerQueue[:int(a.config.MaxSignerCount)]
 		}
-		sideChainRewards(chain.Config(), state, header, snap)
+		sideChainRewards(chain.Config(), state, header, snap, ledger)
 	}
 	// encode header.extra
 	currentHeaderExtraEnc, err := encodeHeaderExtra(a.config, header.Number, currentHeaderExtra)
@@ -875,11 +899,38 @@ func (a *Alien) Finalize(chain consensus.ChainReader, header *types.Header, stat
 	header.Difficulty = new(big.Int).Set(defaultDifficulty)
 
 	header.Root = state.IntermediateRoot(chain.Config().IsEIP158(header.Number))
+
 	// No uncle block
 	header.UncleHash = types.CalcUncleHash(nil)
 
 	// Assemble and return the final block for sealing
-	return types.NewBlock(header, txs, nil, receipts), nil
+	block := types.NewBlock(header, txs, nil, receipts)
+	a.rewards.Add(ledgerKey(block.Header()), ledger)
+
+	return block, nil
+}
+
+// ledgerKey returns the key of the reward ledger of a block until it is written.
+// It is the hash of the header apart from the extra-data, which is only complete
+// once the block is sealed, and on side chains may still change while sealing.
+func ledgerKey(header *types.Header) common.Hash {
+	header = types.CopyHeader(header)
+	header.Extra = nil
+	return header.Hash()
+}
+
+// WriteBlock implements consensus.BlockWriter, storing the reward ledger of a
+// block written into the chain. The ledgers of the blocks finalized but never
+// written, like the abandoned work of the miner, are not stored.
+func (a *Alien) WriteBlock(db ethdb.Putter, block *types.Block) {
+	key := ledgerKey(block.Header())
+	ledger, ok := a.rewards.Get(key)
+	if !ok {
+		log.Warn("Reward ledger of written block missing", "number", block.Number(), "hash", block.Hash())
+		return
+	}
+	rawdb.WriteRewards(db, block.NumberU64(), block.Hash(), ledger.(rewardLedger))
+	a.rewards.Remove(key)
 }
 
 // Authorize injects a private key into the consensus engine to mint new blocks with.
@@ -1026,7 +1077,7 @@ func (a *Alien) APIs(chain consensus.ChainReader) []rpc.API {
 	}}
 }
 
-func sideChainRewards(config *params.ChainConfig, state *state.StateDB, header *types.Header, snap *Snapshot) {
+func sideChainRewards(config *params.ChainConfig, state *state.StateDB, header *types.Header, snap *Snapshot, ledger rewardLedger) {
 	// vanish gas fee
 	gasUsed := new(big.Int).SetUint64(header.GasUsed)
 	if state.GetBalance(header.Coinbase).Cmp(gasUsed) >= 0 {
@@ -1034,12 +1085,12 @@ func sideChainRewards(config *params.ChainConfig, state *state.StateDB, header *
 	}
 	// gas charging
 	for target, volume := range snap.calculateGasCharging() {
-		state.AddBalance(target, volume)
+		ledger.credit(state, target, volume, RewardCategoryGasCharging, "gas charged on main chain")
 	}
 }
 
 // AccumulateRewards credits the coinbase of the given block with the mining reward.
-func accumulateRewards(config *params.ChainConfig, state *state.StateDB, header *types.Header, snap *Snapshot, refundGas RefundGas) error {
+func accumulateRewards(config *params.ChainConfig, state *state.StateDB, header *types.Header, snap *Snapshot, refundGas RefundGas, ledger rewardLedger) error {
 	// Calculate the block reword by year
 	blockNumPerYear := secondsPerYear / config.Alien.Period
 	initSignerBlockReward := new(big.Int).Div(totalBlockReward, big.NewInt(int64(2*blockNumPerYear)))
@@ -1058,29 +1109,34 @@ func accumulateRewards(config *params.ChainConfig, state *state.StateDB, header
 		return err
 	}
 	for voter, reward := range voteRewardMap {
-		state.AddBalance(voter, reward)
+		ledger.credit(state, voter, reward, RewardCategoryVoter, "vote for signer "+header.Coinbase.Hex())
 	}
 
 	// calculate for proposal refund
 	for proposer, refund := range snap.calculateProposalRefund() {
-		state.AddBalance(proposer, refund)
+		ledger.credit(state, proposer, refund, RewardCategoryProposalRefund, "proposal deposit refund")
+	}
+
+	// release the unbonded stake
+	for staker, stake := range snap.calculateUnbondingRelease(header.Number.Uint64()) {
+		ledger.credit(state, staker, stake, RewardCategoryUnbonding, "unbonded stake release")
 	}
 
 	scReward, minerLeft := snap.calculateSCReward(minerReward)
 	minerReward.Set(minerLeft)
 	// rewards for the side chain coinbase
 	for scCoinbase, reward := range scReward {
-		state.AddBalance(scCoinbase, reward)
+		ledger.credit(state, scCoinbase, reward, RewardCategorySideChain, "side chain coinbase reward")
 	}
 	// refund gas for custom txs
 	for sender, gas := range refundGas {
-		state.AddBalance(sender, gas)
+		ledger.credit(state, sender, gas, RewardCategoryGasRefund, "custom transaction gas refund")
 		minerReward.Sub(minerReward, gas)
 	}
 
 	// rewards for the miner, check minerReward value for refund gas
 	if minerReward.Cmp(big.NewInt(0)) > 0 {
-		state.AddBalance(header.Coinbase, minerReward)
+		ledger.credit(state, header.Coinbase, minerReward, RewardCategoryMiner, "block reward")
 	}
 
 	return nil
@@ -19,11 +19,15 @@
 package alien
 
 import (
+	"bytes"
+	"math/big"
+	"sort"
+
 	"github.com/awesome-chain/Xchain/common"
 	"github.com/awesome-chain/Xchain/consensus"
+	"github.com/awesome-chain/Xchain/core/rawdb"
 	"github.com/awesome-chain/Xchain/core/types"
 	"github.com/awesome-chain/Xchain/rpc"
-	"math/big"
 )
 
 // API is a user facing RPC API to allow controlling the signer and voting
@@ -159,3 +163,363 @@ func (api *API) GetSnapshotByHeaderTime(targetTime uint64, scHash common.Hash) (
 	}
 	return nil, errUnknownBlock
 }
+
+const (
+	defaultPageSize = 100  // Number of items returned by the list endpoints if no limit is given
+	maxPageSize     = 1000 // Maximum number of items returned by the list endpoints
+)
+
+// headerAt retrieves the header of a given block, the current one if none is
+// requested.
+func (api *API) headerAt(number *rpc.BlockNumber) (*types.Header, error) {
+	var header *types.Header
+	if number == nil || *number == rpc.LatestBlockNumber || *number == rpc.PendingBlockNumber {
+		header = api.chain.CurrentHeader()
+	} else {
+		header = api.chain.GetHeaderByNumber(uint64(number.Int64()))
+	}
+	if header == nil {
+		return nil, errUnknownBlock
+	}
+	return header, nil
+}
+
+// snapshotAt retrieves the state snapshot at a given block, the current one if
+// none is requested.
+func (api *API) snapshotAt(number *rpc.BlockNumber) (*Snapshot, error) {
+	header, err := api.headerAt(number)
+	if err != nil {
+		return nil, err
+	}
+	return api.alien.snapshot(api.chain, header.Number.Uint64(), header.Hash(), nil, nil, defaultLoopCntRecalculateSigners)
+}
+
+// Page is the pagination of a list retrieved from a snapshot.
+type Page struct {
+	Number uint64 `json:"number"` // Block number of the snapshot
+	Offset uint64 `json:"offset"` // Index of the first item returned
+	Total  uint64 `json:"total"`  // Number of items in the whole list
+}
+
+// paginate returns the bounds of the requested page of a list.
+func paginate(total int, offset *uint64, limit *uint64) (int, int) {
+	start, size := uint64(0), uint64(defaultPageSize)
+	if offset != nil {
+		start = *offset
+	}
+	if limit != nil && *limit > 0 {
+		size = *limit
+	}
+	if size > maxPageSize {
+		size = maxPageSize
+	}
+	if start > uint64(total) {
+		start = uint64(total)
+	}
+	end := start + size
+	if end > uint64(total) {
+		end = uint64(total)
+	}
+	return int(start), int(end)
+}
+
+// CandidateInfo is a candidate for the signer queue.
+type CandidateInfo struct {
+	Address  common.Address `json:"address"`
+	State    uint64         `json:"state"`    // 0- adding procedure 1- normal 2- removing procedure
+	Tally    *big.Int       `json:"tally"`    // Stake voted for the candidate
+	Punished uint64         `json:"punished"` // Punished count cause of missing seal
+	Locked   *big.Int       `json:"locked"`   // Stake locked by the candidate
+}
+
+// CandidatesPage is a page of candidates, by decreasing tally.
+type CandidatesPage struct {
+	Page
+	Candidates []CandidateInfo `json:"candidates"`
+}
+
+// newCandidatesPage lists the candidates of a snapshot.
+func newCandidatesPage(snap *Snapshot, offset *uint64, limit *uint64) *CandidatesPage {
+	var candidates TallySlice
+	for address := range snap.Candidates {
+		stake := big.NewInt(0)
+		if tally, ok := snap.Tally[address]; ok {
+			stake.Set(tally)
+		}
+		candidates = append(candidates, TallyItem{address, stake})
+	}
+	sort.Sort(candidates)
+
+	start, end := paginate(len(candidates), offset, limit)
+	page := &CandidatesPage{
+		Page:       Page{Number: snap.Number, Offset: uint64(start), Total: uint64(len(candidates))},
+		Candidates: make([]CandidateInfo, 0, end-start),
+	}
+	for _, item := range candidates[start:end] {
+		locked := big.NewInt(0)
+		if stake, ok := snap.Locked[item.addr]; ok {
+			locked.Set(stake)
+		}
+		page.Candidates = append(page.Candidates, CandidateInfo{
+			Address:  item.addr,
+			State:    snap.Candidates[item.addr],
+			Tally:    item.stake,
+			Punished: snap.Punished[item.addr],
+			Locked:   locked,
+		})
+	}
+	return page
+}
+
+// TallyInfo is the stake voted for a candidate.
+type TallyInfo struct {
+	Address common.Address `json:"address"`
+	Stake   *big.Int       `json:"stake"`
+}
+
+// TallyPage is a page of the tally, by decreasing stake.
+type TallyPage struct {
+	Page
+	Tally []TallyInfo `json:"tally"`
+}
+
+// newTallyPage lists the tally of a snapshot.
+func newTallyPage(snap *Snapshot, offset *uint64, limit *uint64) *TallyPage {
+	var tally TallySlice
+	for address, stake := range snap.Tally {
+		tally = append(tally, TallyItem{address, new(big.Int).Set(stake)})
+	}
+	sort.Sort(tally)
+
+	start, end := paginate(len(tally), offset, limit)
+	page := &TallyPage{
+		Page:  Page{Number: snap.Number, Offset: uint64(start), Total: uint64(len(tally))},
+		Tally: make([]TallyInfo, 0, end-start),
+	}
+	for _, item := range tally[start:end] {
+		page.Tally = append(page.Tally, TallyInfo{Address: item.addr, Stake: item.stake})
+	}
+	return page
+}
+
+// PunishedInfo is the punished count of a signer.
+type PunishedInfo struct {
+	Address  common.Address `json:"address"`
+	Punished uint64         `json:"punished"`
+}
+
+// PunishedPage is a page of the punished signers, by decreasing count.
+type PunishedPage struct {
+	Page
+	Punished []PunishedInfo `json:"punished"`
+}
+
+// newPunishedPage lists the punished signers of a snapshot.
+func newPunishedPage(snap *Snapshot, offset *uint64, limit *uint64) *PunishedPage {
+	var punished TallySlice
+	for address, count := range snap.Punished {
+		punished = append(punished, TallyItem{address, new(big.Int).SetUint64(count)})
+	}
+	sort.Sort(punished)
+
+	start, end := paginate(len(punished), offset, limit)
+	page := &PunishedPage{
+		Page:     Page{Number: snap.Number, Offset: uint64(start), Total: uint64(len(punished))},
+		Punished: make([]PunishedInfo, 0, end-start),
+	}
+	for _, item := range punished[start:end] {
+		page.Punished = append(page.Punished, PunishedInfo{Address: item.addr, Punished: item.stake.Uint64()})
+	}
+	return page
+}
+
+// SideChainInfo is the record of a side chain.
+type SideChainInfo struct {
+	Hash                common.Hash `json:"hash"`                // Genesis parent hash of the side chain
+	LastConfirmedNumber uint64      `json:"lastConfirmedNumber"` // Last confirmed header number of the side chain
+	MaxHeaderNumber     uint64      `json:"maxHeaderNumber"`     // Max header number of the side chain
+	CountPerPeriod      uint64      `json:"countPerPeriod"`      // Block sealed per period on the side chain
+	RewardPerPeriod     uint64      `json:"rewardPerPeriod"`     // Full reward per period, number per thousand
+}
+
+// SideChainsPage is a page of side chains, by hash.
+type SideChainsPage struct {
+	Page
+	SideChains []SideChainInfo `json:"sideChains"`
+}
+
+// newSideChainsPage lists the side chains of a snapshot.
+func newSideChainsPage(snap *Snapshot, offset *uint64, limit *uint64) *SideChainsPage {
+	hashes := make([]common.Hash, 0, len(snap.SCRecordMap))
+	for hash := range snap.SCRecordMap {
+		hashes = append(hashes, hash)
+	}
+	sort.Slice(hashes, func(i, j int) bool { return bytes.Compare(hashes[i][:], hashes[j][:]) < 0 })
+
+	start, end := paginate(len(hashes), offset, limit)
+	page := &SideChainsPage{
+		Page:       Page{Number: snap.Number, Offset: uint64(start), Total: uint64(len(hashes))},
+		SideChains: make([]SideChainInfo, 0, end-start),
+	}
+	for _, hash := range hashes[start:end] {
+		record := snap.SCRecordMap[hash]
+		page.SideChains = append(page.SideChains, SideChainInfo{
+			Hash:                hash,
+			LastConfirmedNumber: record.LastConfirmedNumber,
+			MaxHeaderNumber:     record.MaxHeaderNumber,
+			CountPerPeriod:      record.CountPerPeriod,
+			RewardPerPeriod:     record.RewardPerPeriod,
+		})
+	}
+	return page
+}
+
+// VoteInfo is the vote of a voter.
+type VoteInfo struct {
+	Voter     common.Address `json:"voter"`
+	Candidate common.Address `json:"candidate"`
+	Stake     *big.Int       `json:"stake"`
+	Number    uint64         `json:"number"` // Block number the vote was cast at
+}
+
+// SignerQueue is the signer queue of a loop.
+type SignerQueue struct {
+	Number        uint64           `json:"number"`        // Block number the queue is taken from
+	LoopStartTime uint64           `json:"loopStartTime"` // Start time of the loop
+	Signers       []common.Address `json:"signers"`
+}
+
+// GetCandidates retrieves a page of the candidates at a given block, by
+// decreasing tally.
+func (api *API) GetCandidates(number *rpc.BlockNumber, offset *uint64, limit *uint64) (*CandidatesPage, error) {
+	snap, err := api.snapshotAt(number)
+	if err != nil {
+		return nil, err
+	}
+	return newCandidatesPage(snap, offset, limit), nil
+}
+
+// GetTally retrieves a page of the stake voted for each candidate at a given
+// block, by decreasing stake.
+func (api *API) GetTally(number *rpc.BlockNumber, offset *uint64, limit *uint64) (*TallyPage, error) {
+	snap, err := api.snapshotAt(number)
+	if err != nil {
+		return nil, err
+	}
+	return newTallyPage(snap, offset, limit), nil
+}
+
+// GetPunished retrieves a page of the punished signers at a given block, by
+// decreasing punished count.
+func (api *API) GetPunished(number *rpc.BlockNumber, offset *uint64, limit *uint64) (*PunishedPage, error) {
+	snap, err := api.snapshotAt(number)
+	if err != nil {
+		return nil, err
+	}
+	return newPunishedPage(snap, offset, limit), nil
+}
+
+// GetSideChains retrieves a page of the side chains at a given block.
+func (api *API) GetSideChains(number *rpc.BlockNumber, offset *uint64, limit *uint64) (*SideChainsPage, error) {
+	snap, err := api.snapshotAt(number)
+	if err != nil {
+		return nil, err
+	}
+	return newSideChainsPage(snap, offset, limit), nil
+}
+
+// GetVotesByVoter retrieves the vote of a voter at a given block, nil if it
+// has none.
+func (api *API) GetVotesByVoter(voter common.Address, number *rpc.BlockNumber) (*VoteInfo, error) {
+	snap, err := api.snapshotAt(number)
+	if err != nil {
+		return nil, err
+	}
+	vote, ok := snap.Votes[voter]
+	if !ok {
+		return nil, nil
+	}
+	info := &VoteInfo{Voter: vote.Voter, Candidate: vote.Candidate, Stake: new(big.Int).Set(vote.Stake)}
+	if voted, ok := snap.Voters[voter]; ok {
+		info.Number = voted.Uint64()
+	}
+	return info, nil
+}
+
+// GetProposal retrieves a proposal going or passed at a given block, nil if
+// there is none with this hash.
+func (api *API) GetProposal(hash common.Hash, number *rpc.BlockNumber) (*Proposal, error) {
+	snap, err := api.snapshotAt(number)
+	if err != nil {
+		return nil, err
+	}
+	if proposal, ok := snap.Proposals[hash]; ok {
+		return proposal.copy(), nil
+	}
+	return nil, nil
+}
+
+// GetSignerQueue retrieves the signer queue of the loop loopOffset loops before
+// the one of a given block.
+func (api *API) GetSignerQueue(loopOffset uint64, number *rpc.BlockNumber) (*SignerQueue, error) {
+	header, err := api.headerAt(number)
+	if err != nil {
+		return nil, err
+	}
+	if back := loopOffset * api.alien.config.MaxSignerCount; back > 0 {
+		if back > header.Number.Uint64() {
+			return nil, errUnknownBlock
+		}
+		if header = api.chain.GetHeaderByNumber(header.Number.Uint64() - back); header == nil {
+			return nil, errUnknownBlock
+		}
+	}
+	if extraVanity+extraSeal > len(header.Extra) {
+		return nil, errMissingSignature
+	}
+	headerExtra := HeaderExtra{}
+	if err := decodeHeaderExtra(api.alien.config, header.Number, header.Extra[extraVanity:len(header.Extra)-extraSeal], &headerExtra); err != nil {
+		return nil, err
+	}
+	return &SignerQueue{
+		Number:        header.Number.Uint64(),
+		LoopStartTime: headerExtra.LoopStartTime,
+		Signers:       headerExtra.SignerQueue,
+	}, nil
+}
+
+// GetRewards retrieves the rewards credited to an address by the blocks from
+// fromBlock to toBlock, the current one if none is requested.
+func (api *API) GetRewards(address common.Address, fromBlock rpc.BlockNumber, toBlock *rpc.BlockNumber) ([]RewardInfo, error) {
+	last, err := api.headerAt(toBlock)
+	if err != nil {
+		return nil, err
+	}
+	from, to := uint64(fromBlock.Int64()), last.Number.Uint64()
+	if fromBlock == rpc.LatestBlockNumber || fromBlock == rpc.PendingBlockNumber {
+		from = api.chain.CurrentHeader().Number.Uint64()
+	}
+	if from > to {
+		return nil, errInvalidRewardRange
+	}
+	if to-from >= maxRewardQueryRange {
+		return nil, errRewardRangeTooLarge
+	}
+	rewards := []RewardInfo{}
+	for number := from; number <= to; number++ {
+		header := api.chain.GetHeaderByNumber(number)
+		if header == nil {
+			return nil, errUnknownBlock
+		}
+		for _, entry := range rawdb.ReadRewards(api.alien.db, address, number, header.Hash()) {
+			rewards = append(rewards, RewardInfo{
+				Number:   number,
+				Hash:     header.Hash(),
+				Category: entry.Category,
+				Amount:   entry.Amount,
+				Reason:   entry.Reason,
+			})
+		}
+	}
+	return rewards, nil
+}
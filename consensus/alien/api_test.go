@@ -0,0 +1,75 @@
+// Copyright 2018 The gttc Authors
+// This file is part of the gttc library.
+//
+// The gttc library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The gttc library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the gttc library. If not, see <http://www.gnu.org/licenses/>.
+
+package alien
+
+import (
+	"math/big"
+	"testing"
+
+	"github.com/awesome-chain/Xchain/common"
+	"github.com/awesome-chain/Xchain/params"
+)
+
+// Tests that the list endpoints are paginated and ordered.
+func TestAPIPagination(t *testing.T) {
+	tests := []struct {
+		total         int
+		offset, limit *uint64
+		start, end    int
+	}{
+		{10, nil, nil, 0, 10},
+		{250, nil, nil, 0, defaultPageSize},
+		{10, uint64p(3), uint64p(4), 3, 7},
+		{10, uint64p(8), uint64p(4), 8, 10},
+		{10, uint64p(12), nil, 10, 10},
+		{5000, uint64p(10), uint64p(5000), 10, 10 + maxPageSize},
+	}
+	for i, tt := range tests {
+		if start, end := paginate(tt.total, tt.offset, tt.limit); start != tt.start || end != tt.end {
+			t.Errorf("test %d: page mismatch: have [%d, %d), want [%d, %d)", i, start, end, tt.start, tt.end)
+		}
+	}
+	config := *params.AllAlienProtocolChanges.Alien
+	var (
+		a, b, c = common.HexToAddress("0x0a"), common.HexToAddress("0x0b"), common.HexToAddress("0x0c")
+		snap    = newSnapshot(&config, nil, common.Hash{}, []*Vote{
+			{Voter: a, Candidate: a, Stake: big.NewInt(100)},
+			{Voter: b, Candidate: b, Stake: big.NewInt(300)},
+			{Voter: c, Candidate: c, Stake: big.NewInt(200)},
+		}, defaultLoopCntRecalculateSigners)
+	)
+	snap.Punished[a] = 5
+	snap.Punished[c] = 9
+
+	candidates := newCandidatesPage(snap, uint64p(1), uint64p(2))
+	if candidates.Total != 3 || len(candidates.Candidates) != 2 {
+		t.Fatalf("candidates page mismatch: have %d of %d, want 2 of 3", len(candidates.Candidates), candidates.Total)
+	}
+	if candidates.Candidates[0].Address != c || candidates.Candidates[1].Address != a || candidates.Candidates[1].Punished != 5 {
+		t.Errorf("candidates mismatch: %+v", candidates.Candidates)
+	}
+	tally := newTallyPage(snap, nil, nil)
+	if len(tally.Tally) != 3 || tally.Tally[0].Address != b || tally.Tally[0].Stake.Cmp(big.NewInt(300)) != 0 {
+		t.Errorf("tally mismatch: %+v", tally.Tally)
+	}
+	punished := newPunishedPage(snap, nil, nil)
+	if len(punished.Punished) != 2 || punished.Punished[0].Address != c || punished.Punished[0].Punished != 9 {
+		t.Errorf("punished mismatch: %+v", punished.Punished)
+	}
+}
+
+func uint64p(n uint64) *uint64 { return &n }
@@ -0,0 +1,320 @@
+// Copyright 2018 The gttc Authors
+// This file is part of the gttc library.
+//
+// The gttc library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The gttc library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the gttc library. If not, see <http://www.gnu.org/licenses/>.
+
+package alien
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/awesome-chain/Xchain/common"
+	"github.com/awesome-chain/Xchain/p2p"
+	"gopkg.in/fatih/set.v0"
+)
+
+var (
+	errClosed            = errors.New("peer set is closed")
+	errAlreadyRegistered = errors.New("peer is already registered")
+	errNotRegistered     = errors.New("peer is not registered")
+)
+
+const (
+	maxKnownConfirmations = 4096 // Maximum confirmation hashes to keep in the known list (prevent DOS)
+
+	// maxQueuedConfirmations is the maximum number of confirmations to queue up
+	// before dropping relays.
+	maxQueuedConfirmations = 128
+
+	// maxConfirmationRate and maxConfirmationBurst limit the number of
+	// confirmations accepted from a single peer per second, and in a single burst.
+	maxConfirmationRate  = 50
+	maxConfirmationBurst = 200
+
+	handshakeTimeout = 5 * time.Second
+)
+
+// ConfirmPeerInfo represents a short summary of the confirmation sub-protocol
+// metadata known about a connected peer.
+type ConfirmPeerInfo struct {
+	Version int `json:"version"` // Confirmation protocol version negotiated
+}
+
+// queuedConfirmation is a confirmation waiting for its turn in the relay queue.
+type queuedConfirmation struct {
+	hash         common.Hash
+	confirmation *SignedConfirmation
+}
+
+// rateLimiter is a token bucket limiting the rate at which confirmations of a
+// peer are accepted.
+type rateLimiter struct {
+	tokens float64
+	last   time.Time
+}
+
+// allow takes a token from the bucket, returning false if it is empty.
+func (l *rateLimiter) allow(now time.Time) bool {
+	if l.last.IsZero() {
+		l.tokens = maxConfirmationBurst
+	} else {
+		l.tokens += now.Sub(l.last).Seconds() * maxConfirmationRate
+		if l.tokens > maxConfirmationBurst {
+			l.tokens = maxConfirmationBurst
+		}
+	}
+	l.last = now
+	if l.tokens < 1 {
+		return false
+	}
+	l.tokens--
+	return true
+}
+
+type confirmPeer struct {
+	id string
+
+	*p2p.Peer
+	rw p2p.MsgReadWriter
+
+	version int // Protocol version negotiated
+
+	limiter rateLimiter // Rate limiter of the inbound confirmations, only used by the read loop
+
+	known  *set.Set                // Set of confirmation hashes known to be known by this peer
+	queued chan queuedConfirmation // Queue of confirmations to relay to the peer
+	term   chan struct{}           // Termination channel to stop the broadcaster
+}
+
+func newConfirmPeer(version int, p *p2p.Peer, rw p2p.MsgReadWriter) *confirmPeer {
+	return &confirmPeer{
+		Peer:    p,
+		rw:      rw,
+		version: version,
+		id:      fmt.Sprintf("%x", p.ID().Bytes()[:8]),
+		known:   set.New(),
+		queued:  make(chan queuedConfirmation, maxQueuedConfirmations),
+		term:    make(chan struct{}),
+	}
+}
+
+// broadcast is a write loop that relays the queued confirmations to the remote
+// peer.
+func (p *confirmPeer) broadcast() {
+	for {
+		select {
+		case q := <-p.queued:
+			if err := p2p.Send(p.rw, ConfirmationMsg, q.confirmation); err != nil {
+				return
+			}
+			p.Log().Trace("Relayed confirmation", "number", q.confirmation.Number, "hash", q.confirmation.Hash)
+
+		case <-p.term:
+			return
+		}
+	}
+}
+
+// close signals the broadcast goroutine to terminate.
+func (p *confirmPeer) close() {
+	close(p.term)
+}
+
+// Info gathers and returns a collection of metadata known about a peer.
+func (p *confirmPeer) Info() *ConfirmPeerInfo {
+	return &ConfirmPeerInfo{Version: p.version}
+}
+
+// MarkConfirmation marks a confirmation as known for the peer, ensuring that it
+// will never be relayed to this particular peer.
+func (p *confirmPeer) MarkConfirmation(hash common.Hash) {
+	// If we reached the memory allowance, drop a previously known hash
+	for p.known.Size() >= maxKnownConfirmations {
+		p.known.Pop()
+	}
+	p.known.Add(hash)
+}
+
+// AsyncSendConfirmation queues a confirmation for relay to the remote peer. If
+// the peer's relay queue is full, the confirmation is silently dropped.
+func (p *confirmPeer) AsyncSendConfirmation(hash common.Hash, confirmation *SignedConfirmation) {
+	select {
+	case p.queued <- queuedConfirmation{hash: hash, confirmation: confirmation}:
+		p.MarkConfirmation(hash)
+	default:
+		p.Log().Debug("Dropping confirmation relay", "hash", hash)
+	}
+}
+
+// Handshake executes the confirmation protocol handshake, negotiating version
+// number, network IDs and genesis blocks.
+func (p *confirmPeer) Handshake(network uint64, genesis common.Hash) error {
+	// Send out own handshake in a new thread
+	errc := make(chan error, 2)
+	var status statusData // safe to read after two values have been received from errc
+
+	go func() {
+		errc <- p2p.Send(p.rw, ConfirmStatusMsg, &statusData{
+			ProtocolVersion: uint32(p.version),
+			NetworkId:       network,
+			GenesisBlock:    genesis,
+		})
+	}()
+	go func() {
+		errc <- p.readStatus(network, &status, genesis)
+	}()
+	timeout := time.NewTimer(handshakeTimeout)
+	defer timeout.Stop()
+	for i := 0; i < 2; i++ {
+		select {
+		case err := <-errc:
+			if err != nil {
+				return err
+			}
+		case <-timeout.C:
+			return p2p.DiscReadTimeout
+		}
+	}
+	return nil
+}
+
+func (p *confirmPeer) readStatus(network uint64, status *statusData, genesis common.Hash) (err error) {
+	msg, err := p.rw.ReadMsg()
+	if err != nil {
+		return err
+	}
+	if msg.Code != ConfirmStatusMsg {
+		return errResp(ErrNoStatusMsg, "first msg has code %x (!= %x)", msg.Code, ConfirmStatusMsg)
+	}
+	if msg.Size > confirmProtocolMaxMsgSize {
+		return errResp(ErrMsgTooLarge, "%v > %v", msg.Size, confirmProtocolMaxMsgSize)
+	}
+	// Decode the handshake and make sure everything matches
+	if err := msg.Decode(&status); err != nil {
+		return errResp(ErrDecode, "msg %v: %v", msg, err)
+	}
+	if status.GenesisBlock != genesis {
+		return errResp(ErrGenesisBlockMismatch, "%x (!= %x)", status.GenesisBlock[:8], genesis[:8])
+	}
+	if status.NetworkId != network {
+		return errResp(ErrNetworkIdMismatch, "%d (!= %d)", status.NetworkId, network)
+	}
+	if int(status.ProtocolVersion) != p.version {
+		return errResp(ErrProtocolVersionMismatch, "%d (!= %d)", status.ProtocolVersion, p.version)
+	}
+	return nil
+}
+
+// String implements fmt.Stringer.
+func (p *confirmPeer) String() string {
+	return fmt.Sprintf("Peer %s [%s]", p.id,
+		fmt.Sprintf("%s/%d", ConfirmProtocolName, p.version),
+	)
+}
+
+// confirmPeerSet represents the collection of active peers currently
+// participating in the confirmation sub-protocol.
+type confirmPeerSet struct {
+	peers  map[string]*confirmPeer
+	lock   sync.RWMutex
+	closed bool
+}
+
+// newConfirmPeerSet creates a new peer set to track the active participants.
+func newConfirmPeerSet() *confirmPeerSet {
+	return &confirmPeerSet{
+		peers: make(map[string]*confirmPeer),
+	}
+}
+
+// Register injects a new peer into the working set, or returns an error if the
+// peer is already known. If a new peer it registered, its broadcast loop is also
+// started.
+func (ps *confirmPeerSet) Register(p *confirmPeer) error {
+	ps.lock.Lock()
+	defer ps.lock.Unlock()
+
+	if ps.closed {
+		return errClosed
+	}
+	if _, ok := ps.peers[p.id]; ok {
+		return errAlreadyRegistered
+	}
+	ps.peers[p.id] = p
+	go p.broadcast()
+
+	return nil
+}
+
+// Unregister removes a remote peer from the active set, disabling any further
+// actions to/from that particular entity.
+func (ps *confirmPeerSet) Unregister(id string) error {
+	ps.lock.Lock()
+	defer ps.lock.Unlock()
+
+	p, ok := ps.peers[id]
+	if !ok {
+		return errNotRegistered
+	}
+	delete(ps.peers, id)
+	p.close()
+
+	return nil
+}
+
+// Peer retrieves the registered peer with the given id.
+func (ps *confirmPeerSet) Peer(id string) *confirmPeer {
+	ps.lock.RLock()
+	defer ps.lock.RUnlock()
+
+	return ps.peers[id]
+}
+
+// Len returns if the current number of peers in the set.
+func (ps *confirmPeerSet) Len() int {
+	ps.lock.RLock()
+	defer ps.lock.RUnlock()
+
+	return len(ps.peers)
+}
+
+// PeersWithoutConfirmation retrieves a list of peers that do not have a given
+// confirmation in their set of known hashes.
+func (ps *confirmPeerSet) PeersWithoutConfirmation(hash common.Hash) []*confirmPeer {
+	ps.lock.RLock()
+	defer ps.lock.RUnlock()
+
+	list := make([]*confirmPeer, 0, len(ps.peers))
+	for _, p := range ps.peers {
+		if !p.known.Has(hash) {
+			list = append(list, p)
+		}
+	}
+	return list
+}
+
+// Close disconnects all peers.
+// No new peers can be registered after Close has returned.
+func (ps *confirmPeerSet) Close() {
+	ps.lock.Lock()
+	defer ps.lock.Unlock()
+
+	for _, p := range ps.peers {
+		p.Disconnect(p2p.DiscQuitting)
+	}
+	ps.closed = true
+}
@@ -0,0 +1,281 @@
+// Copyright 2018 The gttc Authors
+// This file is part of the gttc library.
+//
+// The gttc library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The gttc library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the gttc library. If not, see <http://www.gnu.org/licenses/>.
+
+package alien
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/awesome-chain/Xchain/common"
+	"github.com/awesome-chain/Xchain/consensus"
+	"github.com/awesome-chain/Xchain/event"
+	"github.com/awesome-chain/Xchain/log"
+	"github.com/awesome-chain/Xchain/p2p"
+	"github.com/awesome-chain/Xchain/p2p/discover"
+	"github.com/hashicorp/golang-lru"
+)
+
+// Constants to match up protocol versions and messages
+const (
+	ufoc1 = 1
+)
+
+// ConfirmProtocolName is the official short name of the confirmation protocol
+// used during capability negotiation.
+var ConfirmProtocolName = "ufoc"
+
+// ConfirmProtocolVersions are the supported versions of the confirmation
+// protocol (first is primary).
+var ConfirmProtocolVersions = []uint{ufoc1}
+
+// ConfirmProtocolLengths are the number of implemented message corresponding
+// to different protocol versions.
+var ConfirmProtocolLengths = []uint64{2}
+
+const confirmProtocolMaxMsgSize = 1024 // Maximum cap on the size of a protocol message
+
+// maxSeenConfirmations is the number of confirmation hashes remembered to
+// de-duplicate the gossip.
+const maxSeenConfirmations = 4096
+
+// confirmation protocol message codes
+const (
+	ConfirmStatusMsg = 0x00
+	ConfirmationMsg  = 0x01
+)
+
+type errCode int
+
+const (
+	ErrMsgTooLarge = iota
+	ErrDecode
+	ErrInvalidMsgCode
+	ErrProtocolVersionMismatch
+	ErrNetworkIdMismatch
+	ErrGenesisBlockMismatch
+	ErrNoStatusMsg
+	ErrExtraStatusMsg
+)
+
+func (e errCode) String() string {
+	return errorToString[int(e)]
+}
+
+var errorToString = map[int]string{
+	ErrMsgTooLarge:             "Message too long",
+	ErrDecode:                  "Invalid message",
+	ErrInvalidMsgCode:          "Invalid message code",
+	ErrProtocolVersionMismatch: "Protocol version mismatch",
+	ErrNetworkIdMismatch:       "NetworkId mismatch",
+	ErrGenesisBlockMismatch:    "Genesis block mismatch",
+	ErrNoStatusMsg:             "No status message",
+	ErrExtraStatusMsg:          "Extra status message",
+}
+
+func errResp(code errCode, format string, v ...interface{}) error {
+	return fmt.Errorf("%v - %v", code, fmt.Sprintf(format, v...))
+}
+
+// statusData is the network packet for the status message.
+type statusData struct {
+	ProtocolVersion uint32
+	NetworkId       uint64
+	GenesisBlock    common.Hash
+}
+
+// ConfirmationService relays the confirmations of the recent blocks between the
+// signers. The confirmations signed by the local signer are sent to all peers,
+// the ones received are relayed only once verified and new to the pool.
+type ConfirmationService struct {
+	alien     *Alien
+	chain     consensus.ChainReader
+	networkId uint64
+	genesis   common.Hash
+
+	peers *confirmPeerSet
+	seen  *lru.Cache // Hashes of the confirmations already received or relayed
+
+	SubProtocols []p2p.Protocol
+
+	confirmCh  chan ConfirmationEvent
+	confirmSub event.Subscription
+
+	quit chan struct{}
+	wg   sync.WaitGroup
+}
+
+// NewConfirmationService creates the confirmation sub-protocols of an alien
+// engine, verifying the confirmations against the chain.
+func NewConfirmationService(alien *Alien, chain consensus.ChainReader, networkId uint64) *ConfirmationService {
+	seen, _ := lru.New(maxSeenConfirmations)
+	s := &ConfirmationService{
+		alien:     alien,
+		chain:     chain,
+		networkId: networkId,
+		genesis:   chain.GetHeaderByNumber(0).Hash(),
+		peers:     newConfirmPeerSet(),
+		seen:      seen,
+		confirmCh: make(chan ConfirmationEvent, 16),
+		quit:      make(chan struct{}),
+	}
+	for i, version := range ConfirmProtocolVersions {
+		version := version // Closure for the run
+		s.SubProtocols = append(s.SubProtocols, p2p.Protocol{
+			Name:    ConfirmProtocolName,
+			Version: version,
+			Length:  ConfirmProtocolLengths[i],
+			Run: func(p *p2p.Peer, rw p2p.MsgReadWriter) error {
+				select {
+				case <-s.quit:
+					return p2p.DiscQuitting
+				default:
+				}
+				s.wg.Add(1)
+				defer s.wg.Done()
+				return s.handle(newConfirmPeer(int(version), p, rw))
+			},
+			PeerInfo: func(id discover.NodeID) interface{} {
+				if p := s.peers.Peer(fmt.Sprintf("%x", id[:8])); p != nil {
+					return p.Info()
+				}
+				return nil
+			},
+		})
+	}
+	return s
+}
+
+// Protocols returns the confirmation sub-protocols to run.
+func (s *ConfirmationService) Protocols() []p2p.Protocol {
+	return s.SubProtocols
+}
+
+// Start starts relaying the confirmations signed by the local signer.
+func (s *ConfirmationService) Start() {
+	s.confirmSub = s.alien.SubscribeConfirmationEvent(s.confirmCh)
+
+	s.wg.Add(1)
+	go s.loop()
+}
+
+// Stop disconnects all the peers and waits for their handlers to terminate.
+func (s *ConfirmationService) Stop() {
+	s.confirmSub.Unsubscribe()
+	close(s.quit)
+	s.peers.Close()
+	s.wg.Wait()
+}
+
+// loop relays the confirmations signed by the local signer.
+func (s *ConfirmationService) loop() {
+	defer s.wg.Done()
+
+	for {
+		select {
+		case ev := <-s.confirmCh:
+			s.relay(ev.Confirmation)
+
+		case <-s.confirmSub.Err():
+			return
+		case <-s.quit:
+			return
+		}
+	}
+}
+
+// handle is the callback invoked to manage the life cycle of a confirmation
+// peer. When this function terminates, the peer is disconnected.
+func (s *ConfirmationService) handle(p *confirmPeer) error {
+	if err := p.Handshake(s.networkId, s.genesis); err != nil {
+		p.Log().Debug("Confirmation handshake failed", "err", err)
+		return err
+	}
+	if err := s.peers.Register(p); err != nil {
+		p.Log().Error("Confirmation peer registration failed", "err", err)
+		return err
+	}
+	defer s.peers.Unregister(p.id)
+
+	for {
+		if err := s.handleMsg(p); err != nil {
+			p.Log().Debug("Confirmation message handling failed", "err", err)
+			return err
+		}
+	}
+}
+
+// handleMsg is invoked whenever an inbound message is received from a remote
+// peer. The remote connection is torn down upon returning any error.
+func (s *ConfirmationService) handleMsg(p *confirmPeer) error {
+	// Read the next message from the remote peer, and ensure it's fully consumed
+	msg, err := p.rw.ReadMsg()
+	if err != nil {
+		return err
+	}
+	if msg.Size > confirmProtocolMaxMsgSize {
+		return errResp(ErrMsgTooLarge, "%v > %v", msg.Size, confirmProtocolMaxMsgSize)
+	}
+	defer msg.Discard()
+
+	switch msg.Code {
+	case ConfirmStatusMsg:
+		// Status messages should never arrive after the handshake
+		return errResp(ErrExtraStatusMsg, "uncontrolled status message")
+
+	case ConfirmationMsg:
+		var confirmation SignedConfirmation
+		if err := msg.Decode(&confirmation); err != nil {
+			return errResp(ErrDecode, "msg %v: %v", msg, err)
+		}
+		// Drop the confirmations of peers exceeding their allowance
+		if !p.limiter.allow(time.Now()) {
+			p.Log().Trace("Confirmation peer rate limited")
+			return nil
+		}
+		hash := confirmation.id()
+		p.MarkConfirmation(hash)
+		if s.seen.Contains(hash) {
+			return nil
+		}
+		// Confirmations of blocks not imported yet may arrive again later
+		added, err := s.alien.AddConfirmation(s.chain, &confirmation)
+		if err != nil {
+			p.Log().Trace("Dropped confirmation", "number", confirmation.Number, "hash", confirmation.Hash, "err", err)
+			return nil
+		}
+		if added {
+			s.relay(&confirmation)
+		}
+		return nil
+
+	default:
+		return errResp(ErrInvalidMsgCode, "%v", msg.Code)
+	}
+}
+
+// relay sends a verified confirmation to all the peers which do not know it yet.
+func (s *ConfirmationService) relay(confirmation *SignedConfirmation) {
+	hash := confirmation.id()
+	s.seen.Add(hash, struct{}{})
+
+	peers := s.peers.PeersWithoutConfirmation(hash)
+	for _, p := range peers {
+		p.AsyncSendConfirmation(hash, confirmation)
+	}
+	log.Trace("Relayed confirmation", "number", confirmation.Number, "hash", confirmation.Hash, "recipients", len(peers))
+}
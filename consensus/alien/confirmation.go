@@ -0,0 +1,358 @@
+// Copyright 2018 The gttc Authors
+// This file is part of the gttc library.
+//
+// The gttc library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The gttc library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the gttc library. If not, see <http://www.gnu.org/licenses/>.
+
+package alien
+
+import (
+	"bytes"
+	"errors"
+	"sort"
+	"sync"
+
+	"github.com/awesome-chain/Xchain/accounts"
+	"github.com/awesome-chain/Xchain/common"
+	"github.com/awesome-chain/Xchain/consensus"
+	"github.com/awesome-chain/Xchain/core/types"
+	"github.com/awesome-chain/Xchain/crypto"
+	"github.com/awesome-chain/Xchain/crypto/sha3"
+	"github.com/awesome-chain/Xchain/event"
+	"github.com/awesome-chain/Xchain/rlp"
+)
+
+// After the off-chain confirmation fork the signers no longer confirm blocks by
+// transaction. Every signer signs the hash of each new chain head and sends it
+// to the other signers on the confirmation sub-protocol. The signer of the next
+// block puts the confirmations of more than two thirds of the signers of a
+// recent block into a certificate in its header extra, which advances the
+// confirmed block number of the header.
+
+var (
+	// errInvalidCertificate is returned if a confirmation certificate doesn't
+	// certify the ancestor at its number, or holds a signer twice.
+	errInvalidCertificate = errors.New("invalid confirmation certificate")
+
+	// errInsufficientConfirmations is returned if a confirmation certificate
+	// holds no more than two thirds of the signers.
+	errInsufficientConfirmations = errors.New("insufficient confirmations")
+
+	// errInvalidConfirmedNumber is returned if the confirmed block number of a
+	// header is neither the one of its certificate nor the one of its parent.
+	errInvalidConfirmedNumber = errors.New("invalid confirmed block number")
+)
+
+// SignedConfirmation is the confirmation of a block by a signer in the signer
+// queue of the block, as sent on the confirmation sub-protocol.
+type SignedConfirmation struct {
+	Number    uint64
+	Hash      common.Hash
+	Signature []byte
+}
+
+// ConfirmationEvent is posted when the local signer confirmed a block.
+type ConfirmationEvent struct {
+	Confirmation *SignedConfirmation
+}
+
+// ConfirmationCertificate is the proof in a header that more than two thirds of
+// the signers confirmed a recent block. A zero number means no certificate.
+type ConfirmationCertificate struct {
+	Number     uint64
+	Hash       common.Hash
+	Signatures [][]byte
+}
+
+// confirmationSigHash returns the hash signed by the signers to confirm a block.
+func confirmationSigHash(number uint64, hash common.Hash) (h common.Hash) {
+	hasher := sha3.NewKeccak256()
+	rlp.Encode(hasher, []interface{}{number, hash})
+	hasher.Sum(h[:0])
+	return h
+}
+
+// id returns the hash identifying a confirmation in the gossip.
+func (c *SignedConfirmation) id() common.Hash {
+	return crypto.Keccak256Hash(c.Signature)
+}
+
+// minConfirmations returns the number of signers needed to confirm a block.
+func (a *Alien) minConfirmations() int {
+	return int(a.config.MaxSignerCount*2/3) + 1
+}
+
+// pooledConfirmations are the confirmations of a block, by signer.
+type pooledConfirmations struct {
+	number     uint64
+	signatures map[common.Address][]byte
+}
+
+// confirmationPool collects the verified confirmations of the recent blocks.
+type confirmationPool struct {
+	blocks map[common.Hash]*pooledConfirmations
+	lock   sync.RWMutex
+}
+
+func newConfirmationPool() *confirmationPool {
+	return &confirmationPool{blocks: make(map[common.Hash]*pooledConfirmations)}
+}
+
+// add stores the confirmation of a signer, returning false if it was known.
+func (p *confirmationPool) add(c *SignedConfirmation, signer common.Address) bool {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+
+	block, ok := p.blocks[c.Hash]
+	if !ok {
+		block = &pooledConfirmations{number: c.Number, signatures: make(map[common.Address][]byte)}
+		p.blocks[c.Hash] = block
+	}
+	if _, ok := block.signatures[signer]; ok {
+		return false
+	}
+	block.signatures[signer] = common.CopyBytes(c.Signature)
+	return true
+}
+
+// signatures returns the signatures confirming a block, ordered by signer.
+func (p *confirmationPool) signatures(hash common.Hash) [][]byte {
+	p.lock.RLock()
+	defer p.lock.RUnlock()
+
+	block, ok := p.blocks[hash]
+	if !ok {
+		return nil
+	}
+	signers := make([]common.Address, 0, len(block.signatures))
+	for signer := range block.signatures {
+		signers = append(signers, signer)
+	}
+	sort.Slice(signers, func(i, j int) bool { return bytes.Compare(signers[i][:], signers[j][:]) < 0 })
+
+	signatures := make([][]byte, len(signers))
+	for i, signer := range signers {
+		signatures[i] = block.signatures[signer]
+	}
+	return signatures
+}
+
+// prune drops the confirmations of the blocks below number.
+func (p *confirmationPool) prune(number uint64) {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+
+	for hash, block := range p.blocks {
+		if block.number < number {
+			delete(p.blocks, hash)
+		}
+	}
+}
+
+// recoverConfirmer extracts the address of the signer of a confirmation.
+func recoverConfirmer(number uint64, hash common.Hash, signature []byte) (common.Address, error) {
+	pubkey, err := crypto.Ecrecover(confirmationSigHash(number, hash).Bytes(), signature)
+	if err != nil {
+		return common.Address{}, err
+	}
+	var signer common.Address
+	copy(signer[:], crypto.Keccak256(pubkey[1:])[12:])
+	return signer, nil
+}
+
+// signerQueue returns the signer queue in the header extra of a header.
+func (a *Alien) signerQueue(header *types.Header) ([]common.Address, error) {
+	if extraVanity+extraSeal > len(header.Extra) {
+		return nil, errMissingSignature
+	}
+	headerExtra := HeaderExtra{}
+	if err := decodeHeaderExtra(a.config, header.Number, header.Extra[extraVanity:len(header.Extra)-extraSeal], &headerExtra); err != nil {
+		return nil, err
+	}
+	return headerExtra.SignerQueue, nil
+}
+
+// verifyConfirmation checks that a signature confirms the header and is signed
+// by a signer in its signer queue, returning the signer.
+func verifyConfirmation(header *types.Header, queue []common.Address, signature []byte) (common.Address, error) {
+	signer, err := recoverConfirmer(header.Number.Uint64(), header.Hash(), signature)
+	if err != nil {
+		return common.Address{}, err
+	}
+	for _, s := range queue {
+		if s == signer {
+			return signer, nil
+		}
+	}
+	return common.Address{}, errNotLoopSigner
+}
+
+// SubscribeConfirmationEvent registers a subscription of ConfirmationEvent.
+func (a *Alien) SubscribeConfirmationEvent(ch chan<- ConfirmationEvent) event.Subscription {
+	return a.confirmFeed.Subscribe(ch)
+}
+
+// Confirm signs the confirmation of a new chain head by the local signer, adds
+// it to the pool and posts it to the confirmation sub-protocol. Nothing is
+// signed if the local signer is not in the signer queue of the header.
+func (a *Alien) Confirm(chain consensus.ChainReader, header *types.Header) error {
+	a.lock.RLock()
+	signer, signFn := a.signer, a.signFn
+	a.lock.RUnlock()
+
+	if signFn == nil || header.Number.Sign() == 0 {
+		return nil
+	}
+	queue, err := a.signerQueue(header)
+	if err != nil {
+		return err
+	}
+	confirmer := false
+	for _, s := range queue {
+		if s == signer {
+			confirmer = true
+			break
+		}
+	}
+	if !confirmer {
+		return nil
+	}
+	number, hash := header.Number.Uint64(), header.Hash()
+	signature, err := signFn(accounts.Account{Address: signer}, confirmationSigHash(number, hash).Bytes())
+	if err != nil {
+		return err
+	}
+	confirmation := &SignedConfirmation{Number: number, Hash: hash, Signature: signature}
+	if a.addConfirmation(confirmation, signer, number) {
+		a.confirmFeed.Send(ConfirmationEvent{Confirmation: confirmation})
+	}
+	return nil
+}
+
+// AddConfirmation verifies the confirmation of a recent canonical block received
+// from the network and adds it to the pool, returning false if it was known.
+func (a *Alien) AddConfirmation(chain consensus.ChainReader, confirmation *SignedConfirmation) (bool, error) {
+	head := chain.CurrentHeader().Number.Uint64()
+	if confirmation.Number == 0 || confirmation.Number > head || head-confirmation.Number > a.config.MaxSignerCount {
+		return false, errConfirmOutOfRange
+	}
+	header := chain.GetHeaderByNumber(confirmation.Number)
+	if header == nil || header.Hash() != confirmation.Hash {
+		return false, errUnknownBlock
+	}
+	queue, err := a.signerQueue(header)
+	if err != nil {
+		return false, err
+	}
+	signer, err := verifyConfirmation(header, queue, confirmation.Signature)
+	if err != nil {
+		return false, err
+	}
+	return a.addConfirmation(confirmation, signer, head), nil
+}
+
+// addConfirmation adds a verified confirmation to the pool, dropping the ones
+// too old to be certified at the head number.
+func (a *Alien) addConfirmation(confirmation *SignedConfirmation, signer common.Address, head uint64) bool {
+	if head > a.config.MaxSignerCount {
+		a.confirmations.prune(head - a.config.MaxSignerCount)
+	}
+	return a.confirmations.add(confirmation, signer)
+}
+
+// certificate collects the pooled confirmations of the most recent ancestor of
+// the header confirmed by enough signers, if it is above the confirmed number.
+func (a *Alien) certificate(chain consensus.ChainReader, header *types.Header, confirmed uint64) ConfirmationCertificate {
+	number := header.Number.Uint64()
+	ancestor := chain.GetHeader(header.ParentHash, number-1)
+	for ancestor != nil && ancestor.Number.Uint64() > confirmed && number-ancestor.Number.Uint64() <= a.config.MaxSignerCount {
+		hash := ancestor.Hash()
+		if signatures := a.confirmations.signatures(hash); len(signatures) >= a.minConfirmations() {
+			return ConfirmationCertificate{
+				Number:     ancestor.Number.Uint64(),
+				Hash:       hash,
+				Signatures: signatures[:a.minConfirmations()],
+			}
+		}
+		ancestor = chain.GetHeader(ancestor.ParentHash, ancestor.Number.Uint64()-1)
+	}
+	return ConfirmationCertificate{}
+}
+
+// verifyCertificate checks the confirmation certificate in the header extra, and
+// that the confirmed block number of the header is the one of its certificate,
+// or the one of its parent without certificate.
+func (a *Alien) verifyCertificate(chain consensus.ChainReader, header *types.Header, parents []*types.Header, parent *types.Header) error {
+	headerExtra := HeaderExtra{}
+	if err := decodeHeaderExtra(a.config, header.Number, header.Extra[extraVanity:len(header.Extra)-extraSeal], &headerExtra); err != nil {
+		return err
+	}
+	var confirmed uint64
+	if parent.Number.Sign() > 0 {
+		parentExtra := HeaderExtra{}
+		if err := decodeHeaderExtra(a.config, parent.Number, parent.Extra[extraVanity:len(parent.Extra)-extraSeal], &parentExtra); err != nil {
+			return err
+		}
+		confirmed = parentExtra.ConfirmedBlockNumber
+	}
+	certificate := headerExtra.ConfirmationCertificate
+	if certificate.Number == 0 {
+		if certificate.Hash != (common.Hash{}) || len(certificate.Signatures) > 0 {
+			return errInvalidCertificate
+		}
+		if headerExtra.ConfirmedBlockNumber != confirmed {
+			return errInvalidConfirmedNumber
+		}
+		return nil
+	}
+	number := header.Number.Uint64()
+	if headerExtra.ConfirmedBlockNumber != certificate.Number {
+		return errInvalidConfirmedNumber
+	}
+	if certificate.Number <= confirmed || certificate.Number >= number || number-certificate.Number > a.config.MaxSignerCount {
+		return errConfirmOutOfRange
+	}
+	if len(certificate.Signatures) < a.minConfirmations() {
+		return errInsufficientConfirmations
+	}
+	// the certified block must be the ancestor at its number
+	ancestor := parent
+	for ancestor != nil && ancestor.Number.Uint64() > certificate.Number {
+		if len(parents) > 1 {
+			parents = parents[:len(parents)-1]
+			ancestor = parents[len(parents)-1]
+		} else {
+			parents = nil
+			ancestor = chain.GetHeader(ancestor.ParentHash, ancestor.Number.Uint64()-1)
+		}
+	}
+	if ancestor == nil || ancestor.Hash() != certificate.Hash {
+		return errInvalidCertificate
+	}
+	queue, err := a.signerQueue(ancestor)
+	if err != nil {
+		return err
+	}
+	signers := make(map[common.Address]struct{})
+	for _, signature := range certificate.Signatures {
+		signer, err := verifyConfirmation(ancestor, queue, signature)
+		if err != nil {
+			return err
+		}
+		if _, ok := signers[signer]; ok {
+			return errInvalidCertificate
+		}
+		signers[signer] = struct{}{}
+	}
+	return nil
+}
@@ -0,0 +1,274 @@
+// Copyright 2018 The gttc Authors
+// This file is part of the gttc library.
+//
+// The gttc library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The gttc library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the gttc library. If not, see <http://www.gnu.org/licenses/>.
+
+package alien
+
+import (
+	"crypto/rand"
+	"math/big"
+	"testing"
+	"time"
+
+	"github.com/awesome-chain/Xchain/accounts"
+	"github.com/awesome-chain/Xchain/common"
+	"github.com/awesome-chain/Xchain/core/types"
+	"github.com/awesome-chain/Xchain/crypto"
+	"github.com/awesome-chain/Xchain/p2p"
+	"github.com/awesome-chain/Xchain/p2p/discover"
+	"github.com/awesome-chain/Xchain/params"
+)
+
+// testerConfirmChain is a consensus.ChainReader over a single canonical chain.
+type testerConfirmChain struct {
+	headers []*types.Header
+}
+
+func (c *testerConfirmChain) Config() *params.ChainConfig               { return params.AllAlienProtocolChanges }
+func (c *testerConfirmChain) CurrentHeader() *types.Header              { return c.headers[len(c.headers)-1] }
+func (c *testerConfirmChain) GetBlock(common.Hash, uint64) *types.Block { panic("not supported") }
+func (c *testerConfirmChain) GetHeaderByHash(hash common.Hash) *types.Header {
+	for _, header := range c.headers {
+		if header.Hash() == hash {
+			return header
+		}
+	}
+	return nil
+}
+func (c *testerConfirmChain) GetHeader(hash common.Hash, number uint64) *types.Header {
+	if header := c.GetHeaderByNumber(number); header != nil && header.Hash() == hash {
+		return header
+	}
+	return nil
+}
+func (c *testerConfirmChain) GetHeaderByNumber(number uint64) *types.Header {
+	if number < uint64(len(c.headers)) {
+		return c.headers[number]
+	}
+	return nil
+}
+
+// newTesterConfirmChain creates a chain of headers with the given signer queue.
+func newTesterConfirmChain(t *testing.T, config *params.AlienConfig, length int, queue []common.Address) *testerConfirmChain {
+	chain := &testerConfirmChain{}
+	for i := 0; i < length; i++ {
+		header := &types.Header{Number: big.NewInt(int64(i)), Time: big.NewInt(int64(i)), Extra: make([]byte, extraVanity)}
+		if i > 0 {
+			header.ParentHash = chain.headers[i-1].Hash()
+		}
+		enc, err := encodeHeaderExtra(config, header.Number, HeaderExtra{SignerQueue: queue})
+		if err != nil {
+			t.Fatalf("failed to encode header extra: %v", err)
+		}
+		header.Extra = append(append(header.Extra, enc...), make([]byte, extraSeal)...)
+		chain.headers = append(chain.headers, header)
+	}
+	return chain
+}
+
+// confirm signs the confirmation of a header by an account of the pool.
+func (ap *testerAccountPool) confirm(header *types.Header, signer string) *SignedConfirmation {
+	ap.address(signer)
+	number, hash := header.Number.Uint64(), header.Hash()
+	sig, _ := crypto.Sign(confirmationSigHash(number, hash).Bytes(), ap.accounts[signer])
+	return &SignedConfirmation{Number: number, Hash: hash, Signature: sig}
+}
+
+// signFn returns a signer function signing with the key of an account.
+func (ap *testerAccountPool) signFn(signer string) SignerFn {
+	ap.address(signer)
+	return func(account accounts.Account, hash []byte) ([]byte, error) {
+		return crypto.Sign(hash, ap.accounts[signer])
+	}
+}
+
+func newTesterConfirmConfig() *params.AlienConfig {
+	config := *params.AllAlienProtocolChanges.Alien
+	config.MaxSignerCount = 3
+	config.StakingBlock = big.NewInt(0)
+	config.SlashingBlock = big.NewInt(0)
+	config.ConfirmBlock = big.NewInt(0)
+	return &config
+}
+
+// Tests that confirmations received off-chain are verified, collected into the
+// certificate of the next header, and that the certificate is verified.
+func TestConfirmationCertificate(t *testing.T) {
+	var (
+		config   = newTesterConfirmConfig()
+		accounts = newTesterAccountPool()
+		queue    = []common.Address{accounts.address("A"), accounts.address("B"), accounts.address("C")}
+		chain    = newTesterConfirmChain(t, config, 5, queue)
+		alien    = New(config, nil)
+	)
+	alien.Authorize(accounts.address("A"), accounts.signFn("A"), nil)
+
+	// Confirm the head locally and receive the confirmations of the others
+	if err := alien.Confirm(chain, chain.headers[3]); err != nil {
+		t.Fatalf("failed to confirm: %v", err)
+	}
+	tests := []struct {
+		confirmation *SignedConfirmation
+		added        bool
+		err          error
+	}{
+		{accounts.confirm(chain.headers[3], "B"), true, nil},
+		{accounts.confirm(chain.headers[3], "B"), false, nil},
+		{accounts.confirm(chain.headers[3], "D"), false, errNotLoopSigner},
+		{accounts.confirm(chain.headers[0], "B"), false, errConfirmOutOfRange},
+		{&SignedConfirmation{Number: 3, Hash: common.HexToHash("0x01")}, false, errUnknownBlock},
+	}
+	for i, tt := range tests {
+		if added, err := alien.AddConfirmation(chain, tt.confirmation); added != tt.added || err != tt.err {
+			t.Errorf("test %d: result mismatch: have %v, %v, want %v, %v", i, added, err, tt.added, tt.err)
+		}
+	}
+	// Two confirmations of three signers are not enough for a certificate
+	header := &types.Header{Number: big.NewInt(5), ParentHash: chain.headers[4].Hash()}
+	if certificate := alien.certificate(chain, header, 0); certificate.Number != 0 {
+		t.Fatalf("certificate without enough confirmations: %+v", certificate)
+	}
+	if _, err := alien.AddConfirmation(chain, accounts.confirm(chain.headers[3], "C")); err != nil {
+		t.Fatalf("failed to add confirmation: %v", err)
+	}
+	certificate := alien.certificate(chain, header, 0)
+	if certificate.Number != 3 || certificate.Hash != chain.headers[3].Hash() || len(certificate.Signatures) != 3 {
+		t.Fatalf("certificate mismatch: %+v", certificate)
+	}
+	if certificate := alien.certificate(chain, header, 3); certificate.Number != 0 {
+		t.Errorf("certificate of a confirmed block: %+v", certificate)
+	}
+	// verify seals the header extra with the certificate and verifies it
+	verify := func(confirmed uint64, certificate ConfirmationCertificate) error {
+		enc, err := encodeHeaderExtra(config, header.Number, HeaderExtra{ConfirmedBlockNumber: confirmed, ConfirmationCertificate: certificate})
+		if err != nil {
+			t.Fatalf("failed to encode header extra: %v", err)
+		}
+		header.Extra = append(append(make([]byte, extraVanity), enc...), make([]byte, extraSeal)...)
+
+		var decoded HeaderExtra
+		if err := decodeHeaderExtra(config, header.Number, enc, &decoded); err != nil {
+			t.Fatalf("failed to decode header extra: %v", err)
+		}
+		if have := decoded.ConfirmationCertificate; have.Number != certificate.Number || have.Hash != certificate.Hash || len(have.Signatures) != len(certificate.Signatures) {
+			t.Fatalf("certificate round trip mismatch: have %+v, want %+v", decoded.ConfirmationCertificate, certificate)
+		}
+		return alien.verifyCertificate(chain, header, nil, chain.headers[4])
+	}
+	duplicate := certificate
+	duplicate.Signatures = [][]byte{certificate.Signatures[0], certificate.Signatures[1], certificate.Signatures[0]}
+	stale := certificate
+	stale.Number, stale.Hash = 1, chain.headers[1].Hash()
+	wrong := certificate
+	wrong.Hash = chain.headers[2].Hash()
+
+	checks := []struct {
+		confirmed   uint64
+		certificate ConfirmationCertificate
+		err         error
+	}{
+		{0, ConfirmationCertificate{}, nil},
+		{3, certificate, nil},
+		{2, certificate, errInvalidConfirmedNumber},
+		{3, ConfirmationCertificate{}, errInvalidConfirmedNumber},
+		{3, ConfirmationCertificate{Number: 3, Hash: certificate.Hash, Signatures: certificate.Signatures[:2]}, errInsufficientConfirmations},
+		{3, duplicate, errInvalidCertificate},
+		{3, wrong, errInvalidCertificate},
+		{1, stale, errConfirmOutOfRange},
+	}
+	for i, tt := range checks {
+		if err := verify(tt.confirmed, tt.certificate); err != tt.err {
+			t.Errorf("check %d: error mismatch: have %v, want %v", i, err, tt.err)
+		}
+	}
+}
+
+// testConfirmPeer is a simulated remote peer of the confirmation protocol.
+type testConfirmPeer struct {
+	app  *p2p.MsgPipeRW
+	errc chan error
+}
+
+// newTestConfirmPeer connects a simulated peer to a confirmation service and
+// executes the handshake.
+func newTestConfirmPeer(t *testing.T, s *ConfirmationService) *testConfirmPeer {
+	app, net := p2p.MsgPipe()
+
+	var id discover.NodeID
+	rand.Read(id[:])
+
+	registered := s.peers.Len()
+	errc := make(chan error, 1)
+	go func() {
+		errc <- s.SubProtocols[0].Run(p2p.NewPeer(id, "test", nil), net)
+	}()
+	status := &statusData{ProtocolVersion: ufoc1, NetworkId: s.networkId, GenesisBlock: s.genesis}
+	if err := p2p.ExpectMsg(app, ConfirmStatusMsg, status); err != nil {
+		t.Fatalf("status recv error: %v", err)
+	}
+	if err := p2p.Send(app, ConfirmStatusMsg, status); err != nil {
+		t.Fatalf("status send error: %v", err)
+	}
+	// Wait for the peer to get registered
+	for s.peers.Len() == registered {
+		time.Sleep(time.Millisecond)
+	}
+	return &testConfirmPeer{app: app, errc: errc}
+}
+
+// Tests that the confirmations of the local signer are sent to the peers and
+// that only valid confirmations received are relayed.
+func TestConfirmationRelay(t *testing.T) {
+	var (
+		config   = newTesterConfirmConfig()
+		accounts = newTesterAccountPool()
+		queue    = []common.Address{accounts.address("A"), accounts.address("B"), accounts.address("C")}
+		chain    = newTesterConfirmChain(t, config, 5, queue)
+		alien    = New(config, nil)
+	)
+	alien.Authorize(accounts.address("A"), accounts.signFn("A"), nil)
+
+	service := NewConfirmationService(alien, chain, 1)
+	service.Start()
+	defer service.Stop()
+
+	source := newTestConfirmPeer(t, service)
+	sink := newTestConfirmPeer(t, service)
+
+	// An invalid confirmation must not be relayed, so the first message reaching
+	// the sink must be the valid one
+	invalid, valid := accounts.confirm(chain.headers[4], "D"), accounts.confirm(chain.headers[4], "B")
+	if err := p2p.Send(source.app, ConfirmationMsg, invalid); err != nil {
+		t.Fatalf("failed to send confirmation: %v", err)
+	}
+	if err := p2p.Send(source.app, ConfirmationMsg, valid); err != nil {
+		t.Fatalf("failed to send confirmation: %v", err)
+	}
+	if err := p2p.ExpectMsg(sink.app, ConfirmationMsg, valid); err != nil {
+		t.Fatalf("relayed confirmation mismatch: %v", err)
+	}
+	// The local confirmation is sent to both peers
+	if err := alien.Confirm(chain, chain.headers[4]); err != nil {
+		t.Fatalf("failed to confirm: %v", err)
+	}
+	own := accounts.confirm(chain.headers[4], "A")
+	for i, p := range []*testConfirmPeer{source, sink} {
+		if err := p2p.ExpectMsg(p.app, ConfirmationMsg, own); err != nil {
+			t.Fatalf("peer %d: local confirmation mismatch: %v", i, err)
+		}
+	}
+	source.app.Close()
+	sink.app.Close()
+}
@@ -22,6 +22,7 @@ import (
 	"fmt"
 	"github.com/awesome-chain/Xchain/params"
 	"math/big"
+	"reflect"
 	"strconv"
 	"strings"
 
@@ -182,6 +183,26 @@ type Declare struct {
 	Decision     bool
 }
 
+// StakeLock :
+// stake lock come from typed custom tx with the lock or unbond action
+// Sender of tx is Staker, Amount is moved out of its balance on lock, and back
+// into it once the unbonding period is over on unbond
+type StakeLock struct {
+	Staker common.Address
+	Amount *big.Int
+	Unbond bool
+}
+
+// Evidence :
+// evidence come from typed custom tx with the evidence action, carrying two
+// different headers sealed by Signer at block number Number
+// Sender of tx is Reporter
+type Evidence struct {
+	Signer   common.Address
+	Number   uint64
+	Reporter common.Address
+}
+
 // SCConfirmation is the confirmed tx send by side chain super node
 type SCConfirmation struct {
 	Hash     common.Hash
@@ -215,7 +236,8 @@ type GasCharging struct {
 }
 
 // HeaderExtra is the struct of info in header.Extra[extraVanity:len(header.extra)-extraSeal]
-// HeaderExtra is the current struct
+// HeaderExtra is the current struct. The fields added by a fork follow the
+// fields before it and are only encoded once the fork is active.
 type HeaderExtra struct {
 	CurrentBlockConfirmations []Confirmation
 	CurrentBlockVotes         []Vote
@@ -229,32 +251,64 @@ type HeaderExtra struct {
 	SideChainConfirmations    []SCConfirmation
 	SideChainSetCoinbases     []SCSetCoinbase
 	SideChainNoticeConfirmed  []SCConfirmation
-	SideChainCharging         []GasCharging //This only exist in side chain's header.Extra
+	SideChainCharging         []GasCharging           //This only exist in side chain's header.Extra
+	CurrentBlockStakeLocks    []StakeLock             // since the staking fork
+	CurrentBlockEvidences     []Evidence              // since the slashing fork
+	ConfirmationCertificate   ConfirmationCertificate // since the off-chain confirmation fork
 }
 
-// Encode HeaderExtra
-func encodeHeaderExtra(config *params.AlienConfig, number *big.Int, val HeaderExtra) ([]byte, error) {
-
-	var headerExtra interface{}
-	switch {
-	//case config.IsTrantor(number):
+// headerExtraBaseFields is the number of HeaderExtra fields before the staking fork
+const headerExtraBaseFields = 13
 
-	default:
-		headerExtra = val
+// headerExtraFields returns the number of leading HeaderExtra fields in the
+// header.Extra of the given block, each active fork adds its field.
+func headerExtraFields(config *params.AlienConfig, number *big.Int) int {
+	fields := headerExtraBaseFields
+	for _, active := range []bool{config.IsStaking(number), config.IsSlashing(number), config.IsConfirm(number)} {
+		if !active {
+			break
+		}
+		fields++
 	}
-	return rlp.EncodeToBytes(headerExtra)
+	return fields
+}
 
+// Encode HeaderExtra
+func encodeHeaderExtra(config *params.AlienConfig, number *big.Int, val HeaderExtra) ([]byte, error) {
+	v := reflect.ValueOf(val)
+	fields := make([]interface{}, headerExtraFields(config, number))
+	for i := range fields {
+		fields[i] = v.Field(i).Interface()
+	}
+	return rlp.EncodeToBytes(fields)
 }
 
 // Decode HeaderExtra
 func decodeHeaderExtra(config *params.AlienConfig, number *big.Int, b []byte, val *HeaderExtra) error {
-	var err error
-	switch {
-	//case config.IsTrantor(number):
-	default:
-		err = rlp.DecodeBytes(b, val)
+	content, rest, err := rlp.SplitList(b)
+	if err != nil {
+		return err
+	}
+	if len(rest) > 0 {
+		return rlp.ErrMoreThanOneValue
+	}
+	fields := headerExtraFields(config, number)
+	if count, err := rlp.CountValues(content); err != nil {
+		return err
+	} else if count != fields {
+		return fmt.Errorf("header extra has %d fields, want %d", count, fields)
+	}
+	var decoded HeaderExtra
+	v := reflect.ValueOf(&decoded).Elem()
+	for i := 0; i < fields; i++ {
+		_, _, rest, _ := rlp.Split(content)
+		if err := rlp.DecodeBytes(content[:len(content)-len(rest)], v.Field(i).Addr().Interface()); err != nil {
+			return err
+		}
+		content = rest
 	}
-	return err
+	*val = decoded
+	return nil
 }
 
 // Build side chain confirm data
@@ -275,6 +329,7 @@ func (a *Alien) processCustomTx(headerExtra HeaderExtra, chain consensus.ChainRe
 		number     uint64
 		refundGas  RefundGas
 		refundHash RefundHash
+		failures   []customTxFailure
 	)
 	refundGas = make(map[common.Address]*big.Int)
 	refundHash = make(map[common.Hash]RefundPair)
@@ -286,14 +341,20 @@ func (a *Alien) processCustomTx(headerExtra HeaderExtra, chain consensus.ChainRe
 		}
 	}
 
-	for _, tx := range txs {
+	for i, tx := range txs {
 
 		txSender, err := types.Sender(types.NewEIP155Signer(tx.ChainId()), tx)
 		if err != nil {
 			continue
 		}
 
-		if len(string(tx.Data())) >= len(ufoPrefix) {
+		if a.config.IsCustomTxV2(header.Number) && isCustomTxV2(tx.Data()) {
+			// typed custom tx, rejections are reported in the receipt
+			if action, err := a.processCustomTxV2(&headerExtra, chain, header, state, tx, txSender, snap, refundHash); err != nil {
+				log.Trace("Custom tx rejected", "hash", tx.Hash(), "action", action, "err", err)
+				failures = append(failures, customTxFailure{index: i, sender: txSender, action: action, err: err})
+			}
+		} else if len(string(tx.Data())) >= len(ufoPrefix) {
 			txData := string(tx.Data())
 			txDataInfo := strings.Split(txData, ":")
 			if len(txDataInfo) >= ufoMinSplitLen {
@@ -303,9 +364,11 @@ func (a *Alien) processCustomTx(headerExtra HeaderExtra, chain consensus.ChainRe
 						if txDataInfo[posCategory] == ufoCategoryEvent {
 							if len(txDataInfo) > ufoMinSplitLen {
 								// check is vote or not
-								if txDataInfo[posEventVote] == ufoEventVote && (!candidateNeedPD || snap.isCandidate(*tx.To())) && state.GetBalance(txSender).Cmp(snap.MinVB) > 0 {
-									headerExtra.CurrentBlockVotes = a.processEventVote(headerExtra.CurrentBlockVotes, state, tx, txSender)
-								} else if txDataInfo[posEventConfirm] == ufoEventConfirm && snap.isCandidate(txSender) {
+								if txDataInfo[posEventVote] == ufoEventVote && (!candidateNeedPD || snap.isCandidate(*tx.To())) && !snap.isSlashed(*tx.To()) {
+									if stake := a.voteStake(&headerExtra, header, state, snap, txSender); stake.Cmp(snap.MinVB) > 0 {
+										headerExtra.CurrentBlockVotes = a.processEventVote(headerExtra.CurrentBlockVotes, stake, tx, txSender)
+									}
+								} else if txDataInfo[posEventConfirm] == ufoEventConfirm && snap.isCandidate(txSender) && !a.config.IsConfirm(header.Number) {
 									headerExtra.CurrentBlockConfirmations, refundHash = a.processEventConfirm(headerExtra.CurrentBlockConfirmations, chain, txDataInfo, number, tx, txSender, refundHash)
 								} else if txDataInfo[posEventProposal] == ufoEventPorposal {
 									headerExtra.CurrentBlockProposals = a.processEventProposal(headerExtra.CurrentBlockProposals, txDataInfo, state, tx, txSender, snap)
@@ -355,8 +418,8 @@ func (a *Alien) processCustomTx(headerExtra HeaderExtra, chain consensus.ChainRe
 				}
 			}
 		}
-		// check each address
-		if number > 1 {
+		// check each address, locked stake is not affected by transfers
+		if number > 1 && !a.config.IsStaking(header.Number) {
 			headerExtra.ModifyPredecessorVotes = a.processPredecessorVoter(headerExtra.ModifyPredecessorVotes, state, tx, txSender, snap)
 		}
 
@@ -368,6 +431,8 @@ func (a *Alien) processCustomTx(headerExtra HeaderExtra, chain consensus.ChainRe
 			refundGas = a.refundAddGas(refundGas, pair.Sender, pair.GasPrice)
 		}
 	}
+	attachCustomTxErrors(header, txs, receipts, failures)
+
 	return headerExtra, refundGas, nil
 }
 
@@ -523,25 +588,41 @@ func (a *Alien) processEventProposal(currentBlockProposals []Proposal, txDataInf
 		}
 	}
 	// now the proposal is built
-	currentProposalPay := new(big.Int).Set(proposalDeposit)
+	currentBlockProposals, _ = a.depositProposal(currentBlockProposals, proposal, state, proposer, snap)
+	return currentBlockProposals
+}
+
+// ProposalPayment returns the amount taken from the balance of the proposer of
+// a proposal: the deposit, plus the rent fee in TTC of side chain rents.
+func ProposalPayment(proposalType uint64, scRentFee uint64) *big.Int {
+	payment := new(big.Int).Set(proposalDeposit)
+	if proposalType == proposalTypeRentSideChain {
+		payment.Add(payment, new(big.Int).Mul(new(big.Int).SetUint64(scRentFee), big.NewInt(1e+18)))
+	}
+	return payment
+}
+
+// depositProposal collects the deposit and fees of a built proposal from the
+// proposer and accepts the proposal.
+func (a *Alien) depositProposal(currentBlockProposals []Proposal, proposal Proposal, state *state.StateDB, proposer common.Address, snap *Snapshot) ([]Proposal, error) {
 	if proposal.ProposalType == proposalTypeRentSideChain {
 		// check if the proposal target side chain exist
 		if !snap.isSideChainExist(proposal.SCHash) {
-			return currentBlockProposals
+			return currentBlockProposals, errUnknownSideChain
 		}
 		if (proposal.TargetAddress == common.Address{}) {
-			return currentBlockProposals
+			return currentBlockProposals, errMissingRentTarget
 		}
-		currentProposalPay.Add(currentProposalPay, new(big.Int).Mul(new(big.Int).SetUint64(proposal.SCRentFee), big.NewInt(1e+18)))
 	}
+	currentProposalPay := ProposalPayment(proposal.ProposalType, proposal.SCRentFee)
 	// check enough balance for deposit
 	if state.GetBalance(proposer).Cmp(currentProposalPay) < 0 {
-		return currentBlockProposals
+		return currentBlockProposals, errInsufficientDeposit
 	}
 	// collection the fee for this proposal (deposit and other fee , sc rent fee ...)
 	state.SetBalance(proposer, new(big.Int).Sub(state.GetBalance(proposer), currentProposalPay))
 
-	return append(currentBlockProposals, proposal)
+	return append(currentBlockProposals, proposal), nil
 }
 
 func (a *Alien) processEventDeclare(currentBlockDeclares []Declare, txDataInfo []string, tx *types.Transaction, declarer common.Address) []Declare {
@@ -572,12 +653,7 @@ func (a *Alien) processEventDeclare(currentBlockDeclares []Declare, txDataInfo [
 	return append(currentBlockDeclares, declare)
 }
 
-func (a *Alien) processEventVote(currentBlockVotes []Vote, state *state.StateDB, tx *types.Transaction, voter common.Address) []Vote {
-
-	a.lock.RLock()
-	stake := state.GetBalance(voter)
-	a.lock.RUnlock()
-
+func (a *Alien) processEventVote(currentBlockVotes []Vote, stake *big.Int, tx *types.Transaction, voter common.Address) []Vote {
 	currentBlockVotes = append(currentBlockVotes, Vote{
 		Voter:     voter,
 		Candidate: *tx.To(),
@@ -590,40 +666,48 @@ func (a *Alien) processEventVote(currentBlockVotes []Vote, state *state.StateDB,
 func (a *Alien) processEventConfirm(currentBlockConfirmations []Confirmation, chain consensus.ChainReader, txDataInfo []string, number uint64, tx *types.Transaction, confirmer common.Address, refundHash RefundHash) ([]Confirmation, RefundHash) {
 	if len(txDataInfo) > posEventConfirmNumber {
 		confirmedBlockNumber := new(big.Int)
-		err := confirmedBlockNumber.UnmarshalText([]byte(txDataInfo[posEventConfirmNumber]))
-		if err != nil || number-confirmedBlockNumber.Uint64() > a.config.MaxSignerCount || number-confirmedBlockNumber.Uint64() < 0 {
-			return currentBlockConfirmations, refundHash
-		}
-		// check if the voter is in block
-		confirmedHeader := chain.GetHeaderByNumber(confirmedBlockNumber.Uint64())
-		if confirmedHeader == nil {
-			//log.Info("Fail to get confirmedHeader")
-			return currentBlockConfirmations, refundHash
-		}
-		confirmedHeaderExtra := HeaderExtra{}
-		if extraVanity+extraSeal > len(confirmedHeader.Extra) {
-			return currentBlockConfirmations, refundHash
-		}
-		err = decodeHeaderExtra(a.config, confirmedBlockNumber, confirmedHeader.Extra[extraVanity:len(confirmedHeader.Extra)-extraSeal], &confirmedHeaderExtra)
-		if err != nil {
-			log.Info("Fail to decode parent header", "err", err)
+		if err := confirmedBlockNumber.UnmarshalText([]byte(txDataInfo[posEventConfirmNumber])); err != nil {
 			return currentBlockConfirmations, refundHash
 		}
-		for _, s := range confirmedHeaderExtra.SignerQueue {
-			if s == confirmer {
-				currentBlockConfirmations = append(currentBlockConfirmations, Confirmation{
-					Signer:      confirmer,
-					BlockNumber: new(big.Int).Set(confirmedBlockNumber),
-				})
-				refundHash[tx.Hash()] = RefundPair{confirmer, tx.GasPrice()}
-				break
-			}
-		}
+		currentBlockConfirmations, _ = a.confirmBlock(currentBlockConfirmations, chain, number, confirmedBlockNumber, tx, confirmer, refundHash)
 	}
-
 	return currentBlockConfirmations, refundHash
 }
 
+// confirmBlock accepts the confirmation of a recent block by a signer of its
+// loop, refunding the gas of the confirmation.
+func (a *Alien) confirmBlock(currentBlockConfirmations []Confirmation, chain consensus.ChainReader, number uint64, confirmedBlockNumber *big.Int, tx *types.Transaction, confirmer common.Address, refundHash RefundHash) ([]Confirmation, error) {
+	if number-confirmedBlockNumber.Uint64() > a.config.MaxSignerCount || number-confirmedBlockNumber.Uint64() < 0 {
+		return currentBlockConfirmations, errConfirmOutOfRange
+	}
+	// check if the voter is in block
+	confirmedHeader := chain.GetHeaderByNumber(confirmedBlockNumber.Uint64())
+	if confirmedHeader == nil {
+		//log.Info("Fail to get confirmedHeader")
+		return currentBlockConfirmations, errUnknownBlock
+	}
+	confirmedHeaderExtra := HeaderExtra{}
+	if extraVanity+extraSeal > len(confirmedHeader.Extra) {
+		return currentBlockConfirmations, errMissingSignature
+	}
+	err := decodeHeaderExtra(a.config, confirmedBlockNumber, confirmedHeader.Extra[extraVanity:len(confirmedHeader.Extra)-extraSeal], &confirmedHeaderExtra)
+	if err != nil {
+		log.Info("Fail to decode parent header", "err", err)
+		return currentBlockConfirmations, err
+	}
+	for _, s := range confirmedHeaderExtra.SignerQueue {
+		if s == confirmer {
+			currentBlockConfirmations = append(currentBlockConfirmations, Confirmation{
+				Signer:      confirmer,
+				BlockNumber: new(big.Int).Set(confirmedBlockNumber),
+			})
+			refundHash[tx.Hash()] = RefundPair{confirmer, tx.GasPrice()}
+			return currentBlockConfirmations, nil
+		}
+	}
+	return currentBlockConfirmations, errNotLoopSigner
+}
+
 func (a *Alien) processPredecessorVoter(modifyPredecessorVotes []Vote, state *state.StateDB, tx *types.Transaction, voter common.Address, snap *Snapshot) []Vote {
 	// process normal transaction which relate to voter
 	if tx.Value().Cmp(big.NewInt(0)) > 0 && tx.To() != nil {
@@ -0,0 +1,691 @@
+// Copyright 2018 The gttc Authors
+// This file is part of the gttc library.
+//
+// The gttc library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The gttc library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the gttc library. If not, see <http://www.gnu.org/licenses/>.
+
+package alien
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"math/big"
+
+	"github.com/awesome-chain/Xchain/common"
+	"github.com/awesome-chain/Xchain/consensus"
+	"github.com/awesome-chain/Xchain/core/state"
+	"github.com/awesome-chain/Xchain/core/types"
+	"github.com/awesome-chain/Xchain/crypto"
+	"github.com/awesome-chain/Xchain/log"
+	"github.com/awesome-chain/Xchain/rlp"
+)
+
+/*
+ *  ufo:2:<rlp(customTxEnvelope)>
+ */
+const ufoVersionV2 = "2"
+
+// customTxPrefixV2 is the data prefix of the typed custom transactions.
+var customTxPrefixV2 = []byte(ufoPrefix + ":" + ufoVersionV2 + ":")
+
+// CustomTxErrorTopic is the first topic of the log attached to the receipt of a
+// typed custom transaction which was rejected by the consensus engine. The
+// second topic is the action of the transaction and the third the rejection
+// code of the reason.
+var CustomTxErrorTopic = crypto.Keccak256Hash([]byte("CustomTxError(uint64,uint64)"))
+
+var (
+	// errUnknownCustomTxAction is returned if a typed custom transaction carries
+	// an action this engine does not know about.
+	errUnknownCustomTxAction = errors.New("unknown custom tx action")
+
+	// errInvalidCustomTx is returned if the data of a typed custom transaction
+	// can't be decoded.
+	errInvalidCustomTx = errors.New("invalid custom tx encoding")
+
+	// errMissingRecipient is returned if a typed custom transaction is a contract
+	// creation, the recipient being part of most actions.
+	errMissingRecipient = errors.New("custom tx without recipient")
+
+	// errNotCandidate is returned if an action reserved to the candidates is sent
+	// by someone else, or if a vote is cast for someone who is not a candidate.
+	errNotCandidate = errors.New("not a candidate")
+
+	// errLowVoterBalance is returned if the voter holds, or has locked after the
+	// staking fork, no more than the minimum voter balance.
+	errLowVoterBalance = errors.New("voter balance too low")
+
+	// errUnknownProposalType is returned if a proposal has an unknown type.
+	errUnknownProposalType = errors.New("unknown proposal type")
+
+	// errInvalidValidationLoopCnt is returned if the validation loop count of a
+	// proposal is out of its bounds.
+	errInvalidValidationLoopCnt = fmt.Errorf("validation loop count out of range [%d, %d]", minValidationLoopCnt, maxValidationLoopCnt)
+
+	// errInvalidMinerReward is returned if the miner reward of a proposal is
+	// above one thousand per thousand.
+	errInvalidMinerReward = errors.New("miner reward per thousand above 1000")
+
+	// errInvalidProposalDeposit is returned if the proposal deposit of a proposal
+	// is above its maximum.
+	errInvalidProposalDeposit = fmt.Errorf("proposal deposit above %d", maxProposalDeposit)
+
+	// errInvalidSCRentFee is returned if the side chain rent fee of a proposal is
+	// below its minimum.
+	errInvalidSCRentFee = fmt.Errorf("side chain rent fee below %d", minSCRentFee)
+
+	// errInvalidSCRentLength is returned if the side chain rent length of a
+	// proposal is out of its bounds.
+	errInvalidSCRentLength = fmt.Errorf("side chain rent length out of range [%d, %d]", minSCRentLength, maxSCRentLength)
+
+	// errUnknownSideChain is returned if a proposal rents a side chain which was
+	// never added.
+	errUnknownSideChain = errors.New("unknown side chain")
+
+	// errMissingRentTarget is returned if a side chain rent proposal has no
+	// target address to charge on the side chain.
+	errMissingRentTarget = errors.New("side chain rent without target address")
+
+	// errInsufficientDeposit is returned if the proposer can't pay the deposit
+	// and fees of its proposal.
+	errInsufficientDeposit = errors.New("insufficient balance for proposal deposit")
+
+	// errConfirmOutOfRange is returned if a confirmed block is not among the
+	// recent blocks which may still be confirmed.
+	errConfirmOutOfRange = errors.New("confirmed block out of range")
+
+	// errNotLoopSigner is returned if the confirmer is not in the signer queue
+	// of the confirmed block.
+	errNotLoopSigner = errors.New("confirmer not in signer queue")
+
+	// errLowSetCoinbaseValue is returned if a side chain coinbase is set without
+	// sending the minimum value to it.
+	errLowSetCoinbaseValue = fmt.Errorf("side chain coinbase value below %v", minSCSetCoinbaseValue)
+
+	// errMissingSnapshot is returned if a typed custom transaction is included in
+	// a block without voting snapshot, namely the first one.
+	errMissingSnapshot = errors.New("no voting snapshot")
+
+	// errStakingNotActive is returned if stake is locked or unbonded before the
+	// staking fork.
+	errStakingNotActive = errors.New("locked staking not active")
+
+	// errInvalidStakeAmount is returned if a lock is for no stake at all.
+	errInvalidStakeAmount = errors.New("invalid stake amount")
+
+	// errInsufficientStakeBalance is returned if the staker can't pay the stake
+	// it locks.
+	errInsufficientStakeBalance = errors.New("insufficient balance for stake lock")
+
+	// errInsufficientLockedStake is returned if more stake is unbonded than the
+	// staker has locked.
+	errInsufficientLockedStake = errors.New("insufficient locked stake")
+
+	// errSlashingNotActive is returned if double sign evidence is reported before
+	// the slashing fork.
+	errSlashingNotActive = errors.New("double sign slashing not active")
+
+	// errConfirmTxObsolete is returned if a block is confirmed by transaction
+	// after the off-chain confirmation fork.
+	errConfirmTxObsolete = errors.New("confirmation transactions obsolete")
+)
+
+// CustomTxRejection is the code of the reason a typed custom transaction was
+// rejected for, recorded in its receipt. The codes are part of the consensus,
+// new ones are only ever appended.
+type CustomTxRejection uint64
+
+const (
+	RejectOther                 CustomTxRejection = iota // Rejected for a reason without a code of its own
+	RejectUnknownAction                                  // errUnknownCustomTxAction
+	RejectInvalidEncoding                                // errInvalidCustomTx
+	RejectMissingRecipient                               // errMissingRecipient
+	RejectNotCandidate                                   // errNotCandidate
+	RejectLowVoterBalance                                // errLowVoterBalance
+	RejectUnknownProposalType                            // errUnknownProposalType
+	RejectInvalidValidationLoop                          // errInvalidValidationLoopCnt
+	RejectInvalidMinerReward                             // errInvalidMinerReward
+	RejectInvalidDeposit                                 // errInvalidProposalDeposit
+	RejectInvalidSCRentFee                               // errInvalidSCRentFee
+	RejectInvalidSCRentLength                            // errInvalidSCRentLength
+	RejectUnknownSideChain                               // errUnknownSideChain
+	RejectMissingRentTarget                              // errMissingRentTarget
+	RejectInsufficientDeposit                            // errInsufficientDeposit
+	RejectConfirmOutOfRange                              // errConfirmOutOfRange
+	RejectNotLoopSigner                                  // errNotLoopSigner
+	RejectLowSetCoinbaseValue                            // errLowSetCoinbaseValue
+	RejectMissingSnapshot                                // errMissingSnapshot
+	RejectStakingNotActive                               // errStakingNotActive
+	RejectInvalidStakeAmount                             // errInvalidStakeAmount
+	RejectInsufficientBalance                            // errInsufficientStakeBalance
+	RejectInsufficientStake                              // errInsufficientLockedStake
+	RejectSlashingNotActive                              // errSlashingNotActive
+	RejectConfirmTxObsolete                              // errConfirmTxObsolete
+	RejectInvalidEvidence                                // errInvalidEvidence
+	RejectNotConflicting                                 // errNotConflicting
+	RejectEvidenceOutOfRange                             // errEvidenceOutOfRange
+	RejectEvidenceNotSigner                              // errEvidenceNotSigner
+	RejectAlreadySlashed                                 // errAlreadySlashed
+)
+
+// customTxRejections maps the rejection reasons to their codes.
+var customTxRejections = map[error]CustomTxRejection{
+	errUnknownCustomTxAction:    RejectUnknownAction,
+	errInvalidCustomTx:          RejectInvalidEncoding,
+	errMissingRecipient:         RejectMissingRecipient,
+	errNotCandidate:             RejectNotCandidate,
+	errLowVoterBalance:          RejectLowVoterBalance,
+	errUnknownProposalType:      RejectUnknownProposalType,
+	errInvalidValidationLoopCnt: RejectInvalidValidationLoop,
+	errInvalidMinerReward:       RejectInvalidMinerReward,
+	errInvalidProposalDeposit:   RejectInvalidDeposit,
+	errInvalidSCRentFee:         RejectInvalidSCRentFee,
+	errInvalidSCRentLength:      RejectInvalidSCRentLength,
+	errUnknownSideChain:         RejectUnknownSideChain,
+	errMissingRentTarget:        RejectMissingRentTarget,
+	errInsufficientDeposit:      RejectInsufficientDeposit,
+	errConfirmOutOfRange:        RejectConfirmOutOfRange,
+	errNotLoopSigner:            RejectNotLoopSigner,
+	errLowSetCoinbaseValue:      RejectLowSetCoinbaseValue,
+	errMissingSnapshot:          RejectMissingSnapshot,
+	errStakingNotActive:         RejectStakingNotActive,
+	errInvalidStakeAmount:       RejectInvalidStakeAmount,
+	errInsufficientStakeBalance: RejectInsufficientBalance,
+	errInsufficientLockedStake:  RejectInsufficientStake,
+	errSlashingNotActive:        RejectSlashingNotActive,
+	errConfirmTxObsolete:        RejectConfirmTxObsolete,
+	errInvalidEvidence:          RejectInvalidEvidence,
+	errNotConflicting:           RejectNotConflicting,
+	errEvidenceOutOfRange:       RejectEvidenceOutOfRange,
+	errEvidenceNotSigner:        RejectEvidenceNotSigner,
+	errAlreadySlashed:           RejectAlreadySlashed,
+}
+
+// customTxRejection returns the rejection code of a reason.
+func customTxRejection(err error) CustomTxRejection {
+	if code, ok := customTxRejections[err]; ok {
+		return code
+	}
+	return RejectOther
+}
+
+// String implements fmt.Stringer.
+func (code CustomTxRejection) String() string {
+	for err, c := range customTxRejections {
+		if c == code {
+			return err.Error()
+		}
+	}
+	if code == RejectOther {
+		return "rejected"
+	}
+	return fmt.Sprintf("rejection(%d)", uint64(code))
+}
+
+// CustomTxAction is the action of a typed custom transaction.
+type CustomTxAction uint64
+
+const (
+	ActionVote          CustomTxAction = iota + 1 // Vote for the recipient, payload VotePayload
+	ActionConfirm                                 // Confirm a recent block, payload ConfirmPayload
+	ActionProposal                                // Submit a proposal, payload ProposalPayload
+	ActionDeclare                                 // Declare on a proposal, payload DeclarePayload
+	ActionSCConfirm                               // Confirm a side chain block, payload SCConfirmPayload
+	ActionSCSetCoinbase                           // Set the recipient as side chain coinbase, payload SCSetCoinbasePayload
+	ActionLock                                    // Lock stake for voting, payload LockPayload
+	ActionUnbond                                  // Unbond locked stake, payload UnbondPayload
+	ActionEvidence                                // Report a double signing signer, payload EvidencePayload
+)
+
+// String implements fmt.Stringer.
+func (action CustomTxAction) String() string {
+	switch action {
+	case ActionVote:
+		return "vote"
+	case ActionConfirm:
+		return "confirm"
+	case ActionProposal:
+		return "proposal"
+	case ActionDeclare:
+		return "declare"
+	case ActionSCConfirm:
+		return "scconfirm"
+	case ActionSCSetCoinbase:
+		return "scsetcoinbase"
+	case ActionLock:
+		return "lock"
+	case ActionUnbond:
+		return "unbond"
+	case ActionEvidence:
+		return "evidence"
+	}
+	return fmt.Sprintf("action(%d)", uint64(action))
+}
+
+// customTxEnvelope is the RLP envelope of a typed custom transaction, following
+// the ufo:2: prefix of its data.
+type customTxEnvelope struct {
+	Action  CustomTxAction
+	Payload rlp.RawValue
+}
+
+// VotePayload is the payload of a vote, the candidate being the recipient of
+// the transaction.
+type VotePayload struct{}
+
+// ConfirmPayload is the payload of a block confirmation.
+type ConfirmPayload struct {
+	BlockNumber uint64
+}
+
+// ProposalPayload is the payload of a proposal. Zero values stand for the
+// defaults of the fields having one, see Proposal for the meaning of the fields.
+type ProposalPayload struct {
+	ProposalType           uint64
+	ValidationLoopCnt      uint64
+	TargetAddress          common.Address
+	MinerRewardPerThousand uint64
+	SCHash                 common.Hash
+	SCBlockCountPerPeriod  uint64
+	SCBlockRewardPerPeriod uint64
+	MinVoterBalance        uint64
+	ProposalDeposit        uint64
+	SCRentFee              uint64
+	SCRentRate             uint64
+	SCRentLength           uint64
+}
+
+// DeclarePayload is the payload of a declaration on a proposal.
+type DeclarePayload struct {
+	ProposalHash common.Hash
+	Decision     bool
+}
+
+// SCConfirmPayload is the payload of a side chain block confirmation.
+type SCConfirmPayload struct {
+	SCHash       common.Hash
+	Number       uint64
+	Time         uint64
+	LoopInfo     []string
+	ChargingInfo []string
+}
+
+// SCSetCoinbasePayload is the payload setting the recipient of the transaction
+// as coinbase of the sender on a side chain.
+type SCSetCoinbasePayload struct {
+	SCHash common.Hash
+}
+
+// LockPayload is the payload locking stake of the sender, moving the amount out
+// of its spendable balance.
+type LockPayload struct {
+	Amount *big.Int
+}
+
+// UnbondPayload is the payload unbonding locked stake of the sender, the amount
+// being released into its balance once the unbonding period is over. A zero
+// amount unbonds all the locked stake.
+type UnbondPayload struct {
+	Amount *big.Int
+}
+
+// EvidencePayload is the payload reporting a signer which sealed two different
+// headers for the same block number.
+type EvidencePayload struct {
+	First  *types.Header `rlp:"nil"`
+	Second *types.Header `rlp:"nil"`
+}
+
+// payloadAction returns the action of a typed custom transaction payload.
+func payloadAction(payload interface{}) (CustomTxAction, error) {
+	switch payload.(type) {
+	case *VotePayload, VotePayload:
+		return ActionVote, nil
+	case *ConfirmPayload, ConfirmPayload:
+		return ActionConfirm, nil
+	case *ProposalPayload, ProposalPayload:
+		return ActionProposal, nil
+	case *DeclarePayload, DeclarePayload:
+		return ActionDeclare, nil
+	case *SCConfirmPayload, SCConfirmPayload:
+		return ActionSCConfirm, nil
+	case *SCSetCoinbasePayload, SCSetCoinbasePayload:
+		return ActionSCSetCoinbase, nil
+	case *LockPayload, LockPayload:
+		return ActionLock, nil
+	case *UnbondPayload, UnbondPayload:
+		return ActionUnbond, nil
+	case *EvidencePayload, EvidencePayload:
+		return ActionEvidence, nil
+	}
+	return 0, fmt.Errorf("unknown custom tx payload %T", payload)
+}
+
+// EncodeCustomTx encodes a typed custom transaction payload into transaction
+// data, the action being derived from the type of the payload.
+func EncodeCustomTx(payload interface{}) ([]byte, error) {
+	action, err := payloadAction(payload)
+	if err != nil {
+		return nil, err
+	}
+	enc, err := rlp.EncodeToBytes(payload)
+	if err != nil {
+		return nil, err
+	}
+	env, err := rlp.EncodeToBytes(&customTxEnvelope{Action: action, Payload: enc})
+	if err != nil {
+		return nil, err
+	}
+	return append(append([]byte{}, customTxPrefixV2...), env...), nil
+}
+
+// DecodeCustomTx decodes the data of a typed custom transaction, returning its
+// action and a pointer to its payload. The action is returned as long as the
+// envelope decodes, even if the payload does not.
+func DecodeCustomTx(data []byte) (CustomTxAction, interface{}, error) {
+	if !bytes.HasPrefix(data, customTxPrefixV2) {
+		return 0, nil, errInvalidCustomTx
+	}
+	var env customTxEnvelope
+	if err := rlp.DecodeBytes(data[len(customTxPrefixV2):], &env); err != nil {
+		return 0, nil, fmt.Errorf("%v: %v", errInvalidCustomTx, err)
+	}
+	var payload interface{}
+	switch env.Action {
+	case ActionVote:
+		payload = new(VotePayload)
+	case ActionConfirm:
+		payload = new(ConfirmPayload)
+	case ActionProposal:
+		payload = new(ProposalPayload)
+	case ActionDeclare:
+		payload = new(DeclarePayload)
+	case ActionSCConfirm:
+		payload = new(SCConfirmPayload)
+	case ActionSCSetCoinbase:
+		payload = new(SCSetCoinbasePayload)
+	case ActionLock:
+		payload = new(LockPayload)
+	case ActionUnbond:
+		payload = new(UnbondPayload)
+	case ActionEvidence:
+		payload = new(EvidencePayload)
+	default:
+		return env.Action, nil, errUnknownCustomTxAction
+	}
+	if err := rlp.DecodeBytes(env.Payload, payload); err != nil {
+		return env.Action, nil, fmt.Errorf("%v: %v", errInvalidCustomTx, err)
+	}
+	return env.Action, payload, nil
+}
+
+// isCustomTxV2 checks whether the data of a transaction is a typed custom
+// transaction.
+func isCustomTxV2(data []byte) bool {
+	return bytes.HasPrefix(data, customTxPrefixV2)
+}
+
+// proposal validates the payload and builds the proposal it stands for. Unlike
+// the version 1 proposals, out of range values are reported instead of dropped.
+func (p *ProposalPayload) proposal(hash common.Hash, proposer common.Address) (Proposal, error) {
+	proposal := Proposal{
+		Hash:                   hash,
+		ReceivedNumber:         big.NewInt(0),
+		CurrentDeposit:         proposalDeposit, // for all type of deposit
+		ValidationLoopCnt:      defaultValidationLoopCnt,
+		ProposalType:           p.ProposalType,
+		Proposer:               proposer,
+		TargetAddress:          p.TargetAddress,
+		SCHash:                 p.SCHash,
+		SCBlockCountPerPeriod:  1,
+		SCBlockRewardPerPeriod: p.SCBlockRewardPerPeriod,
+		MinerRewardPerThousand: minerRewardPerThousand,
+		Declares:               []*Declare{},
+		MinVoterBalance:        new(big.Int).Div(minVoterBalance, big.NewInt(1e+18)).Uint64(),
+		ProposalDeposit:        new(big.Int).Div(proposalDeposit, big.NewInt(1e+18)).Uint64(), // default value
+		SCRentFee:              p.SCRentFee,
+		SCRentRate:             1,
+		SCRentLength:           defaultSCRentLength,
+	}
+	if p.ProposalType < proposalTypeCandidateAdd || p.ProposalType > proposalTypeRentSideChain {
+		return Proposal{}, errUnknownProposalType
+	}
+	if p.ValidationLoopCnt != 0 {
+		if p.ValidationLoopCnt < minValidationLoopCnt || p.ValidationLoopCnt > maxValidationLoopCnt {
+			return Proposal{}, errInvalidValidationLoopCnt
+		}
+		proposal.ValidationLoopCnt = p.ValidationLoopCnt
+	}
+	if p.SCBlockCountPerPeriod != 0 {
+		proposal.SCBlockCountPerPeriod = p.SCBlockCountPerPeriod
+	}
+	if p.MinerRewardPerThousand != 0 {
+		if p.MinerRewardPerThousand > 1000 {
+			return Proposal{}, errInvalidMinerReward
+		}
+		proposal.MinerRewardPerThousand = p.MinerRewardPerThousand
+	}
+	if p.MinVoterBalance != 0 {
+		proposal.MinVoterBalance = p.MinVoterBalance
+	}
+	if p.ProposalDeposit != 0 {
+		if p.ProposalDeposit > maxProposalDeposit {
+			return Proposal{}, errInvalidProposalDeposit
+		}
+		proposal.ProposalDeposit = p.ProposalDeposit
+	}
+	if p.SCRentRate != 0 {
+		proposal.SCRentRate = p.SCRentRate
+	}
+	if p.SCRentLength != 0 {
+		if p.SCRentLength < minSCRentLength || p.SCRentLength > maxSCRentLength {
+			return Proposal{}, errInvalidSCRentLength
+		}
+		proposal.SCRentLength = p.SCRentLength
+	}
+	if (p.ProposalType == proposalTypeRentSideChain || p.SCRentFee != 0) && p.SCRentFee < minSCRentFee {
+		return Proposal{}, errInvalidSCRentFee
+	}
+	return proposal, nil
+}
+
+// Validate checks that the values of the payload are in range, a proposal
+// carrying out of range values being rejected.
+func (p *ProposalPayload) Validate() error {
+	_, err := p.proposal(common.Hash{}, common.Address{})
+	return err
+}
+
+// processCustomTxV2 applies a typed custom transaction to the header extra. The
+// returned error is the reason of the rejection of the transaction, not a
+// failure of the block.
+func (a *Alien) processCustomTxV2(headerExtra *HeaderExtra, chain consensus.ChainReader, header *types.Header, state *state.StateDB, tx *types.Transaction, txSender common.Address, snap *Snapshot, refundHash RefundHash) (CustomTxAction, error) {
+	action, payload, err := DecodeCustomTx(tx.Data())
+	if err != nil {
+		if err != errUnknownCustomTxAction {
+			log.Trace("Undecodable custom tx", "hash", tx.Hash(), "err", err)
+			err = errInvalidCustomTx
+		}
+		return action, err
+	}
+	if snap == nil {
+		return action, errMissingSnapshot
+	}
+	switch payload := payload.(type) {
+	case *VotePayload:
+		if tx.To() == nil {
+			return action, errMissingRecipient
+		}
+		if candidateNeedPD && !snap.isCandidate(*tx.To()) {
+			return action, errNotCandidate
+		}
+		if snap.isSlashed(*tx.To()) {
+			return action, errAlreadySlashed
+		}
+		stake := a.voteStake(headerExtra, header, state, snap, txSender)
+		if stake.Cmp(snap.MinVB) <= 0 {
+			return action, errLowVoterBalance
+		}
+		headerExtra.CurrentBlockVotes = a.processEventVote(headerExtra.CurrentBlockVotes, stake, tx, txSender)
+
+	case *ConfirmPayload:
+		if a.config.IsConfirm(header.Number) {
+			return action, errConfirmTxObsolete
+		}
+		if !snap.isCandidate(txSender) {
+			return action, errNotCandidate
+		}
+		headerExtra.CurrentBlockConfirmations, err = a.confirmBlock(headerExtra.CurrentBlockConfirmations, chain, header.Number.Uint64(), new(big.Int).SetUint64(payload.BlockNumber), tx, txSender, refundHash)
+
+	case *ProposalPayload:
+		var proposal Proposal
+		if proposal, err = payload.proposal(tx.Hash(), txSender); err != nil {
+			return action, err
+		}
+		headerExtra.CurrentBlockProposals, err = a.depositProposal(headerExtra.CurrentBlockProposals, proposal, state, txSender, snap)
+
+	case *DeclarePayload:
+		if !snap.isCandidate(txSender) {
+			return action, errNotCandidate
+		}
+		headerExtra.CurrentBlockDeclares = append(headerExtra.CurrentBlockDeclares, Declare{
+			ProposalHash: payload.ProposalHash,
+			Declarer:     txSender,
+			Decision:     payload.Decision,
+		})
+
+	case *SCConfirmPayload:
+		headerExtra.SideChainConfirmations = append(headerExtra.SideChainConfirmations, SCConfirmation{
+			Hash:     payload.SCHash,
+			Coinbase: txSender,
+			Number:   payload.Number,
+			LoopInfo: payload.LoopInfo,
+		})
+		refundHash[tx.Hash()] = RefundPair{txSender, tx.GasPrice()}
+		if len(payload.ChargingInfo) > 0 {
+			headerExtra.SideChainNoticeConfirmed = append(headerExtra.SideChainNoticeConfirmed, SCConfirmation{
+				Hash:     payload.SCHash,
+				Coinbase: txSender,
+				Number:   payload.Number,
+				LoopInfo: payload.ChargingInfo,
+			})
+		}
+
+	case *SCSetCoinbasePayload:
+		if tx.To() == nil {
+			return action, errMissingRecipient
+		}
+		if !snap.isCandidate(txSender) {
+			return action, errNotCandidate
+		}
+		// the signer of main chain must send some value to coinbase of side chain for confirm tx of side chain
+		if tx.Value().Cmp(minSCSetCoinbaseValue) < 0 {
+			return action, errLowSetCoinbaseValue
+		}
+		headerExtra.SideChainSetCoinbases = a.processSCEventSetCoinbase(headerExtra.SideChainSetCoinbases, payload.SCHash, txSender, *tx.To())
+
+	case *LockPayload:
+		if !a.config.IsStaking(header.Number) {
+			return action, errStakingNotActive
+		}
+		if payload.Amount == nil || payload.Amount.Sign() <= 0 {
+			return action, errInvalidStakeAmount
+		}
+		if state.GetBalance(txSender).Cmp(payload.Amount) < 0 {
+			return action, errInsufficientStakeBalance
+		}
+		// the locked stake is held by the snapshot until unbonded
+		state.SubBalance(txSender, payload.Amount)
+		headerExtra.CurrentBlockStakeLocks = append(headerExtra.CurrentBlockStakeLocks, StakeLock{
+			Staker: txSender,
+			Amount: new(big.Int).Set(payload.Amount),
+		})
+
+	case *UnbondPayload:
+		if !a.config.IsStaking(header.Number) {
+			return action, errStakingNotActive
+		}
+		locked := snap.lockedStake(txSender, headerExtra.CurrentBlockStakeLocks)
+		amount := locked
+		if payload.Amount != nil && payload.Amount.Sign() > 0 {
+			amount = payload.Amount
+		}
+		if amount.Sign() <= 0 || amount.Cmp(locked) > 0 {
+			return action, errInsufficientLockedStake
+		}
+		headerExtra.CurrentBlockStakeLocks = append(headerExtra.CurrentBlockStakeLocks, StakeLock{
+			Staker: txSender,
+			Amount: new(big.Int).Set(amount),
+			Unbond: true,
+		})
+
+	case *EvidencePayload:
+		if !a.config.IsSlashing(header.Number) {
+			return action, errSlashingNotActive
+		}
+		var signer common.Address
+		if signer, err = a.verifyDoubleSign(chain, header, snap, payload.First, payload.Second); err != nil {
+			return action, err
+		}
+		for _, evidence := range headerExtra.CurrentBlockEvidences {
+			if evidence.Signer == signer {
+				return action, errAlreadySlashed
+			}
+		}
+		headerExtra.CurrentBlockEvidences = append(headerExtra.CurrentBlockEvidences, Evidence{
+			Signer:   signer,
+			Number:   payload.First.Number.Uint64(),
+			Reporter: txSender,
+		})
+		// reporting is free for the reporter
+		refundHash[tx.Hash()] = RefundPair{txSender, tx.GasPrice()}
+	}
+	return action, err
+}
+
+// customTxFailure is a rejected typed custom transaction.
+type customTxFailure struct {
+	index  int
+	sender common.Address
+	action CustomTxAction
+	err    error
+}
+
+// attachCustomTxErrors appends an error log with the rejection code of the reason
+// to the receipts of the rejected typed custom transactions and recomputes the
+// log indexes and blooms of the block. The block hash of the logs isn't known
+// until the header is sealed, it is filled in when the block is written.
+func attachCustomTxErrors(header *types.Header, txs []*types.Transaction, receipts []*types.Receipt, failures []customTxFailure) {
+	if len(failures) == 0 || len(receipts) != len(txs) {
+		return
+	}
+	for _, failure := range failures {
+		receipt := receipts[failure.index]
+		receipt.Logs = append(receipt.Logs, &types.Log{
+			Address:     failure.sender,
+			Topics:      []common.Hash{CustomTxErrorTopic, common.BigToHash(new(big.Int).SetUint64(uint64(failure.action))), common.BigToHash(new(big.Int).SetUint64(uint64(customTxRejection(failure.err))))},
+			BlockNumber: header.Number.Uint64(),
+			TxHash:      txs[failure.index].Hash(),
+			TxIndex:     uint(failure.index),
+		})
+		receipt.Bloom = types.CreateBloom(types.Receipts{receipt})
+	}
+	var index uint
+	for _, receipt := range receipts {
+		for _, log := range receipt.Logs {
+			log.Index = index
+			index++
+		}
+	}
+}
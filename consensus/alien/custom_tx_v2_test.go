@@ -0,0 +1,172 @@
+// Copyright 2018 The gttc Authors
+// This file is part of the gttc library.
+//
+// The gttc library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The gttc library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the gttc library. If not, see <http://www.gnu.org/licenses/>.
+
+package alien
+
+import (
+	"bytes"
+	"math/big"
+	"reflect"
+	"testing"
+
+	"github.com/awesome-chain/Xchain/common"
+	"github.com/awesome-chain/Xchain/core/types"
+)
+
+// Tests that typed custom transactions round trip through their encoding and
+// that malformed ones are reported.
+func TestCustomTxV2Encoding(t *testing.T) {
+	payloads := []struct {
+		payload interface{}
+		action  CustomTxAction
+	}{
+		{&VotePayload{}, ActionVote},
+		{&ConfirmPayload{BlockNumber: 42}, ActionConfirm},
+		{&ProposalPayload{ProposalType: proposalTypeSideChainAdd, SCHash: common.HexToHash("0x3210"), SCBlockCountPerPeriod: 2}, ActionProposal},
+		{&DeclarePayload{ProposalHash: common.HexToHash("0x853e"), Decision: true}, ActionDeclare},
+		{&SCConfirmPayload{SCHash: common.HexToHash("0x3210"), Number: 7, Time: 1000, LoopInfo: []string{"a", "b"}, ChargingInfo: []string{}}, ActionSCConfirm},
+		{&SCSetCoinbasePayload{SCHash: common.HexToHash("0x3210")}, ActionSCSetCoinbase},
+	}
+	for i, tt := range payloads {
+		data, err := EncodeCustomTx(tt.payload)
+		if err != nil {
+			t.Fatalf("test %d: failed to encode: %v", i, err)
+		}
+		if !bytes.HasPrefix(data, []byte("ufo:2:")) {
+			t.Errorf("test %d: prefix mismatch: have %q", i, data)
+		}
+		action, payload, err := DecodeCustomTx(data)
+		if err != nil {
+			t.Fatalf("test %d: failed to decode: %v", i, err)
+		}
+		if action != tt.action {
+			t.Errorf("test %d: action mismatch: have %v, want %v", i, action, tt.action)
+		}
+		if !reflect.DeepEqual(payload, tt.payload) {
+			t.Errorf("test %d: payload mismatch: have %+v, want %+v", i, payload, tt.payload)
+		}
+	}
+	if _, err := EncodeCustomTx(&Vote{}); err == nil {
+		t.Errorf("unknown payload encoded")
+	}
+	// Malformed envelopes and payloads must fail, the latter with their action
+	if _, _, err := DecodeCustomTx([]byte("ufo:1:event:vote")); err != errInvalidCustomTx {
+		t.Errorf("v1 data error mismatch: have %v, want %v", err, errInvalidCustomTx)
+	}
+	if _, _, err := DecodeCustomTx([]byte("ufo:2:garbage")); err == nil {
+		t.Errorf("garbage envelope decoded")
+	}
+	data, _ := EncodeCustomTx(&ConfirmPayload{BlockNumber: 42})
+	if action, _, err := DecodeCustomTx(data[:len(data)-1]); err == nil || action != 0 {
+		t.Errorf("truncated envelope decoded: action %v, err %v", action, err)
+	}
+	env := append(append([]byte{}, customTxPrefixV2...), 0xc3, 0x02, 0xc1, 0xc0)
+	if action, _, err := DecodeCustomTx(env); err == nil || action != ActionConfirm {
+		t.Errorf("invalid payload error mismatch: action %v, err %v", action, err)
+	}
+	env = append(append([]byte{}, customTxPrefixV2...), 0xc2, 0x63, 0xc0)
+	if action, _, err := DecodeCustomTx(env); err != errUnknownCustomTxAction || action != 99 {
+		t.Errorf("unknown action error mismatch: action %v, err %v", action, err)
+	}
+}
+
+// Tests that proposals are validated, defaulted and rejected with a reason.
+func TestCustomTxV2Proposal(t *testing.T) {
+	tx := types.NewTransaction(0, common.Address{}, big.NewInt(0), 0, big.NewInt(0), nil)
+	proposer := common.HexToAddress("0x01")
+
+	tests := []struct {
+		payload ProposalPayload
+		err     error
+	}{
+		{ProposalPayload{}, errUnknownProposalType},
+		{ProposalPayload{ProposalType: proposalTypeRentSideChain + 1}, errUnknownProposalType},
+		{ProposalPayload{ProposalType: proposalTypeCandidateAdd, ValidationLoopCnt: minValidationLoopCnt - 1}, errInvalidValidationLoopCnt},
+		{ProposalPayload{ProposalType: proposalTypeCandidateAdd, ValidationLoopCnt: maxValidationLoopCnt + 1}, errInvalidValidationLoopCnt},
+		{ProposalPayload{ProposalType: proposalTypeMinerRewardDistributionModify, MinerRewardPerThousand: 1001}, errInvalidMinerReward},
+		{ProposalPayload{ProposalType: proposalTypeProposalDepositModify, ProposalDeposit: maxProposalDeposit + 1}, errInvalidProposalDeposit},
+		{ProposalPayload{ProposalType: proposalTypeRentSideChain}, errInvalidSCRentFee},
+		{ProposalPayload{ProposalType: proposalTypeRentSideChain, SCRentFee: minSCRentFee, SCRentLength: maxSCRentLength + 1}, errInvalidSCRentLength},
+		{ProposalPayload{ProposalType: proposalTypeRentSideChain, SCRentFee: minSCRentFee}, nil},
+		{ProposalPayload{ProposalType: proposalTypeCandidateAdd, TargetAddress: proposer, ValidationLoopCnt: minValidationLoopCnt}, nil},
+	}
+	for i, tt := range tests {
+		proposal, err := tt.payload.proposal(tx.Hash(), proposer)
+		if err != tt.err {
+			t.Errorf("test %d: error mismatch: have %v, want %v", i, err, tt.err)
+			continue
+		}
+		if err != nil {
+			continue
+		}
+		if proposal.Hash != tx.Hash() || proposal.Proposer != proposer || proposal.ProposalType != tt.payload.ProposalType {
+			t.Errorf("test %d: proposal mismatch: %+v", i, proposal)
+		}
+		if tt.payload.ValidationLoopCnt == 0 && proposal.ValidationLoopCnt != defaultValidationLoopCnt {
+			t.Errorf("test %d: validation loop count not defaulted: have %d", i, proposal.ValidationLoopCnt)
+		}
+		if proposal.SCBlockCountPerPeriod != 1 || proposal.SCRentRate != 1 || proposal.SCRentLength != defaultSCRentLength {
+			t.Errorf("test %d: side chain fields not defaulted: %+v", i, proposal)
+		}
+	}
+}
+
+// Tests that rejected typed custom transactions get an error log in their
+// receipt and that the logs of the block are renumbered.
+func TestCustomTxV2Errors(t *testing.T) {
+	var (
+		txs = []*types.Transaction{
+			types.NewTransaction(0, common.Address{}, big.NewInt(0), 0, big.NewInt(0), nil),
+			types.NewTransaction(1, common.Address{}, big.NewInt(0), 0, big.NewInt(0), nil),
+		}
+		receipts = []*types.Receipt{
+			{TxHash: txs[0].Hash(), Logs: []*types.Log{{Index: 0}}},
+			{TxHash: txs[1].Hash(), Logs: []*types.Log{{Index: 1}}},
+		}
+		header = &types.Header{Number: big.NewInt(10)}
+		sender = common.HexToAddress("0x02")
+	)
+	attachCustomTxErrors(header, txs, receipts, []customTxFailure{{index: 0, sender: sender, action: ActionConfirm, err: errNotCandidate}})
+
+	if len(receipts[0].Logs) != 2 || len(receipts[1].Logs) != 1 {
+		t.Fatalf("log count mismatch: have %d and %d, want 2 and 1", len(receipts[0].Logs), len(receipts[1].Logs))
+	}
+	log := receipts[0].Logs[1]
+	if log.Address != sender || log.TxHash != txs[0].Hash() || log.BlockNumber != 10 || log.TxIndex != 0 {
+		t.Errorf("log origin mismatch: %+v", log)
+	}
+	if len(log.Topics) != 3 || log.Topics[0] != CustomTxErrorTopic || log.Topics[1].Big().Uint64() != uint64(ActionConfirm) || log.Topics[2].Big().Uint64() != uint64(RejectNotCandidate) {
+		t.Errorf("log topics mismatch: %v", log.Topics)
+	}
+	if len(log.Data) != 0 {
+		t.Errorf("log data mismatch: have %x, want none", log.Data)
+	}
+	for i, log := range []*types.Log{receipts[0].Logs[0], receipts[0].Logs[1], receipts[1].Logs[0]} {
+		if log.Index != uint(i) {
+			t.Errorf("log %d: index mismatch: have %d", i, log.Index)
+		}
+	}
+	if !receipts[0].Bloom.Test(CustomTxErrorTopic.Big()) || receipts[1].Bloom.Test(CustomTxErrorTopic.Big()) {
+		t.Errorf("bloom mismatch")
+	}
+	// Reasons without a code of their own share a generic one
+	if code := customTxRejection(errUnknownBlock); code != RejectOther {
+		t.Errorf("uncoded reason mismatch: have %v, want %v", code, RejectOther)
+	}
+	if code := RejectNotCandidate; code.String() != errNotCandidate.Error() {
+		t.Errorf("rejection name mismatch: have %q, want %q", code, errNotCandidate)
+	}
+}
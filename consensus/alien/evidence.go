@@ -0,0 +1,170 @@
+// Copyright 2018 The gttc Authors
+// This file is part of the gttc library.
+//
+// The gttc library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The gttc library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the gttc library. If not, see <http://www.gnu.org/licenses/>.
+
+package alien
+
+import (
+	"errors"
+	"math/big"
+
+	"github.com/awesome-chain/Xchain/common"
+	"github.com/awesome-chain/Xchain/consensus"
+	"github.com/awesome-chain/Xchain/core/types"
+)
+
+// After the slashing fork anyone can report a signer which sealed two different
+// headers for the same block number. The locked stake of the signer, unbonding
+// or not, is burned, its voters lose a part of theirs, unbonding or not, and
+// the signer is never a candidate again.
+
+// doubleSignVoterSlashPerThousand is the part of the stake, locked or unbonding,
+// the voters of a double signing signer lose, count in one thousand.
+const doubleSignVoterSlashPerThousand = 100
+
+var (
+	// errInvalidEvidence is returned if a double sign evidence lacks a header.
+	errInvalidEvidence = errors.New("invalid double sign evidence")
+
+	// errNotConflicting is returned if the headers of an evidence are not two
+	// different headers sealed by the same signer for the same block number in
+	// the same slot.
+	errNotConflicting = errors.New("evidence headers not conflicting")
+
+	// errEvidenceOutOfRange is returned if the headers of an evidence are not
+	// among the blocks of the last epoch.
+	errEvidenceOutOfRange = errors.New("evidence out of range")
+
+	// errEvidenceNotSigner is returned if the signer of an evidence was not in
+	// the signer queue at the block number of the evidence.
+	errEvidenceNotSigner = errors.New("evidence signer not in signer queue")
+
+	// errAlreadySlashed is returned if a signer is reported again, or voted for,
+	// once slashed.
+	errAlreadySlashed = errors.New("signer already slashed")
+)
+
+// verifyDoubleSign checks that the headers are evidence of the double signing
+// of a signer of the chain, returning the signer.
+func (a *Alien) verifyDoubleSign(chain consensus.ChainReader, header *types.Header, snap *Snapshot, first *types.Header, second *types.Header) (common.Address, error) {
+	if first == nil || second == nil || first.Number == nil || second.Number == nil {
+		return common.Address{}, errInvalidEvidence
+	}
+	if first.Number.Cmp(second.Number) != 0 || first.Hash() == second.Hash() {
+		return common.Address{}, errNotConflicting
+	}
+	// a signer may seal the same number again in a later slot on a competing
+	// fork, after a missed turn, so only headers of the same slot conflict
+	if first.Time == nil || second.Time == nil || first.Time.Cmp(second.Time) != 0 {
+		return common.Address{}, errNotConflicting
+	}
+	number := first.Number.Uint64()
+	if number == 0 || number >= header.Number.Uint64() || header.Number.Uint64()-number > a.config.Epoch {
+		return common.Address{}, errEvidenceOutOfRange
+	}
+	signer, err := ecrecover(first, a.signatures)
+	if err != nil {
+		return common.Address{}, err
+	}
+	other, err := ecrecover(second, a.signatures)
+	if err != nil {
+		return common.Address{}, err
+	}
+	if signer != other || first.Coinbase != signer || second.Coinbase != signer {
+		return common.Address{}, errNotConflicting
+	}
+	if snap.isSlashed(signer) {
+		return common.Address{}, errAlreadySlashed
+	}
+	// the signer must have been in the signer queue at that block number of the
+	// chain of the header, not of the local canonical chain, as the evidence is
+	// verified again when side chain blocks get imported
+	ancestor := chain.GetHeader(header.ParentHash, header.Number.Uint64()-1)
+	for ancestor != nil && ancestor.Number.Uint64() > number {
+		ancestor = chain.GetHeader(ancestor.ParentHash, ancestor.Number.Uint64()-1)
+	}
+	if ancestor == nil {
+		return common.Address{}, errUnknownBlock
+	}
+	queue, err := a.signerQueue(ancestor)
+	if err != nil {
+		return common.Address{}, err
+	}
+	for _, s := range queue {
+		if s == signer {
+			return signer, nil
+		}
+	}
+	return common.Address{}, errEvidenceNotSigner
+}
+
+// slashVoterStake burns the part of a stake of a voter of a double signing
+// signer.
+func slashVoterStake(stake *big.Int) {
+	slash := new(big.Int).Mul(stake, big.NewInt(doubleSignVoterSlashPerThousand))
+	stake.Sub(stake, slash.Div(slash, big.NewInt(1000)))
+}
+
+// isSlashed checks if the address is a signer slashed for double signing.
+func (s *Snapshot) isSlashed(address common.Address) bool {
+	_, ok := s.Slashed[address]
+	return ok
+}
+
+// updateSnapshotByEvidences slashes the signers reported for double signing.
+func (s *Snapshot) updateSnapshotByEvidences(evidences []Evidence, headerNumber *big.Int) {
+	for _, evidence := range evidences {
+		signer := evidence.Signer
+		if s.isSlashed(signer) {
+			continue
+		}
+		s.Slashed[signer] = headerNumber.Uint64()
+
+		// burn the stake of the signer, unbonding or not
+		delete(s.Locked, signer)
+		for number, unbonding := range s.Unbonding {
+			delete(unbonding, signer)
+			if len(unbonding) == 0 {
+				delete(s.Unbonding, number)
+			}
+		}
+		// the vote of the signer had its stake
+		if vote, ok := s.Votes[signer]; ok && vote.Candidate != signer {
+			if tally, ok := s.Tally[vote.Candidate]; ok {
+				tally.Sub(tally, vote.Stake)
+			}
+			delete(s.Votes, signer)
+			delete(s.Voters, signer)
+		}
+		// slash the voters of the signer and drop their votes
+		for voter, vote := range s.Votes {
+			if vote.Candidate != signer {
+				continue
+			}
+			if locked, ok := s.Locked[voter]; ok {
+				slashVoterStake(locked)
+			}
+			for _, unbonding := range s.Unbonding {
+				if stake, ok := unbonding[voter]; ok {
+					slashVoterStake(stake)
+				}
+			}
+			delete(s.Votes, voter)
+			delete(s.Voters, voter)
+		}
+		delete(s.Tally, signer)
+		delete(s.Candidates, signer)
+	}
+}
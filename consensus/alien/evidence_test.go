@@ -0,0 +1,198 @@
+// Copyright 2018 The gttc Authors
+// This file is part of the gttc library.
+//
+// The gttc library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The gttc library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the gttc library. If not, see <http://www.gnu.org/licenses/>.
+
+package alien
+
+import (
+	"math/big"
+	"testing"
+
+	"github.com/awesome-chain/Xchain/common"
+	"github.com/awesome-chain/Xchain/core/state"
+	"github.com/awesome-chain/Xchain/core/types"
+	"github.com/awesome-chain/Xchain/ethdb"
+	"github.com/awesome-chain/Xchain/params"
+)
+
+// testerForkReader is a testerConfirmChain also serving the headers of a side
+// chain.
+type testerForkReader struct {
+	*testerConfirmChain
+	side map[common.Hash]*types.Header
+}
+
+func (r *testerForkReader) GetHeader(hash common.Hash, number uint64) *types.Header {
+	if header, ok := r.side[hash]; ok {
+		return header
+	}
+	return r.testerConfirmChain.GetHeader(hash, number)
+}
+
+// Tests that double sign evidence is verified against the signer queue and that
+// the snapshot slashes the reported signer and its voters.
+func TestDoubleSignEvidence(t *testing.T) {
+	config := *params.AllAlienProtocolChanges.Alien
+	config.Epoch = 100
+	config.MaxSignerCount = 3
+	config.StakingBlock = big.NewInt(0)
+	config.SlashingBlock = big.NewInt(8)
+
+	var (
+		db       = ethdb.NewMemDatabase()
+		alien    = New(&config, db)
+		accounts = newTesterAccountPool()
+		statedb  = func() *state.StateDB { s, _ := state.New(common.Hash{}, state.NewDatabase(db)); return s }()
+		queue    = []common.Address{accounts.address("A"), accounts.address("B"), accounts.address("A")}
+		reader   = &testerForkReader{testerConfirmChain: newTesterConfirmChain(t, &config, 12, queue), side: make(map[common.Hash]*types.Header)}
+	)
+	// newHeader creates a header sealed by the signer at the given number
+	newHeader := func(number int64, time int64, signer string, extra HeaderExtra) *types.Header {
+		header := &types.Header{
+			Number:   big.NewInt(number),
+			Time:     big.NewInt(time),
+			Coinbase: accounts.address(signer),
+			Extra:    make([]byte, extraVanity),
+		}
+		enc, err := encodeHeaderExtra(&config, header.Number, extra)
+		if err != nil {
+			t.Fatalf("failed to encode header extra: %v", err)
+		}
+		header.Extra = append(append(header.Extra, enc...), make([]byte, extraSeal)...)
+		accounts.sign(header, signer)
+		return header
+	}
+	// A side chain forks off at block 5, where C is in the signer queue instead
+	sideHead := reader.headers[4]
+	for number := int64(5); number < 10; number++ {
+		header := newHeader(number, 100+number, "C", HeaderExtra{SignerQueue: []common.Address{accounts.address("C")}})
+		header.ParentHash = sideHead.Hash()
+		reader.side[header.Hash()] = header
+		sideHead = header
+	}
+
+	snap := newSnapshot(&config, nil, common.Hash{}, []*Vote{
+		{Voter: accounts.address("A"), Candidate: accounts.address("A"), Stake: big.NewInt(500)},
+		{Voter: accounts.address("V"), Candidate: accounts.address("A"), Stake: big.NewInt(200)},
+		{Voter: accounts.address("W"), Candidate: accounts.address("B"), Stake: big.NewInt(300)},
+	}, defaultLoopCntRecalculateSigners)
+	snap.Locked[accounts.address("A")] = big.NewInt(500)
+	snap.Locked[accounts.address("V")] = big.NewInt(200)
+	snap.Locked[accounts.address("W")] = big.NewInt(300)
+	snap.Unbonding[50] = map[common.Address]*big.Int{accounts.address("A"): big.NewInt(100), accounts.address("V"): big.NewInt(50)}
+	snap.Unbonding[60] = map[common.Address]*big.Int{accounts.address("W"): big.NewInt(40)}
+
+	// report processes the evidence of the two headers reported in a block
+	reportOn := func(parent common.Hash, number int64, first, second *types.Header) (*HeaderExtra, RefundHash, error) {
+		data, err := EncodeCustomTx(&EvidencePayload{First: first, Second: second})
+		if err != nil {
+			t.Fatalf("failed to encode evidence: %v", err)
+		}
+		tx := types.NewTransaction(0, common.Address{}, big.NewInt(0), 0, big.NewInt(1), data)
+		extra, refund := new(HeaderExtra), make(RefundHash)
+		_, err = alien.processCustomTxV2(extra, reader, &types.Header{Number: big.NewInt(number), ParentHash: parent}, statedb, tx, accounts.address("R"), snap, refund)
+		return extra, refund, err
+	}
+	report := func(number int64, first, second *types.Header) (*HeaderExtra, RefundHash, error) {
+		var parent common.Hash
+		if header := reader.GetHeaderByNumber(uint64(number - 1)); header != nil {
+			parent = header.Hash()
+		}
+		return reportOn(parent, number, first, second)
+	}
+	// Conflicting headers are sealed in the same slot, differing in content
+	resealed := HeaderExtra{LoopStartTime: 1}
+	first, second := newHeader(5, 15, "A", HeaderExtra{}), newHeader(5, 15, "A", resealed)
+
+	tests := []struct {
+		number        int64
+		first, second *types.Header
+		err           error
+	}{
+		{7, first, second, errSlashingNotActive},
+		{10, first, nil, errInvalidEvidence},
+		{10, first, first, errNotConflicting},
+		{10, first, newHeader(6, 15, "A", HeaderExtra{}), errNotConflicting},
+		{10, first, newHeader(5, 15, "B", resealed), errNotConflicting},
+		{10, first, newHeader(5, 18, "A", HeaderExtra{}), errNotConflicting}, // same height in another slot, e.g. on a fork after a missed turn
+		{10, newHeader(10, 15, "A", HeaderExtra{}), newHeader(10, 15, "A", resealed), errEvidenceOutOfRange},
+		{106, first, second, errEvidenceOutOfRange},
+		{10, newHeader(5, 15, "C", HeaderExtra{}), newHeader(5, 15, "C", resealed), errEvidenceNotSigner},
+	}
+	for i, tt := range tests {
+		if _, _, err := report(tt.number, tt.first, tt.second); err != tt.err {
+			t.Errorf("test %d: error mismatch: have %v, want %v", i, err, tt.err)
+		}
+	}
+	// The signer queue is the one of the chain of the reporting block, not the
+	// one of the local canonical chain
+	cFirst, cSecond := newHeader(5, 15, "C", HeaderExtra{}), newHeader(5, 15, "C", resealed)
+	if _, _, err := reportOn(sideHead.Hash(), 10, cFirst, cSecond); err != nil {
+		t.Errorf("side chain evidence rejected: %v", err)
+	}
+	if _, _, err := reportOn(sideHead.Hash(), 10, first, second); err != errEvidenceNotSigner {
+		t.Errorf("side chain evidence of canonical signer error mismatch: have %v, want %v", err, errEvidenceNotSigner)
+	}
+	extra, refund, err := report(10, first, second)
+	if err != nil {
+		t.Fatalf("failed to report evidence: %v", err)
+	}
+	if len(extra.CurrentBlockEvidences) != 1 {
+		t.Fatalf("evidence count mismatch: have %d, want 1", len(extra.CurrentBlockEvidences))
+	}
+	if evidence := extra.CurrentBlockEvidences[0]; evidence.Signer != accounts.address("A") || evidence.Number != 5 || evidence.Reporter != accounts.address("R") {
+		t.Errorf("evidence mismatch: %+v", evidence)
+	}
+	if len(refund) != 1 {
+		t.Errorf("evidence gas not refunded")
+	}
+	// Slash the signer and check its stake, votes and voters
+	snap.updateSnapshotByEvidences(extra.CurrentBlockEvidences, big.NewInt(10))
+
+	if !snap.isSlashed(accounts.address("A")) {
+		t.Errorf("signer not slashed")
+	}
+	if _, ok := snap.Locked[accounts.address("A")]; ok || snap.Unbonding[50][accounts.address("A")] != nil {
+		t.Errorf("signer stake left: locked %v, unbonding %v", snap.Locked[accounts.address("A")], snap.Unbonding)
+	}
+	if locked := snap.Locked[accounts.address("V")]; locked.Cmp(big.NewInt(180)) != 0 {
+		t.Errorf("voter stake mismatch: have %v, want 180", locked)
+	}
+	if locked := snap.Locked[accounts.address("W")]; locked.Cmp(big.NewInt(300)) != 0 {
+		t.Errorf("other voter stake mismatch: have %v, want 300", locked)
+	}
+	if unbonding := snap.Unbonding[50][accounts.address("V")]; unbonding == nil || unbonding.Cmp(big.NewInt(45)) != 0 {
+		t.Errorf("voter unbonding stake mismatch: have %v, want 45", unbonding)
+	}
+	if unbonding := snap.Unbonding[60][accounts.address("W")]; unbonding.Cmp(big.NewInt(40)) != 0 {
+		t.Errorf("other voter unbonding stake mismatch: have %v, want 40", unbonding)
+	}
+	if _, ok := snap.Tally[accounts.address("A")]; ok || len(snap.Votes) != 1 || len(snap.Voters) != 1 {
+		t.Errorf("signer votes left: tally %v, votes %d", snap.Tally, len(snap.Votes))
+	}
+	if err := snap.verifyTallyCnt(); err != nil {
+		t.Errorf("tally inconsistent: %v", err)
+	}
+	// Slashed signers can't be voted into the signer queue nor reported again
+	snap.updateSnapshotByVotes([]Vote{{Voter: accounts.address("V"), Candidate: accounts.address("A"), Stake: big.NewInt(1)}}, big.NewInt(11))
+	for _, item := range snap.buildTallySlice() {
+		if item.addr == accounts.address("A") {
+			t.Errorf("slashed signer in tally slice")
+		}
+	}
+	if _, _, err := report(11, first, second); err != errAlreadySlashed {
+		t.Errorf("second report error mismatch: have %v, want %v", err, errAlreadySlashed)
+	}
+}
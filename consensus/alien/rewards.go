@@ -0,0 +1,81 @@
+// Copyright 2018 The gttc Authors
+// This file is part of the gttc library.
+//
+// The gttc library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The gttc library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the gttc library. If not, see <http://www.gnu.org/licenses/>.
+
+package alien
+
+import (
+	"errors"
+	"math/big"
+
+	"github.com/awesome-chain/Xchain/common"
+	"github.com/awesome-chain/Xchain/core/rawdb"
+	"github.com/awesome-chain/Xchain/core/state"
+)
+
+// The balances credited by Finalize outside of any transaction are recorded in
+// the reward ledger of the block, stored in the database indexed by address.
+// Finalize runs before the block is sealed, so the ledger is kept in memory by
+// the state root of the block and only stored once the block is written into
+// the chain.
+
+// Categories of the rewards recorded in the reward ledger.
+const (
+	RewardCategoryMiner          = "miner"          // Block reward of the signer
+	RewardCategoryVoter          = "voter"          // Block reward of the voters of the signer
+	RewardCategoryProposalRefund = "proposalRefund" // Refund of a proposal deposit
+	RewardCategoryUnbonding      = "unbonding"      // Release of unbonded stake
+	RewardCategorySideChain      = "sideChain"      // Block reward of a side chain coinbase
+	RewardCategoryGasRefund      = "gasRefund"      // Refund of the gas of custom transactions
+	RewardCategoryGasCharging    = "gasCharging"    // Gas charging on a side chain
+)
+
+var (
+	// errInvalidRewardRange is returned if the rewards are queried from a block
+	// after the last one.
+	errInvalidRewardRange = errors.New("invalid reward block range")
+
+	// errRewardRangeTooLarge is returned if the rewards are queried over more
+	// blocks than a single query searches.
+	errRewardRangeTooLarge = errors.New("reward block range too large")
+)
+
+// maxRewardQueryRange is the maximum number of blocks searched for rewards by a
+// single query.
+const maxRewardQueryRange = 10000
+
+// rewardLedger collects the rewards credited by a block.
+type rewardLedger map[common.Address][]rawdb.RewardEntry
+
+// credit adds a reward to the balance of the address and records it.
+func (l rewardLedger) credit(state *state.StateDB, address common.Address, amount *big.Int, category string, reason string) {
+	state.AddBalance(address, amount)
+	if amount.Sign() > 0 {
+		l[address] = append(l[address], rawdb.RewardEntry{
+			Category: category,
+			Amount:   new(big.Int).Set(amount),
+			Reason:   reason,
+		})
+	}
+}
+
+// RewardInfo is a reward credited to an address by a block.
+type RewardInfo struct {
+	Number   uint64      `json:"number"`   // Number of the block crediting the reward
+	Hash     common.Hash `json:"hash"`     // Hash of the block crediting the reward
+	Category string      `json:"category"` // Kind of reward
+	Amount   *big.Int    `json:"amount"`   // Amount credited
+	Reason   string      `json:"reason"`   // Origin of the reward
+}
@@ -0,0 +1,106 @@
+// Copyright 2018 The gttc Authors
+// This file is part of the gttc library.
+//
+// The gttc library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The gttc library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the gttc library. If not, see <http://www.gnu.org/licenses/>.
+
+package alien
+
+import (
+	"math/big"
+	"testing"
+
+	"github.com/awesome-chain/Xchain/common"
+	"github.com/awesome-chain/Xchain/core/rawdb"
+	"github.com/awesome-chain/Xchain/core/state"
+	"github.com/awesome-chain/Xchain/core/types"
+	"github.com/awesome-chain/Xchain/ethdb"
+	"github.com/awesome-chain/Xchain/params"
+)
+
+// Tests that every balance credited by the block rewards is recorded in the
+// reward ledger of the block.
+func TestRewardLedger(t *testing.T) {
+	var (
+		config   = params.AllAlienProtocolChanges
+		signer   = common.HexToAddress("0x0a")
+		voter    = common.HexToAddress("0x0b")
+		sender   = common.HexToAddress("0x0c")
+		statedb  = func() *state.StateDB { s, _ := state.New(common.Hash{}, state.NewDatabase(ethdb.NewMemDatabase())); return s }()
+		ledger   = make(rewardLedger)
+		header   = &types.Header{Number: big.NewInt(10), Coinbase: signer}
+		refunded = big.NewInt(1000)
+	)
+	snap := newSnapshot(config.Alien, nil, common.Hash{}, []*Vote{
+		{Voter: signer, Candidate: signer, Stake: big.NewInt(300)},
+		{Voter: voter, Candidate: signer, Stake: big.NewInt(100)},
+	}, defaultLoopCntRecalculateSigners)
+
+	if err := accumulateRewards(config, statedb, header, snap, RefundGas{sender: refunded}, ledger); err != nil {
+		t.Fatalf("failed to accumulate rewards: %v", err)
+	}
+	for _, address := range []common.Address{signer, voter, sender} {
+		recorded := big.NewInt(0)
+		for _, entry := range ledger[address] {
+			recorded.Add(recorded, entry.Amount)
+		}
+		if balance := statedb.GetBalance(address); balance.Sign() == 0 || balance.Cmp(recorded) != 0 {
+			t.Errorf("%x: recorded rewards mismatch: have %v, want %v", address, recorded, balance)
+		}
+	}
+	if entries := ledger[sender]; len(entries) != 1 || entries[0].Category != RewardCategoryGasRefund {
+		t.Errorf("gas refund mismatch: %+v", entries)
+	}
+	if entries := ledger[signer]; len(entries) != 2 || entries[0].Category != RewardCategoryVoter || entries[1].Category != RewardCategoryMiner {
+		t.Errorf("signer rewards mismatch: %+v", entries)
+	}
+}
+
+// Tests that the reward ledger of a finalized block is only stored once the
+// block is written into the chain, keyed by the hash of the block.
+func TestRewardLedgerWrite(t *testing.T) {
+	var (
+		db     = ethdb.NewMemDatabase()
+		alien  = New(params.AllAlienProtocolChanges.Alien, db)
+		signer = common.HexToAddress("0x0a")
+		root   = common.HexToHash("0x01")
+		// Blocks with the same state root, finalized with an unsealed extra-data
+		finalized = &types.Header{Number: big.NewInt(10), Time: big.NewInt(15), Root: root, Extra: make([]byte, extraVanity+extraSeal)}
+		dropped   = types.NewBlockWithHeader(&types.Header{Number: big.NewInt(10), Time: big.NewInt(18), Root: root, Extra: make([]byte, extraVanity+extraSeal)})
+	)
+	// Both blocks are finalized, but only one is written into the chain once sealed
+	for i, header := range []*types.Header{finalized, dropped.Header()} {
+		alien.rewards.Add(ledgerKey(header), rewardLedger{signer: {{Category: RewardCategoryMiner, Amount: big.NewInt(int64(i + 1))}}})
+	}
+	sealed := types.CopyHeader(finalized)
+	sealed.Extra[len(sealed.Extra)-1] = 0x01
+	written := types.NewBlockWithHeader(sealed)
+
+	if entries := rawdb.ReadRewards(db, signer, 10, written.Hash()); entries != nil {
+		t.Fatalf("rewards stored before the block is written: %+v", entries)
+	}
+	alien.WriteBlock(db, written)
+
+	if entries := rawdb.ReadRewards(db, signer, 10, written.Hash()); len(entries) != 1 || entries[0].Amount.Int64() != 1 {
+		t.Errorf("written block rewards mismatch: %+v", entries)
+	}
+	if entries := rawdb.ReadRewards(db, signer, 10, dropped.Hash()); entries != nil {
+		t.Errorf("rewards stored for a dropped block: %+v", entries)
+	}
+	// Blocks not finalized locally have no ledger to store
+	unknown := types.NewBlockWithHeader(&types.Header{Number: big.NewInt(11)})
+	alien.WriteBlock(db, unknown)
+	if entries := rawdb.ReadRewards(db, signer, 11, unknown.Hash()); entries != nil {
+		t.Errorf("rewards stored for an unknown ledger: %+v", entries)
+	}
+}
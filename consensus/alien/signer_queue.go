@@ -84,6 +84,9 @@ func (s *Snapshot) verifySignerQueue(signerQueue []common.Address) error {
 func (s *Snapshot) buildTallySlice() TallySlice {
 	var tallySlice TallySlice
 	for address, stake := range s.Tally {
+		if s.isSlashed(address) {
+			continue
+		}
 		if !candidateNeedPD || s.isCandidate(address) {
 			if _, ok := s.Punished[address]; ok {
 				var creditWeight uint64
@@ -143,6 +143,9 @@ type Snapshot struct {
 	LocalNotice     *CCNotice                                         `json:"localNotice"`       // side chain record Notification
 	MinerReward     uint64                                            `json:"minerReward"`       // miner reward per thousand
 	MinVB           *big.Int                                          `json:"minVoterBalance"`   // min voter balance
+	Locked          map[common.Address]*big.Int                       `json:"locked"`            // Stake locked by each staker after the staking fork
+	Unbonding       map[uint64]map[common.Address]*big.Int            `json:"unbonding"`         // Unbonded stake released at given block number
+	Slashed         map[common.Address]uint64                         `json:"slashed"`           // Block number each signer was slashed for double signing at
 }
 
 // newSnapshot creates a new snapshot with the specified startup parameters. only ever use if for
@@ -176,6 +179,9 @@ func newSnapshot(config *params.AlienConfig, sigcache *lru.ARCCache, hash common
 		ProposalRefund:  make(map[uint64]map[common.Address]*big.Int),
 		MinerReward:     minerRewardPerThousand,
 		MinVB:           config.MinVoterBalance,
+		Locked:          make(map[common.Address]*big.Int),
+		Unbonding:       make(map[uint64]map[common.Address]*big.Int),
+		Slashed:         make(map[common.Address]uint64),
 	}
 	snap.HistoryHash = append(snap.HistoryHash, hash)
 
@@ -228,6 +234,16 @@ func loadSnapshot(config *params.AlienConfig, sigcache *lru.ARCCache, db ethdb.D
 	if snap.MinVB == nil {
 		snap.MinVB = new(big.Int).Set(minVoterBalance)
 	}
+	// snapshots stored before the staking fork have no stake
+	if snap.Locked == nil {
+		snap.Locked = make(map[common.Address]*big.Int)
+	}
+	if snap.Unbonding == nil {
+		snap.Unbonding = make(map[uint64]map[common.Address]*big.Int)
+	}
+	if snap.Slashed == nil {
+		snap.Slashed = make(map[common.Address]uint64)
+	}
 	return snap, nil
 }
 
@@ -269,6 +285,9 @@ func (s *Snapshot) copy() *Snapshot {
 		SCNoticeMap:    make(map[common.Hash]*CCNotice),
 		LocalNotice:    &CCNotice{CurrentCharging: make(map[common.Hash]GasCharging), ConfirmReceived: make(map[common.Hash]NoticeCR)},
 		ProposalRefund: make(map[uint64]map[common.Address]*big.Int),
+		Locked:         make(map[common.Address]*big.Int),
+		Unbonding:      make(map[uint64]map[common.Address]*big.Int),
+		Slashed:        make(map[common.Address]uint64),
 
 		MinerReward: s.MinerReward,
 		MinVB:       nil,
@@ -371,6 +390,18 @@ func (s *Snapshot) copy() *Snapshot {
 			cpy.ProposalRefund[number][proposer] = new(big.Int).Set(deposit)
 		}
 	}
+	for staker, stake := range s.Locked {
+		cpy.Locked[staker] = new(big.Int).Set(stake)
+	}
+	for number, unbonding := range s.Unbonding {
+		cpy.Unbonding[number] = make(map[common.Address]*big.Int)
+		for staker, stake := range unbonding {
+			cpy.Unbonding[number][staker] = new(big.Int).Set(stake)
+		}
+	}
+	for signer, number := range s.Slashed {
+		cpy.Slashed[signer] = number
+	}
 	// miner reward per thousand proposal must larger than 0
 	// so minerReward is zeron only when update the program
 	if s.MinerReward == 0 {
@@ -436,12 +467,21 @@ func (s *Snapshot) apply(headers []*types.Header) (*Snapshot, error) {
 		// deal the new confirmation in this block
 		snap.updateSnapshotByConfirmations(headerExtra.CurrentBlockConfirmations)
 
+		// deal the votes cast before the staking fork, before the stake locks
+		snap.updateSnapshotByStakingFork(header.Number)
+
+		// deal the stake locked and unbonded, before the votes using it
+		snap.updateSnapshotByStakeLocks(headerExtra.CurrentBlockStakeLocks, header.Number)
+
 		// deal the new vote from voter
 		snap.updateSnapshotByVotes(headerExtra.CurrentBlockVotes, header.Number)
 
 		// deal the voter which balance modified
 		snap.updateSnapshotByMPVotes(headerExtra.ModifyPredecessorVotes)
 
+		// deal the double sign evidences, after the votes for the slashed signers
+		snap.updateSnapshotByEvidences(headerExtra.CurrentBlockEvidences, header.Number)
+
 		// deal the snap related with punished
 		snap.updateSnapshotForPunish(headerExtra.SignerMissing, header.Number, header.Coinbase)
 
@@ -1031,9 +1071,15 @@ func (s *Snapshot) updateSnapshotByConfirmations(confirmations []Confirmation) {
 
 func (s *Snapshot) updateSnapshotByVotes(votes []Vote, headerNumber *big.Int) {
 	for _, vote := range votes {
+		// the stake of a vote is the stake locked by the end of the block
+		if s.config.IsStaking(headerNumber) {
+			vote.Stake = s.lockedStake(vote.Voter, nil)
+		}
 		// update Votes, Tally, Voters data
 		if lastVote, ok := s.Votes[vote.Voter]; ok {
-			s.Tally[lastVote.Candidate].Sub(s.Tally[lastVote.Candidate], lastVote.Stake)
+			if tally, ok := s.Tally[lastVote.Candidate]; ok {
+				tally.Sub(tally, lastVote.Stake)
+			}
 		}
 		if _, ok := s.Tally[vote.Candidate]; ok {
 
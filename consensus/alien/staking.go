@@ -0,0 +1,148 @@
+// Copyright 2018 The gttc Authors
+// This file is part of the gttc library.
+//
+// The gttc library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The gttc library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the gttc library. If not, see <http://www.gnu.org/licenses/>.
+
+package alien
+
+import (
+	"math/big"
+
+	"github.com/awesome-chain/Xchain/common"
+	"github.com/awesome-chain/Xchain/core/state"
+	"github.com/awesome-chain/Xchain/core/types"
+)
+
+// After the staking fork the stake of a vote is the stake locked by the voter
+// instead of its balance, which it can spend freely. Locking moves the stake
+// out of the balance of the staker into the snapshot, unbonding schedules its
+// release back into the balance once the unbonding period is over.
+//
+// At the fork block the stake of the votes cast before it is reset to the stake
+// locked by their voters, which is none until they lock some, so that funds
+// spent since don't keep counting.
+
+// voteStake returns the stake of a vote cast by the voter in the block being
+// built: its balance before the staking fork, the stake it has locked by now
+// after it.
+func (a *Alien) voteStake(headerExtra *HeaderExtra, header *types.Header, state *state.StateDB, snap *Snapshot, voter common.Address) *big.Int {
+	if !a.config.IsStaking(header.Number) {
+		a.lock.RLock()
+		defer a.lock.RUnlock()
+
+		return state.GetBalance(voter)
+	}
+	return snap.lockedStake(voter, headerExtra.CurrentBlockStakeLocks)
+}
+
+// unbondingPeriod returns the number of blocks unbonded stake stays locked.
+func (s *Snapshot) unbondingPeriod() uint64 {
+	if s.config.UnbondingPeriod > 0 {
+		return s.config.UnbondingPeriod
+	}
+	if s.config.Epoch > 0 {
+		return s.config.Epoch
+	}
+	return 1
+}
+
+// lockedStake returns the stake locked by the staker, after the pending locks
+// and unbonds not yet applied to the snapshot.
+func (s *Snapshot) lockedStake(staker common.Address, pending []StakeLock) *big.Int {
+	stake := big.NewInt(0)
+	if locked, ok := s.Locked[staker]; ok {
+		stake.Set(locked)
+	}
+	for _, lock := range pending {
+		if lock.Staker != staker {
+			continue
+		}
+		if lock.Unbond {
+			stake.Sub(stake, lock.Amount)
+		} else {
+			stake.Add(stake, lock.Amount)
+		}
+	}
+	if stake.Sign() < 0 {
+		stake.SetInt64(0)
+	}
+	return stake
+}
+
+// updateSnapshotByStakingFork resets the stake of every vote to the stake locked
+// by its voter at the staking fork block, moving the tallies accordingly.
+func (s *Snapshot) updateSnapshotByStakingFork(headerNumber *big.Int) {
+	if s.config.StakingBlock == nil || s.config.StakingBlock.Cmp(headerNumber) != 0 {
+		return
+	}
+	for voter, vote := range s.Votes {
+		stake := s.lockedStake(voter, nil)
+		if _, ok := s.Tally[vote.Candidate]; !ok {
+			s.Tally[vote.Candidate] = big.NewInt(0)
+		}
+		s.Tally[vote.Candidate].Add(s.Tally[vote.Candidate], new(big.Int).Sub(stake, vote.Stake))
+		s.Votes[voter] = &Vote{Voter: vote.Voter, Candidate: vote.Candidate, Stake: stake}
+	}
+}
+
+// updateSnapshotByStakeLocks applies the stake locked and unbonded in a block,
+// updating the stake of the votes of the stakers accordingly.
+func (s *Snapshot) updateSnapshotByStakeLocks(locks []StakeLock, headerNumber *big.Int) {
+	// the stake released at this block was refunded when it was finalized
+	delete(s.Unbonding, headerNumber.Uint64())
+
+	for _, lock := range locks {
+		before := s.lockedStake(lock.Staker, nil)
+		after := s.lockedStake(lock.Staker, []StakeLock{lock})
+		if after.Sign() > 0 {
+			s.Locked[lock.Staker] = after
+		} else {
+			delete(s.Locked, lock.Staker)
+		}
+		if lock.Unbond {
+			released := new(big.Int).Sub(before, after)
+			number := headerNumber.Uint64() + s.unbondingPeriod()
+			if _, ok := s.Unbonding[number]; !ok {
+				s.Unbonding[number] = make(map[common.Address]*big.Int)
+			}
+			if _, ok := s.Unbonding[number][lock.Staker]; !ok {
+				s.Unbonding[number][lock.Staker] = big.NewInt(0)
+			}
+			s.Unbonding[number][lock.Staker].Add(s.Unbonding[number][lock.Staker], released)
+		}
+		// move the vote of the staker with its stake
+		vote, ok := s.Votes[lock.Staker]
+		if !ok {
+			continue
+		}
+		stake := new(big.Int).Add(vote.Stake, new(big.Int).Sub(after, before))
+		if stake.Sign() < 0 {
+			stake.SetInt64(0)
+		}
+		if _, ok := s.Tally[vote.Candidate]; !ok {
+			s.Tally[vote.Candidate] = big.NewInt(0)
+		}
+		s.Tally[vote.Candidate].Add(s.Tally[vote.Candidate], new(big.Int).Sub(stake, vote.Stake))
+		s.Votes[lock.Staker] = &Vote{Voter: vote.Voter, Candidate: vote.Candidate, Stake: stake}
+	}
+}
+
+// calculateUnbondingRelease returns the unbonded stake released at the given
+// block number.
+func (s *Snapshot) calculateUnbondingRelease(number uint64) map[common.Address]*big.Int {
+	if release, ok := s.Unbonding[number]; ok {
+		return release
+	}
+	return make(map[common.Address]*big.Int)
+}
@@ -0,0 +1,284 @@
+// Copyright 2018 The gttc Authors
+// This file is part of the gttc library.
+//
+// The gttc library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The gttc library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the gttc library. If not, see <http://www.gnu.org/licenses/>.
+
+package alien
+
+import (
+	"bytes"
+	"math/big"
+	"reflect"
+	"testing"
+
+	"github.com/awesome-chain/Xchain/common"
+	"github.com/awesome-chain/Xchain/core/state"
+	"github.com/awesome-chain/Xchain/core/types"
+	"github.com/awesome-chain/Xchain/crypto"
+	"github.com/awesome-chain/Xchain/ethdb"
+	"github.com/awesome-chain/Xchain/params"
+	"github.com/awesome-chain/Xchain/rlp"
+)
+
+// newStakingConfig returns an alien config with locked staking from block 10.
+func newStakingConfig() *params.AlienConfig {
+	config := *params.AllAlienProtocolChanges.Alien
+	config.StakingBlock = big.NewInt(10)
+	config.UnbondingPeriod = 5
+	config.MaxSignerCount = 1
+	config.MinVoterBalance = big.NewInt(100)
+	return &config
+}
+
+// Tests that header extras keep their encoding before the staking fork and
+// carry the stake locks after it.
+func TestStakingHeaderExtraEncoding(t *testing.T) {
+	config := newStakingConfig()
+	extra := HeaderExtra{
+		LoopStartTime: 42,
+		SignerQueue:   []common.Address{common.HexToAddress("0x01")},
+	}
+	// Before the fork the encoding is the legacy one
+	enc, err := encodeHeaderExtra(config, big.NewInt(9), extra)
+	if err != nil {
+		t.Fatalf("failed to encode legacy extra: %v", err)
+	}
+	var decoded HeaderExtra
+	if err := decodeHeaderExtra(params.AllAlienProtocolChanges.Alien, big.NewInt(9), enc, &decoded); err != nil {
+		t.Fatalf("legacy extra not decodable without the fork: %v", err)
+	}
+	if err := decodeHeaderExtra(config, big.NewInt(9), enc, &decoded); err != nil {
+		t.Fatalf("failed to decode legacy extra: %v", err)
+	}
+	if decoded.LoopStartTime != 42 || !reflect.DeepEqual(decoded.SignerQueue, extra.SignerQueue) {
+		t.Errorf("legacy extra mismatch: have %+v, want %+v", decoded, extra)
+	}
+	// After the fork the stake locks are part of it
+	extra.CurrentBlockStakeLocks = []StakeLock{{Staker: common.HexToAddress("0x02"), Amount: big.NewInt(7), Unbond: true}}
+	if enc, err = encodeHeaderExtra(config, big.NewInt(10), extra); err != nil {
+		t.Fatalf("failed to encode extra: %v", err)
+	}
+	decoded = HeaderExtra{}
+	if err := decodeHeaderExtra(config, big.NewInt(10), enc, &decoded); err != nil {
+		t.Fatalf("failed to decode extra: %v", err)
+	}
+	if decoded.LoopStartTime != 42 || !reflect.DeepEqual(decoded.CurrentBlockStakeLocks, extra.CurrentBlockStakeLocks) {
+		t.Errorf("extra mismatch: have %+v, want %+v", decoded, extra)
+	}
+	if err := decodeHeaderExtra(config, big.NewInt(9), enc, &decoded); err == nil {
+		t.Errorf("extra decoded as legacy")
+	}
+}
+
+// Tests that each fork appends its field to the header extra, and that extras
+// of another fork are refused.
+func TestHeaderExtraForkFields(t *testing.T) {
+	config := *params.AllAlienProtocolChanges.Alien
+	config.StakingBlock, config.SlashingBlock, config.ConfirmBlock = big.NewInt(10), big.NewInt(20), big.NewInt(30)
+
+	extra := HeaderExtra{
+		LoopStartTime:           42,
+		CurrentBlockStakeLocks:  []StakeLock{{Staker: common.HexToAddress("0x02"), Amount: big.NewInt(7)}},
+		CurrentBlockEvidences:   []Evidence{{Signer: common.HexToAddress("0x03"), Number: 5}},
+		ConfirmationCertificate: ConfirmationCertificate{Number: 6, Signatures: [][]byte{{0x01}}},
+	}
+	for i, number := range []int64{9, 10, 20, 30} {
+		enc, err := encodeHeaderExtra(&config, big.NewInt(number), extra)
+		if err != nil {
+			t.Fatalf("block %d: failed to encode: %v", number, err)
+		}
+		content, _, _ := rlp.SplitList(enc)
+		if count, _ := rlp.CountValues(content); count != headerExtraBaseFields+i {
+			t.Errorf("block %d: field count mismatch: have %d, want %d", number, count, headerExtraBaseFields+i)
+		}
+		var decoded HeaderExtra
+		if err := decodeHeaderExtra(&config, big.NewInt(number), enc, &decoded); err != nil {
+			t.Fatalf("block %d: failed to decode: %v", number, err)
+		}
+		// Only the fields of the active forks survive the round trip
+		want := HeaderExtra{LoopStartTime: extra.LoopStartTime}
+		v, w := reflect.ValueOf(extra), reflect.ValueOf(&want).Elem()
+		for j := headerExtraBaseFields; j < headerExtraBaseFields+i; j++ {
+			w.Field(j).Set(v.Field(j))
+		}
+		if have, expect := fullEncoding(t, decoded), fullEncoding(t, want); !bytes.Equal(have, expect) {
+			t.Errorf("block %d: extra mismatch: have %x, want %x", number, have, expect)
+		}
+		for _, other := range []int64{9, 10, 20, 30} {
+			if other != number {
+				if err := decodeHeaderExtra(&config, big.NewInt(other), enc, &decoded); err == nil {
+					t.Errorf("block %d: extra decoded at block %d", number, other)
+				}
+			}
+		}
+	}
+}
+
+// fullEncoding encodes all the fields of a header extra, empty and nil lists alike.
+func fullEncoding(t *testing.T, extra HeaderExtra) []byte {
+	enc, err := rlp.EncodeToBytes(extra)
+	if err != nil {
+		t.Fatalf("failed to encode extra: %v", err)
+	}
+	return enc
+}
+
+// Tests that locking and unbonding stake moves funds out of the balance, and
+// that the snapshot tallies the locked stake and releases the unbonded one.
+func TestStakingLockUnbond(t *testing.T) {
+	var (
+		config    = newStakingConfig()
+		alien     = &Alien{config: config}
+		key, _    = crypto.GenerateKey()
+		staker    = crypto.PubkeyToAddress(key.PublicKey)
+		candidate = common.HexToAddress("0xca")
+		signer    = types.HomesteadSigner{}
+	)
+	statedb, _ := state.New(common.Hash{}, state.NewDatabase(ethdb.NewMemDatabase()))
+	statedb.AddBalance(staker, big.NewInt(1000))
+
+	snap := newSnapshot(config, nil, common.Hash{}, []*Vote{{Voter: candidate, Candidate: candidate, Stake: big.NewInt(500)}}, defaultLoopCntRecalculateSigners)
+	snap.Candidates[candidate] = candidateStateNormal
+
+	// process applies typed custom txs of the staker to a block
+	process := func(number int64, nonce uint64, payload interface{}) (*HeaderExtra, error) {
+		data, err := EncodeCustomTx(payload)
+		if err != nil {
+			t.Fatalf("failed to encode payload: %v", err)
+		}
+		tx, _ := types.SignTx(types.NewTransaction(nonce, candidate, big.NewInt(0), 0, big.NewInt(0), data), signer, key)
+		extra := new(HeaderExtra)
+		_, err = alien.processCustomTxV2(extra, nil, &types.Header{Number: big.NewInt(number)}, statedb, tx, staker, snap, RefundHash{})
+		return extra, err
+	}
+	// Stake can't be locked before the fork, nor beyond the balance
+	if _, err := process(9, 0, &LockPayload{Amount: big.NewInt(300)}); err != errStakingNotActive {
+		t.Fatalf("lock before fork error mismatch: have %v, want %v", err, errStakingNotActive)
+	}
+	if _, err := process(10, 0, &LockPayload{Amount: big.NewInt(1001)}); err != errInsufficientStakeBalance {
+		t.Fatalf("lock beyond balance error mismatch: have %v, want %v", err, errInsufficientStakeBalance)
+	}
+	// Votes need locked stake after the fork, whatever the balance
+	if _, err := process(10, 0, &VotePayload{}); err != errLowVoterBalance {
+		t.Fatalf("vote without stake error mismatch: have %v, want %v", err, errLowVoterBalance)
+	}
+	extra, err := process(10, 0, &LockPayload{Amount: big.NewInt(300)})
+	if err != nil {
+		t.Fatalf("failed to lock: %v", err)
+	}
+	if balance := statedb.GetBalance(staker); balance.Cmp(big.NewInt(700)) != 0 {
+		t.Errorf("balance after lock mismatch: have %v, want 700", balance)
+	}
+	snap.updateSnapshotByStakeLocks(extra.CurrentBlockStakeLocks, big.NewInt(10))
+
+	// Once locked, the stake is voted, and transfers don't change it
+	extra, err = process(11, 1, &VotePayload{})
+	if err != nil {
+		t.Fatalf("failed to vote: %v", err)
+	}
+	statedb.SubBalance(staker, big.NewInt(700))
+	snap.updateSnapshotByVotes(extra.CurrentBlockVotes, big.NewInt(11))
+	if tally := snap.Tally[candidate]; tally.Cmp(big.NewInt(800)) != 0 {
+		t.Errorf("tally after vote mismatch: have %v, want 800", tally)
+	}
+	// Stake can't be unbonded beyond the locked one
+	if _, err := process(12, 2, &UnbondPayload{Amount: big.NewInt(301)}); err != errInsufficientLockedStake {
+		t.Fatalf("unbond beyond stake error mismatch: have %v, want %v", err, errInsufficientLockedStake)
+	}
+	if extra, err = process(12, 2, &UnbondPayload{Amount: big.NewInt(100)}); err != nil {
+		t.Fatalf("failed to unbond: %v", err)
+	}
+	snap.updateSnapshotByStakeLocks(extra.CurrentBlockStakeLocks, big.NewInt(12))
+	if tally := snap.Tally[candidate]; tally.Cmp(big.NewInt(700)) != 0 {
+		t.Errorf("tally after unbond mismatch: have %v, want 700", tally)
+	}
+	if err := snap.verifyTallyCnt(); err != nil {
+		t.Errorf("tally inconsistent: %v", err)
+	}
+	// The unbonded stake is released after the unbonding period only
+	if release := snap.calculateUnbondingRelease(16); len(release) != 0 {
+		t.Errorf("stake released early: %v", release)
+	}
+	if release := snap.calculateUnbondingRelease(17); release[staker] == nil || release[staker].Cmp(big.NewInt(100)) != 0 {
+		t.Errorf("released stake mismatch: have %v, want 100", release[staker])
+	}
+	// Unbonding with no amount unbonds all the rest
+	if extra, err = process(13, 3, &UnbondPayload{}); err != nil {
+		t.Fatalf("failed to unbond all: %v", err)
+	}
+	snap.updateSnapshotByStakeLocks(extra.CurrentBlockStakeLocks, big.NewInt(13))
+	if _, ok := snap.Locked[staker]; ok {
+		t.Errorf("stake left after unbonding all: %v", snap.Locked[staker])
+	}
+	if stake := snap.Votes[staker].Stake; stake.Sign() != 0 {
+		t.Errorf("vote stake after unbonding all mismatch: have %v, want 0", stake)
+	}
+	snap.updateSnapshotByStakeLocks(nil, big.NewInt(17))
+	if _, ok := snap.Unbonding[17]; ok {
+		t.Errorf("released stake not cleared")
+	}
+}
+
+// Tests that votes cast before the staking fork lose their balance based stake
+// at the fork block, so that locking after such a vote counts the lock alone.
+func TestStakingForkResetsVotes(t *testing.T) {
+	var (
+		config    = newStakingConfig()
+		alien     = &Alien{config: config}
+		key, _    = crypto.GenerateKey()
+		voter     = crypto.PubkeyToAddress(key.PublicKey)
+		candidate = common.HexToAddress("0xca")
+		signer    = types.HomesteadSigner{}
+	)
+	statedb, _ := state.New(common.Hash{}, state.NewDatabase(ethdb.NewMemDatabase()))
+	statedb.AddBalance(voter, big.NewInt(1000))
+
+	snap := newSnapshot(config, nil, common.Hash{}, []*Vote{{Voter: candidate, Candidate: candidate, Stake: big.NewInt(500)}}, defaultLoopCntRecalculateSigners)
+	snap.Candidates[candidate] = candidateStateNormal
+
+	// Before the fork the vote is staked with the balance
+	snap.updateSnapshotByVotes([]Vote{{Voter: voter, Candidate: candidate, Stake: statedb.GetBalance(voter)}}, big.NewInt(8))
+	if tally := snap.Tally[candidate]; tally.Cmp(big.NewInt(1500)) != 0 {
+		t.Fatalf("tally before fork mismatch: have %v, want 1500", tally)
+	}
+	snap.updateSnapshotByStakingFork(big.NewInt(9))
+	if tally := snap.Tally[candidate]; tally.Cmp(big.NewInt(1500)) != 0 {
+		t.Fatalf("tally reset before fork: have %v, want 1500", tally)
+	}
+	// Locking in the fork block replaces the balance stake instead of adding to it
+	data, err := EncodeCustomTx(&LockPayload{Amount: big.NewInt(300)})
+	if err != nil {
+		t.Fatalf("failed to encode payload: %v", err)
+	}
+	tx, _ := types.SignTx(types.NewTransaction(0, candidate, big.NewInt(0), 0, big.NewInt(0), data), signer, key)
+	extra := new(HeaderExtra)
+	if _, err := alien.processCustomTxV2(extra, nil, &types.Header{Number: big.NewInt(10)}, statedb, tx, voter, snap, RefundHash{}); err != nil {
+		t.Fatalf("failed to lock: %v", err)
+	}
+	snap.updateSnapshotByStakingFork(big.NewInt(10))
+	snap.updateSnapshotByStakeLocks(extra.CurrentBlockStakeLocks, big.NewInt(10))
+
+	if stake := snap.Votes[voter].Stake; stake.Cmp(big.NewInt(300)) != 0 {
+		t.Errorf("vote stake mismatch: have %v, want 300", stake)
+	}
+	if stake := snap.Votes[candidate].Stake; stake.Sign() != 0 {
+		t.Errorf("unlocked vote stake mismatch: have %v, want 0", stake)
+	}
+	if tally := snap.Tally[candidate]; tally.Cmp(big.NewInt(300)) != 0 {
+		t.Errorf("tally after fork mismatch: have %v, want 300", tally)
+	}
+	if err := snap.verifyTallyCnt(); err != nil {
+		t.Errorf("tally inconsistent: %v", err)
+	}
+}
@@ -23,6 +23,7 @@ import (
 	"github.com/awesome-chain/Xchain/common"
 	"github.com/awesome-chain/Xchain/core/state"
 	"github.com/awesome-chain/Xchain/core/types"
+	"github.com/awesome-chain/Xchain/ethdb"
 	"github.com/awesome-chain/Xchain/params"
 	"github.com/awesome-chain/Xchain/rpc"
 )
@@ -105,3 +106,13 @@ type PoW interface {
 	// Hashrate returns the current mining hashrate of a PoW consensus engine.
 	Hashrate() float64
 }
+
+// BlockWriter is a consensus engine storing data of its own along with the
+// blocks written into the chain.
+type BlockWriter interface {
+	Engine
+
+	// WriteBlock stores the engine data of a block finalized by the engine,
+	// batched with the write of the block itself.
+	WriteBlock(db ethdb.Putter, block *types.Block)
+}
@@ -898,6 +898,9 @@ func (bc *BlockChain) WriteBlockWithState(block *types.Block, receipts []*types.
 	// Write other block data using a batch.
 	batch := bc.db.NewBatch()
 	rawdb.WriteBlock(batch, block)
+	if writer, ok := bc.engine.(consensus.BlockWriter); ok {
+		writer.WriteBlock(batch, block)
+	}
 
 	root, err := state.Commit(bc.chainConfig.IsEIP158(block.Number()))
 	if err != nil {
@@ -955,6 +958,12 @@ func (bc *BlockChain) WriteBlockWithState(block *types.Block, receipts []*types.
 			}
 		}
 	}
+	// Logs attached by the engine on finalization don't know the block hash yet
+	for _, receipt := range receipts {
+		for _, log := range receipt.Logs {
+			log.BlockHash = block.Hash()
+		}
+	}
 	rawdb.WriteReceipts(batch, block.Hash(), block.NumberU64(), receipts)
 
 	// If the total difficulty is higher than our known, add it to the canonical chain
@@ -1009,6 +1018,10 @@ func (bc *BlockChain) InsertChain(chain types.Blocks) (int, error) {
 // only reason this method exists as a separate one is to make locking cleaner
 // with deferred statements.
 func (bc *BlockChain) insertChain(chain types.Blocks) (int, []interface{}, []*types.Log, error) {
+	// If the chain is empty, there's nothing to do
+	if len(chain) == 0 {
+		return 0, nil, nil, nil
+	}
 	// Do a sanity check that the provided chain is actually ordered and linked
 	for i := 1; i < len(chain); i++ {
 		if chain[i].NumberU64() != chain[i-1].NumberU64()+1 || chain[i].ParentHash() != chain[i-1].Hash() {
@@ -1047,6 +1060,9 @@ func (bc *BlockChain) insertChain(chain types.Blocks) (int, []interface{}, []*ty
 	abort, results := bc.engine.VerifyHeaders(bc, headers, seals)
 	defer close(abort)
 
+	// Start a parallel signature recovery (signer will fluke on fork transition, minimal perf loss)
+	senderCacher.recoverFromBlocks(types.MakeSigner(bc.chainConfig, chain[0].Number()), chain)
+
 	// Iterate over the blocks and insert when the verifier permits
 	for i, block := range chain {
 		// If the chain is terminating, stop processing blocks
@@ -1352,6 +1368,42 @@ func (bc *BlockChain) reorg(oldBlock, newBlock *types.Block) error {
 	return nil
 }
 
+// SetCanonical makes a known block the head of the chain irrelevant of the total
+// difficulties, reorganising away the current head and any canonical blocks
+// above it. Agreement based engines decide the canonical block by certificate,
+// blocks of the same height tie on difficulty.
+func (bc *BlockChain) SetCanonical(block *types.Block) error {
+	bc.chainmu.Lock()
+	defer bc.chainmu.Unlock()
+
+	if !bc.HasBlockAndState(block.Hash(), block.NumberU64()) {
+		return fmt.Errorf("unknown block #%d [%x…]", block.NumberU64(), block.Hash().Bytes()[:4])
+	}
+	bc.mu.Lock()
+	currentBlock := bc.CurrentBlock()
+	if currentBlock.Hash() == block.Hash() {
+		bc.mu.Unlock()
+		return nil
+	}
+	if block.ParentHash() != currentBlock.Hash() {
+		if err := bc.reorg(currentBlock, block); err != nil {
+			bc.mu.Unlock()
+			return err
+		}
+	}
+	// Drop the canonical entries of the old chain above the new head
+	for number := block.NumberU64() + 1; rawdb.ReadCanonicalHash(bc.db, number) != (common.Hash{}); number++ {
+		rawdb.DeleteCanonicalHash(bc.db, number)
+	}
+	rawdb.WriteTxLookupEntries(bc.db, block)
+	bc.insert(block)
+	bc.mu.Unlock()
+
+	log.Info("Set canonical head", "number", block.Number(), "hash", block.Hash(), "dropfrom", currentBlock.Number())
+	bc.PostChainEvents([]interface{}{ChainEvent{block, block.Hash(), nil}, ChainHeadEvent{block}}, nil)
+	return nil
+}
+
 // PostChainEvents iterates over the events generated by a chain insertion and
 // posts them into the event feed.
 // TODO: Should not expose PostChainEvents. The chain events should be posted in WriteBlock.
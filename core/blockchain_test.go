@@ -25,6 +25,7 @@ import (
 	"time"
 
 	"github.com/awesome-chain/Xchain/common"
+	"github.com/awesome-chain/Xchain/consensus"
 	"github.com/awesome-chain/Xchain/consensus/ethash"
 	"github.com/awesome-chain/Xchain/core/rawdb"
 	"github.com/awesome-chain/Xchain/core/state"
@@ -179,6 +180,49 @@ func TestLastBlock(t *testing.T) {
 	}
 }
 
+// testWriterEngine is a consensus engine recording the blocks handed over to it
+// to store its own data along with.
+type testWriterEngine struct {
+	consensus.Engine
+	written []common.Hash
+}
+
+func (e *testWriterEngine) WriteBlock(db ethdb.Putter, block *types.Block) {
+	e.written = append(e.written, block.Hash())
+}
+
+// Tests that an engine storing data of its own is handed every block written
+// into the chain, canonical or not, and only those.
+func TestBlockWriter(t *testing.T) {
+	engine := &testWriterEngine{Engine: ethash.NewFaker()}
+	db, blockchain, err := newCanonical(engine, 0, true)
+	if err != nil {
+		t.Fatalf("failed to create pristine chain: %v", err)
+	}
+	defer blockchain.Stop()
+
+	blocks := makeBlockChain(blockchain.CurrentBlock(), 3, ethash.NewFaker(), db, 0)
+	side := makeBlockChain(blockchain.CurrentBlock(), 2, ethash.NewFaker(), db, 1)
+	if _, err := blockchain.InsertChain(blocks); err != nil {
+		t.Fatalf("failed to insert chain: %v", err)
+	}
+	if _, err := blockchain.InsertChain(side); err != nil {
+		t.Fatalf("failed to insert side chain: %v", err)
+	}
+	var want []common.Hash
+	for _, block := range append(blocks, side...) {
+		want = append(want, block.Hash())
+	}
+	if len(engine.written) != len(want) {
+		t.Fatalf("written blocks mismatch: have %d, want %d", len(engine.written), len(want))
+	}
+	for i, hash := range want {
+		if engine.written[i] != hash {
+			t.Errorf("written block %d mismatch: have %x, want %x", i, engine.written[i], hash)
+		}
+	}
+}
+
 // Tests that given a starting canonical chain of a given size, it can be extended
 // with various length chains.
 func TestExtendCanonicalHeaders(t *testing.T) { testExtendCanonical(t, false) }
@@ -896,6 +940,72 @@ func TestLogReorgs(t *testing.T) {
 	}
 }
 
+// logEngine is a consensus engine attaching a log to the first receipt of the
+// block on finalization.
+type logEngine struct {
+	consensus.Engine
+}
+
+func (e logEngine) Finalize(chain consensus.ChainReader, header *types.Header, state *state.StateDB, txs []*types.Transaction, uncles []*types.Header, receipts []*types.Receipt) (*types.Block, error) {
+	if len(receipts) > 0 {
+		receipts[0].Logs = append(receipts[0].Logs, &types.Log{
+			Address:     common.Address{0xee},
+			BlockNumber: header.Number.Uint64(),
+			TxHash:      receipts[0].TxHash,
+		})
+		receipts[0].Bloom = types.CreateBloom(types.Receipts{receipts[0]})
+	}
+	return e.Engine.Finalize(chain, header, state, txs, uncles, receipts)
+}
+
+// Tests that the logs attached by the engine on finalization are announced and
+// stored with the hash of their block.
+func TestEngineLogs(t *testing.T) {
+	var (
+		key1, _ = crypto.HexToECDSA("b71c71a67e1177ad4e901695e1b4b9ee17ae16c6668d313eac2f96dbcda3f291")
+		addr1   = crypto.PubkeyToAddress(key1.PublicKey)
+		db      = ethdb.NewMemDatabase()
+		engine  = logEngine{ethash.NewFaker()}
+		gspec   = &Genesis{Config: params.TestChainConfig, Alloc: GenesisAlloc{addr1: {Balance: big.NewInt(10000000000000)}}}
+		genesis = gspec.MustCommit(db)
+		signer  = types.NewEIP155Signer(gspec.Config.ChainId)
+	)
+	blockchain, _ := NewBlockChain(db, nil, gspec.Config, engine, vm.Config{})
+	defer blockchain.Stop()
+
+	logsCh := make(chan []*types.Log, 1)
+	blockchain.SubscribeLogsEvent(logsCh)
+	chain, _ := GenerateChain(gspec.Config, genesis, engine, db, 1, func(i int, gen *BlockGen) {
+		tx, err := types.SignTx(types.NewTransaction(gen.TxNonce(addr1), common.Address{0x01}, big.NewInt(1000), params.TxGas, nil, nil), signer, key1)
+		if err != nil {
+			t.Fatalf("failed to create tx: %v", err)
+		}
+		gen.AddTx(tx)
+	})
+	if _, err := blockchain.InsertChain(chain); err != nil {
+		t.Fatalf("failed to insert chain: %v", err)
+	}
+	block := chain[0]
+	select {
+	case logs := <-logsCh:
+		if len(logs) != 1 || logs[0].Address != (common.Address{0xee}) {
+			t.Fatalf("announced logs mismatch: have %v, want the engine log", logs)
+		}
+		if logs[0].BlockHash != block.Hash() {
+			t.Errorf("announced log block hash mismatch: have %x, want %x", logs[0].BlockHash, block.Hash())
+		}
+	case <-time.After(time.Second):
+		t.Fatal("no logs announced")
+	}
+	receipts := rawdb.ReadReceipts(db, block.Hash(), block.NumberU64())
+	if len(receipts) != 1 || len(receipts[0].Logs) != 1 {
+		t.Fatalf("stored receipts mismatch: have %v", receipts)
+	}
+	if hash := receipts[0].Logs[0].BlockHash; hash != block.Hash() {
+		t.Errorf("stored log block hash mismatch: have %x, want %x", hash, block.Hash())
+	}
+}
+
 func TestReorgSideEvent(t *testing.T) {
 	var (
 		db      = ethdb.NewMemDatabase()
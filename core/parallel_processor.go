@@ -0,0 +1,384 @@
+// Copyright 2018 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package core
+
+import (
+	"context"
+	"math/big"
+	"sync"
+
+	"github.com/awesome-chain/Xchain/common"
+	"github.com/awesome-chain/Xchain/core/state"
+	"github.com/awesome-chain/Xchain/core/types"
+	"github.com/awesome-chain/Xchain/core/vm"
+	"github.com/awesome-chain/Xchain/util/execpool"
+)
+
+// ripemd is the RIPEMD precompile, whose touches survive reverts as a consensus
+// exception that replaying the changes of a transaction can't reproduce.
+var ripemd = common.BytesToAddress([]byte{3})
+
+var (
+	executionPool     execpool.BacklogPool // Pool running the speculative transaction executions
+	executionPoolOnce sync.Once
+)
+
+// accessSet is a set of state items accessed by a transaction.
+type accessSet struct {
+	accounts map[common.Address]struct{}                 // Balances, nonces, code and existence
+	slots    map[common.Address]map[common.Hash]struct{} // Individual storage slots
+	storages map[common.Address]struct{}                 // Entire storages, wiped or iterated
+}
+
+// newAccessSet creates an empty access set.
+func newAccessSet() *accessSet {
+	return &accessSet{
+		accounts: make(map[common.Address]struct{}),
+		slots:    make(map[common.Address]map[common.Hash]struct{}),
+		storages: make(map[common.Address]struct{}),
+	}
+}
+
+func (s *accessSet) addAccount(addr common.Address) {
+	s.accounts[addr] = struct{}{}
+}
+
+func (s *accessSet) addSlot(addr common.Address, key common.Hash) {
+	if _, ok := s.slots[addr]; !ok {
+		s.slots[addr] = make(map[common.Hash]struct{})
+	}
+	s.slots[addr][key] = struct{}{}
+}
+
+func (s *accessSet) addStorage(addr common.Address) {
+	s.storages[addr] = struct{}{}
+}
+
+// merge adds all the items of another set to the set.
+func (s *accessSet) merge(other *accessSet) {
+	for addr := range other.accounts {
+		s.addAccount(addr)
+	}
+	for addr, keys := range other.slots {
+		for key := range keys {
+			s.addSlot(addr, key)
+		}
+	}
+	for addr := range other.storages {
+		s.addStorage(addr)
+	}
+}
+
+// conflicts reports whether any of the items read, as tracked by the set, were
+// modified by the given set of writes.
+func (s *accessSet) conflicts(writes *accessSet) bool {
+	for addr := range s.accounts {
+		if _, ok := writes.accounts[addr]; ok {
+			return true
+		}
+	}
+	for addr, keys := range s.slots {
+		if _, ok := writes.storages[addr]; ok {
+			return true
+		}
+		written, ok := writes.slots[addr]
+		if !ok {
+			continue
+		}
+		for key := range keys {
+			if _, ok := written[key]; ok {
+				return true
+			}
+		}
+	}
+	for addr := range s.storages {
+		if _, ok := writes.storages[addr]; ok {
+			return true
+		}
+		if _, ok := writes.slots[addr]; ok {
+			return true
+		}
+	}
+	return false
+}
+
+// stateChange is a modification of the state made by a transaction.
+type stateChange struct {
+	account common.Address
+	apply   func(*state.StateDB)
+}
+
+// stateRecorder is a vm.StateDB tracking the state items a transaction reads and
+// writes, and recording its modifications so that they can be replayed onto
+// another state.
+type stateRecorder struct {
+	state   *state.StateDB
+	reads   *accessSet
+	writes  *accessSet
+	changes []stateChange
+	snaps   map[int]int // Number of changes at each snapshot of the state
+	unsafe  bool        // Whether replaying the changes would diverge from the execution
+}
+
+// newStateRecorder creates a recorder of the accesses to the given state.
+func newStateRecorder(statedb *state.StateDB) *stateRecorder {
+	return &stateRecorder{
+		state:  statedb,
+		reads:  newAccessSet(),
+		writes: newAccessSet(),
+		snaps:  make(map[int]int),
+	}
+}
+
+// change records a modification of an account.
+func (r *stateRecorder) change(addr common.Address, apply func(*state.StateDB)) {
+	r.writes.addAccount(addr)
+	r.changes = append(r.changes, stateChange{account: addr, apply: apply})
+}
+
+// replay applies the recorded modifications onto the given state.
+func (r *stateRecorder) replay(statedb *state.StateDB) {
+	for _, change := range r.changes {
+		change.apply(statedb)
+	}
+}
+
+func (r *stateRecorder) CreateAccount(addr common.Address) {
+	r.writes.addStorage(addr)
+	r.change(addr, func(db *state.StateDB) { db.CreateAccount(addr) })
+	r.state.CreateAccount(addr)
+}
+
+func (r *stateRecorder) SubBalance(addr common.Address, amount *big.Int) {
+	amount = new(big.Int).Set(amount)
+	r.change(addr, func(db *state.StateDB) { db.SubBalance(addr, amount) })
+	r.state.SubBalance(addr, amount)
+}
+
+func (r *stateRecorder) AddBalance(addr common.Address, amount *big.Int) {
+	amount = new(big.Int).Set(amount)
+	r.change(addr, func(db *state.StateDB) { db.AddBalance(addr, amount) })
+	r.state.AddBalance(addr, amount)
+}
+
+func (r *stateRecorder) GetBalance(addr common.Address) *big.Int {
+	r.reads.addAccount(addr)
+	return r.state.GetBalance(addr)
+}
+
+func (r *stateRecorder) GetNonce(addr common.Address) uint64 {
+	r.reads.addAccount(addr)
+	return r.state.GetNonce(addr)
+}
+
+func (r *stateRecorder) SetNonce(addr common.Address, nonce uint64) {
+	r.change(addr, func(db *state.StateDB) { db.SetNonce(addr, nonce) })
+	r.state.SetNonce(addr, nonce)
+}
+
+func (r *stateRecorder) GetCodeHash(addr common.Address) common.Hash {
+	r.reads.addAccount(addr)
+	return r.state.GetCodeHash(addr)
+}
+
+func (r *stateRecorder) GetCode(addr common.Address) []byte {
+	r.reads.addAccount(addr)
+	return r.state.GetCode(addr)
+}
+
+func (r *stateRecorder) SetCode(addr common.Address, code []byte) {
+	code = common.CopyBytes(code)
+	r.change(addr, func(db *state.StateDB) { db.SetCode(addr, code) })
+	r.state.SetCode(addr, code)
+}
+
+func (r *stateRecorder) GetCodeSize(addr common.Address) int {
+	r.reads.addAccount(addr)
+	return r.state.GetCodeSize(addr)
+}
+
+func (r *stateRecorder) AddRefund(gas uint64) {
+	r.state.AddRefund(gas)
+}
+
+func (r *stateRecorder) GetRefund() uint64 {
+	return r.state.GetRefund()
+}
+
+func (r *stateRecorder) GetState(addr common.Address, key common.Hash) common.Hash {
+	r.reads.addSlot(addr, key)
+	return r.state.GetState(addr, key)
+}
+
+func (r *stateRecorder) SetState(addr common.Address, key common.Hash, value common.Hash) {
+	r.writes.addSlot(addr, key)
+	r.changes = append(r.changes, stateChange{account: addr, apply: func(db *state.StateDB) { db.SetState(addr, key, value) }})
+	r.state.SetState(addr, key, value)
+}
+
+func (r *stateRecorder) Suicide(addr common.Address) bool {
+	r.reads.addAccount(addr)
+	r.writes.addStorage(addr)
+	r.change(addr, func(db *state.StateDB) { db.Suicide(addr) })
+	return r.state.Suicide(addr)
+}
+
+func (r *stateRecorder) HasSuicided(addr common.Address) bool {
+	r.reads.addAccount(addr)
+	return r.state.HasSuicided(addr)
+}
+
+func (r *stateRecorder) Exist(addr common.Address) bool {
+	r.reads.addAccount(addr)
+	return r.state.Exist(addr)
+}
+
+func (r *stateRecorder) Empty(addr common.Address) bool {
+	r.reads.addAccount(addr)
+	return r.state.Empty(addr)
+}
+
+func (r *stateRecorder) RevertToSnapshot(id int) {
+	// Drop the reverted changes, the accesses stay tracked
+	n := r.snaps[id]
+	for _, change := range r.changes[n:] {
+		if change.account == ripemd {
+			r.unsafe = true
+		}
+	}
+	r.changes = r.changes[:n]
+	r.state.RevertToSnapshot(id)
+}
+
+func (r *stateRecorder) Snapshot() int {
+	id := r.state.Snapshot()
+	r.snaps[id] = len(r.changes)
+	return id
+}
+
+func (r *stateRecorder) AddLog(log *types.Log) {
+	r.changes = append(r.changes, stateChange{account: log.Address, apply: func(db *state.StateDB) { db.AddLog(log) }})
+	r.state.AddLog(log)
+}
+
+func (r *stateRecorder) AddPreimage(hash common.Hash, preimage []byte) {
+	r.changes = append(r.changes, stateChange{apply: func(db *state.StateDB) { db.AddPreimage(hash, preimage) }})
+	r.state.AddPreimage(hash, preimage)
+}
+
+func (r *stateRecorder) ForEachStorage(addr common.Address, cb func(key, value common.Hash) bool) {
+	r.reads.addStorage(addr)
+	r.state.ForEachStorage(addr, cb)
+}
+
+// txExecution is the outcome of executing a transaction on a recorded state.
+type txExecution struct {
+	index    int
+	recorder *stateRecorder
+	msg      types.Message
+	gas      uint64
+	failed   bool
+	err      error
+}
+
+// txExecutionTask is a transaction to execute speculatively on its own copy of
+// the state.
+type txExecutionTask struct {
+	index   int
+	tx      *types.Transaction
+	statedb *state.StateDB
+}
+
+// executeTransaction applies a transaction to the given state, recording the
+// state it accesses. The state is left unfinalised.
+func (p *StateProcessor) executeTransaction(gp *GasPool, statedb *state.StateDB, header *types.Header, tx *types.Transaction, cfg vm.Config) *txExecution {
+	exec := &txExecution{recorder: newStateRecorder(statedb)}
+
+	exec.msg, exec.err = tx.AsMessage(types.MakeSigner(p.config, header.Number))
+	if exec.err != nil {
+		return exec
+	}
+	context := NewEVMContext(exec.msg, header, p.bc, nil)
+	vmenv := vm.NewEVM(context, exec.recorder, p.config, cfg)
+
+	_, exec.gas, exec.failed, exec.err = ApplyMessage(vmenv, exec.msg, gp)
+	return exec
+}
+
+// processParallel applies the transactions of a block optimistically in
+// parallel. Every transaction is first executed on its own copy of the state
+// at the start of the block, recording the state it reads and writes. The
+// executions are then committed in order: if none of the state read by a
+// transaction was written by an earlier one, its changes are replayed onto the
+// state, otherwise it is executed again on the state as it stands. Receipts and
+// state are thus the same as when applying the transactions in order.
+func (p *StateProcessor) processParallel(block *types.Block, statedb *state.StateDB, gp *GasPool, usedGas *uint64, cfg vm.Config) (types.Receipts, error) {
+	var (
+		header = block.Header()
+		txs    = block.Transactions()
+		execs  = make([]*txExecution, len(txs))
+		out    = make(chan interface{}, len(txs))
+	)
+	executionPoolOnce.Do(func() {
+		executionPool = execpool.MakeBacklog(nil, 0, execpool.LowPriority, nil)
+	})
+	execute := func(arg interface{}) interface{} {
+		task := arg.(*txExecutionTask)
+		exec := p.executeTransaction(new(GasPool).AddGas(block.GasLimit()), task.statedb, header, task.tx, cfg)
+		exec.index = task.index
+		return exec
+	}
+	// Copy the state up front, executions must not race with the commits
+	pending := 0
+	for i, tx := range txs {
+		task := &txExecutionTask{index: i, tx: tx, statedb: statedb.Copy()}
+		task.statedb.Prepare(tx.Hash(), block.Hash(), i)
+		if err := executionPool.EnqueueBacklog(context.Background(), execute, task, out); err != nil {
+			break
+		}
+		pending++
+	}
+	for i := 0; i < pending; i++ {
+		exec := (<-out).(*txExecution)
+		execs[exec.index] = exec
+	}
+	// Commit the executions in order, running the conflicting ones again
+	var (
+		receipts types.Receipts
+		written  = newAccessSet()
+	)
+	for i, tx := range txs {
+		statedb.Prepare(tx.Hash(), block.Hash(), i)
+
+		exec := execs[i]
+		if exec == nil || exec.err != nil || exec.recorder.unsafe || gp.Gas() < exec.msg.Gas() || exec.recorder.reads.conflicts(written) {
+			if exec = p.executeTransaction(gp, statedb, header, tx, cfg); exec.err != nil {
+				return nil, exec.err
+			}
+		} else {
+			exec.recorder.replay(statedb)
+			if err := gp.SubGas(exec.gas); err != nil {
+				return nil, err
+			}
+		}
+		written.merge(exec.recorder.writes)
+
+		receipt := finaliseTransaction(p.config, statedb, header, tx, exec.msg, exec.gas, exec.failed, usedGas)
+		receipts = append(receipts, receipt)
+	}
+	return receipts, nil
+}
@@ -0,0 +1,144 @@
+// Copyright 2018 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package core
+
+import (
+	"crypto/ecdsa"
+	"math/big"
+	"reflect"
+	"testing"
+
+	"github.com/awesome-chain/Xchain/common"
+	"github.com/awesome-chain/Xchain/consensus/ethash"
+	"github.com/awesome-chain/Xchain/core/state"
+	"github.com/awesome-chain/Xchain/core/types"
+	"github.com/awesome-chain/Xchain/core/vm"
+	"github.com/awesome-chain/Xchain/crypto"
+	"github.com/awesome-chain/Xchain/ethdb"
+	"github.com/awesome-chain/Xchain/params"
+)
+
+// Tests that processing blocks with optimistic parallel execution yields the
+// same receipts, logs, gas and state as processing them in order, for blocks
+// mixing independent and conflicting transactions.
+func TestParallelProcess(t *testing.T) {
+	testParallelProcess(t, params.TestChainConfig)
+	testParallelProcess(t, &params.ChainConfig{
+		ChainId:        big.NewInt(1),
+		HomesteadBlock: new(big.Int),
+		EIP150Block:    new(big.Int),
+		EIP155Block:    new(big.Int),
+		EIP158Block:    new(big.Int),
+		Ethash:         new(params.EthashConfig),
+	})
+}
+
+func testParallelProcess(t *testing.T, config *params.ChainConfig) {
+	var (
+		db      = ethdb.NewMemDatabase()
+		keys    = make([]*ecdsa.PrivateKey, 12)
+		addrs   = make([]common.Address, len(keys))
+		funds   = big.NewInt(1000000000000000)
+		counter = common.Address{0xc0} // Increments slot 0 and logs on every call
+		suicide = common.Address{0xdd} // Self destructs to the caller
+		empty   = common.Address{0xee}
+		alloc   = GenesisAlloc{
+			counter: {Code: common.FromHex("600054600101600055600060006000a000"), Balance: new(big.Int)},
+			suicide: {Code: common.FromHex("33ff"), Balance: big.NewInt(1000)},
+			empty:   {Balance: new(big.Int)},
+		}
+	)
+	for i := range keys {
+		keys[i], _ = crypto.GenerateKey()
+		addrs[i] = crypto.PubkeyToAddress(keys[i].PublicKey)
+		alloc[addrs[i]] = GenesisAccount{Balance: funds}
+	}
+	gspec := &Genesis{Config: config, Alloc: alloc}
+	genesis := gspec.MustCommit(db)
+
+	blocks, _ := GenerateChain(config, genesis, ethash.NewFaker(), db, 4, func(i int, block *BlockGen) {
+		signer := types.MakeSigner(config, block.Number())
+		send := func(key *ecdsa.PrivateKey, to *common.Address, value int64, gas uint64, data []byte) {
+			from := crypto.PubkeyToAddress(key.PublicKey)
+			var tx *types.Transaction
+			if to == nil {
+				tx = types.NewContractCreation(block.TxNonce(from), big.NewInt(value), gas, big.NewInt(1), data)
+			} else {
+				tx = types.NewTransaction(block.TxNonce(from), *to, big.NewInt(value), gas, big.NewInt(1), data)
+			}
+			tx, err := types.SignTx(tx, signer, key)
+			if err != nil {
+				t.Fatal(err)
+			}
+			block.AddTx(tx)
+		}
+		// Independent transfers to fresh accounts
+		for j := 0; j < 4; j++ {
+			to := common.Address{0x10 + byte(j), byte(i)}
+			send(keys[j], &to, 1000, 21000, nil)
+		}
+		// Transfers along a chain of accounts, each depending on the previous
+		send(keys[4], &addrs[5], 5000, 21000, nil)
+		send(keys[5], &addrs[6], 5000, 21000, nil)
+		send(keys[6], &addrs[4], 5000, 21000, nil)
+
+		// Calls contending for the same storage slot and emitting logs
+		send(keys[7], &counter, 0, 100000, nil)
+		send(keys[0], &counter, 0, 100000, nil)
+
+		// Touch an empty account, create a contract and self destruct one
+		send(keys[8], &empty, 0, 21000, nil)
+		send(keys[9], nil, 0, 100000, common.FromHex("60016000556011601160003960116000f3600054600101600055600060006000a000"))
+		if i == 1 {
+			send(keys[10], &suicide, 0, 100000, nil)
+			send(keys[11], &suicide, 0, 100000, nil)
+		}
+	})
+	blockchain, _ := NewBlockChain(db, nil, config, ethash.NewFaker(), vm.Config{})
+	defer blockchain.Stop()
+
+	for i, block := range blocks {
+		parent := blockchain.CurrentBlock()
+
+		serialdb, _ := state.New(parent.Root(), blockchain.stateCache)
+		receipts, logs, gas, err := blockchain.Processor().Process(block, serialdb, vm.Config{})
+		if err != nil {
+			t.Fatalf("block %d: failed to process in order: %v", i, err)
+		}
+		paralleldb, _ := state.New(parent.Root(), blockchain.stateCache)
+		preceipts, plogs, pgas, err := blockchain.Processor().Process(block, paralleldb, vm.Config{ParallelExecution: true})
+		if err != nil {
+			t.Fatalf("block %d: failed to process in parallel: %v", i, err)
+		}
+		if !reflect.DeepEqual(preceipts, receipts) {
+			t.Errorf("block %d: receipts mismatch:\nhave %v\nwant %v", i, preceipts, receipts)
+		}
+		if !reflect.DeepEqual(plogs, logs) {
+			t.Errorf("block %d: logs mismatch:\nhave %v\nwant %v", i, plogs, logs)
+		}
+		if pgas != gas {
+			t.Errorf("block %d: gas used mismatch: have %d, want %d", i, pgas, gas)
+		}
+		root := serialdb.IntermediateRoot(config.IsEIP158(block.Number()))
+		if proot := paralleldb.IntermediateRoot(config.IsEIP158(block.Number())); proot != root || root != block.Root() {
+			t.Errorf("block %d: state root mismatch: have %x, want %x (block %x)", i, proot, root, block.Root())
+		}
+		if _, err := blockchain.InsertChain(types.Blocks{block}); err != nil {
+			t.Fatalf("block %d: failed to insert: %v", i, err)
+		}
+	}
+}
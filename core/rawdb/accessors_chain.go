@@ -243,6 +243,29 @@ func DeleteBody(db DatabaseDeleter, hash common.Hash, number uint64) {
 	}
 }
 
+// ReadCertificateRLP retrieves the agreement certificate of a block in RLP
+// encoding. Only blocks committed by a Byzantine agreement engine have one.
+func ReadCertificateRLP(db DatabaseReader, hash common.Hash, number uint64) rlp.RawValue {
+	data, _ := db.Get(append(append(blockCertificatePrefix, encodeBlockNumber(number)...), hash.Bytes()...))
+	return data
+}
+
+// WriteCertificateRLP stores the RLP encoded agreement certificate of a block
+// into the database.
+func WriteCertificateRLP(db DatabaseWriter, hash common.Hash, number uint64, rlp rlp.RawValue) {
+	key := append(append(blockCertificatePrefix, encodeBlockNumber(number)...), hash.Bytes()...)
+	if err := db.Put(key, rlp); err != nil {
+		log.Crit("Failed to store block certificate", "err", err)
+	}
+}
+
+// DeleteCertificate removes the agreement certificate of a block.
+func DeleteCertificate(db DatabaseDeleter, hash common.Hash, number uint64) {
+	if err := db.Delete(append(append(blockCertificatePrefix, encodeBlockNumber(number)...), hash.Bytes()...)); err != nil {
+		log.Crit("Failed to delete block certificate", "err", err)
+	}
+}
+
 // ReadTd retrieves a block's total difficulty corresponding to the hash.
 func ReadTd(db DatabaseReader, hash common.Hash, number uint64) *big.Int {
 	data, _ := db.Get(append(append(append(headerPrefix, encodeBlockNumber(number)...), hash[:]...), headerTDSuffix...))
@@ -351,6 +374,7 @@ func DeleteBlock(db DatabaseDeleter, hash common.Hash, number uint64) {
 	DeleteHeader(db, hash, number)
 	DeleteBody(db, hash, number)
 	DeleteTd(db, hash, number)
+	DeleteCertificate(db, hash, number)
 }
 
 // FindCommonAncestor returns the last common ancestor of two block headers
@@ -99,6 +99,28 @@ func TestBodyStorage(t *testing.T) {
 	}
 }
 
+// Tests block certificate storage and retrieval operations.
+func TestCertificateStorage(t *testing.T) {
+	db := ethdb.NewMemDatabase()
+
+	hash, cert := common.Hash{0x01}, []byte{0xc2, 0x01, 0x02}
+	if entry := ReadCertificateRLP(db, hash, 1); entry != nil {
+		t.Fatalf("Non existent certificate returned: %x", entry)
+	}
+	WriteCertificateRLP(db, hash, 1, cert)
+	if entry := ReadCertificateRLP(db, hash, 1); !bytes.Equal(entry, cert) {
+		t.Fatalf("Retrieved certificate mismatch: have %x, want %x", entry, cert)
+	}
+	if entry := ReadCertificateRLP(db, hash, 2); entry != nil {
+		t.Fatalf("Certificate returned for other number: %x", entry)
+	}
+	// Deleting the block must delete its certificate too
+	DeleteBlock(db, hash, 1)
+	if entry := ReadCertificateRLP(db, hash, 1); entry != nil {
+		t.Fatalf("Deleted certificate returned: %x", entry)
+	}
+}
+
 // Tests block storage and retrieval operations.
 func TestBlockStorage(t *testing.T) {
 	db := ethdb.NewMemDatabase()
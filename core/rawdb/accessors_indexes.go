@@ -117,3 +117,43 @@ func WriteBloomBits(db DatabaseWriter, bit uint, section uint64, head common.Has
 		log.Crit("Failed to store bloom bits", "err", err)
 	}
 }
+
+// rewardKey = rewardPrefix + address + num (uint64 big endian) + hash
+func rewardKey(address common.Address, number uint64, hash common.Hash) []byte {
+	return append(append(append(rewardPrefix, address.Bytes()...), encodeBlockNumber(number)...), hash.Bytes()...)
+}
+
+// ReadRewards retrieves the block rewards credited to an address by a block.
+func ReadRewards(db DatabaseReader, address common.Address, number uint64, hash common.Hash) []RewardEntry {
+	data, _ := db.Get(rewardKey(address, number, hash))
+	if len(data) == 0 {
+		return nil
+	}
+	var rewards []RewardEntry
+	if err := rlp.DecodeBytes(data, &rewards); err != nil {
+		log.Error("Invalid reward entries RLP", "address", address, "number", number, "hash", hash, "err", err)
+		return nil
+	}
+	return rewards
+}
+
+// WriteRewards stores the block rewards credited by a block, indexed by the
+// address credited.
+func WriteRewards(db DatabaseWriter, number uint64, hash common.Hash, rewards map[common.Address][]RewardEntry) {
+	for address, entries := range rewards {
+		data, err := rlp.EncodeToBytes(entries)
+		if err != nil {
+			log.Crit("Failed to encode reward entries", "err", err)
+		}
+		if err := db.Put(rewardKey(address, number, hash), data); err != nil {
+			log.Crit("Failed to store reward entries", "err", err)
+		}
+	}
+}
+
+// DeleteRewards removes the block rewards credited to an address by a block.
+func DeleteRewards(db DatabaseDeleter, address common.Address, number uint64, hash common.Hash) {
+	if err := db.Delete(rewardKey(address, number, hash)); err != nil {
+		log.Crit("Failed to delete reward entries", "err", err)
+	}
+}
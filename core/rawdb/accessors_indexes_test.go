@@ -18,6 +18,7 @@ package rawdb
 
 import (
 	"math/big"
+	"reflect"
 	"testing"
 
 	"github.com/awesome-chain/Xchain/common"
@@ -66,3 +67,45 @@ func TestLookupStorage(t *testing.T) {
 		}
 	}
 }
+
+// Tests that block rewards can be stored, retrieved by address and deleted.
+func TestRewardStorage(t *testing.T) {
+	db := ethdb.NewMemDatabase()
+
+	var (
+		miner = common.BytesToAddress([]byte{0x11})
+		voter = common.BytesToAddress([]byte{0x22})
+		hash  = common.BytesToHash([]byte{0x33})
+	)
+	rewards := map[common.Address][]RewardEntry{
+		miner: {{Category: "miner", Amount: big.NewInt(100), Reason: "block reward"}},
+		voter: {
+			{Category: "voter", Amount: big.NewInt(20), Reason: "vote reward"},
+			{Category: "refund", Amount: big.NewInt(3), Reason: "gas refund"},
+		},
+	}
+	if entries := ReadRewards(db, miner, 314, hash); entries != nil {
+		t.Fatalf("non existent rewards returned: %v", entries)
+	}
+	WriteRewards(db, 314, hash, rewards)
+
+	for address, want := range rewards {
+		entries := ReadRewards(db, address, 314, hash)
+		if !reflect.DeepEqual(entries, want) {
+			t.Errorf("rewards of %x mismatch: have %v, want %v", address, entries, want)
+		}
+	}
+	if entries := ReadRewards(db, miner, 315, hash); entries != nil {
+		t.Errorf("rewards returned for another block: %v", entries)
+	}
+	if entries := ReadRewards(db, miner, 314, common.Hash{}); entries != nil {
+		t.Errorf("rewards returned for another block hash: %v", entries)
+	}
+	DeleteRewards(db, voter, 314, hash)
+	if entries := ReadRewards(db, voter, 314, hash); entries != nil {
+		t.Errorf("deleted rewards returned: %v", entries)
+	}
+	if entries := ReadRewards(db, miner, 314, hash); len(entries) != 1 {
+		t.Errorf("rewards of other address deleted")
+	}
+}
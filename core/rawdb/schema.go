@@ -19,6 +19,7 @@ package rawdb
 
 import (
 	"encoding/binary"
+	"math/big"
 
 	"github.com/awesome-chain/Xchain/common"
 	"github.com/awesome-chain/Xchain/metrics"
@@ -47,11 +48,13 @@ var (
 	headerHashSuffix   = []byte("n") // headerPrefix + num (uint64 big endian) + headerHashSuffix -> hash
 	headerNumberPrefix = []byte("H") // headerNumberPrefix + hash -> num (uint64 big endian)
 
-	blockBodyPrefix     = []byte("b") // blockBodyPrefix + num (uint64 big endian) + hash -> block body
-	blockReceiptsPrefix = []byte("r") // blockReceiptsPrefix + num (uint64 big endian) + hash -> block receipts
+	blockBodyPrefix        = []byte("b") // blockBodyPrefix + num (uint64 big endian) + hash -> block body
+	blockReceiptsPrefix    = []byte("r") // blockReceiptsPrefix + num (uint64 big endian) + hash -> block receipts
+	blockCertificatePrefix = []byte("c") // blockCertificatePrefix + num (uint64 big endian) + hash -> block agreement certificate
 
 	txLookupPrefix  = []byte("l") // txLookupPrefix + hash -> transaction/receipt lookup metadata
 	bloomBitsPrefix = []byte("B") // bloomBitsPrefix + bit (uint16 big endian) + section (uint64 big endian) + hash -> bloom bits
+	rewardPrefix    = []byte("w") // rewardPrefix + address + num (uint64 big endian) + hash -> block rewards of the address
 
 	preimagePrefix = []byte("secure-key-")      // preimagePrefix + hash -> preimage
 	configPrefix   = []byte("ethereum-config-") // config prefix for the db
@@ -71,6 +74,14 @@ type TxLookupEntry struct {
 	Index      uint64
 }
 
+// RewardEntry is a credit of a block reward to an address, outside of any
+// transaction.
+type RewardEntry struct {
+	Category string   // Kind of reward, as named by the consensus engine
+	Amount   *big.Int // Amount credited to the address
+	Reason   string   // Human readable origin of the reward
+}
+
 // encodeBlockNumber encodes a block number as big endian uint64
 func encodeBlockNumber(number uint64) []byte {
 	enc := make([]byte, 8)
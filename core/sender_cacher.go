@@ -0,0 +1,127 @@
+// Copyright 2018 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package core
+
+import (
+	"context"
+
+	"github.com/awesome-chain/Xchain/core/types"
+	"github.com/awesome-chain/Xchain/util/execpool"
+)
+
+// senderRecoveryMinBatch is the minimum number of transactions recovered by a
+// single task, below which scheduling costs more than the recovery itself.
+const senderRecoveryMinBatch = 16
+
+// senderCacher is a concurrent transaction sender recoverer and cacher.
+var senderCacher = newTxSenderCacher(nil)
+
+// txSenderCacherRequest is a request for recovering transaction senders with a
+// specific signature scheme and caching it into the transactions themselves.
+type txSenderCacherRequest struct {
+	signer types.Signer
+	txs    []*types.Transaction
+}
+
+// txSenderCacher is a helper structure to concurrently ecrecover transaction
+// senders from digital signatures on an execution pool.
+type txSenderCacher struct {
+	pool execpool.BacklogPool
+}
+
+// newTxSenderCacher creates a new transaction sender background cacher running
+// its recoveries on the given pool. If the pool is nil, a pool of all the CPUs
+// is created and owned by the cacher.
+//
+// The recoveries are scheduled at low priority so that a pool shared with the
+// consensus engine keeps serving its vote verifications first.
+func newTxSenderCacher(pool execpool.BacklogPool) *txSenderCacher {
+	cacher := new(txSenderCacher)
+	if pool == nil {
+		pool = execpool.MakeBacklog(nil, 0, execpool.LowPriority, cacher)
+	}
+	cacher.pool = pool
+	return cacher
+}
+
+// recoverSenders is the execution pool task recovering the senders of a batch
+// of transactions.
+func recoverSenders(arg interface{}) interface{} {
+	req := arg.(*txSenderCacherRequest)
+	for _, tx := range req.txs {
+		types.Sender(req.signer, tx)
+	}
+	return nil
+}
+
+// recover recovers the senders from a batch of transactions and caches them
+// back into the same data structures. There is no validation being done, nor
+// any reaction to invalid signatures. That is up to calling code later.
+//
+// The returned channel is closed once all the senders are cached.
+func (cacher *txSenderCacher) recover(signer types.Signer, txs []*types.Transaction) <-chan struct{} {
+	done := make(chan struct{})
+
+	// If there's nothing to recover, abort
+	if len(txs) == 0 {
+		close(done)
+		return done
+	}
+	// Split the transactions into one contiguous batch per worker
+	tasks := cacher.pool.GetParallelism()
+	if batches := (len(txs) + senderRecoveryMinBatch - 1) / senderRecoveryMinBatch; batches < tasks {
+		tasks = batches
+	}
+	var (
+		size    = (len(txs) + tasks - 1) / tasks
+		out     = make(chan interface{}, tasks)
+		pending = 0
+	)
+	for start := 0; start < len(txs); start += size {
+		end := start + size
+		if end > len(txs) {
+			end = len(txs)
+		}
+		req := &txSenderCacherRequest{signer: signer, txs: txs[start:end]}
+		if err := cacher.pool.EnqueueBacklog(context.Background(), recoverSenders, req, out); err != nil {
+			break
+		}
+		pending++
+	}
+	go func() {
+		for i := 0; i < pending; i++ {
+			<-out
+		}
+		close(done)
+	}()
+	return done
+}
+
+// recoverFromBlocks recovers the senders from a batch of blocks and caches them
+// back into the same data structures. There is no validation being done, nor
+// any reaction to invalid signatures. That is up to calling code later.
+func (cacher *txSenderCacher) recoverFromBlocks(signer types.Signer, blocks []*types.Block) <-chan struct{} {
+	count := 0
+	for _, block := range blocks {
+		count += len(block.Transactions())
+	}
+	txs := make([]*types.Transaction, 0, count)
+	for _, block := range blocks {
+		txs = append(txs, block.Transactions()...)
+	}
+	return cacher.recover(signer, txs)
+}
@@ -0,0 +1,70 @@
+// Copyright 2018 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package core
+
+import (
+	"sync/atomic"
+	"testing"
+
+	"github.com/awesome-chain/Xchain/common"
+	"github.com/awesome-chain/Xchain/core/types"
+	"github.com/awesome-chain/Xchain/crypto"
+)
+
+// countingSigner is a homestead signer counting the signatures it recovers.
+type countingSigner struct {
+	types.HomesteadSigner
+	recovered *int32
+}
+
+func (s countingSigner) Sender(tx *types.Transaction) (common.Address, error) {
+	atomic.AddInt32(s.recovered, 1)
+	return s.HomesteadSigner.Sender(tx)
+}
+
+func (s countingSigner) Equal(s2 types.Signer) bool {
+	other, ok := s2.(countingSigner)
+	return ok && other.recovered == s.recovered
+}
+
+// Tests that the sender cacher recovers the senders of all the transactions of
+// a batch, so that later lookups are served from the cache.
+func TestSenderCacherRecover(t *testing.T) {
+	key, _ := crypto.GenerateKey()
+	from := crypto.PubkeyToAddress(key.PublicKey)
+
+	for _, count := range []int{0, 1, senderRecoveryMinBatch - 1, 10 * senderRecoveryMinBatch, 100*senderRecoveryMinBatch + 3} {
+		txs := make([]*types.Transaction, count)
+		for i := range txs {
+			txs[i] = transaction(uint64(i), 100000, key)
+		}
+		signer := countingSigner{recovered: new(int32)}
+		<-newTxSenderCacher(nil).recover(signer, txs)
+
+		if recovered := atomic.LoadInt32(signer.recovered); int(recovered) != count {
+			t.Fatalf("%d txs: recovered senders mismatch: have %d, want %d", count, recovered, count)
+		}
+		for i, tx := range txs {
+			if sender, err := types.Sender(signer, tx); err != nil || sender != from {
+				t.Fatalf("%d txs: tx %d: sender mismatch: have %x, want %x, err %v", count, i, sender, from, err)
+			}
+		}
+		if recovered := atomic.LoadInt32(signer.recovered); int(recovered) != count {
+			t.Fatalf("%d txs: senders recovered again: have %d, want %d", count, recovered, count)
+		}
+	}
+}
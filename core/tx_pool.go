@@ -394,6 +394,7 @@ func (pool *TxPool) reset(oldHead, newHead *types.Header) {
 				}
 			}
 			reinject = types.TxDifference(discarded, included)
+			senderCacher.recover(pool.signer, reinject)
 		}
 	}
 	// Initialize the internal state to the current head
@@ -25,6 +25,7 @@ import (
 	"github.com/awesome-chain/Xchain/common/math"
 	"github.com/awesome-chain/Xchain/crypto"
 	"github.com/awesome-chain/Xchain/crypto/bn256"
+	"github.com/awesome-chain/Xchain/crypto/vrf"
 	"github.com/awesome-chain/Xchain/params"
 	"golang.org/x/crypto/ripemd160"
 )
@@ -59,6 +60,21 @@ var PrecompiledContractsByzantium = map[common.Address]PrecompiledContract{
 	common.BytesToAddress([]byte{8}): &bn256Pairing{},
 }
 
+// PrecompiledContractsVRF contains the set of pre-compiled contracts used after
+// the VRF fork, the Byzantium ones and the VRF proof verification. The latter
+// lives outside of the address range of the Ethereum pre-compiles.
+var PrecompiledContractsVRF = map[common.Address]PrecompiledContract{
+	common.BytesToAddress([]byte{1}):       &ecrecover{},
+	common.BytesToAddress([]byte{2}):       &sha256hash{},
+	common.BytesToAddress([]byte{3}):       &ripemd160hash{},
+	common.BytesToAddress([]byte{4}):       &dataCopy{},
+	common.BytesToAddress([]byte{5}):       &bigModExp{},
+	common.BytesToAddress([]byte{6}):       &bn256Add{},
+	common.BytesToAddress([]byte{7}):       &bn256ScalarMul{},
+	common.BytesToAddress([]byte{8}):       &bn256Pairing{},
+	common.BytesToAddress([]byte{1, 0x01}): &vrfVerify{},
+}
+
 // RunPrecompiledContract runs and evaluates the output of a precompiled contract.
 func RunPrecompiledContract(p PrecompiledContract, input []byte, contract *Contract) (ret []byte, err error) {
 	gas := p.RequiredGas(input)
@@ -358,3 +374,54 @@ func (c *bn256Pairing) Run(input []byte) ([]byte, error) {
 	}
 	return false32Byte, nil
 }
+
+const (
+	vrfPubkeyLength = 64      // Length of the VRF public key, the X and Y coordinates
+	vrfProofLength  = 64 + 65 // Length of the VRF proof, s, t and the VRF point
+)
+
+var (
+	// errBadVRFInput is returned if the VRF verification input is too short.
+	errBadVRFInput = errors.New("bad VRF verification input size")
+
+	// errBadVRFPubkey is returned if the VRF public key is not on the curve.
+	errBadVRFPubkey = errors.New("invalid VRF public key")
+)
+
+// vrfVerify implements a native VRF proof verification, returning the output of
+// the VRF of the secp256k1 public key on the input if the proof is valid.
+//
+// The input is the public key as X and Y coordinates, the proof, then alpha, the
+// input of the VRF, taking all remaining bytes.
+type vrfVerify struct{}
+
+// RequiredGas returns the gas required to execute the pre-compiled contract.
+//
+// This method does not require any overflow checking as the input size gas costs
+// required for anything significant is so high it's impossible to pay for.
+func (c *vrfVerify) RequiredGas(input []byte) uint64 {
+	var words uint64
+	if len(input) > vrfPubkeyLength+vrfProofLength {
+		words = uint64(len(input)-vrfPubkeyLength-vrfProofLength+31) / 32
+	}
+	return params.VRFVerifyBaseGas + words*params.VRFVerifyPerWordGas
+}
+
+func (c *vrfVerify) Run(input []byte) ([]byte, error) {
+	if len(input) < vrfPubkeyLength+vrfProofLength {
+		return nil, errBadVRFInput
+	}
+	pubkey := crypto.ToECDSAPub(append([]byte{0x04}, input[:vrfPubkeyLength]...))
+	if pubkey.X == nil {
+		return nil, errBadVRFPubkey
+	}
+	var (
+		proof = input[vrfPubkeyLength : vrfPubkeyLength+vrfProofLength]
+		alpha = input[vrfPubkeyLength+vrfProofLength:]
+	)
+	output, err := (&vrf.PublicKey{PublicKey: pubkey}).ProofToHash(alpha, proof)
+	if err != nil {
+		return nil, err
+	}
+	return output[:], nil
+}
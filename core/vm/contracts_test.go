@@ -336,8 +336,26 @@ var bn256PairingTests = []precompiledTest{
 	},
 }
 
+// vrfVerifyTests are proofs of the VRF of the key of address
+// 0x970e8128ab834e8eac17ab8e3812f010678cf791.
+var vrfVerifyTests = []precompiledTest{
+	{
+		input:    "ca634cae0d49acb401d8a4c6b6fe8c55b70d115bf400769cc1400f3258cd31387574077f301b421bc84df7266c44e9e6d569fc56be00812904767bf5ccd1fc7f7b3b4c687c65907a787043f1d76054e4950d771ea1b5e4c6d8e9e47064a0cedb482ed42a2dcfa8e0e6104d5c0c622eba0fdee1bdc4f4cc0e21a5c1b130c049f9047026ead6d4041a3a5d5e08da11b1bb9cb513cb6473c7574294755a6fe9660b46490e4fadaaad38436c0890e19c70809e3d1bc8dd04e641bc321a088c0debe6996d8b6e0f7ed6a3ba5ed5c2e7c0a4b8f2b89cb4c4de1b8a3d14e0b4b2ff3cd2a1",
+		expected: "02957047fc8adfe7b6c7a628b2078b7c2ba51de09ad1d355f0c19719c1eb8c04",
+		name:     "seed",
+	}, {
+		input:    "ca634cae0d49acb401d8a4c6b6fe8c55b70d115bf400769cc1400f3258cd31387574077f301b421bc84df7266c44e9e6d569fc56be00812904767bf5ccd1fc7f09554f011dff27c2617235b456db2a2b00dbe1bbd6337f9b122999b14a3457b3e1bd28606f150b709dfd0e997088992f8974f44d6b32c771758ed07f79e307ce0444fc97195d1ba6f35239d6cf26acce01264e6609eb38a706853cded5f458cc59b47d07841224dd4b54ed4ee0f70fda25d87943ad4c806e9db9f8aa0f94b369aa73616d706c65",
+		expected: "405f81ec25abbf7021a5c0c3e68cd0e977d38f3efd83669e67da7144134a340b",
+		name:     "sample",
+	},
+}
+
 func testPrecompiled(addr string, test precompiledTest, t *testing.T) {
-	p := PrecompiledContractsByzantium[common.HexToAddress(addr)]
+	testPrecompiledContract(PrecompiledContractsByzantium[common.HexToAddress(addr)], test, t)
+}
+
+// testPrecompiledContract runs a test against a precompiled contract.
+func testPrecompiledContract(p PrecompiledContract, test precompiledTest, t *testing.T) {
 	in := common.Hex2Bytes(test.input)
 	contract := NewContract(AccountRef(common.HexToAddress("1337")),
 		nil, new(big.Int), p.RequiredGas(in))
@@ -351,10 +369,14 @@ func testPrecompiled(addr string, test precompiledTest, t *testing.T) {
 }
 
 func benchmarkPrecompiled(addr string, test precompiledTest, bench *testing.B) {
+	benchmarkPrecompiledContract(PrecompiledContractsByzantium[common.HexToAddress(addr)], test, bench)
+}
+
+// benchmarkPrecompiledContract benchmarks a precompiled contract on a test input.
+func benchmarkPrecompiledContract(p PrecompiledContract, test precompiledTest, bench *testing.B) {
 	if test.noBenchmark {
 		return
 	}
-	p := PrecompiledContractsByzantium[common.HexToAddress(addr)]
 	in := common.Hex2Bytes(test.input)
 	reqGas := p.RequiredGas(in)
 	contract := NewContract(AccountRef(common.HexToAddress("1337")),
@@ -481,3 +503,37 @@ func BenchmarkPrecompiledBn256Pairing(bench *testing.B) {
 		benchmarkPrecompiled("08", test, bench)
 	}
 }
+
+// vrfVerifyContract is the VRF proof verification, only precompiled after the
+// VRF fork.
+var vrfVerifyContract = PrecompiledContractsVRF[common.HexToAddress("0101")]
+
+func TestPrecompiledVRFVerify(t *testing.T) {
+	for _, test := range vrfVerifyTests {
+		testPrecompiledContract(vrfVerifyContract, test, t)
+	}
+}
+
+func TestPrecompiledVRFVerifyFail(t *testing.T) {
+	p := vrfVerifyContract
+	valid := common.Hex2Bytes(vrfVerifyTests[0].input)
+
+	tampered := common.CopyBytes(valid)
+	tampered[len(tampered)-1] ^= 0x01
+
+	offcurve := common.CopyBytes(valid)
+	offcurve[0] ^= 0x01
+
+	for name, input := range map[string][]byte{"short": valid[:vrfPubkeyLength+vrfProofLength-1], "alpha": tampered, "pubkey": offcurve} {
+		if res, err := p.Run(input); err == nil {
+			t.Errorf("%s: invalid input accepted: %x", name, res)
+		}
+	}
+}
+
+// Benchmarks the VRF proof verification, priced against the ecrecover one.
+func BenchmarkPrecompiledVRFVerify(bench *testing.B) {
+	for _, test := range vrfVerifyTests {
+		benchmarkPrecompiledContract(vrfVerifyContract, test, bench)
+	}
+}
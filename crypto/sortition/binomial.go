@@ -0,0 +1,35 @@
+// +build !boost
+
+package sortition
+
+import "math"
+
+// binomialCDFWalk returns the smallest j such that ratio <= CDF(j) of the
+// binomial distribution B(n, p), capped at money.
+//
+// The probability mass is accumulated in log space, as (1-p)^n underflows for
+// the committee sizes used by the agreement protocol.
+func binomialCDFWalk(n float64, p float64, ratio float64, money uint64) uint64 {
+	if p >= 1 {
+		return money
+	}
+	var (
+		logP    = math.Log(p)
+		logQ    = math.Log1p(-p)
+		logPmf  = n * logQ
+		cdf     = 0.0
+		maxStep = uint64(n)
+	)
+	if maxStep > money {
+		maxStep = money
+	}
+	for j := uint64(0); j < maxStep; j++ {
+		cdf += math.Exp(logPmf)
+		if ratio <= cdf {
+			return j
+		}
+		k := float64(j)
+		logPmf += math.Log(n-k) - math.Log(k+1) + logP - logQ
+	}
+	return money
+}
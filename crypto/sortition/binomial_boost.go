@@ -0,0 +1,16 @@
+// +build boost
+
+package sortition
+
+// #cgo CFLAGS: -O3
+// #include <stdint.h>
+// #include <stdlib.h>
+// #include "sortition.h"
+import "C"
+
+// binomialCDFWalk returns the smallest j such that ratio <= CDF(j) of the
+// binomial distribution B(n, p), capped at money. This variant uses the
+// boost implementation and is enabled with the boost build tag.
+func binomialCDFWalk(n float64, p float64, ratio float64, money uint64) uint64 {
+	return uint64(C.sortition_binomial_cdf_walk_0(C.double(n), C.double(p), C.double(ratio), C.uint64_t(money)))
+}
@@ -1,3 +1,5 @@
+// +build boost
+
 #include "sortition.h"
 #include <boost/math/distributions/binomial.hpp>
 
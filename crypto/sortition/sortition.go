@@ -1,10 +1,5 @@
 package sortition
 
-// #cgo CFLAGS: -O3
-// #include <stdint.h>
-// #include <stdlib.h>
-// #include "sortition.h"
-import "C"
 import (
 	"math/big"
 )
@@ -30,5 +25,5 @@ func Select(money uint64, totalMoney uint64, expectedSize float64, vrfOutput [32
 	ratio := big.Float{}
 	cratio, _ := ratio.Quo(&h, max).Float64()
 
-	return uint64(C.sortition_binomial_cdf_walk_0(C.double(binomialN), C.double(binomialP), C.double(cratio), C.uint64_t(money)))
+	return binomialCDFWalk(binomialN, binomialP, cratio, money)
 }
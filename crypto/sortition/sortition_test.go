@@ -46,3 +46,28 @@ func TestSortitionBasic(t *testing.T) {
 		t.Errorf("wanted %d selections but got %d, d=%d, maxd=%d", expected, hitcount, d, maxd)
 	}
 }
+
+func TestSortitionLargeCommittee(t *testing.T) {
+	hitcount := uint64(0)
+	const N = 200
+	const expectedSize = 2990
+	const myMoney = 1000000
+	const totalMoney = 1000000
+	for i := 0; i < N; i++ {
+		var vrfOutput [32]byte
+		rand.Read(vrfOutput[:])
+		hitcount += Select(myMoney, totalMoney, expectedSize, vrfOutput)
+	}
+	expected := uint64(N * expectedSize)
+	var d uint64
+	if expected > hitcount {
+		d = expected - hitcount
+	} else {
+		d = hitcount - expected
+	}
+	// within 2% good enough
+	maxd := expected / 50
+	if d > maxd {
+		t.Errorf("wanted %d selections but got %d, d=%d, maxd=%d", expected, hitcount, d, maxd)
+	}
+}
@@ -0,0 +1,439 @@
+// Copyright 2018 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package vrf
+
+// This file implements the ECVRF-EDWARDS25519-SHA512-ELL2 suite of
+// https://tools.ietf.org/html/draft-irtf-cfrg-vrf, hashing to the curve with
+// the edwards25519_XMD:SHA-512_ELL2_NU_ suite of
+// https://tools.ietf.org/html/draft-irtf-cfrg-hash-to-curve.
+//
+// The arithmetic is variable time and only suited for verification and for
+// proving with keys which do not need to be protected from timing attacks.
+
+import (
+	"crypto/sha512"
+	"errors"
+	"fmt"
+	"math/big"
+)
+
+const (
+	ed25519SuiteID   = 0x04 // suite_string of the draft
+	ed25519ProofLen  = 80   // Length of Gamma, c and s
+	ed25519SeedLen   = 32   // Length of an ed25519 private key seed
+	ed25519PointLen  = 32   // Length of an encoded point
+	ed25519FieldLen  = 48   // Length of the uniform bytes of a field element
+	ed25519ScalarLen = 32   // Length of an encoded scalar
+)
+
+var (
+	// ed25519DST is the domain separation tag of the hash to curve, made of
+	// "ECVRF_", the hash to curve suite and the suite string.
+	ed25519DST = append([]byte("ECVRF_edwards25519_XMD:SHA-512_ELL2_NU_"), ed25519SuiteID)
+
+	edP, _  = new(big.Int).SetString("7fffffffffffffffffffffffffffffffffffffffffffffffffffffffffffffed", 16)
+	edL, _  = new(big.Int).SetString("1000000000000000000000000000000014def9dea2f79cd65812631a5cf5d3ed", 16)
+	edD     = edFieldDiv(big.NewInt(-121665), big.NewInt(121666))
+	edD2    = edField(new(big.Int).Lsh(edD, 1))
+	edBaseX = bigFromString("15112221349535400772501151409588531511454012693041857206046113283949847762202")
+	edBaseY = edFieldDiv(big.NewInt(4), big.NewInt(5))
+	edBase  = newEdPoint(edBaseX, edBaseY)
+
+	// Parameters of the Elligator 2 map to curve25519 and of the rational map
+	// from curve25519 to edwards25519.
+	ell2J  = big.NewInt(486662)
+	ell2Z  = big.NewInt(2)
+	ell2C1 = edSqrt(big.NewInt(-486664), 0)
+)
+
+// edwards25519VRF is the ECVRF-EDWARDS25519-SHA512-ELL2 suite. Private keys are
+// 32 byte ed25519 seeds or 64 byte ed25519 private keys, public keys are ed25519
+// public keys.
+type edwards25519VRF struct{}
+
+// Suite implements VRF, returning the name of the suite.
+func (edwards25519VRF) Suite() string { return SuiteEdwards25519SHA512ELL2 }
+
+// PublicKey implements VRF, deriving the ed25519 public key.
+func (edwards25519VRF) PublicKey(sk []byte) ([]byte, error) {
+	x, _, err := ed25519Expand(sk)
+	if err != nil {
+		return nil, err
+	}
+	return new(edPoint).mul(edBase, x).encode(), nil
+}
+
+// Prove implements VRF, following ECVRF_prove.
+func (edwards25519VRF) Prove(sk, alpha []byte) ([]byte, error) {
+	x, prefix, err := ed25519Expand(sk)
+	if err != nil {
+		return nil, err
+	}
+	pk := new(edPoint).mul(edBase, x).encode()
+
+	h := ed25519EncodeToCurve(pk, alpha)
+	hs := h.encode()
+	gamma := new(edPoint).mul(h, x).encode()
+
+	digest := sha512.New()
+	digest.Write(prefix)
+	digest.Write(hs)
+	k := leToInt(digest.Sum(nil))
+	k.Mod(k, edL)
+
+	c := ed25519Challenge(pk, hs, gamma, new(edPoint).mul(edBase, k).encode(), new(edPoint).mul(h, k).encode())
+	s := new(big.Int).Mul(c, x)
+	s.Add(s, k).Mod(s, edL)
+
+	proof := make([]byte, 0, ed25519ProofLen)
+	proof = append(proof, gamma...)
+	proof = append(proof, intToLE(c, ed25519ProofLen-ed25519PointLen-ed25519ScalarLen)...)
+	return append(proof, intToLE(s, ed25519ScalarLen)...), nil
+}
+
+// Verify implements VRF, following ECVRF_verify with public key validation.
+func (edwards25519VRF) Verify(pk, alpha, proof []byte) ([]byte, error) {
+	y, ok := decodeEdPoint(pk)
+	if !ok || y.smallOrder() {
+		return nil, ErrPointNotOnCurve
+	}
+	gamma, c, s, err := ed25519DecodeProof(proof)
+	if err != nil {
+		return nil, err
+	}
+	h := ed25519EncodeToCurve(pk, alpha)
+
+	// U = s*B - c*Y, V = s*H - c*Gamma
+	u := new(edPoint).add(new(edPoint).mul(edBase, s), new(edPoint).mul(y, c).neg())
+	v := new(edPoint).add(new(edPoint).mul(h, s), new(edPoint).mul(gamma, c).neg())
+
+	if ed25519Challenge(pk, h.encode(), proof[:ed25519PointLen], u.encode(), v.encode()).Cmp(c) != 0 {
+		return nil, ErrInvalidVRF
+	}
+	return ed25519GammaToHash(gamma), nil
+}
+
+// ProofToHash implements VRF, following ECVRF_proof_to_hash.
+func (edwards25519VRF) ProofToHash(proof []byte) ([]byte, error) {
+	gamma, _, _, err := ed25519DecodeProof(proof)
+	if err != nil {
+		return nil, err
+	}
+	return ed25519GammaToHash(gamma), nil
+}
+
+// ed25519Expand derives the secret scalar and the nonce prefix of a private key
+// the way ed25519 does.
+func ed25519Expand(sk []byte) (*big.Int, []byte, error) {
+	switch len(sk) {
+	case ed25519SeedLen, 2 * ed25519SeedLen:
+	default:
+		return nil, nil, fmt.Errorf("invalid private key length %d", len(sk))
+	}
+	h := sha512.Sum512(sk[:ed25519SeedLen])
+	h[0] &= 248
+	h[31] &= 127
+	h[31] |= 64
+	return leToInt(h[:32]), h[32:], nil
+}
+
+// ed25519DecodeProof splits a proof into Gamma, c and s.
+func ed25519DecodeProof(proof []byte) (*edPoint, *big.Int, *big.Int, error) {
+	if len(proof) != ed25519ProofLen {
+		return nil, nil, nil, ErrInvalidVRF
+	}
+	gamma, ok := decodeEdPoint(proof[:ed25519PointLen])
+	if !ok {
+		return nil, nil, nil, ErrInvalidVRF
+	}
+	c := leToInt(proof[ed25519PointLen : ed25519ProofLen-ed25519ScalarLen])
+	s := leToInt(proof[ed25519ProofLen-ed25519ScalarLen:])
+	if s.Cmp(edL) >= 0 {
+		return nil, nil, nil, ErrInvalidVRF
+	}
+	return gamma, c, s, nil
+}
+
+// ed25519Challenge hashes the points of a proof into the truncated challenge c,
+// following ECVRF_challenge_generation.
+func ed25519Challenge(points ...[]byte) *big.Int {
+	h := sha512.New()
+	h.Write([]byte{ed25519SuiteID, 0x02})
+	for _, p := range points {
+		h.Write(p)
+	}
+	h.Write([]byte{0x00})
+	return leToInt(h.Sum(nil)[:ed25519ProofLen-ed25519PointLen-ed25519ScalarLen])
+}
+
+// ed25519GammaToHash derives the VRF output from the point Gamma of a proof.
+func ed25519GammaToHash(gamma *edPoint) []byte {
+	h := sha512.New()
+	h.Write([]byte{ed25519SuiteID, 0x03})
+	h.Write(new(edPoint).mulByCofactor(gamma).encode())
+	h.Write([]byte{0x00})
+	return h.Sum(nil)
+}
+
+// ed25519EncodeToCurve hashes alpha salted with the public key to a point of
+// the prime order subgroup, following encode_to_curve of the hash to curve
+// suite.
+func ed25519EncodeToCurve(pk, alpha []byte) *edPoint {
+	msg := make([]byte, 0, len(pk)+len(alpha))
+	msg = append(append(msg, pk...), alpha...)
+
+	u := new(big.Int).SetBytes(expandMessageXMD(msg, ed25519DST, ed25519FieldLen))
+	u.Mod(u, edP)
+
+	return new(edPoint).mulByCofactor(ell2MapToCurve(u))
+}
+
+// expandMessageXMD expands a message into uniform bytes with SHA-512, following
+// expand_message_xmd of the hash to curve draft.
+func expandMessageXMD(msg, dst []byte, length int) []byte {
+	var (
+		ell      = (length + sha512.Size - 1) / sha512.Size
+		dstPrime = append(append([]byte{}, dst...), byte(len(dst)))
+	)
+	h := sha512.New()
+	h.Write(make([]byte, sha512.BlockSize))
+	h.Write(msg)
+	h.Write([]byte{byte(length >> 8), byte(length), 0x00})
+	h.Write(dstPrime)
+	b0 := h.Sum(nil)
+
+	h.Reset()
+	h.Write(b0)
+	h.Write([]byte{0x01})
+	h.Write(dstPrime)
+	bi := h.Sum(nil)
+
+	uniform := append([]byte{}, bi...)
+	for i := 2; i <= ell; i++ {
+		chain := make([]byte, sha512.Size)
+		for j := range chain {
+			chain[j] = b0[j] ^ bi[j]
+		}
+		h.Reset()
+		h.Write(chain)
+		h.Write([]byte{byte(i)})
+		h.Write(dstPrime)
+		bi = h.Sum(nil)
+		uniform = append(uniform, bi...)
+	}
+	return uniform[:length]
+}
+
+// ell2MapToCurve maps a field element to edwards25519 with Elligator 2 onto
+// curve25519 followed by the rational map to edwards25519.
+func ell2MapToCurve(u *big.Int) *edPoint {
+	// x1 = -J / (1 + Z * u^2), or -J if the denominator is zero
+	tv := edField(new(big.Int).Mul(u, u))
+	tv = edField(tv.Mul(tv, ell2Z).Add(tv, big.NewInt(1)))
+
+	x1 := edField(new(big.Int).Neg(ell2J))
+	if tv.Sign() != 0 {
+		x1 = edFieldDiv(x1, tv)
+	}
+	x2 := edField(new(big.Int).Sub(new(big.Int).Neg(x1), ell2J))
+
+	var s, t *big.Int
+	if gx1 := ell2Montgomery(x1); edIsSquare(gx1) {
+		s, t = x1, edSqrt(gx1, 1)
+	} else {
+		s, t = x2, edSqrt(ell2Montgomery(x2), 0)
+	}
+	// (x, y) = (c1 * s / t, (s - 1) / (s + 1)), or the identity at the poles
+	sp1 := edField(new(big.Int).Add(s, big.NewInt(1)))
+	if t.Sign() == 0 || sp1.Sign() == 0 {
+		return newEdPoint(new(big.Int), big.NewInt(1))
+	}
+	x := edFieldDiv(new(big.Int).Mul(ell2C1, s), t)
+	y := edFieldDiv(new(big.Int).Sub(s, big.NewInt(1)), sp1)
+	return newEdPoint(x, y)
+}
+
+// ell2Montgomery evaluates the right hand side of curve25519, x^3 + J*x^2 + x.
+func ell2Montgomery(x *big.Int) *big.Int {
+	x2 := new(big.Int).Mul(x, x)
+	gx := new(big.Int).Mul(x2, x)
+	gx.Add(gx, x2.Mul(x2, ell2J))
+	return edField(gx.Add(gx, x))
+}
+
+// edPoint is a point of edwards25519 in extended coordinates, with x = X/Z,
+// y = Y/Z and x*y = T/Z.
+type edPoint struct {
+	X, Y, Z, T *big.Int
+}
+
+// newEdPoint creates a point from its affine coordinates.
+func newEdPoint(x, y *big.Int) *edPoint {
+	return &edPoint{
+		X: new(big.Int).Set(x),
+		Y: new(big.Int).Set(y),
+		Z: big.NewInt(1),
+		T: edField(new(big.Int).Mul(x, y)),
+	}
+}
+
+// decodeEdPoint decodes a point in the encoding of RFC 8032, rejecting
+// non-canonical encodings.
+func decodeEdPoint(data []byte) (*edPoint, bool) {
+	if len(data) != ed25519PointLen {
+		return nil, false
+	}
+	enc := append([]byte{}, data...)
+	sign := uint(enc[31] >> 7)
+	enc[31] &= 0x7f
+
+	y := leToInt(enc)
+	if y.Cmp(edP) >= 0 {
+		return nil, false
+	}
+	// x^2 = (y^2 - 1) / (d*y^2 + 1)
+	y2 := edField(new(big.Int).Mul(y, y))
+	num := edField(new(big.Int).Sub(y2, big.NewInt(1)))
+	den := edField(new(big.Int).Add(new(big.Int).Mul(edD, y2), big.NewInt(1)))
+	x2 := edFieldDiv(num, den)
+
+	if !edIsSquare(x2) {
+		return nil, false
+	}
+	if x2.Sign() == 0 && sign == 1 {
+		return nil, false
+	}
+	return newEdPoint(edSqrt(x2, sign), y), true
+}
+
+// encode encodes the point in the encoding of RFC 8032.
+func (p *edPoint) encode() []byte {
+	zi := new(big.Int).ModInverse(p.Z, edP)
+	x := edField(new(big.Int).Mul(p.X, zi))
+	y := edField(new(big.Int).Mul(p.Y, zi))
+
+	enc := intToLE(y, ed25519PointLen)
+	enc[31] |= byte(x.Bit(0)) << 7
+	return enc
+}
+
+// add sets p to a + b and returns p, using the complete addition formula of
+// twisted Edwards curves with a = -1.
+func (p *edPoint) add(a, b *edPoint) *edPoint {
+	var (
+		pa = edField(new(big.Int).Mul(new(big.Int).Sub(a.Y, a.X), new(big.Int).Sub(b.Y, b.X)))
+		pb = edField(new(big.Int).Mul(new(big.Int).Add(a.Y, a.X), new(big.Int).Add(b.Y, b.X)))
+		pc = edField(new(big.Int).Mul(new(big.Int).Mul(a.T, edD2), b.T))
+		pd = edField(new(big.Int).Lsh(new(big.Int).Mul(a.Z, b.Z), 1))
+		pe = new(big.Int).Sub(pb, pa)
+		pf = new(big.Int).Sub(pd, pc)
+		pg = new(big.Int).Add(pd, pc)
+		ph = new(big.Int).Add(pb, pa)
+	)
+	p.X = edField(new(big.Int).Mul(pe, pf))
+	p.Y = edField(new(big.Int).Mul(pg, ph))
+	p.T = edField(new(big.Int).Mul(pe, ph))
+	p.Z = edField(new(big.Int).Mul(pf, pg))
+	return p
+}
+
+// neg sets p to -p and returns p.
+func (p *edPoint) neg() *edPoint {
+	p.X = edField(new(big.Int).Neg(p.X))
+	p.T = edField(new(big.Int).Neg(p.T))
+	return p
+}
+
+// mul sets p to k*a and returns p.
+func (p *edPoint) mul(a *edPoint, k *big.Int) *edPoint {
+	r := newEdPoint(new(big.Int), big.NewInt(1))
+	for i := k.BitLen() - 1; i >= 0; i-- {
+		r.add(r, r)
+		if k.Bit(i) == 1 {
+			r.add(r, a)
+		}
+	}
+	*p = *r
+	return p
+}
+
+// mulByCofactor sets p to 8*a and returns p.
+func (p *edPoint) mulByCofactor(a *edPoint) *edPoint {
+	return p.mul(a, big.NewInt(8))
+}
+
+// smallOrder returns whether the point is of an order dividing the cofactor.
+func (p *edPoint) smallOrder() bool {
+	q := new(edPoint).mulByCofactor(p)
+	return q.X.Sign() == 0 && q.Y.Cmp(q.Z) == 0
+}
+
+// edField reduces x modulo the field prime in place and returns it.
+func edField(x *big.Int) *big.Int {
+	return x.Mod(x, edP)
+}
+
+// edFieldDiv returns a / b in the field.
+func edFieldDiv(a, b *big.Int) *big.Int {
+	inv := new(big.Int).ModInverse(edField(new(big.Int).Set(b)), edP)
+	return edField(inv.Mul(inv, a))
+}
+
+// edIsSquare returns whether x is a square in the field, zero included.
+func edIsSquare(x *big.Int) bool {
+	return big.Jacobi(x, edP) >= 0
+}
+
+// edSqrt returns the square root of x in the field whose least significant bit
+// is sign. The result is undefined if x is not a square.
+func edSqrt(x *big.Int, sign uint) *big.Int {
+	r := new(big.Int).ModSqrt(edField(new(big.Int).Set(x)), edP)
+	if r == nil {
+		return new(big.Int)
+	}
+	if r.Bit(0) != sign {
+		r.Sub(edP, r)
+	}
+	return edField(r)
+}
+
+// leToInt decodes a little endian unsigned integer.
+func leToInt(b []byte) *big.Int {
+	be := make([]byte, len(b))
+	for i := range b {
+		be[len(b)-1-i] = b[i]
+	}
+	return new(big.Int).SetBytes(be)
+}
+
+// intToLE encodes an unsigned integer in little endian into n bytes.
+func intToLE(x *big.Int, n int) []byte {
+	le := x.FillBytes(make([]byte, n))
+	for i, j := 0, n-1; i < j; i, j = i+1, j-1 {
+		le[i], le[j] = le[j], le[i]
+	}
+	return le
+}
+
+// bigFromString parses a decimal constant.
+func bigFromString(s string) *big.Int {
+	n, ok := new(big.Int).SetString(s, 10)
+	if !ok {
+		panic(errors.New("invalid constant " + s))
+	}
+	return n
+}
@@ -0,0 +1,302 @@
+// Copyright 2018 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package vrf
+
+// This file implements the try-and-increment ECVRF suites of
+// https://tools.ietf.org/html/draft-irtf-cfrg-vrf on short Weierstrass curves
+// with a cofactor of 1 and SHA-256 as hash function.
+
+import (
+	"bytes"
+	"crypto/elliptic"
+	"crypto/hmac"
+	"crypto/sha256"
+	"errors"
+	"fmt"
+	"math/big"
+)
+
+var (
+	p256SHA256TAI = &taiVRF{
+		suite:     SuiteP256SHA256TAI,
+		id:        0x01,
+		curve:     elliptic.P256(),
+		unmarshal: elliptic.UnmarshalCompressed,
+	}
+	// There is no registered suite string for secp256k1, use the one of the
+	// private range in use by other implementations of this suite.
+	secp256k1SHA256TAI = &taiVRF{
+		suite:     SuiteSecp256k1SHA256TAI,
+		id:        0xfe,
+		curve:     curve,
+		unmarshal: Unmarshal,
+	}
+)
+
+const (
+	taiChallengeLen = 16  // Length of the truncated challenge, n in the draft
+	taiMaxTries     = 256 // Counter values of the encode to curve, a single octet
+)
+
+// taiVRF is an ECVRF suite using the try-and-increment encode to curve. Private
+// keys are 32 byte scalars, public keys and points are compressed.
+type taiVRF struct {
+	suite     string
+	id        byte // suite_string of the draft
+	curve     elliptic.Curve
+	unmarshal func(elliptic.Curve, []byte) (x, y *big.Int) // Compressed point decoder
+}
+
+// Suite implements VRF, returning the name of the suite.
+func (v *taiVRF) Suite() string { return v.suite }
+
+// PublicKey implements VRF, deriving the compressed public key.
+func (v *taiVRF) PublicKey(sk []byte) ([]byte, error) {
+	if _, err := v.secret(sk); err != nil {
+		return nil, err
+	}
+	return v.marshal(v.curve.ScalarBaseMult(sk)), nil
+}
+
+// Prove implements VRF, following ECVRF_prove.
+func (v *taiVRF) Prove(sk, alpha []byte) ([]byte, error) {
+	x, err := v.secret(sk)
+	if err != nil {
+		return nil, err
+	}
+	params := v.curve.Params()
+	pk := v.marshal(v.curve.ScalarBaseMult(sk))
+
+	hx, hy, err := v.encodeToCurve(pk, alpha)
+	if err != nil {
+		return nil, err
+	}
+	h := v.marshal(hx, hy)
+	gamma := v.marshal(v.curve.ScalarMult(hx, hy, sk))
+
+	k := v.nonce(x, h)
+	kb := k.FillBytes(make([]byte, v.scalarLen()))
+	c := v.challenge(pk, h, gamma, v.marshal(v.curve.ScalarBaseMult(kb)), v.marshal(v.curve.ScalarMult(hx, hy, kb)))
+
+	s := new(big.Int).Mul(c, x)
+	s.Add(s, k).Mod(s, params.N)
+
+	proof := append(gamma, c.FillBytes(make([]byte, taiChallengeLen))...)
+	return append(proof, s.FillBytes(make([]byte, v.scalarLen()))...), nil
+}
+
+// Verify implements VRF, following ECVRF_verify with public key validation.
+func (v *taiVRF) Verify(pk, alpha, proof []byte) ([]byte, error) {
+	yx, yy := v.decodePoint(pk)
+	if yx == nil {
+		return nil, ErrPointNotOnCurve
+	}
+	gx, gy, c, s, err := v.decodeProof(proof)
+	if err != nil {
+		return nil, err
+	}
+	hx, hy, err := v.encodeToCurve(pk, alpha)
+	if err != nil {
+		return nil, err
+	}
+	// U = s*B - c*Y, V = s*H - c*Gamma
+	var (
+		sb = s.FillBytes(make([]byte, v.scalarLen()))
+		cb = c.FillBytes(make([]byte, v.scalarLen()))
+	)
+	sbx, sby := v.curve.ScalarBaseMult(sb)
+	cyx, cyy := v.curve.ScalarMult(yx, yy, cb)
+	shx, shy := v.curve.ScalarMult(hx, hy, sb)
+	cgx, cgy := v.curve.ScalarMult(gx, gy, cb)
+
+	ux, uy := v.sub(sbx, sby, cyx, cyy)
+	wx, wy := v.sub(shx, shy, cgx, cgy)
+	if ux == nil || wx == nil {
+		return nil, ErrInvalidVRF
+	}
+	want := v.challenge(pk, v.marshal(hx, hy), proof[:v.pointLen()], v.marshal(ux, uy), v.marshal(wx, wy))
+	if want.Cmp(c) != 0 {
+		return nil, ErrInvalidVRF
+	}
+	return v.gammaToHash(gx, gy), nil
+}
+
+// ProofToHash implements VRF, following ECVRF_proof_to_hash.
+func (v *taiVRF) ProofToHash(proof []byte) ([]byte, error) {
+	gx, gy, _, _, err := v.decodeProof(proof)
+	if err != nil {
+		return nil, err
+	}
+	return v.gammaToHash(gx, gy), nil
+}
+
+// secret decodes a private key into its scalar.
+func (v *taiVRF) secret(sk []byte) (*big.Int, error) {
+	if len(sk) != v.scalarLen() {
+		return nil, fmt.Errorf("invalid private key length %d", len(sk))
+	}
+	x := new(big.Int).SetBytes(sk)
+	if x.Sign() == 0 || x.Cmp(v.curve.Params().N) >= 0 {
+		return nil, errors.New("invalid private key")
+	}
+	return x, nil
+}
+
+// scalarLen returns the length of an encoded scalar, qLen in the draft.
+func (v *taiVRF) scalarLen() int {
+	return (v.curve.Params().N.BitLen() + 7) / 8
+}
+
+// pointLen returns the length of a compressed point, ptLen in the draft.
+func (v *taiVRF) pointLen() int {
+	return 1 + (v.curve.Params().BitSize+7)/8
+}
+
+// decodePoint decodes a compressed point, returning nil if it is malformed or
+// not on the curve.
+func (v *taiVRF) decodePoint(data []byte) (x, y *big.Int) {
+	if len(data) != v.pointLen() {
+		return nil, nil
+	}
+	x, y = v.unmarshal(v.curve, data)
+	if x == nil || x.Cmp(v.curve.Params().P) >= 0 || !v.curve.IsOnCurve(x, y) {
+		return nil, nil
+	}
+	return x, y
+}
+
+// decodeProof splits a proof into Gamma, c and s.
+func (v *taiVRF) decodeProof(proof []byte) (gx, gy, c, s *big.Int, err error) {
+	if len(proof) != v.pointLen()+taiChallengeLen+v.scalarLen() {
+		return nil, nil, nil, nil, ErrInvalidVRF
+	}
+	if gx, gy = v.decodePoint(proof[:v.pointLen()]); gx == nil {
+		return nil, nil, nil, nil, ErrInvalidVRF
+	}
+	c = new(big.Int).SetBytes(proof[v.pointLen() : v.pointLen()+taiChallengeLen])
+	s = new(big.Int).SetBytes(proof[v.pointLen()+taiChallengeLen:])
+	if s.Cmp(v.curve.Params().N) >= 0 {
+		return nil, nil, nil, nil, ErrInvalidVRF
+	}
+	return gx, gy, c, s, nil
+}
+
+// encodeToCurve hashes alpha salted with the public key to a point, following
+// ECVRF_encode_to_curve_try_and_increment.
+func (v *taiVRF) encodeToCurve(pk, alpha []byte) (x, y *big.Int, err error) {
+	for ctr := 0; ctr < taiMaxTries; ctr++ {
+		h := sha256.New()
+		h.Write([]byte{v.id, 0x01})
+		h.Write(pk)
+		h.Write(alpha)
+		h.Write([]byte{byte(ctr), 0x00})
+
+		if x, y = v.decodePoint(h.Sum([]byte{0x02})); x != nil {
+			return x, y, nil
+		}
+	}
+	return nil, nil, errors.New("failed to encode to curve")
+}
+
+// nonce derives the deterministic nonce of RFC 6979 for the secret scalar x and
+// the message m.
+func (v *taiVRF) nonce(x *big.Int, m []byte) *big.Int {
+	var (
+		q     = v.curve.Params().N
+		rlen  = v.scalarLen()
+		h1    = sha256.Sum256(m)
+		bx    = x.FillBytes(make([]byte, rlen))
+		bh    = v.bits2int(h1[:])
+		vv    = bytes.Repeat([]byte{0x01}, sha256.Size)
+		kk    = make([]byte, sha256.Size)
+		hmacK = func(key []byte, data ...[]byte) []byte {
+			mac := hmac.New(sha256.New, key)
+			for _, d := range data {
+				mac.Write(d)
+			}
+			return mac.Sum(nil)
+		}
+	)
+	bh.Mod(bh, q)
+	bm := bh.FillBytes(make([]byte, rlen))
+
+	kk = hmacK(kk, vv, []byte{0x00}, bx, bm)
+	vv = hmacK(kk, vv)
+	kk = hmacK(kk, vv, []byte{0x01}, bx, bm)
+	vv = hmacK(kk, vv)
+	for {
+		var t []byte
+		for len(t) < rlen {
+			vv = hmacK(kk, vv)
+			t = append(t, vv...)
+		}
+		if k := v.bits2int(t); k.Sign() > 0 && k.Cmp(q) < 0 {
+			return k
+		}
+		kk = hmacK(kk, vv, []byte{0x00})
+		vv = hmacK(kk, vv)
+	}
+}
+
+// bits2int converts a bit string to an integer of the bit length of the group
+// order, as defined by RFC 6979.
+func (v *taiVRF) bits2int(b []byte) *big.Int {
+	i := new(big.Int).SetBytes(b)
+	if excess := len(b)*8 - v.curve.Params().N.BitLen(); excess > 0 {
+		i.Rsh(i, uint(excess))
+	}
+	return i
+}
+
+// challenge hashes the points of a proof into the truncated challenge c,
+// following ECVRF_challenge_generation.
+func (v *taiVRF) challenge(points ...[]byte) *big.Int {
+	h := sha256.New()
+	h.Write([]byte{v.id, 0x02})
+	for _, p := range points {
+		h.Write(p)
+	}
+	h.Write([]byte{0x00})
+	return new(big.Int).SetBytes(h.Sum(nil)[:taiChallengeLen])
+}
+
+// gammaToHash derives the VRF output from the point Gamma of a proof.
+func (v *taiVRF) gammaToHash(gx, gy *big.Int) []byte {
+	h := sha256.New()
+	h.Write([]byte{v.id, 0x03})
+	h.Write(v.marshal(gx, gy))
+	h.Write([]byte{0x00})
+	return h.Sum(nil)
+}
+
+// marshal encodes a point in compressed form.
+func (v *taiVRF) marshal(x, y *big.Int) []byte {
+	return elliptic.MarshalCompressed(v.curve, x, y)
+}
+
+// sub returns the difference of two points, or nil if either of them is the
+// point at infinity or the difference is.
+func (v *taiVRF) sub(x1, y1, x2, y2 *big.Int) (*big.Int, *big.Int) {
+	if x1 == nil || x2 == nil {
+		return nil, nil
+	}
+	x, y := v.curve.Add(x1, y1, x2, new(big.Int).Sub(v.curve.Params().P, y2))
+	if x == nil || (x.Sign() == 0 && y.Sign() == 0) {
+		return nil, nil
+	}
+	return x, y
+}
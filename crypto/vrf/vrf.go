@@ -0,0 +1,172 @@
+// Copyright 2018 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package vrf
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/sha256"
+	"errors"
+	"fmt"
+	"math/big"
+)
+
+// Names of the supported VRF suites.
+const (
+	// SuiteConiksSecp256k1 is the CONIKS discrete log VRF on secp256k1
+	// implemented by PrivateKey.Evaluate and PublicKey.ProofToHash.
+	SuiteConiksSecp256k1 = "CONIKS-SECP256K1"
+
+	// SuiteEdwards25519SHA512ELL2 is the ECVRF-EDWARDS25519-SHA512-ELL2 suite of
+	// draft-irtf-cfrg-vrf, keyed with ed25519 seeds.
+	SuiteEdwards25519SHA512ELL2 = "ECVRF-EDWARDS25519-SHA512-ELL2"
+
+	// SuiteP256SHA256TAI is the ECVRF-P256-SHA256-TAI suite of
+	// draft-irtf-cfrg-vrf.
+	SuiteP256SHA256TAI = "ECVRF-P256-SHA256-TAI"
+
+	// SuiteSecp256k1SHA256TAI is the try-and-increment ECVRF of
+	// draft-irtf-cfrg-vrf instantiated on secp256k1, keyed with the same keys
+	// as the accounts.
+	SuiteSecp256k1SHA256TAI = "ECVRF-SECP256K1-SHA256-TAI"
+)
+
+// ErrUnknownSuite is returned if a VRF suite is requested by an unknown name.
+var ErrUnknownSuite = errors.New("unknown VRF suite")
+
+// VRF is a verifiable random function suite. Keys, proofs and outputs are
+// passed in the byte encoding of the suite.
+type VRF interface {
+	// Suite returns the name of the suite.
+	Suite() string
+
+	// PublicKey derives the public key of a private key.
+	PublicKey(sk []byte) ([]byte, error)
+
+	// Prove computes the proof of the output of the private key on alpha.
+	Prove(sk, alpha []byte) ([]byte, error)
+
+	// Verify checks a proof of the public key on alpha and returns the output
+	// it commits to, or ErrInvalidVRF if the proof does not validate.
+	Verify(pk, alpha, proof []byte) ([]byte, error)
+
+	// ProofToHash returns the output a proof commits to without verifying it.
+	// It must only be used on proofs known to be valid.
+	ProofToHash(proof []byte) ([]byte, error)
+}
+
+// suites are the supported VRF suites by name.
+var suites = map[string]VRF{
+	SuiteConiksSecp256k1:        coniksVRF{},
+	SuiteEdwards25519SHA512ELL2: edwards25519VRF{},
+	SuiteP256SHA256TAI:          p256SHA256TAI,
+	SuiteSecp256k1SHA256TAI:     secp256k1SHA256TAI,
+}
+
+// New returns the VRF suite of the given name.
+func New(suite string) (VRF, error) {
+	if v, ok := suites[suite]; ok {
+		return v, nil
+	}
+	return nil, fmt.Errorf("%v: %q", ErrUnknownSuite, suite)
+}
+
+// coniksVRF adapts the CONIKS VRF on secp256k1 to the VRF interface. Private
+// keys are 32 byte scalars, public keys uncompressed or compressed points.
+type coniksVRF struct{}
+
+// Suite implements VRF, returning the name of the suite.
+func (coniksVRF) Suite() string { return SuiteConiksSecp256k1 }
+
+// PublicKey implements VRF, deriving the compressed public key.
+func (coniksVRF) PublicKey(sk []byte) ([]byte, error) {
+	key, err := toS256Key(sk)
+	if err != nil {
+		return nil, err
+	}
+	return marshalCompressed(key.X, key.Y), nil
+}
+
+// Prove implements VRF, evaluating the VRF on alpha.
+func (coniksVRF) Prove(sk, alpha []byte) ([]byte, error) {
+	key, err := toS256Key(sk)
+	if err != nil {
+		return nil, err
+	}
+	_, proof := PrivateKey{PrivateKey: key}.Evaluate(alpha)
+	if proof == nil {
+		return nil, errors.New("failed to evaluate VRF")
+	}
+	return proof, nil
+}
+
+// Verify implements VRF, checking the proof of alpha.
+func (coniksVRF) Verify(pk, alpha, proof []byte) ([]byte, error) {
+	x, y := unmarshalS256(pk)
+	if x == nil {
+		return nil, ErrPointNotOnCurve
+	}
+	pub := &PublicKey{PublicKey: &ecdsa.PublicKey{Curve: curve, X: x, Y: y}}
+	index, err := pub.ProofToHash(alpha, proof)
+	if err != nil {
+		return nil, err
+	}
+	return index[:], nil
+}
+
+// ProofToHash implements VRF, hashing the VRF point of the proof.
+func (coniksVRF) ProofToHash(proof []byte) ([]byte, error) {
+	if len(proof) != 64+65 {
+		return nil, ErrInvalidVRF
+	}
+	index := sha256.Sum256(proof[64:])
+	return index[:], nil
+}
+
+// toS256Key converts a 32 byte scalar into a secp256k1 private key.
+func toS256Key(sk []byte) (*ecdsa.PrivateKey, error) {
+	if len(sk) != 32 {
+		return nil, fmt.Errorf("invalid private key length %d", len(sk))
+	}
+	d := new(big.Int).SetBytes(sk)
+	if d.Sign() == 0 || d.Cmp(params.N) >= 0 {
+		return nil, errors.New("invalid private key")
+	}
+	key := &ecdsa.PrivateKey{PublicKey: ecdsa.PublicKey{Curve: curve}, D: d}
+	key.X, key.Y = curve.ScalarBaseMult(sk)
+	return key, nil
+}
+
+// unmarshalS256 decodes an uncompressed or compressed secp256k1 point, returning
+// nil if it is malformed or not on the curve.
+func unmarshalS256(data []byte) (x, y *big.Int) {
+	switch len(data) {
+	case 65:
+		x, y = elliptic.Unmarshal(curve, data)
+	case 33:
+		x, y = Unmarshal(curve, data)
+	}
+	if x == nil || !curve.IsOnCurve(x, y) {
+		return nil, nil
+	}
+	return x, y
+}
+
+// marshalCompressed encodes a secp256k1 point in compressed form.
+func marshalCompressed(x, y *big.Int) []byte {
+	return elliptic.MarshalCompressed(curve, x, y)
+}
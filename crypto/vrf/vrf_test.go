@@ -0,0 +1,189 @@
+// Copyright 2018 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package vrf
+
+import (
+	"bytes"
+	"encoding/hex"
+	"testing"
+)
+
+// Test vectors of draft-irtf-cfrg-vrf. The draft has none for secp256k1, its
+// vectors were generated with an independent implementation of the draft for
+// the suite string 0xfe, which reproduces the P-256 vectors below.
+var vrfTests = []struct {
+	suite string
+	sk    string
+	pk    string
+	alpha string
+	proof string
+	beta  string
+}{
+	{
+		suite: SuiteEdwards25519SHA512ELL2,
+		sk:    "9d61b19deffd5a60ba844af492ec2cc44449c5697b326919703bac031cae7f60",
+		pk:    "d75a980182b10ab7d54bfed3c964073a0ee172f3daa62325af021a68f707511a",
+		alpha: "",
+		proof: "7d9c633ffeee27349264cf5c667579fc583b4bda63ab71d001f89c10003ab46f14adf9a3cd8b8412d9038531e865c341cafa73589b023d14311c331a9ad15ff2fb37831e00f0acaa6d73bc9997b06501",
+		beta:  "9d574bf9b8302ec0fc1e21c3ec5368269527b87b462ce36dab2d14ccf80c53cccf6758f058c5b1c856b116388152bbe509ee3b9ecfe63d93c3b4346c1fbc6c54",
+	},
+	{
+		suite: SuiteEdwards25519SHA512ELL2,
+		sk:    "4ccd089b28ff96da9db6c346ec114e0f5b8a319f35aba624da8cf6ed4fb8a6fb",
+		pk:    "3d4017c3e843895a92b70aa74d1b7ebc9c982ccf2ec4968cc0cd55f12af4660c",
+		alpha: "72",
+		proof: "47b327393ff2dd81336f8a2ef10339112401253b3c714eeda879f12c509072ef055b48372bb82efbdce8e10c8cb9a2f9d60e93908f93df1623ad78a86a028d6bc064dbfc75a6a57379ef855dc6733801",
+		beta:  "38561d6b77b71d30eb97a062168ae12b667ce5c28caccdf76bc88e093e4635987cd96814ce55b4689b3dd2947f80e59aac7b7675f8083865b46c89b2ce9cc735",
+	},
+	{
+		suite: SuiteEdwards25519SHA512ELL2,
+		sk:    "c5aa8df43f9f837bedb7442f31dcb7b166d38535076f094b85ce3a2e0b4458f7",
+		pk:    "fc51cd8e6218a1a38da47ed00230f0580816ed13ba3303ac5deb911548908025",
+		alpha: "af82",
+		proof: "926e895d308f5e328e7aa159c06eddbe56d06846abf5d98c2512235eaa57fdce35b46edfc655bc828d44ad09d1150f31374e7ef73027e14760d42e77341fe05467bb286cc2c9d7fde29120a0b2320d04",
+		beta:  "121b7f9b9aaaa29099fc04a94ba52784d44eac976dd1a3cca458733be5cd090a7b5fbd148444f17f8daf1fb55cb04b1ae85a626e30a54b4b0f8abf4a43314a58",
+	},
+	{
+		suite: SuiteP256SHA256TAI,
+		sk:    "c9afa9d845ba75166b5c215767b1d6934e50c3db36e89b127b8a622b120f6721",
+		pk:    "0360fed4ba255a9d31c961eb74c6356d68c049b8923b61fa6ce669622e60f29fb6",
+		alpha: "73616d706c65",
+		proof: "035b5c726e8c0e2c488a107c600578ee75cb702343c153cb1eb8dec77f4b5071b4a53f0a46f018bc2c56e58d383f2305e0975972c26feea0eb122fe7893c15af376b33edf7de17c6ea056d4d82de6bc02f",
+		beta:  "a3ad7b0ef73d8fc6655053ea22f9bede8c743f08bbed3d38821f0e16474b505e",
+	},
+	{
+		suite: SuiteP256SHA256TAI,
+		sk:    "c9afa9d845ba75166b5c215767b1d6934e50c3db36e89b127b8a622b120f6721",
+		pk:    "0360fed4ba255a9d31c961eb74c6356d68c049b8923b61fa6ce669622e60f29fb6",
+		alpha: "74657374",
+		proof: "034dac60aba508ba0c01aa9be80377ebd7562c4a52d74722e0abae7dc3080ddb56c19e067b15a8a8174905b13617804534214f935b94c2287f797e393eb0816969d864f37625b443f30f1a5a33f2b3c854",
+		beta:  "a284f94ceec2ff4b3794629da7cbafa49121972671b466cab4ce170aa365f26d",
+	},
+	{
+		suite: SuiteSecp256k1SHA256TAI,
+		sk:    "c9afa9d845ba75166b5c215767b1d6934e50c3db36e89b127b8a622b120f6721",
+		pk:    "032c8c31fc9f990c6b55e3865a184a4ce50e09481f2eaeb3e60ec1cea13a6ae645",
+		alpha: "",
+		proof: "03ea3a3f2fadddc36eb70d8c81797a92621cbaaecfd03cbf5916a990073181a29a8be98ee0c5eaa88c3040325b123ea1d9c4e99d19296d337aeaaec94549387700801cfc3320dc17995c8e31d10af6f690",
+		beta:  "dcc8f9f13ecef09eab19c58beddf5d1e644eb072af29e6139b7ec89786b4153f",
+	},
+	{
+		suite: SuiteSecp256k1SHA256TAI,
+		sk:    "c9afa9d845ba75166b5c215767b1d6934e50c3db36e89b127b8a622b120f6721",
+		pk:    "032c8c31fc9f990c6b55e3865a184a4ce50e09481f2eaeb3e60ec1cea13a6ae645",
+		alpha: "73616d706c65",
+		proof: "0338ec99b5d0f94ebcc2c704c04af3de8b4289df8798e5fb9f920d7f5d77ac03d7718b9677d1c9348649ac2ec4f7ecbe519b30dd10c4eb5efc21dd5944709f2f3b7e97a25f6f095334593502d05103bc5b",
+		beta:  "d466c22e14dc3b7fd169668dd3ee9ac6351429a24aebc5e8af61a0f0de89b65a",
+	},
+	{
+		suite: SuiteSecp256k1SHA256TAI,
+		sk:    "c9afa9d845ba75166b5c215767b1d6934e50c3db36e89b127b8a622b120f6721",
+		pk:    "032c8c31fc9f990c6b55e3865a184a4ce50e09481f2eaeb3e60ec1cea13a6ae645",
+		alpha: "74657374",
+		proof: "020ead2dc62f604a6ae2003b6c3012cf7ce2988dedf7606110e66edd5bb7f4b17bec303fd0bff5bfdff67ff6e4b6d4775d9efbe999f4d2467b61ab58659b6385c1a6c55fe84d1bb56c70152856a641364f",
+		beta:  "20b81616f3a3a4c51986e61f3b8e8e80d84f7fa0e05933bd0317150a5a250c09",
+	},
+	{
+		suite: SuiteSecp256k1SHA256TAI,
+		sk:    "b71c71a67e1177ad4e901695e1b4b9ee17ae16c6668d313eac2f96dbcda3f291",
+		pk:    "03ca634cae0d49acb401d8a4c6b6fe8c55b70d115bf400769cc1400f3258cd3138",
+		alpha: "72",
+		proof: "02b8a72665bb865f23938936fbf664e839a6f01b7bb4600fdd25d4ebd4d5f38553cd4a177a15b072a0cd856aecbf38fc749dd65407dc55ed24ca0b9b6337a715d54ee09908875ee58afceb7107bd2266b4",
+		beta:  "9d7e8bf9be763e6087c0c098a5109de3b91c57aebddd4c95a2ce3f44d3329bbb",
+	},
+}
+
+func TestVRFVectors(t *testing.T) {
+	for i, tt := range vrfTests {
+		v, err := New(tt.suite)
+		if err != nil {
+			t.Fatalf("test %d: %v", i, err)
+		}
+		var (
+			sk, _    = hex.DecodeString(tt.sk)
+			pk, _    = hex.DecodeString(tt.pk)
+			alpha, _ = hex.DecodeString(tt.alpha)
+			proof, _ = hex.DecodeString(tt.proof)
+			beta, _  = hex.DecodeString(tt.beta)
+		)
+		if have, err := v.PublicKey(sk); err != nil || !bytes.Equal(have, pk) {
+			t.Errorf("test %d: public key mismatch: have %x, want %x, err %v", i, have, pk, err)
+		}
+		if have, err := v.Prove(sk, alpha); err != nil || !bytes.Equal(have, proof) {
+			t.Errorf("test %d: proof mismatch: have %x, want %x, err %v", i, have, proof, err)
+		}
+		if have, err := v.Verify(pk, alpha, proof); err != nil || !bytes.Equal(have, beta) {
+			t.Errorf("test %d: verified output mismatch: have %x, want %x, err %v", i, have, beta, err)
+		}
+		if have, err := v.ProofToHash(proof); err != nil || !bytes.Equal(have, beta) {
+			t.Errorf("test %d: output mismatch: have %x, want %x, err %v", i, have, beta, err)
+		}
+	}
+}
+
+func TestVRFSuites(t *testing.T) {
+	keys := map[string]string{
+		SuiteConiksSecp256k1:        "b71c71a67e1177ad4e901695e1b4b9ee17ae16c6668d313eac2f96dbcda3f291",
+		SuiteEdwards25519SHA512ELL2: "9d61b19deffd5a60ba844af492ec2cc44449c5697b326919703bac031cae7f60",
+		SuiteP256SHA256TAI:          "c9afa9d845ba75166b5c215767b1d6934e50c3db36e89b127b8a622b120f6721",
+		SuiteSecp256k1SHA256TAI:     "b71c71a67e1177ad4e901695e1b4b9ee17ae16c6668d313eac2f96dbcda3f291",
+	}
+	for suite, key := range keys {
+		v, err := New(suite)
+		if err != nil {
+			t.Fatalf("%s: %v", suite, err)
+		}
+		if v.Suite() != suite {
+			t.Errorf("%s: suite name mismatch: have %s", suite, v.Suite())
+		}
+		sk, _ := hex.DecodeString(key)
+		pk, err := v.PublicKey(sk)
+		if err != nil {
+			t.Fatalf("%s: failed to derive public key: %v", suite, err)
+		}
+		proof, err := v.Prove(sk, []byte("data1"))
+		if err != nil {
+			t.Fatalf("%s: failed to prove: %v", suite, err)
+		}
+		beta, err := v.Verify(pk, []byte("data1"), proof)
+		if err != nil {
+			t.Fatalf("%s: failed to verify: %v", suite, err)
+		}
+		if hash, err := v.ProofToHash(proof); err != nil || !bytes.Equal(hash, beta) {
+			t.Errorf("%s: output mismatch: have %x, want %x, err %v", suite, hash, beta, err)
+		}
+		// Proofs must not verify for other inputs, keys or once tampered with
+		if _, err := v.Verify(pk, []byte("data2"), proof); err != ErrInvalidVRF {
+			t.Errorf("%s: proof of other input error mismatch: have %v, want %v", suite, err, ErrInvalidVRF)
+		}
+		other, _ := v.PublicKey(append([]byte{sk[0] ^ 0x01}, sk[1:]...))
+		if _, err := v.Verify(other, []byte("data1"), proof); err != ErrInvalidVRF {
+			t.Errorf("%s: proof of other key error mismatch: have %v, want %v", suite, err, ErrInvalidVRF)
+		}
+		tampered := append([]byte{}, proof...)
+		tampered[len(tampered)-1] ^= 0x01
+		if _, err := v.Verify(pk, []byte("data1"), tampered); err != ErrInvalidVRF {
+			t.Errorf("%s: tampered proof error mismatch: have %v, want %v", suite, err, ErrInvalidVRF)
+		}
+		if _, err := v.Verify(pk, []byte("data1"), proof[1:]); err != ErrInvalidVRF {
+			t.Errorf("%s: truncated proof error mismatch: have %v, want %v", suite, err, ErrInvalidVRF)
+		}
+	}
+	if _, err := New("unknown"); err == nil {
+		t.Errorf("unknown suite accepted")
+	}
+}
@@ -29,6 +29,7 @@ import (
 	"github.com/awesome-chain/Xchain/common"
 	"github.com/awesome-chain/Xchain/common/hexutil"
 	"github.com/awesome-chain/Xchain/consensus"
+	"github.com/awesome-chain/Xchain/consensus/algo"
 	"github.com/awesome-chain/Xchain/consensus/alien"
 	"github.com/awesome-chain/Xchain/consensus/clique"
 	"github.com/awesome-chain/Xchain/consensus/ethash"
@@ -37,6 +38,7 @@ import (
 	"github.com/awesome-chain/Xchain/core/rawdb"
 	"github.com/awesome-chain/Xchain/core/types"
 	"github.com/awesome-chain/Xchain/core/vm"
+	"github.com/awesome-chain/Xchain/crypto"
 	"github.com/awesome-chain/Xchain/eth/downloader"
 	"github.com/awesome-chain/Xchain/eth/filters"
 	"github.com/awesome-chain/Xchain/eth/gasprice"
@@ -72,6 +74,8 @@ type Ethereum struct {
 	blockchain      *core.BlockChain
 	protocolManager *ProtocolManager
 	lesServer       LesServer
+	agreement       *algo.Service              // Agreement service of the algo engine, nil for other engines
+	confirmation    *alien.ConfirmationService // Confirmation service of the alien engine, nil unless confirming off-chain
 
 	// DB interfaces
 	chainDb ethdb.Database // Block chain database
@@ -150,7 +154,7 @@ func New(ctx *node.ServiceContext, config *Config) (*Ethereum, error) {
 		rawdb.WriteDatabaseVersion(chainDb, core.BlockChainVersion)
 	}
 	var (
-		vmConfig    = vm.Config{EnablePreimageRecording: config.EnablePreimageRecording}
+		vmConfig    = vm.Config{EnablePreimageRecording: config.EnablePreimageRecording, ParallelExecution: config.ParallelExecution}
 		cacheConfig = &core.CacheConfig{Disabled: config.NoPruning, TrieNodeLimit: config.TrieCache, TrieTimeLimit: config.TrieTimeout}
 	)
 	eth.blockchain, err = core.NewBlockChain(chainDb, cacheConfig, eth.chainConfig, eth.engine, vmConfig)
@@ -173,6 +177,17 @@ func New(ctx *node.ServiceContext, config *Config) (*Ethereum, error) {
 	if eth.protocolManager, err = NewProtocolManager(eth.chainConfig, config.SyncMode, config.NetworkId, eth.eventMux, eth.txPool, eth.engine, eth.blockchain, chainDb); err != nil {
 		return nil, err
 	}
+	if engine, ok := eth.engine.(*algo.Algorand); ok {
+		eth.agreement = algo.NewService(engine, algo.Parameters{
+			Ledger:    miner.NewChainLedger(eth.blockchain),
+			Factory:   miner.NewBlockFactory(eth, eth.chainConfig, engine, makeExtraData(config.ExtraData)),
+			Validator: miner.NewBlockValidator(eth.blockchain, engine),
+			NetworkId: config.NetworkId,
+		})
+	}
+	if engine, ok := eth.engine.(*alien.Alien); ok && chainConfig.Alien.PBFTEnable && chainConfig.Alien.ConfirmBlock != nil {
+		eth.confirmation = alien.NewConfirmationService(engine, eth.blockchain, config.NetworkId)
+	}
 	eth.miner = miner.New(eth, eth.chainConfig, eth.EventMux(), eth.engine)
 	eth.miner.SetExtra(makeExtraData(config.ExtraData))
 
@@ -222,6 +237,8 @@ func CreateConsensusEngine(ctx *node.ServiceContext, config *ethash.Config, chai
 		return clique.New(chainConfig.Clique, db)
 	} else if chainConfig.Alien != nil {
 		return alien.New(chainConfig.Alien, db)
+	} else if chainConfig.Algo != nil {
+		return algo.New(chainConfig.Algo, db)
 	}
 	// Otherwise assume proof-of-work
 	switch config.PowMode {
@@ -363,6 +380,18 @@ func (s *Ethereum) StartMining(local bool) error {
 		}
 		alien.Authorize(eb, wallet.SignHash, wallet.SignTx)
 	}
+	if algo, ok := s.engine.(*algo.Algorand); ok {
+		if s.config.AlgoKey == nil {
+			log.Error("Algo participation key unavailable")
+			return fmt.Errorf("participation key missing")
+		}
+		// The etherbase is the stake account, which must have registered the
+		// participation key unless it is the key's own account
+		if signer := crypto.PubkeyToAddress(s.config.AlgoKey.PublicKey); signer != eb {
+			log.Info("Participating on behalf of stake account", "etherbase", eb, "key", signer)
+		}
+		algo.AuthorizeParticipation(eb, s.config.AlgoKey)
+	}
 	if local {
 		// If local (CPU) mining is started, we can disable the transaction rejection
 		// mechanism introduced to speed sync times. CPU mining on mainnet is ludicrous
@@ -392,10 +421,17 @@ func (s *Ethereum) Downloader() *downloader.Downloader { return s.protocolManage
 // Protocols implements node.Service, returning all the currently configured
 // network protocols to start.
 func (s *Ethereum) Protocols() []p2p.Protocol {
+	protos := s.protocolManager.SubProtocols
+	if s.agreement != nil {
+		protos = append(protos, s.agreement.Protocols()...)
+	}
+	if s.confirmation != nil {
+		protos = append(protos, s.confirmation.Protocols()...)
+	}
 	if s.lesServer == nil {
-		return s.protocolManager.SubProtocols
+		return protos
 	}
-	return append(s.protocolManager.SubProtocols, s.lesServer.Protocols()...)
+	return append(protos, s.lesServer.Protocols()...)
 }
 
 // Start implements node.Service, starting all internal goroutines needed by the
@@ -417,6 +453,12 @@ func (s *Ethereum) Start(srvr *p2p.Server) error {
 	}
 	// Start the networking layer and the light server if requested
 	s.protocolManager.Start(maxPeers)
+	if s.agreement != nil {
+		s.agreement.Start()
+	}
+	if s.confirmation != nil {
+		s.confirmation.Start()
+	}
 	if s.lesServer != nil {
 		s.lesServer.Start(srvr)
 	}
@@ -427,6 +469,12 @@ func (s *Ethereum) Start(srvr *p2p.Server) error {
 // Ethereum protocol.
 func (s *Ethereum) Stop() error {
 	s.bloomIndexer.Close()
+	if s.agreement != nil {
+		s.agreement.Stop()
+	}
+	if s.confirmation != nil {
+		s.confirmation.Stop()
+	}
 	s.blockchain.Stop()
 	s.protocolManager.Stop()
 	if s.lesServer != nil {
@@ -17,6 +17,7 @@
 package eth
 
 import (
+	"crypto/ecdsa"
 	"math/big"
 	"os"
 	"os/user"
@@ -99,6 +100,7 @@ type Config struct {
 	MinerThreads int            `toml:",omitempty"`
 	ExtraData    []byte         `toml:",omitempty"`
 	GasPrice     *big.Int
+	AlgoKey      *ecdsa.PrivateKey `toml:"-"` // Participation key of the algo consensus engine
 
 	// Ethash options
 	Ethash ethash.Config
@@ -112,6 +114,9 @@ type Config struct {
 	// Enables tracking of SHA3 preimages in the VM
 	EnablePreimageRecording bool
 
+	// Enables optimistic parallel execution of the transactions of a block
+	ParallelExecution bool
+
 	// Miscellaneous options
 	DocRoot string `toml:"-"`
 }
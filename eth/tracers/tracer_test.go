@@ -138,3 +138,39 @@ func TestHaltBetweenSteps(t *testing.T) {
 		t.Errorf("Expected timeout error, got %v", err)
 	}
 }
+
+// Tests that isPrecompiled reports the precompiled contracts of the traced block.
+func TestIsPrecompiled(t *testing.T) {
+	config := *params.TestChainConfig
+	config.VRFBlock = nil
+	vrfConfig := *params.TestChainConfig
+	vrfConfig.VRFBlock = big.NewInt(1)
+
+	for _, tt := range []struct {
+		config *params.ChainConfig
+		want   string
+	}{
+		{&config, "[true,false]"},
+		{&vrfConfig, "[true,true]"},
+	} {
+		tracer, err := New("{res: null, step: function() { this.res = [isPrecompiled(toAddress('0x0000000000000000000000000000000000000001')), isPrecompiled(toAddress('0x0000000000000000000000000000000000000101'))]; }, fault: function() {}, result: function() { return this.res; }}")
+		if err != nil {
+			t.Fatal(err)
+		}
+		env := vm.NewEVM(vm.Context{BlockNumber: big.NewInt(1)}, nil, tt.config, vm.Config{Debug: true, Tracer: tracer})
+
+		contract := vm.NewContract(account{}, account{}, big.NewInt(0), 10000)
+		contract.Code = []byte{byte(vm.PUSH1), 0x1, 0x0}
+
+		if _, err := env.Interpreter().Run(contract, []byte{}); err != nil {
+			t.Fatal(err)
+		}
+		ret, err := tracer.GetResult()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Equal(ret, []byte(tt.want)) {
+			t.Errorf("VRF block %v: precompiles mismatch: have %s, want %s", tt.config.VRFBlock, ret, tt.want)
+		}
+	}
+}
@@ -0,0 +1,175 @@
+// Copyright 2018 The gttc Authors
+// This file is part of the gttc library.
+//
+// The gttc library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The gttc library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the gttc library. If not, see <http://www.gnu.org/licenses/>.
+
+// Package alienclient provides a client for the alien RPC API, and sends the
+// custom transactions of the alien consensus engine.
+package alienclient
+
+import (
+	"context"
+	"math/big"
+
+	"github.com/awesome-chain/Xchain/common"
+	"github.com/awesome-chain/Xchain/common/hexutil"
+	"github.com/awesome-chain/Xchain/consensus/alien"
+	"github.com/awesome-chain/Xchain/ethclient"
+	"github.com/awesome-chain/Xchain/rpc"
+)
+
+// Client defines typed wrappers for the alien RPC API.
+type Client struct {
+	c  *rpc.Client
+	ec *ethclient.Client
+}
+
+// Dial connects a client to the given URL.
+func Dial(rawurl string) (*Client, error) {
+	return DialContext(context.Background(), rawurl)
+}
+
+// DialContext connects a client to the given URL, the context being used for
+// the connection.
+func DialContext(ctx context.Context, rawurl string) (*Client, error) {
+	c, err := rpc.DialContext(ctx, rawurl)
+	if err != nil {
+		return nil, err
+	}
+	return NewClient(c), nil
+}
+
+// NewClient creates a client that uses the given RPC client.
+func NewClient(c *rpc.Client) *Client {
+	return &Client{c: c, ec: ethclient.NewClient(c)}
+}
+
+// Close closes the underlying RPC connection.
+func (ac *Client) Close() {
+	ac.c.Close()
+}
+
+// Snapshot returns the alien snapshot at the given block. If number is nil, the
+// latest known block is used.
+func (ac *Client) Snapshot(ctx context.Context, number *big.Int) (*alien.Snapshot, error) {
+	var snap *alien.Snapshot
+	if err := ac.c.CallContext(ctx, &snap, "alien_getSnapshot", toBlockNumArg(number)); err != nil {
+		return nil, err
+	}
+	return snap, nil
+}
+
+// SnapshotAtHash returns the alien snapshot at the block with the given hash.
+func (ac *Client) SnapshotAtHash(ctx context.Context, hash common.Hash) (*alien.Snapshot, error) {
+	var snap *alien.Snapshot
+	if err := ac.c.CallContext(ctx, &snap, "alien_getSnapshotAtHash", hash); err != nil {
+		return nil, err
+	}
+	return snap, nil
+}
+
+// SnapshotByHeaderTime returns the alien snapshot of the main chain at the
+// header time of a block of the side chain with the given hash.
+func (ac *Client) SnapshotByHeaderTime(ctx context.Context, headerTime uint64, scHash common.Hash) (*alien.Snapshot, error) {
+	var snap *alien.Snapshot
+	if err := ac.c.CallContext(ctx, &snap, "alien_getSnapshotByHeaderTime", headerTime, scHash); err != nil {
+		return nil, err
+	}
+	return snap, nil
+}
+
+// Candidates returns a page of the candidates at the given block, by decreasing
+// tally. A zero limit stands for the default page size of the node.
+func (ac *Client) Candidates(ctx context.Context, number *big.Int, offset uint64, limit uint64) (*alien.CandidatesPage, error) {
+	var page *alien.CandidatesPage
+	if err := ac.c.CallContext(ctx, &page, "alien_getCandidates", toBlockNumArg(number), offset, limit); err != nil {
+		return nil, err
+	}
+	return page, nil
+}
+
+// Tally returns a page of the stake voted for each candidate at the given block,
+// by decreasing stake.
+func (ac *Client) Tally(ctx context.Context, number *big.Int, offset uint64, limit uint64) (*alien.TallyPage, error) {
+	var page *alien.TallyPage
+	if err := ac.c.CallContext(ctx, &page, "alien_getTally", toBlockNumArg(number), offset, limit); err != nil {
+		return nil, err
+	}
+	return page, nil
+}
+
+// Punished returns a page of the punished signers at the given block, by
+// decreasing punished count.
+func (ac *Client) Punished(ctx context.Context, number *big.Int, offset uint64, limit uint64) (*alien.PunishedPage, error) {
+	var page *alien.PunishedPage
+	if err := ac.c.CallContext(ctx, &page, "alien_getPunished", toBlockNumArg(number), offset, limit); err != nil {
+		return nil, err
+	}
+	return page, nil
+}
+
+// SideChains returns a page of the side chains at the given block.
+func (ac *Client) SideChains(ctx context.Context, number *big.Int, offset uint64, limit uint64) (*alien.SideChainsPage, error) {
+	var page *alien.SideChainsPage
+	if err := ac.c.CallContext(ctx, &page, "alien_getSideChains", toBlockNumArg(number), offset, limit); err != nil {
+		return nil, err
+	}
+	return page, nil
+}
+
+// VoteOf returns the vote of the voter at the given block, nil if it has none.
+func (ac *Client) VoteOf(ctx context.Context, voter common.Address, number *big.Int) (*alien.VoteInfo, error) {
+	var vote *alien.VoteInfo
+	if err := ac.c.CallContext(ctx, &vote, "alien_getVotesByVoter", voter, toBlockNumArg(number)); err != nil {
+		return nil, err
+	}
+	return vote, nil
+}
+
+// Proposal returns the proposal with the given hash at the given block, nil if
+// there is none.
+func (ac *Client) Proposal(ctx context.Context, hash common.Hash, number *big.Int) (*alien.Proposal, error) {
+	var proposal *alien.Proposal
+	if err := ac.c.CallContext(ctx, &proposal, "alien_getProposal", hash, toBlockNumArg(number)); err != nil {
+		return nil, err
+	}
+	return proposal, nil
+}
+
+// SignerQueue returns the signer queue of the loop loopOffset loops before the
+// one of the given block.
+func (ac *Client) SignerQueue(ctx context.Context, loopOffset uint64, number *big.Int) (*alien.SignerQueue, error) {
+	var queue *alien.SignerQueue
+	if err := ac.c.CallContext(ctx, &queue, "alien_getSignerQueue", loopOffset, toBlockNumArg(number)); err != nil {
+		return nil, err
+	}
+	return queue, nil
+}
+
+// Rewards returns the rewards credited to the address by the blocks from
+// fromBlock to toBlock. If toBlock is nil, the latest known block is used.
+func (ac *Client) Rewards(ctx context.Context, address common.Address, fromBlock *big.Int, toBlock *big.Int) ([]alien.RewardInfo, error) {
+	var rewards []alien.RewardInfo
+	if err := ac.c.CallContext(ctx, &rewards, "alien_getRewards", address, toBlockNumArg(fromBlock), toBlockNumArg(toBlock)); err != nil {
+		return nil, err
+	}
+	return rewards, nil
+}
+
+func toBlockNumArg(number *big.Int) string {
+	if number == nil {
+		return "latest"
+	}
+	return hexutil.EncodeBig(number)
+}
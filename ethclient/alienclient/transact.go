@@ -0,0 +1,189 @@
+// Copyright 2018 The gttc Authors
+// This file is part of the gttc library.
+//
+// The gttc library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The gttc library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the gttc library. If not, see <http://www.gnu.org/licenses/>.
+
+package alienclient
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/big"
+	"strings"
+
+	"github.com/awesome-chain/Xchain"
+	"github.com/awesome-chain/Xchain/accounts/abi/bind"
+	"github.com/awesome-chain/Xchain/common"
+	"github.com/awesome-chain/Xchain/consensus/alien"
+	"github.com/awesome-chain/Xchain/core/types"
+)
+
+// The data built here is the "ufo:1:category:action:..." text of the custom
+// transactions, understood by the alien engine at any block. The typed format
+// enabled by the custom transaction fork is built with alien.EncodeCustomTx.
+
+const ufoEventPrefix = "ufo:1:event:"
+
+// Proposal types of alien.ProposalPayload.
+const (
+	ProposalTypeCandidateAdd                  = 1
+	ProposalTypeCandidateRemove               = 2
+	ProposalTypeMinerRewardDistributionModify = 3
+	ProposalTypeSideChainAdd                  = 4
+	ProposalTypeSideChainRemove               = 5
+	ProposalTypeMinVoterBalanceModify         = 6
+	ProposalTypeProposalDepositModify         = 7
+	ProposalTypeRentSideChain                 = 8
+)
+
+// VoteData returns the data of a transaction voting for its recipient.
+func VoteData() []byte {
+	return []byte(ufoEventPrefix + "vote")
+}
+
+// ConfirmData returns the data of a transaction confirming the given block, sent
+// by a signer.
+func ConfirmData(number uint64) []byte {
+	return []byte(fmt.Sprintf("%sconfirm:%d", ufoEventPrefix, number))
+}
+
+// ProposalData returns the data of a transaction making a proposal. Zero fields
+// of the payload are left to their default value.
+func ProposalData(p *alien.ProposalPayload) []byte {
+	// the proposal type is always set, a proposal without any field is ignored
+	proposalType := p.ProposalType
+	if proposalType == 0 {
+		proposalType = ProposalTypeCandidateAdd
+	}
+	fields := []string{"proposal", "proposal_type", fmt.Sprint(proposalType)}
+	add := func(key string, value uint64) {
+		if value != 0 {
+			fields = append(fields, key, fmt.Sprint(value))
+		}
+	}
+	add("vlcnt", p.ValidationLoopCnt)
+	if (p.TargetAddress != common.Address{}) {
+		// candidate and scrt both set the target address of the proposal
+		fields = append(fields, "candidate", p.TargetAddress.Hex())
+	}
+	add("mrpt", p.MinerRewardPerThousand)
+	if (p.SCHash != common.Hash{}) {
+		fields = append(fields, "schash", p.SCHash.Hex())
+	}
+	add("sccount", p.SCBlockCountPerPeriod)
+	add("screward", p.SCBlockRewardPerPeriod)
+	add("mvb", p.MinVoterBalance)
+	add("mpd", p.ProposalDeposit)
+	add("scrf", p.SCRentFee)
+	add("scrr", p.SCRentRate)
+	add("scrl", p.SCRentLength)
+	return []byte(ufoEventPrefix + strings.Join(fields, ":"))
+}
+
+// DeclareData returns the data of a transaction declaring the decision of a
+// signer on a proposal.
+func DeclareData(proposal common.Hash, decision bool) []byte {
+	value := "no"
+	if decision {
+		value = "yes"
+	}
+	return []byte(fmt.Sprintf("%sdeclare:hash:%s:decision:%s", ufoEventPrefix, proposal.Hex(), value))
+}
+
+// SCSetCoinbaseData returns the data of a transaction setting its recipient as
+// the coinbase of the sending signer on the side chain with the given hash.
+func SCSetCoinbaseData(scHash common.Hash) []byte {
+	return []byte("ufo:1:sc:setcb:" + scHash.Hex())
+}
+
+// MinSCSetCoinbaseValue is the value a signer must send to its side chain
+// coinbase for the coinbase to be set.
+var MinSCSetCoinbaseValue = big.NewInt(5e+18)
+
+// errLowSetCoinbaseValue is returned if a side chain coinbase is set sending
+// less than the minimum value to it.
+var errLowSetCoinbaseValue = errors.New("set coinbase value below 5 TTC")
+
+// Vote sends a transaction voting for the candidate.
+func (ac *Client) Vote(opts *bind.TransactOpts, candidate common.Address) (*types.Transaction, error) {
+	return ac.Transact(opts, candidate, VoteData())
+}
+
+// Propose sends a transaction making a proposal, the deposit being taken from
+// the balance of the sender.
+func (ac *Client) Propose(opts *bind.TransactOpts, p *alien.ProposalPayload) (*types.Transaction, error) {
+	return ac.Transact(opts, opts.From, ProposalData(p))
+}
+
+// Declare sends a transaction declaring the decision of a signer on a proposal.
+func (ac *Client) Declare(opts *bind.TransactOpts, proposal common.Hash, decision bool) (*types.Transaction, error) {
+	return ac.Transact(opts, opts.From, DeclareData(proposal, decision))
+}
+
+// SetSideChainCoinbase sends a transaction setting the coinbase of a signer on
+// a side chain, the value of opts must be at least 5 TTC.
+func (ac *Client) SetSideChainCoinbase(opts *bind.TransactOpts, scHash common.Hash, coinbase common.Address) (*types.Transaction, error) {
+	if opts.Value == nil || opts.Value.Cmp(MinSCSetCoinbaseValue) < 0 {
+		return nil, errLowSetCoinbaseValue
+	}
+	return ac.Transact(opts, coinbase, SCSetCoinbaseData(scHash))
+}
+
+// Transact signs and sends a transaction with the given data to the recipient,
+// filling in the nonce, gas price and gas limit missing from opts.
+func (ac *Client) Transact(opts *bind.TransactOpts, to common.Address, data []byte) (*types.Transaction, error) {
+	var err error
+
+	ctx := opts.Context
+	if ctx == nil {
+		ctx = context.TODO()
+	}
+	value := opts.Value
+	if value == nil {
+		value = new(big.Int)
+	}
+	var nonce uint64
+	if opts.Nonce == nil {
+		if nonce, err = ac.ec.PendingNonceAt(ctx, opts.From); err != nil {
+			return nil, fmt.Errorf("failed to retrieve account nonce: %v", err)
+		}
+	} else {
+		nonce = opts.Nonce.Uint64()
+	}
+	gasPrice := opts.GasPrice
+	if gasPrice == nil {
+		if gasPrice, err = ac.ec.SuggestGasPrice(ctx); err != nil {
+			return nil, fmt.Errorf("failed to suggest gas price: %v", err)
+		}
+	}
+	gasLimit := opts.GasLimit
+	if gasLimit == 0 {
+		msg := ethereum.CallMsg{From: opts.From, To: &to, Value: value, Data: data}
+		if gasLimit, err = ac.ec.EstimateGas(ctx, msg); err != nil {
+			return nil, fmt.Errorf("failed to estimate gas needed: %v", err)
+		}
+	}
+	if opts.Signer == nil {
+		return nil, errors.New("no signer to authorize the transaction with")
+	}
+	tx, err := opts.Signer(types.HomesteadSigner{}, opts.From, types.NewTransaction(nonce, to, value, gasLimit, gasPrice, data))
+	if err != nil {
+		return nil, err
+	}
+	if err := ac.ec.SendTransaction(ctx, tx); err != nil {
+		return nil, err
+	}
+	return tx, nil
+}
@@ -0,0 +1,57 @@
+// Copyright 2018 The gttc Authors
+// This file is part of the gttc library.
+//
+// The gttc library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The gttc library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the gttc library. If not, see <http://www.gnu.org/licenses/>.
+
+package alienclient
+
+import (
+	"testing"
+
+	"github.com/awesome-chain/Xchain/common"
+	"github.com/awesome-chain/Xchain/consensus/alien"
+)
+
+// Tests that the custom transaction data is built in the format the alien
+// engine parses.
+func TestCustomTxData(t *testing.T) {
+	var (
+		hash    = common.HexToHash("0x3210000000000000000000000000000000000000000000000000000000000000")
+		address = common.HexToAddress("0x00000000000000000000000000000000000000ff")
+	)
+	tests := []struct {
+		data []byte
+		want string
+	}{
+		{VoteData(), "ufo:1:event:vote"},
+		{ConfirmData(42), "ufo:1:event:confirm:42"},
+		{ProposalData(&alien.ProposalPayload{}), "ufo:1:event:proposal:proposal_type:1"},
+		{
+			ProposalData(&alien.ProposalPayload{ProposalType: 4, ValidationLoopCnt: 4, SCHash: hash, SCBlockCountPerPeriod: 2, SCBlockRewardPerPeriod: 50}),
+			"ufo:1:event:proposal:proposal_type:4:vlcnt:4:schash:" + hash.Hex() + ":sccount:2:screward:50",
+		},
+		{
+			ProposalData(&alien.ProposalPayload{ProposalType: 2, TargetAddress: address, MinerRewardPerThousand: 600}),
+			"ufo:1:event:proposal:proposal_type:2:candidate:" + address.Hex() + ":mrpt:600",
+		},
+		{DeclareData(hash, true), "ufo:1:event:declare:hash:" + hash.Hex() + ":decision:yes"},
+		{DeclareData(hash, false), "ufo:1:event:declare:hash:" + hash.Hex() + ":decision:no"},
+		{SCSetCoinbaseData(hash), "ufo:1:sc:setcb:" + hash.Hex()},
+	}
+	for i, tt := range tests {
+		if string(tt.data) != tt.want {
+			t.Errorf("test %d: data mismatch: have %s, want %s", i, tt.data, tt.want)
+		}
+	}
+}
@@ -27,6 +27,7 @@ import (
 	"github.com/awesome-chain/Xchain"
 	"github.com/awesome-chain/Xchain/common"
 	"github.com/awesome-chain/Xchain/common/hexutil"
+	"github.com/awesome-chain/Xchain/consensus/algo/beacon"
 	"github.com/awesome-chain/Xchain/core/types"
 	"github.com/awesome-chain/Xchain/rlp"
 	"github.com/awesome-chain/Xchain/rpc"
@@ -168,6 +169,29 @@ func (ec *Client) HeaderByNumber(ctx context.Context, number *big.Int) (*types.H
 	return head, err
 }
 
+// RandomnessByHash returns the randomness beacon entry of the block with the
+// given hash. The entry can be checked with its Verify method without trusting
+// the node.
+func (ec *Client) RandomnessByHash(ctx context.Context, hash common.Hash) (*beacon.Randomness, error) {
+	var r *beacon.Randomness
+	err := ec.c.CallContext(ctx, &r, "eth_getRandomnessByHash", hash)
+	if err == nil && r == nil {
+		err = ethereum.NotFound
+	}
+	return r, err
+}
+
+// RandomnessAt returns the randomness beacon entry of the given block. If number
+// is nil, the latest known entry is returned.
+func (ec *Client) RandomnessAt(ctx context.Context, number *big.Int) (*beacon.Randomness, error) {
+	var r *beacon.Randomness
+	err := ec.c.CallContext(ctx, &r, "eth_getRandomness", toBlockNumArg(number))
+	if err == nil && r == nil {
+		err = ethereum.NotFound
+	}
+	return r, err
+}
+
 type rpcTransaction struct {
 	tx *types.Transaction
 	txExtraInfo
@@ -0,0 +1,216 @@
+// Copyright 2018 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package miner
+
+import (
+	"context"
+	"fmt"
+	"math/big"
+	"time"
+
+	"github.com/awesome-chain/Xchain/common"
+	"github.com/awesome-chain/Xchain/consensus"
+	"github.com/awesome-chain/Xchain/core"
+	"github.com/awesome-chain/Xchain/core/types"
+	"github.com/awesome-chain/Xchain/core/vm"
+	"github.com/awesome-chain/Xchain/log"
+	"github.com/awesome-chain/Xchain/params"
+)
+
+// BlockFactory assembles the blocks proposed by an agreement based consensus
+// engine out of the pending transactions of the pool.
+type BlockFactory struct {
+	config *params.ChainConfig
+	engine consensus.Engine
+	chain  *core.BlockChain
+	txPool *core.TxPool
+	extra  []byte
+}
+
+// NewBlockFactory creates a block factory assembling blocks on top of the head
+// of the chain of the backend.
+func NewBlockFactory(eth Backend, config *params.ChainConfig, engine consensus.Engine, extra []byte) *BlockFactory {
+	return &BlockFactory{
+		config: config,
+		engine: engine,
+		chain:  eth.BlockChain(),
+		txPool: eth.TxPool(),
+		extra:  extra,
+	}
+}
+
+// AssembleBlock assembles a finalized block for the given round, executing as
+// many pending transactions as fit into the block before the deadline passes.
+func (f *BlockFactory) AssembleBlock(round uint64, deadline time.Time) (*types.Block, error) {
+	tstart := time.Now()
+	parent := f.chain.CurrentBlock()
+	if parent.NumberU64()+1 != round {
+		return nil, fmt.Errorf("round %d not on top of head %d", round, parent.NumberU64())
+	}
+	tstamp := tstart.Unix()
+	if parent.Time().Cmp(new(big.Int).SetInt64(tstamp)) >= 0 {
+		tstamp = parent.Time().Int64() + 1
+	}
+	header := &types.Header{
+		ParentHash: parent.Hash(),
+		Number:     new(big.Int).SetUint64(round),
+		GasLimit:   core.CalcGasLimit(parent),
+		Extra:      f.extra,
+		Time:       big.NewInt(tstamp),
+	}
+	if err := f.engine.Prepare(f.chain, header); err != nil {
+		return nil, err
+	}
+	statedb, err := f.chain.StateAt(parent.Root())
+	if err != nil {
+		return nil, err
+	}
+	pending, err := f.txPool.Pending()
+	if err != nil {
+		return nil, err
+	}
+	var (
+		coinbase = header.Coinbase
+		signer   = types.MakeSigner(f.config, header.Number)
+		txs      = types.NewTransactionsByPriceAndNonce(signer, pending)
+		gasPool  = new(core.GasPool).AddGas(header.GasLimit)
+		included []*types.Transaction
+		receipts []*types.Receipt
+	)
+	for {
+		// Stop once out of time or out of gas for any further transactions
+		if time.Now().After(deadline) {
+			log.Debug("Proposal assembly deadline reached", "number", round, "txs", len(included))
+			break
+		}
+		if gasPool.Gas() < params.TxGas {
+			break
+		}
+		tx := txs.Peek()
+		if tx == nil {
+			break
+		}
+		if tx.Protected() && !f.config.IsEIP155(header.Number) {
+			txs.Pop()
+			continue
+		}
+		statedb.Prepare(tx.Hash(), common.Hash{}, len(included))
+
+		snap := statedb.Snapshot()
+		receipt, _, err := core.ApplyTransaction(f.config, f.chain, &coinbase, gasPool, statedb, header, tx, &header.GasUsed, vm.Config{})
+		switch err {
+		case core.ErrGasLimitReached, core.ErrNonceTooHigh:
+			// Skip the remaining transactions of the account
+			statedb.RevertToSnapshot(snap)
+			txs.Pop()
+
+		case nil:
+			included = append(included, tx)
+			receipts = append(receipts, receipt)
+			txs.Shift()
+
+		default:
+			// Nonce too low or a strange error, discard the transaction and
+			// move on to the next one of the account
+			log.Trace("Transaction skipped in proposal", "hash", tx.Hash(), "err", err)
+			statedb.RevertToSnapshot(snap)
+			txs.Shift()
+		}
+	}
+	block, err := f.engine.Finalize(f.chain, header, statedb, included, nil, receipts)
+	if err != nil {
+		return nil, err
+	}
+	log.Debug("Assembled proposal", "number", round, "txs", len(included), "gas", header.GasUsed, "elapsed", common.PrettyDuration(time.Since(tstart)))
+	return block, nil
+}
+
+// BlockValidator validates the blocks proposed by an agreement based consensus
+// engine, executing their transactions on top of their parent.
+type BlockValidator struct {
+	engine consensus.Engine
+	chain  *core.BlockChain
+}
+
+// NewBlockValidator creates a validator of the blocks proposed on top of the
+// given chain.
+func NewBlockValidator(chain *core.BlockChain, engine consensus.Engine) *BlockValidator {
+	return &BlockValidator{engine: engine, chain: chain}
+}
+
+// Validate checks the header and the body of a proposed block, then executes
+// its transactions and checks the resulting state and receipt roots.
+func (v *BlockValidator) Validate(ctx context.Context, block *types.Block) (*types.Block, error) {
+	if err := v.engine.VerifyHeader(v.chain, block.Header(), true); err != nil {
+		return nil, err
+	}
+	switch err := v.chain.Validator().ValidateBody(block); err {
+	case nil:
+	case core.ErrKnownBlock:
+		// Already imported, e.g. by the synchronisation
+		return block, nil
+	default:
+		return nil, err
+	}
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	parent := v.chain.GetBlock(block.ParentHash(), block.NumberU64()-1)
+	if parent == nil {
+		return nil, consensus.ErrUnknownAncestor
+	}
+	statedb, err := v.chain.StateAt(parent.Root())
+	if err != nil {
+		return nil, err
+	}
+	receipts, _, usedGas, err := v.chain.Processor().Process(block, statedb, vm.Config{})
+	if err != nil {
+		return nil, err
+	}
+	if err := v.chain.Validator().ValidateState(block, parent, statedb, receipts, usedGas); err != nil {
+		return nil, err
+	}
+	return block, nil
+}
+
+// ChainLedger adapts a blockchain to the ledger of an agreement based consensus
+// engine, committing the agreed blocks as the canonical chain.
+type ChainLedger struct {
+	*core.BlockChain
+}
+
+// NewChainLedger creates an agreement ledger on top of the given chain.
+func NewChainLedger(chain *core.BlockChain) *ChainLedger {
+	return &ChainLedger{chain}
+}
+
+// EnsureBlock inserts an agreed block unless it was already imported by the
+// synchronisation, then makes sure it is canonical. Uncertified blocks of the
+// same height tie on total difficulty with the agreed one and may have been
+// imported first, leaving the agreed block on a side chain.
+func (l *ChainLedger) EnsureBlock(block *types.Block) error {
+	if !l.HasBlock(block.Hash(), block.NumberU64()) {
+		if _, err := l.InsertChain(types.Blocks{block}); err != nil {
+			return err
+		}
+	}
+	if canonical := l.GetBlockByNumber(block.NumberU64()); canonical != nil && canonical.Hash() == block.Hash() {
+		return nil
+	}
+	log.Warn("Reorganising to agreed block", "number", block.NumberU64(), "hash", block.Hash(), "head", l.CurrentBlock().NumberU64())
+	return l.SetCanonical(block)
+}
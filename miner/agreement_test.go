@@ -0,0 +1,199 @@
+// Copyright 2018 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package miner
+
+import (
+	"context"
+	"math/big"
+	"testing"
+	"time"
+
+	"github.com/awesome-chain/Xchain/accounts"
+	"github.com/awesome-chain/Xchain/common"
+	"github.com/awesome-chain/Xchain/consensus/algo"
+	"github.com/awesome-chain/Xchain/core"
+	"github.com/awesome-chain/Xchain/core/types"
+	"github.com/awesome-chain/Xchain/core/vm"
+	"github.com/awesome-chain/Xchain/crypto"
+	"github.com/awesome-chain/Xchain/ethdb"
+	"github.com/awesome-chain/Xchain/params"
+)
+
+// testBackend is a mining backend of a chain and its transaction pool.
+type testBackend struct {
+	db     ethdb.Database
+	chain  *core.BlockChain
+	txPool *core.TxPool
+}
+
+func (b *testBackend) AccountManager() *accounts.Manager { return nil }
+func (b *testBackend) BlockChain() *core.BlockChain      { return b.chain }
+func (b *testBackend) TxPool() *core.TxPool              { return b.txPool }
+func (b *testBackend) ChainDb() ethdb.Database           { return b.db }
+
+func TestAgreementBlockFactory(t *testing.T) {
+	var (
+		db      = ethdb.NewMemDatabase()
+		key, _  = crypto.HexToECDSA("b71c71a67e1177ad4e901695e1b4b9ee17ae16c6668d313eac2f96dbcda3f291")
+		address = crypto.PubkeyToAddress(key.PublicKey)
+		config  = *params.AllAlgoProtocolChanges
+	)
+	config.Algo = &params.AlgoConfig{Period: 1, Participants: []common.UnprefixedAddress{common.UnprefixedAddress(address)}}
+	gspec := &core.Genesis{Config: &config, Alloc: core.GenesisAlloc{address: {Balance: new(big.Int).Mul(big.NewInt(10000), big.NewInt(params.Ether))}}}
+	gspec.MustCommit(db)
+
+	engine := algo.New(config.Algo, db)
+	engine.Authorize(key)
+	chain, err := core.NewBlockChain(db, nil, &config, engine, vm.Config{})
+	if err != nil {
+		t.Fatalf("failed to create chain: %v", err)
+	}
+	defer chain.Stop()
+
+	poolConfig := core.DefaultTxPoolConfig
+	poolConfig.Journal = ""
+	pool := core.NewTxPool(poolConfig, &config, chain)
+	defer pool.Stop()
+
+	signer := types.NewEIP155Signer(config.ChainId)
+	for nonce := uint64(0); nonce < 3; nonce++ {
+		tx, _ := types.SignTx(types.NewTransaction(nonce, common.Address{0x01}, big.NewInt(1000), params.TxGas, big.NewInt(1), nil), signer, key)
+		if err := pool.AddLocal(tx); err != nil {
+			t.Fatalf("failed to add transaction %d: %v", nonce, err)
+		}
+	}
+	var (
+		backend   = &testBackend{db: db, chain: chain, txPool: pool}
+		factory   = NewBlockFactory(backend, &config, engine, nil)
+		validator = NewBlockValidator(chain, engine)
+	)
+	// Without time left the proposal must stay empty
+	block, err := factory.AssembleBlock(1, time.Now().Add(-time.Second))
+	if err != nil {
+		t.Fatalf("failed to assemble late block: %v", err)
+	}
+	if len(block.Transactions()) != 0 {
+		t.Fatalf("late block transactions mismatch: have %d, want 0", len(block.Transactions()))
+	}
+	// Given the time, all the pending transactions must be included
+	if block, err = factory.AssembleBlock(1, time.Now().Add(time.Second)); err != nil {
+		t.Fatalf("failed to assemble block: %v", err)
+	}
+	if len(block.Transactions()) != 3 {
+		t.Fatalf("block transactions mismatch: have %d, want 3", len(block.Transactions()))
+	}
+	if block, err = engine.Seal(chain, block, make(chan struct{})); err != nil {
+		t.Fatalf("failed to seal block: %v", err)
+	}
+	if _, err := validator.Validate(context.Background(), block); err != nil {
+		t.Fatalf("failed to validate block: %v", err)
+	}
+	// A block with a forged state root must be rejected even if re-signed
+	header := block.Header()
+	header.Root = common.Hash{0x01}
+	header.Sig, _ = crypto.Sign(algo.HashHeader(header).Bytes(), key)
+	if _, err := validator.Validate(context.Background(), block.WithSeal(header)); err == nil {
+		t.Fatalf("forged state root accepted")
+	}
+	if _, err := chain.InsertChain(types.Blocks{block}); err != nil {
+		t.Fatalf("failed to insert block: %v", err)
+	}
+	// Proposals are only assembled on top of the head
+	if _, err := factory.AssembleBlock(1, time.Now().Add(time.Second)); err == nil {
+		t.Fatalf("stale round assembled")
+	}
+}
+
+// Tests that an agreed block becomes canonical even if competing uncertified
+// blocks were imported first and won the total difficulty comparison.
+func TestChainLedgerEnsureBlock(t *testing.T) {
+	var (
+		db      = ethdb.NewMemDatabase()
+		key, _  = crypto.HexToECDSA("b71c71a67e1177ad4e901695e1b4b9ee17ae16c6668d313eac2f96dbcda3f291")
+		address = crypto.PubkeyToAddress(key.PublicKey)
+		config  = *params.AllAlgoProtocolChanges
+	)
+	config.Algo = &params.AlgoConfig{Period: 1, Participants: []common.UnprefixedAddress{common.UnprefixedAddress(address)}}
+	gspec := &core.Genesis{Config: &config, Alloc: core.GenesisAlloc{address: {Balance: new(big.Int).Mul(big.NewInt(10000), big.NewInt(params.Ether))}}}
+	gspec.MustCommit(db)
+
+	engine := algo.New(config.Algo, db)
+	engine.Authorize(key)
+	chain, err := core.NewBlockChain(db, nil, &config, engine, vm.Config{})
+	if err != nil {
+		t.Fatalf("failed to create chain: %v", err)
+	}
+	defer chain.Stop()
+
+	poolConfig := core.DefaultTxPoolConfig
+	poolConfig.Journal = ""
+	pool := core.NewTxPool(poolConfig, &config, chain)
+	defer pool.Stop()
+
+	var (
+		factory = NewBlockFactory(&testBackend{db: db, chain: chain, txPool: pool}, &config, engine, nil)
+		ledger  = NewChainLedger(chain)
+	)
+	seal := func(round uint64) *types.Block {
+		block, err := factory.AssembleBlock(round, time.Now().Add(time.Second))
+		if err != nil {
+			t.Fatalf("failed to assemble block %d: %v", round, err)
+		}
+		if block, err = engine.Seal(chain, block, make(chan struct{})); err != nil {
+			t.Fatalf("failed to seal block %d: %v", round, err)
+		}
+		return block
+	}
+	// The agreed block is assembled first, but a competing uncertified branch
+	// arrives first and becomes canonical
+	agreed := seal(1)
+
+	signer := types.NewEIP155Signer(config.ChainId)
+	tx, _ := types.SignTx(types.NewTransaction(0, common.Address{0x01}, big.NewInt(1000), params.TxGas, big.NewInt(1), nil), signer, key)
+	if err := pool.AddLocal(tx); err != nil {
+		t.Fatalf("failed to add transaction: %v", err)
+	}
+	for round := uint64(1); round <= 2; round++ {
+		if _, err := chain.InsertChain(types.Blocks{seal(round)}); err != nil {
+			t.Fatalf("failed to insert competing block %d: %v", round, err)
+		}
+	}
+	if _, err := chain.InsertChain(types.Blocks{agreed}); err != nil {
+		t.Fatalf("failed to insert agreed block: %v", err)
+	}
+	if head := chain.CurrentBlock(); head.NumberU64() != 2 || !chain.HasBlock(agreed.Hash(), 1) {
+		t.Fatalf("competing branch not canonical: head %d, agreed known %v", head.NumberU64(), chain.HasBlock(agreed.Hash(), 1))
+	}
+	// Ensuring the agreed block, already stored on a side chain, must reorg to it
+	if err := ledger.EnsureBlock(agreed); err != nil {
+		t.Fatalf("failed to ensure agreed block: %v", err)
+	}
+	if head := chain.CurrentBlock(); head.Hash() != agreed.Hash() {
+		t.Fatalf("head mismatch: have %d [%x], want 1 [%x]", head.NumberU64(), head.Hash(), agreed.Hash())
+	}
+	// Ensuring the canonical block again is a no-op, a new block gets inserted
+	if err := ledger.EnsureBlock(agreed); err != nil {
+		t.Fatalf("failed to re-ensure agreed block: %v", err)
+	}
+	next := seal(2)
+	if err := ledger.EnsureBlock(next); err != nil {
+		t.Fatalf("failed to ensure next block: %v", err)
+	}
+	if head := chain.CurrentBlock(); head.Hash() != next.Hash() {
+		t.Fatalf("head mismatch: have %d [%x], want 2 [%x]", head.NumberU64(), head.Hash(), next.Hash())
+	}
+}
@@ -25,6 +25,7 @@ import (
 
 	"github.com/awesome-chain/Xchain/common"
 	"github.com/awesome-chain/Xchain/consensus"
+	"github.com/awesome-chain/Xchain/consensus/alien"
 	"github.com/awesome-chain/Xchain/core"
 	"github.com/awesome-chain/Xchain/core/state"
 	"github.com/awesome-chain/Xchain/core/types"
@@ -263,6 +264,8 @@ func (self *worker) update() {
 		case ev := <-self.chainSideCh:
 			if self.config.Alien != nil {
 				// uncle block useless in Alien consensus
+			} else if self.config.Algo != nil {
+				// uncle block useless in Algo consensus
 			} else if self.config.Clique != nil {
 				// uncle block useless in Clique consensus
 			} else {
@@ -347,8 +350,12 @@ func (self *worker) receiveBlock(mustCommitNewWork bool, result *Result) bool {
 	self.mux.Post(core.NewMinedBlockEvent{Block: block})
 	var (
 		events []interface{}
-		logs   = work.state.Logs()
+		logs   []*types.Log
 	)
+	// Take the logs from the receipts, which include those of the engine
+	for _, r := range work.receipts {
+		logs = append(logs, r.Logs...)
+	}
 	events = append(events, core.ChainEvent{Block: block, Hash: block.Hash(), Logs: logs})
 	if stat == core.CanonStatTy {
 		events = append(events, core.ChainHeadEvent{Block: block})
@@ -379,6 +386,11 @@ func (self *worker) push(work *Work) {
 	if atomic.LoadInt32(&self.mining) != 1 {
 		return
 	}
+	// Algo blocks are proposed and certified by the agreement service only, the
+	// pending work is kept for the pending state but never sealed
+	if self.config.Algo != nil {
+		return
+	}
 	for agent := range self.agents {
 		atomic.AddInt32(&self.atWork, 1)
 		if ch := agent.Work(); ch != nil {
@@ -513,8 +525,12 @@ func (self *worker) commitNewWork() {
 	self.updateSnapshot()
 	// todo: add params into gttc, to decide if or not send this tx
 	if self.config.Alien != nil && self.config.Alien.PBFTEnable {
-		err = self.sendConfirmTx(parent.Number())
-		if err != nil {
+		if engine, ok := self.engine.(*alien.Alien); ok && self.config.Alien.IsConfirm(header.Number) {
+			// confirm the parent off-chain, the next signers certify it in their headers
+			if err = engine.Confirm(self.chain, parent.Header()); err != nil {
+				log.Info("Fail to confirm the block by coinbase", "err", err)
+			}
+		} else if err = self.sendConfirmTx(parent.Number()); err != nil {
 			log.Info("Fail to Sign the transaction by coinbase", "err", err)
 		}
 	}
@@ -0,0 +1,152 @@
+// Copyright 2018 The gttc Authors
+// This file is part of the gttc library.
+//
+// The gttc library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The gttc library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the gttc library. If not, see <http://www.gnu.org/licenses/>.
+
+// Contains a wrapper for the alien client.
+
+package geth
+
+import (
+	"encoding/json"
+	"math/big"
+
+	"github.com/awesome-chain/Xchain/consensus/alien"
+	"github.com/awesome-chain/Xchain/ethclient/alienclient"
+)
+
+// AlienClient provides access to the alien APIs. The snapshots and lists are
+// returned as JSON data dumps.
+type AlienClient struct {
+	client *alienclient.Client
+}
+
+// NewAlienClient connects a client to the given URL.
+func NewAlienClient(rawurl string) (client *AlienClient, _ error) {
+	rawClient, err := alienclient.Dial(rawurl)
+	return &AlienClient{rawClient}, err
+}
+
+// encodeJSON encodes a result of the alien client into a JSON data dump.
+func encodeJSON(result interface{}, err error) (string, error) {
+	if err != nil {
+		return "", err
+	}
+	data, err := json.Marshal(result)
+	return string(data), err
+}
+
+// alienBlockNumber converts a block number of the mobile API, the latest known
+// block if number is <0.
+func alienBlockNumber(number int64) *big.Int {
+	if number < 0 {
+		return nil
+	}
+	return big.NewInt(number)
+}
+
+// GetSnapshot returns the alien snapshot at the given block. If number is <0,
+// the latest known block is used.
+func (ac *AlienClient) GetSnapshot(ctx *Context, number int64) (snapshot string, _ error) {
+	return encodeJSON(ac.client.Snapshot(ctx.context, alienBlockNumber(number)))
+}
+
+// GetCandidates returns a page of the candidates at the given block, by
+// decreasing tally.
+func (ac *AlienClient) GetCandidates(ctx *Context, number int64, offset int64, limit int64) (candidates string, _ error) {
+	return encodeJSON(ac.client.Candidates(ctx.context, alienBlockNumber(number), uint64(offset), uint64(limit)))
+}
+
+// GetTally returns a page of the stake voted for each candidate at the given
+// block, by decreasing stake.
+func (ac *AlienClient) GetTally(ctx *Context, number int64, offset int64, limit int64) (tally string, _ error) {
+	return encodeJSON(ac.client.Tally(ctx.context, alienBlockNumber(number), uint64(offset), uint64(limit)))
+}
+
+// GetPunished returns a page of the punished signers at the given block, by
+// decreasing punished count.
+func (ac *AlienClient) GetPunished(ctx *Context, number int64, offset int64, limit int64) (punished string, _ error) {
+	return encodeJSON(ac.client.Punished(ctx.context, alienBlockNumber(number), uint64(offset), uint64(limit)))
+}
+
+// GetSideChains returns a page of the side chains at the given block.
+func (ac *AlienClient) GetSideChains(ctx *Context, number int64, offset int64, limit int64) (sideChains string, _ error) {
+	return encodeJSON(ac.client.SideChains(ctx.context, alienBlockNumber(number), uint64(offset), uint64(limit)))
+}
+
+// GetVoteOf returns the vote of the voter at the given block, null if it has
+// none.
+func (ac *AlienClient) GetVoteOf(ctx *Context, voter *Address, number int64) (vote string, _ error) {
+	return encodeJSON(ac.client.VoteOf(ctx.context, voter.address, alienBlockNumber(number)))
+}
+
+// GetProposal returns the proposal with the given hash at the given block, null
+// if there is none.
+func (ac *AlienClient) GetProposal(ctx *Context, hash *Hash, number int64) (proposal string, _ error) {
+	return encodeJSON(ac.client.Proposal(ctx.context, hash.hash, alienBlockNumber(number)))
+}
+
+// GetSignerQueue returns the signer queue of the loop loopOffset loops before
+// the one of the given block.
+func (ac *AlienClient) GetSignerQueue(ctx *Context, loopOffset int64, number int64) (queue string, _ error) {
+	return encodeJSON(ac.client.SignerQueue(ctx.context, uint64(loopOffset), alienBlockNumber(number)))
+}
+
+// GetRewards returns the rewards credited to the address by the blocks from
+// fromBlock to toBlock. If toBlock is <0, the latest known block is used.
+func (ac *AlienClient) GetRewards(ctx *Context, address *Address, fromBlock int64, toBlock int64) (rewards string, _ error) {
+	return encodeJSON(ac.client.Rewards(ctx.context, address.address, alienBlockNumber(fromBlock), alienBlockNumber(toBlock)))
+}
+
+// Vote sends a transaction voting for the candidate.
+func (ac *AlienClient) Vote(opts *TransactOpts, candidate *Address) (tx *Transaction, _ error) {
+	rawTx, err := ac.client.Vote(&opts.opts, candidate.address)
+	if err != nil {
+		return nil, err
+	}
+	return &Transaction{rawTx}, nil
+}
+
+// Propose sends a transaction making the proposal given as the JSON dump of an
+// alien proposal payload.
+func (ac *AlienClient) Propose(opts *TransactOpts, proposal string) (tx *Transaction, _ error) {
+	payload := new(alien.ProposalPayload)
+	if err := json.Unmarshal([]byte(proposal), payload); err != nil {
+		return nil, err
+	}
+	rawTx, err := ac.client.Propose(&opts.opts, payload)
+	if err != nil {
+		return nil, err
+	}
+	return &Transaction{rawTx}, nil
+}
+
+// Declare sends a transaction declaring the decision of a signer on a proposal.
+func (ac *AlienClient) Declare(opts *TransactOpts, proposal *Hash, decision bool) (tx *Transaction, _ error) {
+	rawTx, err := ac.client.Declare(&opts.opts, proposal.hash, decision)
+	if err != nil {
+		return nil, err
+	}
+	return &Transaction{rawTx}, nil
+}
+
+// SetSideChainCoinbase sends a transaction setting the coinbase of a signer on
+// a side chain, the value of opts must be at least 5 TTC.
+func (ac *AlienClient) SetSideChainCoinbase(opts *TransactOpts, scHash *Hash, coinbase *Address) (tx *Transaction, _ error) {
+	rawTx, err := ac.client.SetSideChainCoinbase(&opts.opts, scHash.hash, coinbase.address)
+	if err != nil {
+		return nil, err
+	}
+	return &Transaction{rawTx}, nil
+}
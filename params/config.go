@@ -168,23 +168,30 @@ var (
 	//
 	// This configuration is intentionally not using keyed fields to force anyone
 	// adding flags to the config to also have to set these fields.
-	AllEthashProtocolChanges = &ChainConfig{big.NewInt(1337), big.NewInt(0), big.NewInt(0), common.Hash{}, big.NewInt(0), big.NewInt(0), big.NewInt(0), nil, new(EthashConfig), nil, nil}
+	AllEthashProtocolChanges = &ChainConfig{big.NewInt(1337), big.NewInt(0), big.NewInt(0), common.Hash{}, big.NewInt(0), big.NewInt(0), big.NewInt(0), nil, big.NewInt(0), new(EthashConfig), nil, nil, nil}
 
 	// AllCliqueProtocolChanges contains every protocol change (EIPs) introduced
 	// and accepted by the Ethereum core developers into the Clique consensus.
 	//
 	// This configuration is intentionally not using keyed fields to force anyone
 	// adding flags to the config to also have to set these fields.
-	AllCliqueProtocolChanges = &ChainConfig{big.NewInt(1337), big.NewInt(0), big.NewInt(0), common.Hash{}, big.NewInt(0), big.NewInt(0), big.NewInt(0), nil, nil, &CliqueConfig{Period: 0, Epoch: 30000}, nil}
+	AllCliqueProtocolChanges = &ChainConfig{big.NewInt(1337), big.NewInt(0), big.NewInt(0), common.Hash{}, big.NewInt(0), big.NewInt(0), big.NewInt(0), nil, big.NewInt(0), nil, &CliqueConfig{Period: 0, Epoch: 30000}, nil, nil}
 
 	// AllAlienProtocolChanges contains every protocol change (EIPs) introduced
 	// and accepted by the Ethereum core developers into the Alien consensus.
 	//
 	// This configuration is intentionally not using keyed fields to force anyone
 	// adding flags to the config to also have to set these fields.
-	AllAlienProtocolChanges = &ChainConfig{big.NewInt(1337), big.NewInt(0), big.NewInt(0), common.Hash{}, big.NewInt(0), big.NewInt(0), big.NewInt(0), nil, nil, nil, &AlienConfig{Period: 3, Epoch: 30000, MaxSignerCount: 21, MinVoterBalance: new(big.Int).Mul(big.NewInt(10000), big.NewInt(1000000000000000000)), GenesisTimestamp: 0, SelfVoteSigners: []common.UnprefixedAddress{}}}
+	AllAlienProtocolChanges = &ChainConfig{big.NewInt(1337), big.NewInt(0), big.NewInt(0), common.Hash{}, big.NewInt(0), big.NewInt(0), big.NewInt(0), nil, big.NewInt(0), nil, nil, &AlienConfig{Period: 3, Epoch: 30000, MaxSignerCount: 21, MinVoterBalance: new(big.Int).Mul(big.NewInt(10000), big.NewInt(1000000000000000000)), GenesisTimestamp: 0, SelfVoteSigners: []common.UnprefixedAddress{}, CustomTxV2Block: big.NewInt(0)}, nil}
 
-	TestChainConfig = &ChainConfig{big.NewInt(1), big.NewInt(0), big.NewInt(0), common.Hash{}, big.NewInt(0), big.NewInt(0), big.NewInt(0), nil, new(EthashConfig), nil, nil}
+	// AllAlgoProtocolChanges contains every protocol change (EIPs) introduced
+	// and accepted by the Ethereum core developers into the Algorand consensus.
+	//
+	// This configuration is intentionally not using keyed fields to force anyone
+	// adding flags to the config to also have to set these fields.
+	AllAlgoProtocolChanges = &ChainConfig{big.NewInt(1337), big.NewInt(0), big.NewInt(0), common.Hash{}, big.NewInt(0), big.NewInt(0), big.NewInt(0), nil, big.NewInt(0), nil, nil, nil, &AlgoConfig{Period: 1}}
+
+	TestChainConfig = &ChainConfig{big.NewInt(1), big.NewInt(0), big.NewInt(0), common.Hash{}, big.NewInt(0), big.NewInt(0), big.NewInt(0), nil, big.NewInt(0), new(EthashConfig), nil, nil, nil}
 	TestRules       = TestChainConfig.Rules(new(big.Int))
 )
 
@@ -208,10 +215,13 @@ type ChainConfig struct {
 	ByzantiumBlock      *big.Int `json:"byzantiumBlock,omitempty"`      // Byzantium switch block (nil = no fork, 0 = already on byzantium)
 	ConstantinopleBlock *big.Int `json:"constantinopleBlock,omitempty"` // Constantinople switch block (nil = no fork, 0 = already activated)
 
+	VRFBlock *big.Int `json:"vrfBlock,omitempty"` // VRF precompile switch block (nil = no fork, 0 = already activated), must not precede Byzantium
+
 	// Various consensus engines
 	Ethash *EthashConfig `json:"ethash,omitempty"`
 	Clique *CliqueConfig `json:"clique,omitempty"`
 	Alien  *AlienConfig  `json:"alien,omitempty"`
+	Algo   *AlgoConfig   `json:"algo,omitempty"`
 }
 
 // EthashConfig is the consensus engine configs for proof-of-work based sealing.
@@ -254,9 +264,14 @@ type AlienConfig struct {
 	MCRPCClient      *rpc.Client                // Main chain rpc client for side chain
 	PBFTEnable       bool                       `json:"pbft"` //
 
-	TrantorBlock  *big.Int          `json:"trantorBlock,omitempty"`  // Trantor switch block (nil = no fork)
-	TerminusBlock *big.Int          `json:"terminusBlock,omitempty"` // Terminus switch block (nil = no fork)
-	LightConfig   *AlienLightConfig `json:"lightConfig,omitempty"`
+	TrantorBlock    *big.Int          `json:"trantorBlock,omitempty"`    // Trantor switch block (nil = no fork)
+	TerminusBlock   *big.Int          `json:"terminusBlock,omitempty"`   // Terminus switch block (nil = no fork)
+	CustomTxV2Block *big.Int          `json:"customTxV2Block,omitempty"` // Typed custom transaction switch block (nil = no fork)
+	StakingBlock    *big.Int          `json:"stakingBlock,omitempty"`    // Locked staking switch block (nil = no fork, needs typed custom transactions)
+	UnbondingPeriod uint64            `json:"unbondingPeriod,omitempty"` // Number of blocks unbonded stake stays locked (0 = epoch)
+	SlashingBlock   *big.Int          `json:"slashingBlock,omitempty"`   // Double sign slashing switch block (nil = no fork, needs locked staking)
+	ConfirmBlock    *big.Int          `json:"confirmBlock,omitempty"`    // Off-chain PBFT confirmation switch block (nil = no fork, needs slashing)
+	LightConfig     *AlienLightConfig `json:"lightConfig,omitempty"`
 }
 
 // String implements the stringer interface, returning the consensus engine details.
@@ -274,6 +289,44 @@ func (a *AlienConfig) IsTerminus(num *big.Int) bool {
 	return isForked(a.TerminusBlock, num)
 }
 
+// IsCustomTxV2 returns whether num is either equal to the typed custom
+// transaction switch block or greater.
+func (a *AlienConfig) IsCustomTxV2(num *big.Int) bool {
+	return isForked(a.CustomTxV2Block, num)
+}
+
+// IsStaking returns whether num is either equal to the locked staking block or greater.
+func (a *AlienConfig) IsStaking(num *big.Int) bool {
+	return isForked(a.StakingBlock, num)
+}
+
+// IsSlashing returns whether num is either equal to the double sign slashing block or greater.
+func (a *AlienConfig) IsSlashing(num *big.Int) bool {
+	return isForked(a.SlashingBlock, num)
+}
+
+// IsConfirm returns whether num is either equal to the off-chain confirmation block or greater.
+func (a *AlienConfig) IsConfirm(num *big.Int) bool {
+	return isForked(a.ConfirmBlock, num)
+}
+
+// AlgoConfig is the consensus engine configs for pure-proof-of-stake based sealing.
+type AlgoConfig struct {
+	Period       uint64                     `json:"period"`             // Number of seconds between blocks to enforce
+	Participants []common.UnprefixedAddress `json:"participants"`       // Stake holders taking part in the sortition, make sure the accounts are pre-funded
+	Protocol     string                     `json:"protocol,omitempty"` // Version of the agreement protocol the chain starts with
+
+	// CommitteeScale divides the committee sizes and vote thresholds of the
+	// agreement protocol, for networks holding less stake than the committees
+	// need (0 = full size committees)
+	CommitteeScale uint64 `json:"committeeScale,omitempty"`
+}
+
+// String implements the stringer interface, returning the consensus engine details.
+func (c *AlgoConfig) String() string {
+	return "algo"
+}
+
 // String implements the fmt.Stringer interface.
 func (c *ChainConfig) String() string {
 	var engine interface{}
@@ -284,10 +337,12 @@ func (c *ChainConfig) String() string {
 		engine = c.Clique
 	case c.Alien != nil:
 		engine = c.Alien
+	case c.Algo != nil:
+		engine = c.Algo
 	default:
 		engine = "unknown"
 	}
-	return fmt.Sprintf("{ChainID: %v Homestead: %v EIP150: %v EIP155: %v EIP158: %v Byzantium: %v Constantinople: %v Engine: %v}",
+	return fmt.Sprintf("{ChainID: %v Homestead: %v EIP150: %v EIP155: %v EIP158: %v Byzantium: %v Constantinople: %v VRF: %v Engine: %v}",
 		c.ChainId,
 		c.HomesteadBlock,
 		c.EIP150Block,
@@ -295,6 +350,7 @@ func (c *ChainConfig) String() string {
 		c.EIP158Block,
 		c.ByzantiumBlock,
 		c.ConstantinopleBlock,
+		c.VRFBlock,
 		engine,
 	)
 }
@@ -324,6 +380,11 @@ func (c *ChainConfig) IsConstantinople(num *big.Int) bool {
 	return isForked(c.ConstantinopleBlock, num)
 }
 
+// IsVRF returns whether num is either equal to the VRF precompile block or greater.
+func (c *ChainConfig) IsVRF(num *big.Int) bool {
+	return isForked(c.VRFBlock, num)
+}
+
 // GasTable returns the gas table corresponding to the current phase (homestead or homestead reprice).
 //
 // The returned GasTable's fields shouldn't, under any circumstances, be changed.
@@ -359,6 +420,42 @@ func (c *ChainConfig) CheckCompatible(newcfg *ChainConfig, height uint64) *Confi
 	return lasterr
 }
 
+// CheckConfigForkOrder checks that the forks which build upon each other are
+// not scheduled before the forks they need.
+func (c *ChainConfig) CheckConfigForkOrder() error {
+	if c.Alien != nil {
+		return c.Alien.checkForkOrder()
+	}
+	return nil
+}
+
+// checkForkOrder checks that the alien forks are scheduled in the order they
+// build upon each other: typed custom transactions, locked staking, slashing
+// and off-chain confirmation. The header extra layout of a block is picked by
+// the first fork not active yet, so a fork scheduled before the ones it needs
+// would silently drop its header fields.
+func (a *AlienConfig) checkForkOrder() error {
+	forks := []struct {
+		name  string
+		block *big.Int
+	}{
+		{"customTxV2Block", a.CustomTxV2Block},
+		{"stakingBlock", a.StakingBlock},
+		{"slashingBlock", a.SlashingBlock},
+		{"confirmBlock", a.ConfirmBlock},
+	}
+	for i := 1; i < len(forks); i++ {
+		prev, cur := forks[i-1], forks[i]
+		if cur.block == nil {
+			continue
+		}
+		if prev.block == nil || prev.block.Cmp(cur.block) > 0 {
+			return fmt.Errorf("unsupported alien fork ordering: %s %v enabled before %s %v", cur.name, cur.block, prev.name, prev.block)
+		}
+	}
+	return nil
+}
+
 func (c *ChainConfig) checkCompatible(newcfg *ChainConfig, head *big.Int) *ConfigCompatError {
 	if isForkIncompatible(c.HomesteadBlock, newcfg.HomesteadBlock, head) {
 		return newCompatError("Homestead fork block", c.HomesteadBlock, newcfg.HomesteadBlock)
@@ -381,6 +478,9 @@ func (c *ChainConfig) checkCompatible(newcfg *ChainConfig, head *big.Int) *Confi
 	if isForkIncompatible(c.ConstantinopleBlock, newcfg.ConstantinopleBlock, head) {
 		return newCompatError("Constantinople fork block", c.ConstantinopleBlock, newcfg.ConstantinopleBlock)
 	}
+	if isForkIncompatible(c.VRFBlock, newcfg.VRFBlock, head) {
+		return newCompatError("VRF fork block", c.VRFBlock, newcfg.VRFBlock)
+	}
 	return nil
 }
 
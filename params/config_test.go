@@ -79,3 +79,29 @@ func TestCheckCompatible(t *testing.T) {
 		}
 	}
 }
+
+func TestCheckConfigForkOrder(t *testing.T) {
+	alien := func(customTxV2, staking, slashing, confirm *big.Int) *ChainConfig {
+		return &ChainConfig{Alien: &AlienConfig{CustomTxV2Block: customTxV2, StakingBlock: staking, SlashingBlock: slashing, ConfirmBlock: confirm}}
+	}
+	tests := []struct {
+		config *ChainConfig
+		valid  bool
+	}{
+		{AllEthashProtocolChanges, true},
+		{AllAlienProtocolChanges, true},
+		{alien(nil, nil, nil, nil), true},
+		{alien(big.NewInt(0), big.NewInt(10), big.NewInt(10), big.NewInt(20)), true},
+		{alien(big.NewInt(0), big.NewInt(10), nil, nil), true},
+		{alien(nil, big.NewInt(10), nil, nil), false},
+		{alien(big.NewInt(0), nil, nil, big.NewInt(20)), false},
+		{alien(big.NewInt(0), big.NewInt(10), nil, big.NewInt(20)), false},
+		{alien(big.NewInt(0), big.NewInt(10), big.NewInt(30), big.NewInt(20)), false},
+		{alien(big.NewInt(20), big.NewInt(10), big.NewInt(30), big.NewInt(40)), false},
+	}
+	for i, tt := range tests {
+		if err := tt.config.CheckConfigForkOrder(); (err == nil) != tt.valid {
+			t.Errorf("test %d: validity mismatch: have %v, want valid %v", i, err, tt.valid)
+		}
+	}
+}
@@ -77,6 +77,8 @@ const (
 	Bn256ScalarMulGas       uint64 = 40000  // Gas needed for an elliptic curve scalar multiplication
 	Bn256PairingBaseGas     uint64 = 100000 // Base price for an elliptic curve pairing check
 	Bn256PairingPerPointGas uint64 = 80000  // Per-point price for an elliptic curve pairing check
+	VRFVerifyBaseGas        uint64 = 15000  // Base price for a VRF proof verification, about five ecrecovers
+	VRFVerifyPerWordGas     uint64 = 12     // Per-word price for hashing the input of a VRF proof verification
 )
 
 var (